@@ -0,0 +1,685 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// allocation is a graph allocated on a device together with its input and
+// output fifos, one for each of the graph's tensors.  The tensors of a
+// request are concatenated in its input and output, in order.
+//
+// Inferences are pipelined: submit writes a tensor to the input fifo and
+// queues its inference without waiting, while a separate goroutine reads the
+// results from the output fifo in order.  Up to the depth of the input fifo
+// may be in flight at once, so the stick can work on one frame while the
+// host prepares the next.
+type allocation struct {
+	device                      *Device
+	graph                       graphHandle
+	inputs, outputs             []tensorFifo
+	inputHandles, outputHandles []fifoHandle
+
+	// inputDesc and outputDesc are those of the first input and output
+	// tensors, which Process and the decoders use
+	inputDesc, outputDesc TensorDescriptor
+
+	// total number of elements in the input and output tensors, and the
+	// half precision buffers used with FP16 fifos
+	inputLen, outputLen   int
+	inputType, outputType DataType
+	input16, output16     []uint16
+
+	retry   *RetryPolicy
+	profile bool
+	stats   *stats
+	timings chan<- Timing
+
+	mu       sync.RWMutex // held for writing to close
+	closed   bool
+	writeMu  sync.Mutex    // held while writing to the input fifo
+	slots    *lanes        // one per request in flight
+	inflight chan *request // requests in the order they were written
+	drained  chan struct{} // closed when drain returns
+
+	// with a timeout, the watchdog timer is armed while the output of
+	// watching is read, into readBuf, and fails it if the stick hangs.
+	// hung is closed once it has, and hungErr is the error of the requests
+	// failed
+	timeout  time.Duration
+	timer    *time.Timer
+	watchMu  sync.Mutex
+	watching *request
+	readBuf  []float32
+	hung     chan struct{}
+	hungErr  error
+}
+
+// tensorFifo is the fifo of one of a graph's input or output tensors.
+type tensorFifo struct {
+	handle fifoHandle
+	desc   TensorDescriptor
+	size   int // of an element, in bytes
+
+	// offset and length of the tensor's values in the input or output of a
+	// request
+	offset, length int
+}
+
+// request is a single inference in flight.
+type request struct {
+	input  []float32
+	output []float32
+	err    error
+
+	// id is passed through the fifos with the request, and checked when its
+	// output is read.  source is the Multiplexer source the frame was read
+	// from, user is the caller's value for the request, and img the frame
+	// input was made from, if it is still valid once done is called.  readAt
+	// is when the frame was read.
+	id     uint64
+	source string
+	user   interface{}
+	img    image.Image
+	readAt time.Time
+
+	// roi is the rectangle of the frame, of the given bounds, the graph
+	// saw, if it was cropped to its region of interest or letterboxed
+	roi, bounds image.Rectangle
+
+	written time.Time
+	times   stageTimes
+	timing  Timing
+
+	// warmup requests are left out of the graph's Stats and Timings
+	warmup bool
+
+	// priority is the lane the request waits in for a slot, and ready is
+	// where it is handed one
+	priority Priority
+	ready    chan struct{}
+
+	// outputs are the output tensors of the allocation the request was
+	// written to, for splitting output
+	outputs []tensorFifo
+
+	// done is called from the drain goroutine once output has been read,
+	// in the order the requests were submitted.
+	done func(*request)
+}
+
+var (
+	errClosed  = fmt.Errorf("graph was closed")
+	errSkipped = fmt.Errorf("fifo has elements, skipping this frame")
+
+	// errReloaded is returned by run once Reload has replaced the
+	// allocation it was using.
+	errReloaded = fmt.Errorf("graph was reloaded")
+)
+
+func (f *Graph) allocate(device *Device) (*allocation, error) {
+	a := &allocation{
+		device:     device,
+		inputType:  f.InputFifo.DataType,
+		outputType: f.OutputFifo.DataType,
+		retry:      f.Retry,
+		profile:    f.Profile,
+		stats:      &f.stats,
+		timings:    f.Timings,
+	}
+
+	b, err := ioutil.ReadFile(f.GraphFile)
+	if err != nil {
+		return nil, err
+	}
+
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if device.handle == nil {
+		return nil, fmt.Errorf("device %d is closed", device.Index)
+	}
+
+	var ret Status
+	if a.graph, ret = api.graphCreate(f.graphName()); ret != OK {
+		return nil, fmt.Errorf("could not create graph, %w", errorFor(ret))
+	}
+	if f.Executors > 1 && device.Hardware != MyriadX {
+		api.graphDestroy(a.graph)
+		return nil, fmt.Errorf("device %d is a %v, only a Myriad X runs a graph on %d executors: %w", device.Index, device.Hardware, f.Executors, ErrUnsupportedFeature)
+	}
+	if f.Executors > 0 {
+		if ret := api.graphSetIntOption(a.graph, optExecutors, f.Executors); ret != OK {
+			api.graphDestroy(a.graph)
+			return nil, fmt.Errorf("error setting executors of graph: %w", errorFor(ret))
+		}
+	}
+
+	input, output, ret := api.graphAllocateWithFifos(device.handle, a.graph, b, f.InputFifo, f.OutputFifo)
+	if ret != OK {
+		err := a.errorForLocked(ret)
+		api.graphDestroy(a.graph)
+		return nil, fmt.Errorf("error allocating graph: %w", err)
+	}
+	a.inputs = []tensorFifo{{handle: input}}
+	a.outputs = []tensorFifo{{handle: output}}
+
+	inputDescs, ret := api.graphTensorDescriptors(a.graph, true)
+	if ret != OK {
+		a.destroyLocked()
+		return nil, fmt.Errorf("error getting input tensor descriptor: %w", errorFor(ret))
+	}
+	outputDescs, ret := api.graphTensorDescriptors(a.graph, false)
+	if ret != OK {
+		a.destroyLocked()
+		return nil, fmt.Errorf("error getting output tensor descriptor: %w", errorFor(ret))
+	}
+
+	// ncGraphAllocateWithFifos only makes fifos for the first input and
+	// output, so those of any other tensors are made here
+	if a.inputs, err = a.createFifos(a.inputs, inputDescs, true, f.InputFifo); err != nil {
+		a.destroyLocked()
+		return nil, err
+	}
+	if a.outputs, err = a.createFifos(a.outputs, outputDescs, false, f.OutputFifo); err != nil {
+		a.destroyLocked()
+		return nil, err
+	}
+
+	a.inputDesc, a.outputDesc = a.inputs[0].desc, a.outputs[0].desc
+	a.inputLen, a.inputHandles = a.layout(a.inputs, a.inputType)
+	a.outputLen, a.outputHandles = a.layout(a.outputs, a.outputType)
+	if a.inputType == FP16 {
+		a.input16 = make([]uint16, a.inputLen)
+	}
+	if a.outputType == FP16 {
+		a.output16 = make([]uint16, a.outputLen)
+	}
+
+	f.logf("fifo input/output sizes: %d/%d", a.inputs[0].size, a.outputs[0].size)
+	f.logf("input/output tensors: %v/%v", inputDescs, outputDescs)
+
+	if a.outputLen > len(f.Names) {
+		f.logf("outputsize %d greater than names %d", a.outputLen, len(f.Names))
+	}
+
+	depth := f.InputFifo.depth()
+	a.slots = newLanes(depth)
+	a.inflight = make(chan *request, depth)
+	a.drained = make(chan struct{})
+	a.hung = make(chan struct{})
+
+	if f.InferenceTimeout > 0 {
+		a.timeout = f.InferenceTimeout
+		a.readBuf = make([]float32, a.outputLen)
+		a.timer = time.AfterFunc(a.timeout, a.expire)
+		a.timer.Stop()
+	}
+
+	go a.drain()
+
+	return a, nil
+}
+
+// maxGraphName is the longest graph name the NCAPI accepts, in bytes.
+const maxGraphName = 27
+
+// graphName returns the name the graph is created with.
+func (f *Graph) graphName() string {
+	name := f.Name
+	if name == "" {
+		base := filepath.Base(f.GraphFile)
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if len(name) > maxGraphName {
+		name = name[:maxGraphName]
+	}
+	return name
+}
+
+// imageInput returns an error unless the graph takes a single RGB or
+// grayscale image as input, as Process and InferImage require.
+func (a *allocation) imageInput() error {
+	if len(a.inputs) > 1 {
+		return fmt.Errorf("graph has %d input tensors, images can only be run through graphs with one", len(a.inputs))
+	} else if a.inputDesc.C != 3 && a.inputDesc.C != 1 {
+		return fmt.Errorf("graph expects %d channels, only RGB and grayscale input are supported", a.inputDesc.C)
+	}
+	return nil
+}
+
+// descriptors returns the shapes of the tensors of fifos.
+func (a *allocation) descriptors(fifos []tensorFifo) []TensorDescriptor {
+	descs := make([]TensorDescriptor, len(fifos))
+	for i, t := range fifos {
+		descs[i] = t.desc
+	}
+	return descs
+}
+
+// splitTensors returns the tensors of a request's output, which share its memory.
+func splitTensors(outputs []tensorFifo, output []float32) [][]float32 {
+	tensors := make([][]float32, len(outputs))
+	for i, t := range outputs {
+		tensors[i] = output[t.offset : t.offset+t.length : t.offset+t.length]
+	}
+	return tensors
+}
+
+// createFifos describes the tensors of descs with fifos, the first of which
+// is already made, making the fifos of the others on the device.
+func (a *allocation) createFifos(fifos []tensorFifo, descs []TensorDescriptor, input bool, config FifoConfig) ([]tensorFifo, error) {
+	for i, desc := range descs {
+		if i == 0 {
+			fifos[0].desc = desc
+			continue
+		}
+
+		handle, ret := api.fifoCreate(fmt.Sprintf("tensor%d", i), input)
+		if ret != OK {
+			return fifos, fmt.Errorf("could not create fifo for tensor %d, %w", i, errorFor(ret))
+		}
+		if ret := api.fifoAllocate(handle, a.device.handle, desc, config); ret != OK {
+			api.fifoDestroy(handle)
+			return fifos, fmt.Errorf("error allocating fifo for tensor %d: %w", i, errorFor(ret))
+		}
+		fifos = append(fifos, tensorFifo{handle: handle, desc: desc})
+	}
+
+	return fifos, nil
+}
+
+// layout sets the size and place of each of fifos in a request, returning
+// the request's total number of values and the fifos' handles.
+func (a *allocation) layout(fifos []tensorFifo, t DataType) (int, []fifoHandle) {
+	n := 0
+	handles := make([]fifoHandle, len(fifos))
+	for i := range fifos {
+		fifos[i].size, _ = api.fifoElementSize(fifos[i].handle)
+		fifos[i].offset, fifos[i].length = n, fifos[i].size/t.size()
+		n += fifos[i].length
+		handles[i] = fifos[i].handle
+	}
+	return n, handles
+}
+
+// allocateWarm allocates the graph on device and then runs WarmupFrames
+// inferences through it.
+func (f *Graph) allocateWarm(device *Device) (*allocation, error) {
+	a, err := f.allocate(device)
+	if err != nil || f.WarmupFrames <= 0 {
+		return a, err
+	}
+
+	if err := a.warmup(context.Background(), f.WarmupFrames); err != nil {
+		a.close()
+		return nil, fmt.Errorf("error warming up graph: %w", err)
+	}
+
+	return a, nil
+}
+
+// inputFillLevel returns the number of elements waiting in the input fifo.
+func (a *allocation) inputFillLevel() (int, error) {
+	level, ret := api.fifoWriteFillLevel(a.inputs[0].handle)
+	if ret != OK {
+		return 0, fmt.Errorf("error getting fifo fill level %w", a.errorFor(ret))
+	}
+
+	return level, nil
+}
+
+// outputFillLevel returns the number of outputs waiting to be read from the
+// output fifo.
+func (a *allocation) outputFillLevel() (int, error) {
+	level, ret := api.fifoReadFillLevel(a.outputs[0].handle)
+	if ret != OK {
+		return 0, fmt.Errorf("error getting fifo fill level %w", a.errorFor(ret))
+	}
+
+	return level, nil
+}
+
+// submit writes r.input to the input fifo and queues its inference, waiting
+// for a free slot if the pipeline is full.
+func (a *allocation) submit(ctx context.Context, r *request) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return errClosed
+	}
+
+	// the watchdog frees the slots of a hung stick, so that acquire would
+	// succeed
+	if a.stalled() {
+		return a.hungErr
+	} else if !a.slots.acquire(r, ctx.Done(), a.hung) {
+		if a.stalled() {
+			return a.hungErr
+		}
+		return ctx.Err()
+	}
+	return a.write(r)
+}
+
+// full reports whether every slot in the pipeline is in use.
+func (a *allocation) full() bool {
+	return a.slots.full()
+}
+
+// trySubmit is like submit, but returns errSkipped instead of waiting if the
+// pipeline is full.
+func (a *allocation) trySubmit(r *request) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return errClosed
+	}
+
+	if a.stalled() {
+		return a.hungErr
+	} else if !a.slots.tryAcquire() {
+		return errSkipped
+	}
+	return a.write(r)
+}
+
+// write sends r to the stick; the caller must hold a slot and a.mu.
+func (a *allocation) write(r *request) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	if a.inputType == FP16 {
+		toHalf(a.input16, r.input)
+	}
+
+	var t *tensorFifo
+	writeElem := func() Status {
+		in := unsafe.Pointer(&r.input[t.offset])
+		if a.inputType == FP16 {
+			in = unsafe.Pointer(&a.input16[t.offset])
+		}
+		return api.fifoWrite(t.handle, in, t.size, r.id)
+	}
+	queueInference := func() Status {
+		return api.queueInference(a.graph, a.inputHandles, a.outputHandles)
+	}
+
+	r.written = time.Now()
+	r.outputs = a.outputs
+
+	for i := range a.inputs {
+		t = &a.inputs[i]
+		if ret := a.retry.call(writeElem); ret != OK {
+			a.slots.release()
+			return fmt.Errorf("error writing fifo, %w", a.errorFor(ret))
+		}
+	}
+	if ret := a.retry.call(queueInference); ret != OK {
+		a.slots.release()
+		return fmt.Errorf("error queuing inference, %w", a.errorFor(ret))
+	}
+	r.times.queued = time.Now()
+
+	a.inflight <- r
+	return nil
+}
+
+// drain reads the output of each request in flight and completes it.
+func (a *allocation) drain() {
+	defer close(a.drained)
+
+	for r := range a.inflight {
+		// with the watchdog, r is not touched until it is known not to have
+		// been failed, since it may have been reused by then
+		id, output := r.id, r.output
+		if a.timeout > 0 {
+			output = a.readBuf
+			a.watch(r)
+		}
+
+		readStart := time.Now()
+		inferred, err := a.read(id, output)
+		readDone := time.Now()
+
+		if a.timeout > 0 {
+			if !a.timer.Stop() {
+				// the watchdog has failed r and every request after it
+				return
+			}
+			copy(r.output, output)
+		}
+
+		r.err = err
+		r.times.readStart, r.times.inferred, r.times.readDone = readStart, inferred, readDone
+		r.timing = Timing{Latency: readDone.Sub(r.written)}
+
+		if r.err == nil && a.profile {
+			r.timing.Layers, r.timing.Device, r.err = a.timeTaken()
+		}
+		a.finish(r)
+	}
+}
+
+// finish frees r's slot, records its timing, and completes it.
+func (a *allocation) finish(r *request) {
+	a.slots.release()
+
+	if r.err == nil && !r.warmup {
+		a.stats.record(r.timing)
+		if a.timings != nil {
+			a.timings <- r.timing
+		}
+	}
+	r.done(r)
+}
+
+// watch arms the watchdog for the output of r.
+func (a *allocation) watch(r *request) {
+	a.watchMu.Lock()
+	a.watching = r
+	a.watchMu.Unlock()
+
+	a.timer.Reset(a.timeout)
+}
+
+// expire is called by the watchdog when the stick has not returned an
+// output within the timeout.  drain is stuck reading it, so the request
+// being read, and every one after it, is failed here instead.
+func (a *allocation) expire() {
+	a.watchMu.Lock()
+	r := a.watching
+	a.watchMu.Unlock()
+
+	a.hungErr = fmt.Errorf("no output from the stick in %v, %w", a.timeout, ErrTimeout)
+	close(a.hung)
+	a.stats.fail(ErrTimeout)
+
+	r.err = a.hungErr
+	a.finish(r)
+	for r := range a.inflight {
+		r.err = a.hungErr
+		a.finish(r)
+	}
+}
+
+// stalled reports whether the watchdog has given up on the stick.
+func (a *allocation) stalled() bool {
+	select {
+	case <-a.hung:
+		return true
+	default:
+		return false
+	}
+}
+
+// timeTaken returns the time the stick spent in each layer of the graph for
+// the last inference, and their total.
+func (a *allocation) timeTaken() ([]time.Duration, time.Duration, error) {
+	ms, ret := api.graphTimeTaken(a.graph)
+	if ret != OK {
+		return nil, 0, fmt.Errorf("error getting time taken: %w", a.errorFor(ret))
+	}
+
+	layers := make([]time.Duration, len(ms))
+	var total time.Duration
+	for i, t := range ms {
+		layers[i] = time.Duration(float64(t) * float64(time.Millisecond))
+		total += layers[i]
+	}
+
+	return layers, total, nil
+}
+
+// read reads the output of the request with the given id into output,
+// returning when the first of its tensors arrived.
+func (a *allocation) read(id uint64, output []float32) (inferred time.Time, _ error) {
+	var t *tensorFifo
+	var readID uint64
+	readElem := func() Status {
+		out := unsafe.Pointer(&output[t.offset])
+		if a.outputType == FP16 {
+			out = unsafe.Pointer(&a.output16[t.offset])
+		}
+
+		var ret Status
+		readID, ret = api.fifoRead(t.handle, out, t.size)
+		return ret
+	}
+
+	for i := range a.outputs {
+		t = &a.outputs[i]
+
+		ret := a.retry.call(readElem)
+		if i == 0 {
+			inferred = time.Now()
+		}
+		if ret != OK {
+			return inferred, fmt.Errorf("error reading output of inference, %w", a.errorFor(ret))
+		} else if readID != id {
+			return inferred, fmt.Errorf("output fifo returned the output of request %d, expected %d", readID, id)
+		}
+	}
+
+	if a.outputType == FP16 {
+		fromHalf(output, a.output16)
+	}
+
+	return inferred, nil
+}
+
+// do runs the inference of r, blocking until its output has been read or ctx
+// is done; r.done is replaced.  If ctx is done first the inference still
+// completes, and its output is written, in the background.
+func (a *allocation) do(ctx context.Context, r *request) error {
+	done := make(chan struct{})
+
+	r.done = func(*request) { close(done) }
+	if err := a.submit(ctx, r); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// warmup runs n inferences of a zero tensor, keeping the pipeline full, and
+// returns the first error.
+func (a *allocation) warmup(ctx context.Context, n int) error {
+	input := make([]float32, a.inputLen)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var first error
+	fail := func(err error) {
+		mu.Lock()
+		if first == nil {
+			first = err
+		}
+		mu.Unlock()
+	}
+
+	for i := 0; i < n; i++ {
+		r := &request{input: input, output: make([]float32, a.outputLen), warmup: true}
+		r.done = func(r *request) {
+			if r.err != nil {
+				fail(r.err)
+			}
+			wg.Done()
+		}
+
+		wg.Add(1)
+		if err := a.submit(ctx, r); err != nil {
+			wg.Done()
+			fail(err)
+			break
+		}
+	}
+	wg.Wait()
+
+	return first
+}
+
+// close stops accepting requests, waits for the requests in flight to
+// complete, and then destroys the fifos and graph.
+func (a *allocation) close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.inflight)
+	a.mu.Unlock()
+
+	select {
+	case <-a.drained:
+		return a.destroy()
+	case <-a.hung:
+		// drain may still be blocked in the NCAPI using the fifos, so they
+		// are left to closing the device, which resets the stick
+		return nil
+	}
+}
+
+// destroy destroys the fifos and then the graph, returning the first error.
+func (a *allocation) destroy() error {
+	a.device.mu.Lock()
+	defer a.device.mu.Unlock()
+
+	return a.destroyLocked()
+}
+
+// destroyLocked is destroy for callers already holding a.device.mu.
+func (a *allocation) destroyLocked() error {
+	var err error
+
+	for _, t := range a.inputs {
+		if ret := api.fifoDestroy(t.handle); ret != OK && err == nil {
+			err = fmt.Errorf("error destroying input fifo: %w", errorFor(ret))
+		}
+	}
+	for _, t := range a.outputs {
+		if ret := api.fifoDestroy(t.handle); ret != OK && err == nil {
+			err = fmt.Errorf("error destroying output fifo: %w", errorFor(ret))
+		}
+	}
+	if ret := api.graphDestroy(a.graph); ret != OK && err == nil {
+		err = fmt.Errorf("error destroying graph: %w", errorFor(ret))
+	}
+
+	return err
+}
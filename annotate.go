@@ -0,0 +1,187 @@
+package mvnc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+)
+
+// Annotator draws the detections of each frame read by Process onto a copy
+// of the frame, for example to show a live preview.  Boxes are outlined and
+// labelled with their name and confidence; the names found by a
+// Classification or Embedding graph are listed in the top left corner.
+type Annotator struct {
+	// Color is the color of the boxes and the background of the labels.  It
+	// defaults to green; labels are written in black.
+	Color color.Color
+
+	// Images, if non-nil, receives every annotated frame.
+	Images chan<- image.Image
+
+	// MJPEG, if non-nil, receives every annotated frame as a part of an
+	// MJPEG stream.  Errors writing to it are logged, and it is not written
+	// to again.
+	MJPEG *MJPEGWriter
+
+	mu sync.Mutex // held while writing to MJPEG
+}
+
+// Draw returns a copy of img with boxes and their names outlined and
+// labels listed in its top left corner.
+func (a *Annotator) Draw(img image.Image, boxes []BoundingBox, labels []string) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+
+	c := a.Color
+	if c == nil {
+		c = color.RGBA{G: 0xff, A: 0xff}
+	}
+	fill := image.NewUniform(c)
+
+	// keep the lines and text legible on large frames
+	scale := 1 + b.Dx()/640
+
+	for _, box := range boxes {
+		r := box.Rect(b)
+		for _, edge := range []image.Rectangle{
+			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+scale*2),
+			image.Rect(r.Min.X, r.Max.Y-scale*2, r.Max.X, r.Max.Y),
+			image.Rect(r.Min.X, r.Min.Y, r.Min.X+scale*2, r.Max.Y),
+			image.Rect(r.Max.X-scale*2, r.Min.Y, r.Max.X, r.Max.Y),
+		} {
+			draw.Draw(out, edge.Intersect(b), fill, image.Point{}, draw.Src)
+		}
+
+		if box.Name != "" {
+			label := fmt.Sprintf("%s %.0f%%", box.Name, box.Confidence*100)
+			// above the box, or inside it if it touches the top of the frame
+			y := r.Min.Y - textHeight(scale)
+			if y < b.Min.Y {
+				y = r.Min.Y
+			}
+			drawLabel(out, image.Pt(r.Min.X, y), label, fill, scale)
+		}
+	}
+
+	for i, label := range labels {
+		drawLabel(out, image.Pt(b.Min.X, b.Min.Y+i*textHeight(scale)), label, fill, scale)
+	}
+
+	return out
+}
+
+// emit draws and sends a single frame.
+func (a *Annotator) emit(f *Graph, img image.Image, boxes []BoundingBox, labels []string) {
+	out := a.Draw(img, boxes, labels)
+
+	if a.Images != nil {
+		a.Images <- out
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.MJPEG != nil {
+		if err := a.MJPEG.WriteImage(out); err != nil {
+			f.logf("error writing annotated frame: %v", err)
+			a.MJPEG = nil
+		}
+	}
+}
+
+// the glyphs are 5 by 7 pixels, with a pixel between them and a two pixel
+// margin around each label
+const (
+	glyphW, glyphH = 5, 7
+	labelMargin    = 2
+)
+
+func textHeight(scale int) int {
+	return (glyphH + 2*labelMargin) * scale
+}
+
+// drawLabel writes s in black on a background of fill with its top left
+// corner at p.
+func drawLabel(dst *image.RGBA, p image.Point, s string, fill image.Image, scale int) {
+	w := (len(s)*(glyphW+1) - 1 + 2*labelMargin) * scale
+	draw.Draw(dst, image.Rect(p.X, p.Y, p.X+w, p.Y+textHeight(scale)).Intersect(dst.Bounds()), fill, image.Point{}, draw.Src)
+
+	x0, y0 := p.X+labelMargin*scale, p.Y+labelMargin*scale
+	for i := 0; i < len(s); i++ {
+		g := glyph(s[i])
+		for row := 0; row < glyphH; row++ {
+			for col := 0; col < glyphW; col++ {
+				if g[row]&(0x10>>uint(col)) == 0 {
+					continue
+				}
+				x, y := x0+(i*(glyphW+1)+col)*scale, y0+row*scale
+				draw.Draw(dst, image.Rect(x, y, x+scale, y+scale).Intersect(dst.Bounds()), image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+// glyph returns the rows of the glyph for c, five bits each.  Lower case
+// letters are drawn as upper case, and characters without a glyph as a
+// box.
+func glyph(c byte) [glyphH]byte {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	if g, ok := font[c]; ok {
+		return g
+	}
+	return [glyphH]byte{0x1f, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1f}
+}
+
+var font = map[byte][glyphH]byte{
+	' ': {},
+	'A': {0x0e, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'B': {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'C': {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e},
+	'D': {0x1e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1e},
+	'E': {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f},
+	'F': {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},
+	'G': {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f},
+	'H': {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'I': {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},
+	'M': {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'P': {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},
+	'Q': {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d},
+	'R': {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},
+	'S': {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e},
+	'T': {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a},
+	'X': {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04},
+	'Z': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	',': {0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08},
+	'%': {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'-': {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1f},
+	':': {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'(': {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')': {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'#': {0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a},
+}
@@ -0,0 +1,205 @@
+package mvnc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// AttributeConfig describes the output of an Attributes graph, which
+// estimates attributes of a single object such as the age and gender of a
+// face, each from a head of its own.
+type AttributeConfig struct {
+	Heads []AttributeHead `json:"heads"`
+}
+
+// AttributeHead is one head of an attribute network.  It reads Length
+// values from Offset in the output tensor numbered Tensor, of the graph's
+// output tensors in order; Length defaults to the rest of the tensor.
+//
+// A head with Labels is categorical: its attribute is the label scoring
+// highest, after a softmax if Softmax is set, with its score as the
+// confidence.  Otherwise it is a regression: its attribute is its first
+// value times Scale, which defaults to 1, plus Bias, so an age predicted
+// as a fraction of 100 years has a Scale of 100.
+type AttributeHead struct {
+	Name   string `json:"name"`
+	Tensor int    `json:"tensor,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Length int    `json:"length,omitempty"`
+
+	Labels  []string `json:"labels,omitempty"`
+	Softmax bool     `json:"softmax,omitempty"`
+
+	Scale float32 `json:"scale,omitempty"`
+	Bias  float32 `json:"bias,omitempty"`
+}
+
+// Attribute is the value of an attribute head.  Value is the value of a
+// regression, or the index of the label of a categorical head, which is
+// Label.
+type Attribute struct {
+	Value      float32
+	Label      string
+	Confidence float32
+}
+
+// Decode decodes output, the concatenation of tensors of the given shapes,
+// into the attributes of each head, by name.
+func (c *AttributeConfig) Decode(output []float32, tensors []TensorDescriptor) (map[string]Attribute, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	// the offset of each tensor in output
+	offsets := make([]int, len(tensors)+1)
+	for i, t := range tensors {
+		offsets[i+1] = offsets[i] + t.Elements()
+	}
+	if len(tensors) == 0 {
+		offsets = []int{0, len(output)}
+	}
+
+	attrs := make(map[string]Attribute, len(c.Heads))
+	for _, h := range c.Heads {
+		if h.Offset < 0 || h.Length < 0 {
+			return nil, fmt.Errorf("attribute %s has a negative offset or length", h.Name)
+		} else if h.Tensor < 0 || h.Tensor >= len(offsets)-1 {
+			return nil, fmt.Errorf("attribute %s reads output tensor %d, the graph has %d", h.Name, h.Tensor, len(offsets)-1)
+		}
+
+		start, end := offsets[h.Tensor]+h.Offset, offsets[h.Tensor+1]
+		if h.Length > 0 {
+			end = start + h.Length
+		}
+		if start >= end || end > offsets[h.Tensor+1] || end > len(output) {
+			return nil, fmt.Errorf("attribute %s reads values %d to %d of output tensor %d, which has %d", h.Name, h.Offset, end-offsets[h.Tensor], h.Tensor, offsets[h.Tensor+1]-offsets[h.Tensor])
+		}
+		values := output[start:end]
+
+		if len(h.Labels) == 0 {
+			scale := h.Scale
+			if scale == 0 {
+				scale = 1
+			}
+			attrs[h.Name] = Attribute{Value: values[0]*scale + h.Bias, Confidence: 1}
+			continue
+		}
+
+		if h.Softmax {
+			values = append([]float32(nil), values...)
+			softmax(values)
+		}
+		best := 0
+		for i := range values {
+			if values[i] > values[best] {
+				best = i
+			}
+		}
+		a := Attribute{Value: float32(best), Confidence: values[best]}
+		if best < len(h.Labels) {
+			a.Label = h.Labels[best]
+		}
+		attrs[h.Name] = a
+	}
+	return attrs, nil
+}
+
+// AttributePostprocessor decodes the output of an Attributes graph
+// described by Config, like the Attributes OutputFormat, into a Detection
+// of each categorical attribute whose confidence is above Threshold, named
+// by its label, all carrying the attributes.
+type AttributePostprocessor struct {
+	Config    *AttributeConfig
+	Threshold float32
+}
+
+func (p *AttributePostprocessor) Decode(output []float32, meta FrameMeta) []Detection {
+	attrs, err := p.Config.Decode(output, meta.Outputs)
+	if err != nil {
+		return nil
+	}
+
+	var dets []Detection
+	for _, h := range p.Config.Heads {
+		if a := attrs[h.Name]; a.Label != "" && a.Confidence > p.Threshold {
+			dets = append(dets, Detection{FrameID: meta.FrameID, Class: int(a.Value), Name: a.Label, Confidence: a.Confidence, Attributes: attrs})
+		}
+	}
+	return dets
+}
+
+// attributes returns the attributes of bout, appending the labels of the
+// categorical ones above the graph's Threshold to dets.
+func (f *Graph) attributes(bout []float32, meta FrameMeta, dets []detection) (map[string]Attribute, []detection, error) {
+	attrs, err := f.Attributes.Decode(bout, meta.Outputs)
+	if err != nil {
+		return nil, dets, err
+	}
+
+	for _, h := range f.Attributes.Heads {
+		if a := attrs[h.Name]; a.Label != "" && a.Confidence > f.Threshold {
+			dets = append(dets, detection{name: a.Label, confidence: a.Confidence})
+		}
+	}
+	return attrs, dets, nil
+}
+
+// DecodeAttributes stores the Attributes of r in the fields of the struct
+// v points to, each taking the attribute named by its mvnc tag, or else
+// its own name in lower case:
+//
+//	var face struct {
+//		Age    int
+//		Gender string `mvnc:"gender"`
+//	}
+//	err := res.DecodeAttributes(&face)
+//
+// String fields are set to the attribute's label, floating point fields to
+// its value, and integer fields to its value rounded.  Fields without an
+// attribute are left alone.
+func (r *Result) DecodeAttributes(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("attributes can only be decoded into a pointer to a struct, not %T", v)
+	}
+	s := rv.Elem()
+
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Type().Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		name := field.Tag.Get("mvnc")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		a, ok := r.Attributes[name]
+		if !ok {
+			continue
+		}
+
+		fv := s.Field(i)
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(a.Label)
+		case reflect.Float32, reflect.Float64:
+			fv.SetFloat(float64(a.Value))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv.SetInt(int64(math.Round(float64(a.Value))))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if a.Value < 0 {
+				return fmt.Errorf("attribute %s is %v, which does not fit field %s", name, a.Value, field.Name)
+			}
+			fv.SetUint(uint64(math.Round(float64(a.Value))))
+		default:
+			return fmt.Errorf("attribute %s cannot be stored in field %s of type %v", name, field.Name, field.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package mvnc
+
+import (
+	"context"
+	"testing"
+)
+
+// TestDecodeAttributeTensors decodes the output of an age and gender graph
+// with its heads in separate output tensors.
+func TestDecodeAttributeTensors(t *testing.T) {
+	stick := testStick(0)
+	stick.Outputs = []TensorDescriptor{{N: 1, C: 1, W: 1, H: 1}, {N: 1, C: 2, W: 1, H: 1}}
+	stick.Infer = func(input []float32) []float32 { return []float32{0.31, 0.1, 0.9} }
+	defer UseFake(stick)()
+
+	g := testGraph(t)
+	g.OutputFormat = Attributes
+	g.Attributes = &AttributeConfig{Heads: []AttributeHead{
+		{Name: "age", Scale: 100},
+		{Name: "gender", Tensor: 1, Labels: []string{"female", "male"}},
+	}}
+	defer g.Close()
+
+	output, err := g.Infer(context.Background(), make([]float32, testFrameSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := g.Decode(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if age := res.Attributes["age"]; age.Value < 30.99 || age.Value > 31.01 {
+		t.Errorf("decoded an age of %v, want 31", age.Value)
+	}
+	if gender := res.Attributes["gender"]; gender.Label != "male" || gender.Confidence != 0.9 {
+		t.Errorf("decoded a gender of %s (%v), want male (0.9)", gender.Label, gender.Confidence)
+	}
+	if len(res.Names) != 1 || res.Names[0] != "male" {
+		t.Errorf("detected %q, want [male]", res.Names)
+	}
+}
+
+func TestAttributeConfigErrors(t *testing.T) {
+	tensors := []TensorDescriptor{{N: 1, C: 1, W: 1, H: 1}, {N: 1, C: 2, W: 1, H: 1}}
+	output := []float32{0.31, 0.1, 0.9}
+
+	tests := []struct {
+		name string
+		head AttributeHead
+		err  string
+	}{
+		{"negative offset", AttributeHead{Name: "age", Offset: -1}, "attribute age has a negative offset or length"},
+		{"negative length", AttributeHead{Name: "age", Length: -2}, "attribute age has a negative offset or length"},
+		{"negative tensor", AttributeHead{Name: "age", Tensor: -1}, "attribute age reads output tensor -1, the graph has 2"},
+		{"missing tensor", AttributeHead{Name: "age", Tensor: 2}, "attribute age reads output tensor 2, the graph has 2"},
+		{"past the tensor", AttributeHead{Name: "gender", Tensor: 1, Offset: 1, Length: 2}, "attribute gender reads values 1 to 3 of output tensor 1, which has 2"},
+		{"empty", AttributeHead{Name: "age", Offset: 1}, "attribute age reads values 1 to 1 of output tensor 0, which has 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &AttributeConfig{Heads: []AttributeHead{tt.head}}
+			attrs, err := c.Decode(output, tensors)
+			if err == nil {
+				t.Fatalf("decoded %v, want an error", attrs)
+			}
+			if err.Error() != tt.err {
+				t.Errorf("returned %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
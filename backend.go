@@ -0,0 +1,83 @@
+package mvnc
+
+import "unsafe"
+
+// deviceAPI is the NCAPI as called by the rest of the package, so that the
+// sticks can be replaced by the simulated ones of UseFake.  ncapi calls
+// libmvnc through cgo, unless the package is built with the mvnc_stub tag,
+// when stubAPI fails every call instead.
+//
+// The handles are opaque to the package, and nil once destroyed.  Each
+// method returns the status of the underlying NCAPI call.
+type deviceAPI interface {
+	apiVersion() (Version, Status)
+	logLevel() (LogLevel, Status)
+	setLogLevel(level LogLevel) Status
+
+	deviceCreate(index int) (deviceHandle, Status)
+	deviceOpen(d deviceHandle) Status
+	deviceClose(d deviceHandle) Status
+	deviceDestroy(d deviceHandle) Status
+	deviceName(d deviceHandle) (string, Status)
+	deviceFirmwareVersion(d deviceHandle) (Version, Status)
+	deviceThermalStats(d deviceHandle) ([]float32, Status)
+	deviceIntOption(d deviceHandle, option deviceOption) (int, Status)
+	deviceDebugInfo(d deviceHandle) (string, Status)
+
+	// graphAllocate allocates g on d from the contents of a graph file,
+	// and graphAllocateWithFifos also creates its fifos, of the given
+	// depths and data types.
+	graphCreate(name string) (graphHandle, Status)
+	graphAllocate(d deviceHandle, g graphHandle, file []byte) Status
+	graphAllocateWithFifos(d deviceHandle, g graphHandle, file []byte, in, out FifoConfig) (input, output fifoHandle, _ Status)
+	graphDestroy(g graphHandle) Status
+	graphSetIntOption(g graphHandle, option graphOption, v int) Status
+	graphTensorDescriptors(g graphHandle, input bool) ([]TensorDescriptor, Status)
+	graphTimeTaken(g graphHandle) ([]float32, Status)
+	graphDebugInfo(g graphHandle) (string, Status)
+
+	// queueInference runs g on the next element of each of inputs, one per
+	// input tensor, writing an element to each of outputs.
+	queueInference(g graphHandle, inputs, outputs []fifoHandle) Status
+
+	// fifoWrite writes the size bytes at data to f, passing id through to
+	// the output, and fifoRead reads an element of size bytes into data and
+	// returns the id it was written with.
+	//
+	// fifoCreate creates a fifo the host writes to if input, or reads from
+	// otherwise, and fifoAllocate allocates it on d for elements of desc's
+	// shape.
+	fifoCreate(name string, input bool) (fifoHandle, Status)
+	fifoAllocate(f fifoHandle, d deviceHandle, desc TensorDescriptor, config FifoConfig) Status
+	fifoElementSize(f fifoHandle) (int, Status)
+	fifoWriteFillLevel(f fifoHandle) (int, Status)
+	fifoReadFillLevel(f fifoHandle) (int, Status)
+	fifoWrite(f fifoHandle, data unsafe.Pointer, size int, id uint64) Status
+	fifoRead(f fifoHandle, data unsafe.Pointer, size int) (uint64, Status)
+	fifoDestroy(f fifoHandle) Status
+}
+
+// Handles of the devices, graphs and fifos of a deviceAPI.
+type (
+	deviceHandle interface{}
+	graphHandle  interface{}
+	fifoHandle   interface{}
+)
+
+// graphOption is an integer option set by graphSetIntOption, before the
+// graph is allocated.
+type graphOption int
+
+const (
+	optExecutors graphOption = iota
+)
+
+// deviceOption is an integer option read by deviceIntOption.
+type deviceOption int
+
+const (
+	optThrottlingLevel deviceOption = iota
+	optMemoryUsed
+	optMemorySize
+	optHardwareVersion
+)
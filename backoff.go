@@ -0,0 +1,87 @@
+package mvnc
+
+import (
+	"errors"
+	"time"
+)
+
+// Backoff is an exponential backoff schedule: the first wait is Initial, and
+// each wait after that is twice as long as the one before, up to Max.
+type Backoff struct {
+	Initial time.Duration // defaults to 100ms
+	Max     time.Duration // defaults to 30s
+}
+
+// delay returns how long to wait before the given retry, counting from 0.
+func (b *Backoff) delay(attempt int) time.Duration {
+	d, max := b.Initial, b.Max
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	return d
+}
+
+// recoverable reports whether err means the stick itself has failed, for
+// example because it was reset or unplugged, and so reopening it may help.
+func recoverable(err error) bool {
+	for _, s := range []Status{ErrError, ErrMyriadError, ErrTimeout, ErrDeviceNotFound} {
+		if errors.Is(err, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy retries fifo writes and reads and inference queueing that fail
+// with a transient status, such as NC_BUSY.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a call is made before its error
+	// is returned, including the first.
+	MaxAttempts int
+
+	// Backoff is the wait between attempts.
+	Backoff Backoff
+
+	// Statuses lists the statuses that are retried.  It defaults to
+	// ErrBusy.
+	Statuses []Status
+}
+
+func (p *RetryPolicy) retries(s Status) bool {
+	if s == OK {
+		return false
+	} else if len(p.Statuses) == 0 {
+		return s == ErrBusy
+	}
+
+	for _, t := range p.Statuses {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+// call calls fn until it succeeds, fails with a status the policy does not
+// retry, or runs out of attempts.  A nil policy calls fn once.
+func (p *RetryPolicy) call(fn func() Status) Status {
+	ret := fn()
+
+	for attempt := 0; p != nil && attempt+1 < p.MaxAttempts && p.retries(ret); attempt++ {
+		time.Sleep(p.Backoff.delay(attempt))
+		ret = fn()
+	}
+
+	return ret
+}
@@ -0,0 +1,35 @@
+package mvnc
+
+import "fmt"
+
+// Backpressure selects what Process does with a frame read while the graph
+// already has as many inferences in flight as its input fifo holds.
+type Backpressure int
+
+const (
+	// DropLatest skips the frame just read, so that the stick only ever
+	// works on frames that were current when they were read.
+	DropLatest Backpressure = iota
+
+	// DropOldest keeps the frame just read waiting on the host until a slot
+	// frees up, replacing any frame that was already waiting, so the next
+	// frame run is always the newest.
+	DropOldest
+
+	// Block waits for a slot before reading the next frame, so that no
+	// frames are lost, for example when scoring recorded video.
+	Block
+)
+
+func (b Backpressure) String() string {
+	switch b {
+	case DropLatest:
+		return "drop-latest"
+	case DropOldest:
+		return "drop-oldest"
+	case Block:
+		return "block"
+	default:
+		return fmt.Sprintf("Backpressure(%d)", int(b))
+	}
+}
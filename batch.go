@@ -0,0 +1,122 @@
+package mvnc
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+)
+
+// InferBatch runs every image of imgs through the graph, preprocessed like
+// those of InferImage, and returns their Results in the same order, decoded
+// as by Decode.  Unlike calling InferImage on each image in turn, the images
+// are pipelined through the graph's fifos, keeping the input fifo full, so
+// the stick never waits for the host between them; this is the fastest way
+// to score a dataset held in memory.
+//
+// If an inference fails, no more images are queued and the first error is
+// returned.  Like Infer, InferBatch is safe to call concurrently.
+func (f *Graph) InferBatch(ctx context.Context, imgs []image.Image) ([]Result, error) {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := a.inputDesc
+	if err := a.imageInput(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range imgs {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([]Result, len(imgs))
+
+	var (
+		mu    sync.Mutex
+		first error
+	)
+
+	// one worker per slot in the input fifo keeps it full
+	var wg sync.WaitGroup
+	for w := 0; w < f.InputFifo.depth(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conv := f.converter()
+			bb := make([]byte, desc.W*desc.H*3)
+
+			for i := range jobs {
+				img := imgs[i]
+				r := &request{
+					input:    make([]float32, a.inputLen),
+					output:   make([]float32, a.outputLen),
+					priority: priorityOf(ctx),
+				}
+
+				r.times.started = time.Now()
+				seen := f.fit(bb, desc, img, img.Bounds())
+				conv.Convert(r.input, bb)
+				r.times.preprocessed = time.Now()
+
+				err := a.do(ctx, r)
+				f.traceDone(ctx, r, err)
+				if err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+
+				var roi image.Rectangle
+				if f.Fit == Letterbox {
+					roi = seen
+				}
+				meta := FrameMeta{Outputs: make([]TensorDescriptor, len(r.outputs))}
+				for i, t := range r.outputs {
+					meta.Outputs[i] = t.desc
+				}
+				if len(r.outputs) > 0 {
+					meta.Output = r.outputs[0].desc
+				}
+				res, err := f.decodeRect(r.output, meta, roi, img.Bounds())
+				if err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+				res.Time, res.Timing = r.times.started, r.timing
+				if len(r.outputs) > 1 {
+					res.Tensors = splitTensors(r.outputs, res.Output)
+				}
+				results[i] = res
+			}
+		}()
+	}
+	wg.Wait()
+
+	if first != nil {
+		return nil, first
+	}
+	return results, nil
+}
@@ -0,0 +1,156 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchmarkResult is the throughput and latency measured by Benchmark.
+type BenchmarkResult struct {
+	Frames  int
+	Elapsed time.Duration
+	FPS     float64
+
+	// P50, P95 and P99 are percentiles of the inferences' latency, from
+	// writing the input to the fifo to reading the output, as in Timing.
+	P50, P95, P99 time.Duration
+
+	// Preprocess and DeviceIO are the average host time spent on each frame
+	// resizing and normalizing it, and writing it to and reading its output
+	// from the fifos.
+	Preprocess, DeviceIO time.Duration
+}
+
+func (b BenchmarkResult) String() string {
+	return fmt.Sprintf("%d frames in %v: %.1f fps, latency p50 %v p95 %v p99 %v, preprocess %v, device I/O %v per frame",
+		b.Frames, b.Elapsed, b.FPS, b.P50, b.P95, b.P99, b.Preprocess, b.DeviceIO)
+}
+
+// Benchmark runs n inferences as fast as the graph accepts them, keeping
+// its input fifo full, and measures their throughput and latency.  The
+// frames are taken from frames in turn, and preprocessed like those of
+// InferImage; with no frames, a random image the size of the input tensor
+// is used.  The inferences are counted in Stats like any other.
+func (f *Graph) Benchmark(ctx context.Context, n int, frames ...image.Image) (BenchmarkResult, error) {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return BenchmarkResult{}, err
+	}
+
+	desc := a.inputDesc
+	if err := a.imageInput(); err != nil {
+		return BenchmarkResult{}, err
+	}
+	if len(frames) == 0 {
+		frames = []image.Image{noise(desc.W, desc.H)}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan image.Image)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case jobs <- frames[i%len(frames)]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		pre, fifo time.Duration
+		first     error
+	)
+
+	start := time.Now()
+
+	// one worker per slot in the input fifo keeps it full
+	var wg sync.WaitGroup
+	for w := 0; w < f.InputFifo.depth(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conv := f.converter()
+			bb := make([]byte, desc.W*desc.H*3)
+
+			for img := range jobs {
+				r := &request{
+					input:    make([]float32, a.inputLen),
+					output:   make([]float32, a.outputLen),
+					priority: priorityOf(ctx),
+				}
+
+				r.times.started = time.Now()
+				f.fit(bb, desc, img, img.Bounds())
+				conv.Convert(r.input, bb)
+				r.times.preprocessed = time.Now()
+
+				err := a.do(ctx, r)
+				f.traceDone(ctx, r, err)
+
+				mu.Lock()
+				if err != nil {
+					if first == nil {
+						first = err
+						cancel()
+					}
+				} else {
+					latencies = append(latencies, r.timing.Latency)
+					pre += r.times.preprocessed.Sub(r.times.started)
+					fifo += r.times.queued.Sub(r.written) + r.times.readDone.Sub(r.times.readStart)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	b := BenchmarkResult{Frames: len(latencies), Elapsed: time.Since(start)}
+	if first != nil {
+		return b, first
+	}
+	if b.Frames == 0 {
+		return b, nil
+	}
+
+	b.FPS = float64(b.Frames) / b.Elapsed.Seconds()
+	b.Preprocess = pre / time.Duration(b.Frames)
+	b.DeviceIO = fifo / time.Duration(b.Frames)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.P50 = percentile(latencies, 50)
+	b.P95 = percentile(latencies, 95)
+	b.P99 = percentile(latencies, 99)
+
+	return b, nil
+}
+
+// percentile returns the p'th percentile of sorted, by the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (p*len(sorted)+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// noise returns an image of random pixels.
+func noise(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rand.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
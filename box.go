@@ -0,0 +1,38 @@
+package mvnc
+
+import (
+	"image"
+)
+
+// BoundingBox is a region of a frame found by a detection graph.  Its
+// coordinates are normalized to [0, 1] across the frame the graph saw, with
+// (0, 0) at the top left.
+type BoundingBox struct {
+	Class      int
+	Name       string
+	Confidence float32
+
+	XMin, YMin, XMax, YMax float32
+}
+
+// within maps b, relative to the rectangle r of a frame with the given
+// bounds, to be relative to the whole frame.
+func (b BoundingBox) within(r, bounds image.Rectangle) BoundingBox {
+	w, h := float32(bounds.Dx()), float32(bounds.Dy())
+	x := func(v float32) float32 { return (float32(r.Min.X-bounds.Min.X) + v*float32(r.Dx())) / w }
+	y := func(v float32) float32 { return (float32(r.Min.Y-bounds.Min.Y) + v*float32(r.Dy())) / h }
+
+	b.XMin, b.YMin, b.XMax, b.YMax = x(b.XMin), y(b.YMin), x(b.XMax), y(b.YMax)
+	return b
+}
+
+// Rect returns the box in pixels, given the rectangle r of the frame the
+// graph saw.
+func (b BoundingBox) Rect(r image.Rectangle) image.Rectangle {
+	w, h := float32(r.Dx()), float32(r.Dy())
+
+	return image.Rect(
+		r.Min.X+int(b.XMin*w+0.5), r.Min.Y+int(b.YMin*h+0.5),
+		r.Min.X+int(b.XMax*w+0.5), r.Min.Y+int(b.YMax*h+0.5),
+	)
+}
@@ -0,0 +1,57 @@
+package mvnc
+
+import (
+	"sync"
+)
+
+// scratch holds the temporary buffers used to postprocess a single
+// inference.  They are recycled through scratchPool so that a steady stream
+// of frames makes no allocations.
+type scratch struct {
+	scores []float32
+	idx    []int
+	dets   []detection
+}
+
+var scratchPool = sync.Pool{
+	New: func() interface{} { return new(scratch) },
+}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+func putScratch(sc *scratch) {
+	sc.scores, sc.idx, sc.dets = sc.scores[:0], sc.idx[:0], sc.dets[:0]
+	scratchPool.Put(sc)
+}
+
+// bytePool and float32Pool recycle the pixel and tensor buffers of
+// InferImage.  They hold pointers to slices, since putting a slice itself
+// in a sync.Pool allocates.
+var (
+	bytePool    sync.Pool
+	float32Pool sync.Pool
+)
+
+// getBytes returns a buffer of n bytes from bytePool.
+func getBytes(n int) *[]byte {
+	if b, ok := bytePool.Get().(*[]byte); ok && cap(*b) >= n {
+		*b = (*b)[:n]
+		return b
+	}
+
+	b := make([]byte, n)
+	return &b
+}
+
+// getFloat32s returns a buffer of n float32s from float32Pool.
+func getFloat32s(n int) *[]float32 {
+	if b, ok := float32Pool.Get().(*[]float32); ok && cap(*b) >= n {
+		*b = (*b)[:n]
+		return b
+	}
+
+	b := make([]float32, n)
+	return &b
+}
@@ -0,0 +1,135 @@
+package mvnc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// Calibration maps the raw confidences of a graph, which are often
+// over-confident, onto calibrated probabilities before thresholds are
+// applied, so that a Threshold of 0.8 keeps detections which are right
+// about 80% of the time.  It applies to the class scores of Classification
+// graphs and the box confidences of SSD and YOLO graphs, and the calibrated
+// confidences are those reported.
+type Calibration struct {
+	// Temperature divides the logits of every class: for a Softmax graph
+	// its outputs, before the softmax, and otherwise the logit of each
+	// confidence.  A temperature above 1 softens over-confident scores.
+	// Zero leaves them alone, as does 1.
+	Temperature float32 `json:"temperature,omitempty"`
+
+	// Platt holds the Platt scaling parameters [A, B] of individual
+	// classes, by index, which take precedence over Temperature: the
+	// logit s of a confidence becomes 1 / (1 + exp(A*s + B)).  For a
+	// Softmax graph they apply to the scores after the softmax, which
+	// Temperature has already softened.
+	Platt map[int][2]float32 `json:"platt,omitempty"`
+}
+
+// LoadCalibration reads a Calibration from a JSON file such as
+//
+//	{"temperature": 1.7, "platt": {"15": [-1.2, 0.3]}}
+//
+// as written by the tool used to fit it on a validation set.
+func LoadCalibration(path string) (*Calibration, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Calibration{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("error parsing calibration %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Calibrate returns the calibrated confidence of class for the raw
+// confidence v.
+func (c *Calibration) Calibrate(class int, v float32) float32 {
+	if p, ok := c.Platt[class]; ok {
+		return platt(p, v)
+	} else if c.Temperature != 0 && c.Temperature != 1 {
+		return sigmoid(logit(v) / c.Temperature)
+	}
+	return v
+}
+
+// raw returns the raw confidence of class which calibrates to v, or 0 if
+// the calibration cannot be inverted, so that outputs can be decoded with a
+// threshold low enough to keep every box which calibrates above v.
+func (c *Calibration) raw(class int, v float32) float32 {
+	if p, ok := c.Platt[class]; ok {
+		if p[0] >= 0 {
+			// not increasing
+			return 0
+		}
+		return sigmoid((float32(math.Log(float64(1/unitOpen(v)-1))) - p[1]) / p[0])
+	} else if c.Temperature > 0 {
+		return sigmoid(logit(v) * c.Temperature)
+	}
+	return v
+}
+
+// scores calibrates the scores of a Classification graph in place, which
+// are logits to be passed through a softmax if logits is set.
+func (c *Calibration) scores(scores []float32, logits bool) {
+	if !logits {
+		for i := range scores {
+			scores[i] = c.Calibrate(i, scores[i])
+		}
+		return
+	}
+
+	if c.Temperature != 0 && c.Temperature != 1 {
+		for i := range scores {
+			scores[i] /= c.Temperature
+		}
+	}
+	softmax(scores)
+	for class, p := range c.Platt {
+		if class >= 0 && class < len(scores) {
+			scores[class] = platt(p, scores[class])
+		}
+	}
+}
+
+// platt applies the Platt scaling parameters p to v.
+func platt(p [2]float32, v float32) float32 {
+	return 1 / (1 + exp32(p[0]*logit(v)+p[1]))
+}
+
+// logit is the inverse of sigmoid.
+func logit(v float32) float32 {
+	v = unitOpen(v)
+	return float32(math.Log(float64(v / (1 - v))))
+}
+
+// unitOpen clamps v to (0, 1), so that its logit is finite.
+func unitOpen(v float32) float32 {
+	const eps = 1e-6
+	if v < eps {
+		return eps
+	} else if v > 1-eps {
+		return 1 - eps
+	}
+	return v
+}
+
+// decodeThreshold returns the raw confidence above which the boxes of an
+// SSD or YOLO graph are decoded: the lowest which calibrates above the
+// threshold of any class.
+func (f *Graph) decodeThreshold() float32 {
+	min := f.minThreshold()
+	if f.Calibration == nil {
+		return min
+	}
+
+	raw := f.Calibration.raw(-1, min)
+	for class := range f.Calibration.Platt {
+		raw = min32(raw, f.Calibration.raw(class, f.threshold(class)))
+	}
+	return raw
+}
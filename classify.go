@@ -0,0 +1,64 @@
+package mvnc
+
+// threshold returns the confidence a class must exceed to be detected.
+func (f *Graph) threshold(class int) float32 {
+	if t, ok := f.Thresholds[class]; ok {
+		return t
+	} else if t, ok := f.NamedThresholds[f.Names[class]]; ok {
+		return t
+	}
+	return f.Threshold
+}
+
+// minThreshold returns the lowest threshold of any class.
+func (f *Graph) minThreshold() float32 {
+	min := f.Threshold
+	for _, t := range f.Thresholds {
+		min = min32(min, t)
+	}
+	for _, t := range f.NamedThresholds {
+		min = min32(min, t)
+	}
+	return min
+}
+
+// classes returns the indices of the named classes scoring above their
+// threshold in bout, applying the softmax first if Softmax is set and the
+// Calibration if there is one, along with the scores they refer to.  With
+// TopK set only the TopK best are returned, best first; otherwise they are
+// returned in index order.  The results are built in sc.
+func (f *Graph) classes(bout []float32, sc *scratch) ([]int, []float32) {
+	scores := bout
+	if f.Calibration != nil {
+		sc.scores = append(sc.scores[:0], bout...)
+		scores = sc.scores
+		f.Calibration.scores(scores, f.Softmax)
+	} else if f.Softmax {
+		sc.scores = append(sc.scores[:0], bout...)
+		scores = sc.scores
+		softmax(scores)
+	}
+
+	idx := sc.idx[:0]
+	for i, r := range scores {
+		if _, ok := f.Names[i]; ok && r > f.threshold(i) {
+			idx = append(idx, i)
+		}
+	}
+	sc.idx = idx
+
+	if f.TopK > 0 {
+		// insertion sort, which is stable and, unlike sort.SliceStable,
+		// does not allocate
+		for i := 1; i < len(idx); i++ {
+			for j := i; j > 0 && scores[idx[j]] > scores[idx[j-1]]; j-- {
+				idx[j], idx[j-1] = idx[j-1], idx[j]
+			}
+		}
+		if len(idx) > f.TopK {
+			idx = idx[:f.TopK]
+		}
+	}
+
+	return idx, scores
+}
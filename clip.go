@@ -0,0 +1,231 @@
+package mvnc
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultClipTemplate names the clips of a Clipper by default, by the time
+// of their first frame and the name detected.
+const DefaultClipTemplate = `{{.Start.Format "20060102-150405"}}-{{.Name}}`
+
+// ClipFrame is a frame of a Clip, as a JPEG.
+type ClipFrame struct {
+	FrameID uint64
+	Time    time.Time
+	JPEG    []byte
+}
+
+// Clip is the frames around a detection kept by a Clipper.  Name is the
+// name whose detection started it, Start and End the times of its first
+// and last frames, and Path where it was saved, if it was.
+type Clip struct {
+	Name       string
+	Start, End time.Time
+	Frames     []ClipFrame
+	Path       string
+}
+
+// Clipper keeps the frames read by Process, a Pool or a Multiplexer for
+// the last Before, so that when something is detected it can save a clip
+// of the frames from Before the detection to After it, as security
+// cameras' pre-roll does.  Set Graph.Clips to use one.  Every frame is
+// encoded as a JPEG from the goroutine completing its inference, and kept
+// until it is older than Before.  Frames older than the last one added are
+// dropped, as the frames of a Pool may complete out of order, and the
+// sources of a Multiplexer share the clips.  It is safe for concurrent use.
+type Clipper struct {
+	// Before and After are how long before and after a detection a clip
+	// runs.  Detections during the clip extend it to After the last of
+	// them, but to no longer than MaxDuration if that is set.
+	Before      time.Duration
+	After       time.Duration
+	MaxDuration time.Duration
+
+	// Dir, if set, is the directory the clips are saved in, named by
+	// Template, a text/template executed with the Clip, or
+	// DefaultClipTemplate.  A clip is saved as the concatenated JPEGs of
+	// an MJPEG file, with the extension .mjpeg, or if Sequence is set as
+	// a directory of JPEGs numbered from 000001.jpg.
+	Dir      string
+	Template string
+	Sequence bool
+	Quality  int
+
+	// Clips, if non-nil, receives every clip once it ends.
+	Clips chan<- Clip
+
+	// Names, if non-empty, limits the clips to detections of the names it
+	// lists.
+	Names []string
+
+	mu     sync.Mutex
+	tmpl   *template.Template
+	err    error
+	recent []ClipFrame // the frames of the last Before
+	active *Clip
+	until  time.Time // when the active clip ends
+	frame  uint64
+}
+
+// NewClipper returns a Clipper saving clips from before to after a
+// detection to dir, with their names parsed from tmpl, or
+// DefaultClipTemplate if tmpl is empty.
+func NewClipper(dir, tmpl string, before, after time.Duration) (*Clipper, error) {
+	c := &Clipper{Dir: dir, Template: tmpl, Before: before, After: after}
+	if _, err := c.template(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// template returns the parsed Template.
+func (c *Clipper) template() (*template.Template, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tmpl == nil && c.err == nil {
+		text := c.Template
+		if text == "" {
+			text = DefaultClipTemplate
+		}
+		if c.tmpl, c.err = template.New("clip").Parse(text); c.err != nil {
+			c.err = fmt.Errorf("error parsing clip template: %w", c.err)
+		}
+	}
+	return c.tmpl, c.err
+}
+
+// add adds the frame of r, in which dets were detected, to the frames
+// kept, starting, extending or ending a clip.
+func (c *Clipper) add(f *Graph, r *request, dets []detection) {
+	quality := c.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, r.img, &jpeg.Options{Quality: quality}); err != nil {
+		f.logf("error encoding frame %d for clips: %v", r.id, err)
+		return
+	}
+	fr := ClipFrame{FrameID: r.id, Time: r.readAt, JPEG: b.Bytes()}
+
+	// the best detection a clip is made of
+	trigger := -1
+	for i, d := range dets {
+		if (len(c.Names) == 0 || contains(c.Names, d.name)) && (trigger < 0 || d.confidence > dets[trigger].confidence) {
+			trigger = i
+		}
+	}
+
+	c.mu.Lock()
+	if r.id != 0 && r.id <= c.frame {
+		c.mu.Unlock()
+		return
+	}
+	c.frame = r.id
+	c.recent = append(c.recent, fr)
+	old := 0
+	for old < len(c.recent) && fr.Time.Sub(c.recent[old].Time) > c.Before {
+		old++
+	}
+	c.recent = append(c.recent[:0], c.recent[old:]...)
+
+	if c.active == nil && trigger >= 0 {
+		c.active = &Clip{Name: dets[trigger].name, Frames: append([]ClipFrame(nil), c.recent[:len(c.recent)-1]...)}
+	}
+	if c.active != nil {
+		c.active.Frames = append(c.active.Frames, fr)
+		if trigger >= 0 {
+			c.until = fr.Time.Add(c.After)
+			if start := c.active.Frames[0].Time; c.MaxDuration > 0 && c.until.Sub(start) > c.MaxDuration {
+				c.until = start.Add(c.MaxDuration)
+			}
+		}
+	}
+	var done *Clip
+	if c.active != nil && !fr.Time.Before(c.until) {
+		done, c.active = c.active, nil
+	}
+	c.mu.Unlock()
+
+	if done != nil {
+		if err := c.save(done); err != nil {
+			f.logf("error saving clip of frame %d: %v", r.id, err)
+		}
+	}
+}
+
+// Flush ends the clip in progress, if any, saving it and sending it to
+// Clips, and forgets the frames kept, as at the end of a stream.
+func (c *Clipper) Flush() error {
+	c.mu.Lock()
+	done := c.active
+	c.active, c.recent, c.frame = nil, nil, 0
+	c.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	return c.save(done)
+}
+
+// save saves clip to Dir and sends it to Clips.
+func (c *Clipper) save(clip *Clip) error {
+	clip.Start, clip.End = clip.Frames[0].Time, clip.Frames[len(clip.Frames)-1].Time
+
+	var err error
+	if c.Dir != "" {
+		err = c.write(clip)
+	}
+	if c.Clips != nil {
+		c.Clips <- *clip
+	}
+	return err
+}
+
+// write saves clip to Dir, setting its Path.
+func (c *Clipper) write(clip *Clip) error {
+	tmpl, err := c.template()
+	if err != nil {
+		return err
+	}
+
+	named := *clip
+	named.Name = safeName(named.Name)
+	path, err := templatePath(c.Dir, tmpl, named)
+	if err != nil {
+		return err
+	}
+
+	if c.Sequence {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		for i, fr := range clip.Frames {
+			if err := ioutil.WriteFile(filepath.Join(path, fmt.Sprintf("%06d.jpg", i+1)), fr.JPEG, 0644); err != nil {
+				return err
+			}
+		}
+		clip.Path = path
+		return nil
+	}
+
+	path += ".mjpeg"
+	var b bytes.Buffer
+	for _, fr := range clip.Frames {
+		b.Write(fr.JPEG)
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	clip.Path = path
+	return nil
+}
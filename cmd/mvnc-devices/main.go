@@ -0,0 +1,269 @@
+// Command mvnc-devices lists the Neural Compute Sticks attached to the host
+// with their hardware (Myriad 2 or Myriad X), firmware version, thermal state
+// and memory, and can run a self-test on each, for diagnosing sticks that are
+// not found or misbehave.
+//
+// Usage:
+//
+//	mvnc-devices [-json] [-device index] [-selftest [-graph file] [-n count]]
+//
+// The self-test opens each stick, reads its telemetry and closes it again.
+// Given a compiled graph with -graph, it also allocates the graph and runs
+// -n inferences on the same random input, checking that every output is
+// identical and finite, which exercises the USB link and the fifos.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// Device is the report on a single stick.
+type Device struct {
+	Index    int    `json:"index"`
+	Name     string `json:"name"`
+	Hardware string `json:"hardware,omitempty"`
+	Firmware string `json:"firmware,omitempty"`
+
+	Temperature float32 `json:"temperature_celsius,omitempty"`
+	Throttling  string  `json:"throttling,omitempty"`
+	MemoryUsed  int     `json:"memory_used_bytes,omitempty"`
+	MemorySize  int     `json:"memory_size_bytes,omitempty"`
+
+	SelfTest *SelfTest `json:"self_test,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// SelfTest is the result of the self-test of a stick.
+type SelfTest struct {
+	Passed     bool    `json:"passed"`
+	Inferences int     `json:"inferences,omitempty"`
+	LatencyMS  float64 `json:"avg_latency_ms,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+const notFound = `no Neural Compute Sticks found.  Check that:
+  - the stick is listed by lsusb, with vendor id 03e7
+  - the udev rules installed by the NCSDK give this user access to it
+  - no other process has the stick open
+`
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	index := flag.Int("device", -1, "report only the stick at this `index`")
+	selftest := flag.Bool("selftest", false, "run a self-test on each stick")
+	graph := flag.String("graph", "", "compiled graph `file` to run during the self-test")
+	n := flag.Int("n", 10, "`count` of inferences run by the self-test")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	infos, err := mvnc.ListDevices()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mvnc-devices: %v\n", err)
+	}
+
+	var devices []Device
+	for _, info := range infos {
+		if *index >= 0 && info.Index != *index {
+			continue
+		}
+
+		d := report(info)
+		if *selftest {
+			d.SelfTest = test(info.Index, *graph, *n)
+		}
+		devices = append(devices, d)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if devices == nil {
+			devices = []Device{}
+		}
+		enc.Encode(devices)
+	} else {
+		print(devices)
+	}
+
+	switch {
+	case len(devices) == 0 && *index >= 0:
+		fmt.Fprintf(os.Stderr, "mvnc-devices: no stick at index %d\n", *index)
+		os.Exit(1)
+	case len(devices) == 0:
+		fmt.Fprint(os.Stderr, "mvnc-devices: "+notFound)
+		os.Exit(1)
+	}
+	for _, d := range devices {
+		if d.Error != "" || (d.SelfTest != nil && !d.SelfTest.Passed) {
+			os.Exit(1)
+		}
+	}
+}
+
+// report opens the stick and reads its firmware version and telemetry.
+func report(info mvnc.DeviceInfo) Device {
+	d := Device{Index: info.Index, Name: info.Name, Hardware: info.Hardware.String()}
+
+	device, err := mvnc.OpenDevice(info.Index)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	defer device.Close()
+	d.Hardware = device.Hardware.String()
+
+	v, err := device.FirmwareVersion()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Firmware = v.String()
+
+	t, err := device.Telemetry()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	if len(t.Temperatures) > 0 {
+		d.Temperature = t.Temperatures[0]
+	}
+	d.Throttling = t.Throttling.String()
+	d.MemoryUsed, d.MemorySize = t.MemoryUsed, t.MemorySize
+
+	return d
+}
+
+// test runs the self-test on the stick at index.
+func test(index int, graphFile string, n int) *SelfTest {
+	st := &SelfTest{}
+	if err := selfTest(index, graphFile, n, st); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Passed = true
+	return st
+}
+
+func selfTest(index int, graphFile string, n int, st *SelfTest) error {
+	device, err := mvnc.OpenDevice(index)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	if _, err := device.Telemetry(); err != nil {
+		return err
+	}
+	if graphFile == "" {
+		return nil
+	}
+
+	g := &mvnc.Graph{
+		GraphFile: graphFile,
+		Device:    device,
+		Logger:    log.New(ioutil.Discard, "", 0),
+	}
+	defer g.Close()
+
+	desc, err := g.InputDescriptor()
+	if err != nil {
+		return err
+	}
+
+	input := make([]float32, desc.W*desc.H*desc.C)
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+
+	var want []float32
+	var total time.Duration
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		output, err := g.Infer(context.Background(), input)
+		if err != nil {
+			return fmt.Errorf("inference %d failed: %w", i+1, err)
+		}
+		total += time.Since(start)
+		st.Inferences++
+
+		for j, v := range output {
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				return fmt.Errorf("inference %d: output %d is %v", i+1, j, v)
+			}
+		}
+
+		if want == nil {
+			want = output
+		} else if !equal(output, want) {
+			return fmt.Errorf("inference %d: output differs from the first for the same input", i+1)
+		}
+	}
+	if st.Inferences > 0 {
+		st.LatencyMS = float64(total) / float64(st.Inferences) / float64(time.Millisecond)
+	}
+
+	return nil
+}
+
+func equal(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// print writes the report as a table.
+func print(devices []Device) {
+	if v, err := mvnc.APIVersion(); err == nil {
+		fmt.Printf("NCAPI %v\n\n", v)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tNAME\tHARDWARE\tFIRMWARE\tTEMP\tTHROTTLING\tMEMORY\tSELF-TEST")
+	for _, d := range devices {
+		if d.Error != "" {
+			fmt.Fprintf(w, "%d\t%s\terror: %s\n", d.Index, d.Name, d.Error)
+			continue
+		}
+
+		test := "-"
+		switch {
+		case d.SelfTest == nil:
+		case !d.SelfTest.Passed:
+			test = "FAILED: " + d.SelfTest.Error
+		case d.SelfTest.Inferences > 0:
+			test = fmt.Sprintf("passed (%d inferences, %.2fms)", d.SelfTest.Inferences, d.SelfTest.LatencyMS)
+		default:
+			test = "passed"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.1f°C\t%s\t%s / %s\t%s\n", d.Index, d.Name, d.Hardware, d.Firmware,
+			d.Temperature, d.Throttling, megabytes(d.MemoryUsed), megabytes(d.MemorySize), test)
+	}
+	w.Flush()
+}
+
+func megabytes(n int) string {
+	return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+}
@@ -0,0 +1,62 @@
+// Command mvnc-golden checks the decoding of recorded output tensors against
+// the detections expected of them, so that changes to the postprocessing,
+// such as SSD and YOLO decoding, NMS and thresholds, can be checked without
+// a stick.  go test runs the same checks on testdata/golden; this command
+// runs them on any directory of cases.  Build it with the mvnc_stub tag on
+// machines without the NCSDK:
+//
+//	go run -tags mvnc_stub ./cmd/mvnc-golden testdata/golden
+//
+// Each JSON file in the directory is a case: the config of the graph, as
+// read by mvnc.ParseConfig, the output tensor, and the names, confidences
+// and boxes Graph.Decode is expected to return for it.  With -update, the
+// expected results are rewritten from the current decoding instead, to be
+// reviewed before committing.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/donniet/mvnc/internal/golden"
+)
+
+func main() {
+	update := flag.Bool("update", false, "rewrite the expected results from the current decoding")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: mvnc-golden [-update] directory\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	paths, err := golden.Files(flag.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		if err := golden.Check(path, *update); err != nil {
+			fmt.Printf("FAIL %s: %v\n", filepath.Base(path), err)
+			failed++
+		} else {
+			fmt.Printf("ok   %s\n", filepath.Base(path))
+		}
+	}
+
+	if failed > 0 {
+		fatalf("%d of %d cases failed", failed, len(paths))
+	}
+}
+
+func fatalf(format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, "mvnc-golden: "+format+"\n", v...)
+	os.Exit(1)
+}
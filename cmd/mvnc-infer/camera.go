@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/donniet/mvnc"
+	"github.com/donniet/mvnc/rtsp"
+)
+
+// openCamera opens an rtsp:// stream, an http:// or https:// MJPEG stream,
+// a V4L2 device or a unix:// socket frames are streamed to, as a reader of
+// raw RGB frames of width by height pixels.
+func openCamera(url string, width, height int) (io.ReadCloser, error) {
+	switch {
+	case isURL(url, "rtsp"):
+		// H.264 streams need ffmpeg, but Motion JPEG streams can do without
+		var dec rtsp.Decoder
+		ffmpeg, err := rtsp.NewFFmpegDecoder(width, height)
+		if err == nil {
+			dec = ffmpeg
+		}
+
+		src, err := rtsp.Dial(url, dec)
+		if err != nil {
+			if ffmpeg != nil {
+				ffmpeg.Close()
+			}
+			return nil, err
+		}
+		return &readCloser{
+			Reader: &mvnc.ImageReader{Width: width, Height: height, Next: src.Next},
+			close: func() error {
+				err := src.Close()
+				if ffmpeg != nil {
+					ffmpeg.Close()
+				}
+				return err
+			},
+		}, nil
+
+	case isURL(url, "http"), isURL(url, "https"):
+		return mvnc.OpenMJPEG(url, width, height)
+
+	case strings.HasPrefix(url, "/dev/"):
+		return openV4L2(url, width, height)
+
+	case isURL(url, "unix"):
+		srv, err := mvnc.ListenFrames("unix", url[len("unix://"):])
+		if err != nil {
+			return nil, err
+		}
+		return &readCloser{Reader: mvnc.NewImageReader(srv, width, height), close: srv.Close}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported camera '%s', expected an rtsp://, http://, unix:// or /dev/ url", url)
+	}
+}
+
+// readCloser is a reader closed by calling close.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r *readCloser) Close() error {
+	return r.close()
+}
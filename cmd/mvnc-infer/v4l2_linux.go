@@ -0,0 +1,17 @@
+package main
+
+import (
+	"io"
+
+	"github.com/donniet/mvnc/v4l2"
+)
+
+// openV4L2 captures YUYV frames from a V4L2 device, which nearly every
+// webcam supports.
+func openV4L2(path string, width, height int) (io.ReadCloser, error) {
+	c, err := v4l2.Open(path, width, height, v4l2.YUYV)
+	if err != nil {
+		return nil, err
+	}
+	return &readCloser{Reader: c.Reader(width, height), close: c.Close}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func openV4L2(path string, width, height int) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("cannot open %s: V4L2 devices are only supported on Linux", path)
+}
@@ -0,0 +1,619 @@
+package mvnc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config describes a graph and where its results go, so that a deployment
+// can be described in a file rather than in Go.  LoadConfig reads it from
+// JSON or YAML, using the field names in the json tags:
+//
+//	graph: mobilenet-ssd.graph
+//	labels: labels.txt
+//	output_format: ssd
+//	threshold: 0.5
+//	thresholds: {person: 0.7}
+//	preprocess: {mean: [127.5], scale: [0.007843]}
+//	device: {name: "1.3-ma2480"}
+//	input_fifo: {depth: 4, data_type: fp16}
+//	throttle: 200ms
+//	backpressure: drop-oldest
+//	sinks:
+//	  jsonl: detections.jsonl
+//	  mqtt: {broker: "tcp://broker:1883", topic: "cameras/door/{name}", qos: 1}
+//	  webhook: {url: "http://homeassistant:8123/api/webhook/door"}
+type Config struct {
+	// Graph is the path of the compiled graph file, and Name and Executors
+	// those of Graph.
+	Graph     string `json:"graph"`
+	Name      string `json:"name,omitempty"`
+	Executors int    `json:"executors,omitempty"`
+
+	// Labels is the path of a label file, read with LoadLabels, and Names
+	// lists names by class index in the file itself.  Names take
+	// precedence over labels for the same index.
+	Labels string         `json:"labels,omitempty"`
+	Names  map[int]string `json:"names,omitempty"`
+
+	// OutputFormat is classification, ssd, yolo, embedding, segmentation,
+	// heatmap, keypoints, attributes or the name of a Postprocessor
+	// registered with RegisterPostprocessor.  It defaults to
+	// classification.
+	OutputFormat string           `json:"output_format,omitempty"`
+	YOLO         *YOLOConfig      `json:"yolo,omitempty"`
+	Map          *MapConfig       `json:"map,omitempty"`
+	Keypoints    *KeypointConfig  `json:"keypoints,omitempty"`
+	Attributes   *AttributeConfig `json:"attributes,omitempty"`
+
+	Threshold  float32            `json:"threshold,omitempty"`
+	Thresholds map[string]float32 `json:"thresholds,omitempty"`
+	TopK       int                `json:"top_k,omitempty"`
+	Softmax    bool               `json:"softmax,omitempty"`
+	Smoothing  *Smoothing         `json:"smoothing,omitempty"`
+	Tracker    *Tracker           `json:"tracker,omitempty"`
+
+	// Calibration is the path of a calibration file, read with
+	// LoadCalibration, and Temperature a temperature to calibrate with
+	// otherwise, or in place of the file's.
+	Calibration string  `json:"calibration,omitempty"`
+	Temperature float32 `json:"temperature,omitempty"`
+
+	// Remap renames classes, and Include and Exclude keep or drop the
+	// classes they list, by their names after remapping.
+	Remap   map[string]string `json:"remap,omitempty"`
+	Include []string          `json:"include,omitempty"`
+	Exclude []string          `json:"exclude,omitempty"`
+
+	// Zones are regions of the frame with rules for the boxes in them, as
+	// described by Graph.Zones.
+	Zones       []Zone `json:"zones,omitempty"`
+	OnlyInZones bool   `json:"only_in_zones,omitempty"`
+
+	// Mean and Stddev, or Preprocess, describe the normalization of the
+	// input pixels.
+	Mean       float32           `json:"mean,omitempty"`
+	Stddev     float32           `json:"stddev,omitempty"`
+	Preprocess *PreprocessConfig `json:"preprocess,omitempty"`
+
+	// Width and Height are the size of the raw frames, PixelFormat is
+	// rgb24, i420 or nv12, and Framing is fixed or length-prefixed.
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	PixelFormat string `json:"pixel_format,omitempty"`
+	Framing     string `json:"framing,omitempty"`
+
+	// ROI is the region of interest of the frames, as [x0, y0, x1, y1]
+	// in pixels.
+	ROI []int `json:"roi,omitempty"`
+
+	// Fit is crop or letterbox, and PadColor the letterbox's [r, g, b].
+	Fit      string `json:"fit,omitempty"`
+	PadColor []int  `json:"pad_color,omitempty"`
+
+	Device     DeviceConfig `json:"device"`
+	InputFifo  FifoSettings `json:"input_fifo"`
+	OutputFifo FifoSettings `json:"output_fifo"`
+
+	// Throttle is a duration such as "200ms", and Backpressure is
+	// drop-latest, drop-oldest or block.
+	Throttle     Duration `json:"throttle,omitempty"`
+	PaceReads    bool     `json:"pace_reads,omitempty"`
+	Backpressure string   `json:"backpressure,omitempty"`
+	WarmupFrames int      `json:"warmup_frames,omitempty"`
+
+	// InferenceTimeout is a duration such as "2s".
+	InferenceTimeout Duration `json:"inference_timeout,omitempty"`
+
+	Sinks SinkConfig `json:"sinks"`
+
+	// Snapshots, if set, saves snapshots of the frames with detections.
+	Snapshots *SnapshotConfig `json:"snapshots,omitempty"`
+
+	// Clips, if set, saves clips of the frames around detections.
+	Clips *ClipConfig `json:"clips,omitempty"`
+}
+
+// SnapshotConfig is the configuration of a Snapshotter.  Interval is a
+// duration such as "10s".
+type SnapshotConfig struct {
+	Dir      string   `json:"dir"`
+	Template string   `json:"template,omitempty"`
+	Quality  int      `json:"quality,omitempty"`
+	Crop     bool     `json:"crop,omitempty"`
+	Names    []string `json:"names,omitempty"`
+	Interval Duration `json:"interval,omitempty"`
+}
+
+// ClipConfig is the configuration of a Clipper.  Before, After and
+// MaxDuration are durations such as "5s".
+type ClipConfig struct {
+	Dir         string   `json:"dir"`
+	Template    string   `json:"template,omitempty"`
+	Before      Duration `json:"before,omitempty"`
+	After       Duration `json:"after,omitempty"`
+	MaxDuration Duration `json:"max_duration,omitempty"`
+	Sequence    bool     `json:"sequence,omitempty"`
+	Quality     int      `json:"quality,omitempty"`
+	Names       []string `json:"names,omitempty"`
+}
+
+// PreprocessConfig is the configuration of a Preprocess.  Mean and Scale
+// have either one value, used for every channel, or one for each of R, G
+// and B.  Scale defaults to 1.  Order is rgb or bgr, and Layout hwc or chw.
+type PreprocessConfig struct {
+	Mean   []float32 `json:"mean,omitempty"`
+	Scale  []float32 `json:"scale,omitempty"`
+	Order  string    `json:"order,omitempty"`
+	Layout Layout    `json:"layout,omitempty"`
+}
+
+// DeviceConfig selects the stick a Config's graph runs on, as Graph's
+// DeviceIndex and DeviceName do.
+type DeviceConfig struct {
+	Index int    `json:"index,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// FifoSettings is the configuration of a FifoConfig.  DataType is fp32 or
+// fp16.
+type FifoSettings struct {
+	Depth    int    `json:"depth,omitempty"`
+	DataType string `json:"data_type,omitempty"`
+}
+
+// SinkConfig describes where the results of a Config's graph are sent.
+type SinkConfig struct {
+	// JSONL is the path of a file the detections are appended to as JSON
+	// Lines, or "-" for standard output.
+	JSONL string `json:"jsonl,omitempty"`
+
+	// MQTT is connected by the mqtt package's Attach.
+	MQTT *MQTTConfig `json:"mqtt,omitempty"`
+
+	// Webhook posts the detections to a URL.
+	Webhook *WebhookConfig `json:"webhook,omitempty"`
+
+	// Buffer and Backpressure, drop-latest, drop-oldest or block, are the
+	// SinkOptions of each sink.
+	Buffer       int    `json:"buffer,omitempty"`
+	Backpressure string `json:"backpressure,omitempty"`
+}
+
+// Options returns the SinkOptions described by s.
+func (s SinkConfig) Options() (SinkOptions, error) {
+	b, err := parseBackpressure(s.Backpressure)
+	if err != nil {
+		return SinkOptions{}, fmt.Errorf("sinks: %w", err)
+	}
+	return SinkOptions{Buffer: s.Buffer, Backpressure: b}, nil
+}
+
+// MQTTConfig describes an MQTT broker and topic detections are published
+// to.  See the mqtt package for the meaning of its fields.
+type MQTTConfig struct {
+	Broker   string `json:"broker"`
+	ClientID string `json:"client_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	QoS      byte   `json:"qos,omitempty"`
+	Retain   bool   `json:"retain,omitempty"`
+	Source   string `json:"source,omitempty"`
+
+	// HomeAssistant announces the classes to Home Assistant by MQTT
+	// discovery.  Topic may then be empty.
+	HomeAssistant *HomeAssistantConfig `json:"home_assistant,omitempty"`
+}
+
+// HomeAssistantConfig describes the Home Assistant sensors of an MQTT sink.
+// See the mqtt package's HomeAssistant for the meaning of its fields.
+// NodeID defaults to the sink's Source, or else its ClientID, Classes to
+// the names of the graph, and OffDelay is a duration such as "5s".
+type HomeAssistantConfig struct {
+	Prefix      string   `json:"prefix,omitempty"`
+	NodeID      string   `json:"node_id,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Classes     []string `json:"classes,omitempty"`
+	DeviceClass string   `json:"device_class,omitempty"`
+	OffDelay    Duration `json:"off_delay,omitempty"`
+}
+
+// WebhookConfig describes a Webhook.  Snapshots also posts the snapshots
+// of the Config's Snapshots, which must be set, and Timeout is a duration
+// such as "10s".
+type WebhookConfig struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	MaxAttempts int      `json:"max_attempts,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty"`
+	Snapshots   bool     `json:"snapshots,omitempty"`
+}
+
+// Duration is a time.Duration read from a string such as "1.5s", or from a
+// number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig reads a Config from a JSON or YAML file, chosen by its
+// extension.  Relative paths in the file are resolved against the file's
+// directory.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c *Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		c, err = ParseYAMLConfig(b)
+	default:
+		c, err = ParseConfig(b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config from %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p *string) {
+		if *p != "" && *p != "-" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	resolve(&c.Graph)
+	resolve(&c.Labels)
+	resolve(&c.Calibration)
+	resolve(&c.Sinks.JSONL)
+	if c.Snapshots != nil {
+		resolve(&c.Snapshots.Dir)
+	}
+	if c.Clips != nil {
+		resolve(&c.Clips.Dir)
+	}
+
+	return c, nil
+}
+
+// ParseConfig parses a Config from JSON.  Unknown fields are an error, so
+// that misspelt settings are not silently ignored.
+func ParseConfig(b []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	c := &Config{}
+	if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseYAMLConfig parses a Config from YAML.  Only the block and flow
+// mappings, sequences and scalars configuration needs are supported; anchors,
+// tags and multi-line strings are not.
+func ParseYAMLConfig(b []byte) (*Config, error) {
+	v, err := parseYAML(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(j)
+}
+
+// NewGraph returns the graph described by c, with the labels loaded and the
+// JSONL and webhook sinks, if any, attached with AddSink.  The sinks are
+// closed, and the JSONL file with them, by the graph's Shutdown.
+func (c *Config) NewGraph() (*Graph, error) {
+	if c.Graph == "" {
+		return nil, fmt.Errorf("config has no graph file")
+	}
+
+	f := &Graph{
+		GraphFile:        c.Graph,
+		YOLO:             c.YOLO,
+		Map:              c.Map,
+		Keypoints:        c.Keypoints,
+		Attributes:       c.Attributes,
+		Threshold:        c.Threshold,
+		NamedThresholds:  c.Thresholds,
+		TopK:             c.TopK,
+		Softmax:          c.Softmax,
+		Remap:            c.Remap,
+		Include:          c.Include,
+		Exclude:          c.Exclude,
+		Zones:            c.Zones,
+		OnlyInZones:      c.OnlyInZones,
+		Smoothing:        c.Smoothing,
+		Tracker:          c.Tracker,
+		Mean:             c.Mean,
+		Stddev:           c.Stddev,
+		Width:            c.Width,
+		Height:           c.Height,
+		DeviceIndex:      c.Device.Index,
+		DeviceName:       c.Device.Name,
+		Throttle:         time.Duration(c.Throttle),
+		PaceReads:        c.PaceReads,
+		WarmupFrames:     c.WarmupFrames,
+		InferenceTimeout: time.Duration(c.InferenceTimeout),
+		Name:             c.Name,
+		Executors:        c.Executors,
+	}
+
+	f.Names = make(map[int]string)
+	if c.Labels != "" {
+		names, err := LoadLabels(c.Labels)
+		if err != nil {
+			return nil, err
+		}
+		f.Names = names
+	}
+	for i, name := range c.Names {
+		f.Names[i] = name
+	}
+
+	if c.Calibration != "" {
+		cal, err := LoadCalibration(c.Calibration)
+		if err != nil {
+			return nil, err
+		}
+		f.Calibration = cal
+	}
+	if c.Temperature != 0 {
+		if f.Calibration == nil {
+			f.Calibration = &Calibration{}
+		}
+		f.Calibration.Temperature = c.Temperature
+	}
+
+	if len(c.ROI) == 4 {
+		f.ROI = image.Rect(c.ROI[0], c.ROI[1], c.ROI[2], c.ROI[3])
+	} else if len(c.ROI) != 0 {
+		return nil, fmt.Errorf("roi has %d values, expected 4", len(c.ROI))
+	}
+
+	for _, z := range c.Zones {
+		if len(z.Polygon) < 3 {
+			return nil, fmt.Errorf("zone %s has %d points, expected at least 3", z.Name, len(z.Polygon))
+		}
+	}
+
+	if len(c.PadColor) == 3 {
+		f.PadColor = color.RGBA{uint8(c.PadColor[0]), uint8(c.PadColor[1]), uint8(c.PadColor[2]), 255}
+	} else if len(c.PadColor) != 0 {
+		return nil, fmt.Errorf("pad_color has %d values, expected 3", len(c.PadColor))
+	}
+
+	var err error
+	if fn, ok := registeredPostprocessor(c.OutputFormat); ok {
+		if f.Postprocessor, err = fn(f); err != nil {
+			return nil, fmt.Errorf("output format %s: %w", c.OutputFormat, err)
+		}
+	} else if f.OutputFormat, err = parseOutputFormat(c.OutputFormat); err != nil {
+		return nil, err
+	}
+	if err := f.checkFormat(); err != nil {
+		return nil, err
+	}
+	if f.PixelFormat, err = parsePixelFormat(c.PixelFormat); err != nil {
+		return nil, err
+	}
+	if f.Framing, err = parseFraming(c.Framing); err != nil {
+		return nil, err
+	}
+	if f.Backpressure, err = parseBackpressure(c.Backpressure); err != nil {
+		return nil, err
+	}
+	if f.Fit, err = parseFit(c.Fit); err != nil {
+		return nil, err
+	}
+	if f.InputFifo, err = c.InputFifo.fifoConfig(); err != nil {
+		return nil, fmt.Errorf("input fifo: %w", err)
+	}
+	if f.OutputFifo, err = c.OutputFifo.fifoConfig(); err != nil {
+		return nil, fmt.Errorf("output fifo: %w", err)
+	}
+	if c.Preprocess != nil {
+		if f.Preprocess, err = c.Preprocess.preprocess(); err != nil {
+			return nil, fmt.Errorf("preprocess: %w", err)
+		}
+	}
+
+	if c.Snapshots != nil {
+		if f.Snapshots, err = NewSnapshotter(c.Snapshots.Dir, c.Snapshots.Template); err != nil {
+			return nil, err
+		}
+		f.Snapshots.Quality = c.Snapshots.Quality
+		f.Snapshots.Crop = c.Snapshots.Crop
+		f.Snapshots.Names = c.Snapshots.Names
+		f.Snapshots.Interval = time.Duration(c.Snapshots.Interval)
+	}
+	if c.Clips != nil {
+		if f.Clips, err = NewClipper(c.Clips.Dir, c.Clips.Template, time.Duration(c.Clips.Before), time.Duration(c.Clips.After)); err != nil {
+			return nil, err
+		}
+		f.Clips.MaxDuration = time.Duration(c.Clips.MaxDuration)
+		f.Clips.Sequence = c.Clips.Sequence
+		f.Clips.Quality = c.Clips.Quality
+		f.Clips.Names = c.Clips.Names
+	}
+
+	sinkOpts, err := c.Sinks.Options()
+	if err != nil {
+		return nil, err
+	}
+	if c.Sinks.Webhook != nil && c.Sinks.Webhook.Snapshots && f.Snapshots == nil {
+		return nil, fmt.Errorf("webhook snapshots need snapshots to be configured")
+	}
+	if c.Sinks.JSONL == "-" {
+		// hide the Close of os.Stdout from the JSONLWriter
+		f.sinks = append(f.sinks, f.AddSink(NewJSONLWriter(struct{ io.Writer }{os.Stdout}), sinkOpts))
+	} else if c.Sinks.JSONL != "" {
+		w, err := os.OpenFile(c.Sinks.JSONL, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		f.sinks = append(f.sinks, f.AddSink(NewJSONLWriter(w), sinkOpts))
+	}
+	if c.Sinks.Webhook != nil {
+		w := NewWebhook(c.Sinks.Webhook.URL, c.Sinks.Webhook.Secret)
+		w.MaxAttempts = c.Sinks.Webhook.MaxAttempts
+		if c.Sinks.Webhook.Timeout > 0 {
+			w.Client = &http.Client{Timeout: time.Duration(c.Sinks.Webhook.Timeout)}
+		}
+
+		var s Sink = w
+		if c.Sinks.Webhook.Snapshots {
+			s = postSnapshots(f, w, f.Snapshots)
+		}
+		f.sinks = append(f.sinks, f.AddSink(s, sinkOpts))
+	}
+
+	return f, nil
+}
+
+func (s FifoSettings) fifoConfig() (FifoConfig, error) {
+	fc := FifoConfig{Depth: s.Depth}
+	switch strings.ToLower(s.DataType) {
+	case "", "fp32":
+		fc.DataType = FP32
+	case "fp16":
+		fc.DataType = FP16
+	default:
+		return fc, fmt.Errorf("unknown data type '%s'", s.DataType)
+	}
+	return fc, nil
+}
+
+func (c *PreprocessConfig) preprocess() (*Preprocess, error) {
+	p := &Preprocess{Scale: [3]float32{1, 1, 1}}
+
+	channels := func(dst *[3]float32, v []float32, name string) error {
+		switch len(v) {
+		case 0:
+		case 1:
+			*dst = [3]float32{v[0], v[0], v[0]}
+		case 3:
+			copy(dst[:], v)
+		default:
+			return fmt.Errorf("%s has %d values, expected 1 or 3", name, len(v))
+		}
+		return nil
+	}
+	if err := channels(&p.Mean, c.Mean, "mean"); err != nil {
+		return nil, err
+	}
+	if err := channels(&p.Scale, c.Scale, "scale"); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(c.Order) {
+	case "", "rgb":
+		p.Order = RGB
+	case "bgr":
+		p.Order = BGR
+	default:
+		return nil, fmt.Errorf("unknown channel order '%s'", c.Order)
+	}
+
+	p.Layout = c.Layout
+	return p, nil
+}
+
+func parseOutputFormat(s string) (OutputFormat, error) {
+	for _, o := range []OutputFormat{Classification, SSD, YOLO, Embedding, Segmentation, Heatmap, Keypoints, Attributes} {
+		if strings.EqualFold(s, o.String()) {
+			return o, nil
+		}
+	}
+	if s == "" {
+		return Classification, nil
+	}
+	return 0, fmt.Errorf("unknown output format '%s'", s)
+}
+
+func parsePixelFormat(s string) (PixelFormat, error) {
+	for _, p := range []PixelFormat{RGB24, I420, NV12} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	if s == "" {
+		return RGB24, nil
+	}
+	return 0, fmt.Errorf("unknown pixel format '%s'", s)
+}
+
+func parseFraming(s string) (Framing, error) {
+	switch strings.ToLower(s) {
+	case "", "fixed":
+		return FixedSize, nil
+	case "length-prefixed":
+		return LengthPrefixed, nil
+	default:
+		return 0, fmt.Errorf("unknown framing '%s'", s)
+	}
+}
+
+func parseBackpressure(s string) (Backpressure, error) {
+	for _, b := range []Backpressure{DropLatest, DropOldest, Block} {
+		if strings.EqualFold(s, b.String()) {
+			return b, nil
+		}
+	}
+	if s == "" {
+		return DropLatest, nil
+	}
+	return 0, fmt.Errorf("unknown backpressure '%s'", s)
+}
+
+func parseFit(s string) (Fit, error) {
+	for _, f := range []Fit{Crop, Letterbox} {
+		if strings.EqualFold(s, f.String()) {
+			return f, nil
+		}
+	}
+	if s == "" {
+		return Crop, nil
+	}
+	return 0, fmt.Errorf("unknown fit '%s'", s)
+}
@@ -0,0 +1,173 @@
+package mvnc
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// HardwareVersion is the VPU a stick is built around.
+type HardwareVersion int
+
+const (
+	// Myriad2 is the MA2450 of the original Neural Compute Stick.
+	Myriad2 HardwareVersion = iota
+
+	// MyriadX is the MA2480 of the Neural Compute Stick 2, which can run a
+	// graph on several executors at once.
+	MyriadX
+)
+
+func (h HardwareVersion) String() string {
+	switch h {
+	case Myriad2:
+		return "Myriad 2"
+	case MyriadX:
+		return "Myriad X"
+	default:
+		return fmt.Sprintf("HardwareVersion(%d)", int(h))
+	}
+}
+
+// hardwareOf returns the hardware of a stick from its name, which libmvnc
+// ends with the VPU's part number, since the NCAPI only reports it once the
+// stick is open.
+func hardwareOf(name string) HardwareVersion {
+	if strings.HasSuffix(name, "ma2480") {
+		return MyriadX
+	}
+	return Myriad2
+}
+
+// DeviceInfo describes a Neural Compute Stick attached to the host.
+type DeviceInfo struct {
+	Index    int
+	Name     string
+	Hardware HardwareVersion
+}
+
+// ListDevices enumerates the sticks currently attached to the host, in index
+// order.  The devices are not opened, so this is safe to call while other
+// processes are using them.
+func ListDevices() ([]DeviceInfo, error) {
+	var infos []DeviceInfo
+
+	for i := 0; ; i++ {
+		handle, ret := api.deviceCreate(i)
+		if ret == ErrDeviceNotFound {
+			break
+		} else if ret != OK {
+			return infos, fmt.Errorf("could not create device %d: %w", i, errorFor(ret))
+		}
+
+		name, err := deviceName(handle)
+		api.deviceDestroy(handle)
+
+		if err != nil {
+			return infos, fmt.Errorf("could not get name of device %d: %w", i, err)
+		}
+
+		infos = append(infos, DeviceInfo{Index: i, Name: name, Hardware: hardwareOf(name)})
+	}
+
+	return infos, nil
+}
+
+func deviceName(handle deviceHandle) (string, error) {
+	name, ret := api.deviceName(handle)
+	return name, errorFor(ret)
+}
+
+// Device is an opened Neural Compute Stick.  A Device may be shared by
+// several Graphs through their Device field; allocating and destroying
+// graphs on it is serialized, while inferences on the graphs run
+// independently through their own fifos.
+type Device struct {
+	Index    int
+	Name     string
+	Hardware HardwareVersion
+
+	mu     sync.Mutex // held while allocating or destroying a graph
+	handle deviceHandle
+}
+
+// OpenDevice opens the stick at the given index, as reported by ListDevices.
+func OpenDevice(index int) (*Device, error) {
+	d := &Device{Index: index}
+
+	var ret Status
+	if d.handle, ret = api.deviceCreate(index); ret != OK {
+		return nil, fmt.Errorf("could not create device %d: %w", index, errorFor(ret))
+	}
+
+	name, err := deviceName(d.handle)
+	if err != nil {
+		api.deviceDestroy(d.handle)
+		return nil, fmt.Errorf("could not get name of device %d: %w", index, err)
+	}
+	d.Name = name
+
+	if ret := api.deviceOpen(d.handle); ret != OK {
+		api.deviceDestroy(d.handle)
+		return nil, fmt.Errorf("could not open device %d: %w", index, errorFor(ret))
+	}
+
+	if hw, ret := api.deviceIntOption(d.handle, optHardwareVersion); ret == OK {
+		d.Hardware = HardwareVersion(hw)
+	} else {
+		d.Hardware = hardwareOf(name)
+	}
+
+	runtime.SetFinalizer(d, (*Device).finalize)
+	return d, nil
+}
+
+// OpenDeviceByName opens the stick with the given name, as reported by
+// ListDevices.
+func OpenDeviceByName(name string) (*Device, error) {
+	infos, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.Name == name {
+			return OpenDevice(info.Index)
+		}
+	}
+
+	return nil, fmt.Errorf("could not find device named '%s': %w", name, ErrDeviceNotFound)
+}
+
+// Close closes and destroys the device handle.  Any graphs sharing the
+// device must be closed first.
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handle == nil {
+		return nil
+	}
+
+	var err error
+	if ret := api.deviceClose(d.handle); ret != OK {
+		err = fmt.Errorf("could not close device %d: %w", d.Index, errorFor(ret))
+	}
+	if ret := api.deviceDestroy(d.handle); ret != OK && err == nil {
+		err = fmt.Errorf("could not destroy device %d: %w", d.Index, errorFor(ret))
+	}
+
+	d.handle = nil
+	return err
+}
+
+// finalize closes a device dropped without being closed, since until its
+// handle is closed the stick cannot be opened again without replugging it.
+func (d *Device) finalize() {
+	if d.handle != nil {
+		log.Printf("mvnc: device %d was not closed", d.Index)
+		d.Close()
+	}
+}
@@ -0,0 +1,140 @@
+package mvnc
+
+import "fmt"
+
+// ErrAlreadyRunning is reported by Process when it is called on a graph it
+// is already running on.
+var ErrAlreadyRunning = fmt.Errorf("graph is already being processed")
+
+// Status is a status code returned by the NCAPI.  Every status other than OK
+// is an error, so failures can be tested for with errors.Is, for example
+// errors.Is(err, mvnc.ErrDeviceNotFound).
+type Status int
+
+// The values of ncStatus_t.
+const (
+	OK                              Status = 0
+	ErrBusy                         Status = -1
+	ErrError                        Status = -2
+	ErrOutOfMemory                  Status = -3
+	ErrDeviceNotFound               Status = -4
+	ErrInvalidParameters            Status = -5
+	ErrTimeout                      Status = -6
+	ErrMvcmdNotFound                Status = -7
+	ErrNotAllocated                 Status = -8
+	ErrUnauthorized                 Status = -9
+	ErrUnsupportedGraphFile         Status = -10
+	ErrUnsupportedConfigurationFile Status = -11
+	ErrUnsupportedFeature           Status = -12
+	ErrMyriadError                  Status = -13
+	ErrInvalidDataLength            Status = -14
+	ErrInvalidHandle                Status = -15
+)
+
+// ErrNoBackend is returned by every call to the NCAPI when the package was
+// built with the mvnc_stub tag, without libmvnc, unless UseFake is in use.
+const ErrNoBackend Status = -1000
+
+func (s Status) Error() string {
+	switch s {
+	case OK:
+		return "NC_OK: The function call worked as expected."
+	case ErrBusy:
+		return "NC_BUSY: The device is busy; retry later."
+	case ErrError:
+		return "NC_ERROR: An unexpected error was encountered during the function call."
+	case ErrOutOfMemory:
+		return "NC_OUT_OF_MEMORY: The host is out of memory."
+	case ErrDeviceNotFound:
+		return "NC_DEVICE_NOT_FOUND: There is no device at the given index or name."
+	case ErrInvalidParameters:
+		return "NC_INVALID_PARAMETERS: At least one of the given parameters is invalid in the context of the function call."
+	case ErrTimeout:
+		return "NC_TIMEOUT: Timeout in the communication with the device."
+	case ErrMvcmdNotFound:
+		return "NC_MVCMD_NOT_FOUND: The file to boot the device was not found. This file typically has the extension .mvcmd and should be installed during the NCSDK installation. This message may mean that the installation failed."
+	case ErrNotAllocated:
+		return "NC_NOT_ALLOCATED: The graph or fifo has not been allocated."
+	case ErrUnauthorized:
+		return "NC_UNAUTHORIZED: An unauthorized operation has been attempted."
+	case ErrUnsupportedGraphFile:
+		return "NC_UNSUPPORTED_GRAPH_FILE: The graph file may have been created with an incompatible prior version of the Toolkit. Try to recompile the graph file with the version of the Toolkit that corresponds to the API version."
+	case ErrUnsupportedConfigurationFile:
+		return "NC_UNSUPPORTED_CONFIGURATION_FILE: Unsupported configuration file"
+	case ErrUnsupportedFeature:
+		return "NC_UNSUPPORTED_FEATURE: Operation attempted a feature that is not supported by this firmware version."
+	case ErrMyriadError:
+		return "NC_MYRIAD_ERROR: An error has been reported by Intel® Movidius™ VPU. Use ncGraphGetOption() for NC_RO_GRAPH_DEBUG_INFO and ncDeviceGetOption for NC_RO_DEVICE_DEBUG_INFO to get more information on the error."
+	case ErrInvalidDataLength:
+		return "NC_INVALID_DATA_LENGTH: An invalid data length has been passed when getting or setting an option."
+	case ErrInvalidHandle:
+		return "NC_INVALID_HANDLE: An invalid handle has been passed to a function."
+	case ErrNoBackend:
+		return "no NCAPI backend: the package was built with the mvnc_stub tag, without libmvnc"
+	default:
+		return fmt.Sprintf("unknown MVNC error: '%d'", int(s))
+	}
+}
+
+func errorFor(status Status) error {
+	if status == OK {
+		return nil
+	}
+	return status
+}
+
+// DebugError is an NC_MYRIAD_ERROR together with the debug information the
+// graph and device report for it.  errors.Is(err, ErrMyriadError) holds for
+// a DebugError.
+type DebugError struct {
+	Status Status
+
+	// Graph and Device are NC_RO_GRAPH_DEBUG_INFO and NC_RO_DEVICE_DEBUG_INFO,
+	// or empty if they could not be read.
+	Graph, Device string
+}
+
+func (e *DebugError) Error() string {
+	return fmt.Sprintf("%v (graph debug info: '%s', device debug info: '%s')", e.Status, e.Graph, e.Device)
+}
+
+func (e *DebugError) Unwrap() error {
+	return e.Status
+}
+
+// errorFor is like the package's errorFor, but counts the error in the
+// graph's Stats and attaches the debug information of the graph and device
+// to an NC_MYRIAD_ERROR, read holding the device's lock so that the device
+// is not closed meanwhile.
+func (a *allocation) errorFor(status Status) error {
+	if status == ErrMyriadError {
+		a.device.mu.Lock()
+		defer a.device.mu.Unlock()
+	}
+	return a.errorForLocked(status)
+}
+
+// errorForLocked is errorFor for callers already holding a.device.mu.
+func (a *allocation) errorForLocked(status Status) error {
+	if status != OK {
+		a.stats.fail(status)
+	}
+	if status != ErrMyriadError {
+		return errorFor(status)
+	}
+
+	e := &DebugError{Status: ErrMyriadError}
+
+	if a.graph != nil {
+		if info, ret := api.graphDebugInfo(a.graph); ret == OK {
+			e.Graph = info
+		}
+	}
+	if a.device.handle != nil {
+		if info, ret := api.deviceDebugInfo(a.device.handle); ret == OK {
+			e.Device = info
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,213 @@
+package mvnc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+// Event kinds: ObjectEntered when an object is first seen, ObjectLeft when
+// it has not been seen for long enough, and DwellExceeded once when it has
+// been present for longer than the Presence's Dwell.
+const (
+	ObjectEntered EventKind = iota
+	ObjectLeft
+	DwellExceeded
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case ObjectEntered:
+		return "entered"
+	case ObjectLeft:
+		return "left"
+	case DwellExceeded:
+		return "dwell"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change in the objects present in a stream of frames, from
+// Presence.  ObjectID identifies the object across its events, and Box is
+// where it was last seen.  Time and FrameID are those of the frame which
+// caused the event, and Duration is how long the object has been present:
+// zero when it enters, unless MinFrames delayed it, and the time from its
+// first frame to its last when it leaves.
+type Event struct {
+	Kind     EventKind
+	ObjectID uint64
+	Name     string
+	Box      BoundingBox
+	FrameID  uint64
+	Time     time.Time
+	Duration time.Duration
+}
+
+// Presence turns the boxes found in a stream of frames into events as
+// objects enter, dwell and leave, rather than a detection for every frame.
+// The boxes of each frame are associated with the objects present by their
+// overlap with where each was last seen, best first, and only with objects
+// of the same name.  The zero Presence is ready to use; it is safe for
+// concurrent use, but frames must be passed to Update in order.
+type Presence struct {
+	// IoU is the overlap a box must have with an object's last box to be
+	// the same object, by default 0.3.
+	IoU float32
+
+	// MinFrames is the number of frames an object must be seen in before
+	// it enters, by default 1, so that a spurious box does not enter and
+	// leave at once.  MaxMissed is the number of frames in a row it may go
+	// unseen before it leaves, by default 5.
+	MinFrames int
+	MaxMissed int
+
+	// Dwell, if positive, is how long an object may be present before a
+	// DwellExceeded event.
+	Dwell time.Duration
+
+	mu      sync.Mutex
+	objects []*presentObject
+	lastID  uint64
+	frame   uint64
+}
+
+// presentObject is an object a Presence has seen.
+type presentObject struct {
+	id          uint64
+	box         BoundingBox
+	first, last time.Time
+	hits        int
+	missed      int
+	entered     bool
+	dwelled     bool
+}
+
+// Update associates the boxes of a frame, read at t, with the objects
+// present and returns the events they cause.  Frames older than the last
+// one passed are ignored, as the frames of a Pool may complete out of
+// order.
+func (p *Presence) Update(frameID uint64, t time.Time, boxes []BoundingBox) []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if frameID != 0 && frameID <= p.frame {
+		return nil
+	}
+	p.frame = frameID
+
+	iou, minFrames, maxMissed := p.IoU, p.MinFrames, p.MaxMissed
+	if iou == 0 {
+		iou = 0.3
+	}
+	if minFrames == 0 {
+		minFrames = 1
+	}
+	if maxMissed == 0 {
+		maxMissed = 5
+	}
+
+	// every pair of an object and a box of its name overlapping enough,
+	// best first
+	type pair struct {
+		object, box int
+		iou         float32
+	}
+	var pairs []pair
+	for i, o := range p.objects {
+		for j, b := range boxes {
+			if b.Name != o.box.Name {
+				continue
+			}
+			if v := IoU(o.box, b); v >= iou {
+				pairs = append(pairs, pair{i, j, v})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	var events []Event
+	event := func(kind EventKind, o *presentObject, d time.Duration) {
+		events = append(events, Event{Kind: kind, ObjectID: o.id, Name: o.box.Name, Box: o.box, FrameID: frameID, Time: t, Duration: d})
+	}
+
+	seen := make([]bool, len(p.objects))
+	used := make([]bool, len(boxes))
+	for _, m := range pairs {
+		if seen[m.object] || used[m.box] {
+			continue
+		}
+		seen[m.object], used[m.box] = true, true
+
+		o := p.objects[m.object]
+		o.box, o.last = boxes[m.box], t
+		o.hits++
+		o.missed = 0
+	}
+
+	for j, b := range boxes {
+		if !used[j] {
+			p.lastID++
+			p.objects = append(p.objects, &presentObject{id: p.lastID, box: b, first: t, last: t, hits: 1})
+			seen = append(seen, true)
+		}
+	}
+
+	present := p.objects[:0]
+	for i, o := range p.objects {
+		if !seen[i] {
+			if o.missed++; o.missed > maxMissed {
+				if o.entered {
+					event(ObjectLeft, o, o.last.Sub(o.first))
+				}
+				continue
+			}
+		} else if !o.entered && o.hits >= minFrames {
+			o.entered = true
+			event(ObjectEntered, o, t.Sub(o.first))
+		}
+
+		if o.entered && !o.dwelled && p.Dwell > 0 && o.last.Sub(o.first) >= p.Dwell {
+			o.dwelled = true
+			event(DwellExceeded, o, o.last.Sub(o.first))
+		}
+		present = append(present, o)
+	}
+	for i := len(present); i < len(p.objects); i++ {
+		p.objects[i] = nil
+	}
+	p.objects = present
+
+	return events
+}
+
+// Flush ends the presence of every object, returning an ObjectLeft event
+// for each that entered, as at the end of a stream at time t.
+func (p *Presence) Flush(t time.Time) []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var events []Event
+	for _, o := range p.objects {
+		if o.entered {
+			events = append(events, Event{Kind: ObjectLeft, ObjectID: o.id, Name: o.box.Name, Box: o.box, Time: t, Duration: o.last.Sub(o.first)})
+		}
+	}
+	p.objects = nil
+	return events
+}
+
+// OnEvent registers fn to be called with the events p makes of the boxes
+// of every frame run by Process, a Pool or a Multiplexer, from an OnFrame
+// handler.  The sources of a Multiplexer need a Presence each, fed from
+// their own handler.
+func (f *Graph) OnEvent(p *Presence, fn func(Event)) {
+	f.OnFrame(func(res Result) {
+		for _, e := range p.Update(res.FrameID, res.Time, res.Boxes) {
+			fn(e)
+		}
+	})
+}
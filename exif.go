@@ -0,0 +1,129 @@
+package mvnc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// jpegOrientation returns the EXIF orientation of the JPEG file in b, from 1
+// to 8, or 1 if it has none.  Orientation 1 is upright; the others are the
+// rotations and mirrorings a camera records instead of rotating the pixels.
+func jpegOrientation(b []byte) int {
+	if len(b) < 4 || b[0] != 0xff || b[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return 1
+		}
+		marker := b[i+1]
+		if marker == 0xff {
+			// a fill byte before the marker
+			i++
+			continue
+		} else if marker == 0xd8 || marker >= 0xd0 && marker <= 0xd7 || marker == 0x01 {
+			// markers without a length
+			i += 2
+			continue
+		} else if marker == 0xda {
+			// the EXIF segment comes before the start of scan
+			return 1
+		}
+
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			return 1
+		}
+		segment := b[i+4 : i+2+n]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + n
+	}
+	return 1
+}
+
+// exifOrientation returns the orientation tag of the first IFD of the TIFF
+// structure of an EXIF segment.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// orient returns img rotated and mirrored upright from the EXIF orientation
+// o, or img itself if it is already upright.
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		// orientations 5 to 8 swap the width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // mirror
+				dx, dy = w-1-x, y
+			case 3: // turn half way
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // turn clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // turn counterclockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,197 @@
+package mvnc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// exifSegment returns an APP1 segment holding an EXIF IFD of the given
+// byte order with the entries given as tag and value pairs.
+func exifSegment(order binary.AppendByteOrder, entries ...uint16) []byte {
+	tiff := []byte("II")
+	if order == binary.BigEndian {
+		tiff = []byte("MM")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, uint16(len(entries)/2))
+	for i := 0; i < len(entries); i += 2 {
+		// a SHORT value of count 1, stored in the entry
+		tiff = order.AppendUint16(tiff, entries[i])
+		tiff = order.AppendUint16(tiff, 3)
+		tiff = order.AppendUint32(tiff, 1)
+		tiff = order.AppendUint16(tiff, entries[i+1])
+		tiff = order.AppendUint16(tiff, 0)
+	}
+	tiff = order.AppendUint32(tiff, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	b := []byte{0xff, 0xe1}
+	b = binary.BigEndian.AppendUint16(b, uint16(2+len(segment)))
+	return append(b, segment...)
+}
+
+// withSegments returns the JPEG file jpg with segments inserted after its
+// start of image marker.
+func withSegments(jpg []byte, segments ...[]byte) []byte {
+	b := append([]byte(nil), jpg[:2]...)
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+	return append(b, jpg[2:]...)
+}
+
+// testJPEG returns a w by h JPEG file, black but for a white top left pixel.
+func testJPEG(t *testing.T, w, h int) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.White)
+
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	jpg := testJPEG(t, 8, 8)
+	app0 := []byte{0xff, 0xe0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0}
+
+	tests := []struct {
+		name string
+		file []byte
+		want int
+	}{
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"no EXIF", jpg, 1},
+		{"little endian", withSegments(jpg, exifSegment(binary.LittleEndian, 0x0112, 6)), 6},
+		{"big endian", withSegments(jpg, exifSegment(binary.BigEndian, 0x0112, 3)), 3},
+		{"after other tags", withSegments(jpg, exifSegment(binary.BigEndian, 0x010f, 1, 0x0110, 2, 0x0112, 8)), 8},
+		{"after APP0", withSegments(jpg, app0, exifSegment(binary.LittleEndian, 0x0112, 5)), 5},
+		{"fill bytes", withSegments(jpg, []byte{0xff}, exifSegment(binary.LittleEndian, 0x0112, 2)), 2},
+		{"no orientation tag", withSegments(jpg, exifSegment(binary.LittleEndian, 0x010f, 6)), 1},
+		{"invalid orientation", withSegments(jpg, exifSegment(binary.LittleEndian, 0x0112, 9)), 1},
+		{"not EXIF", withSegments(jpg, []byte{0xff, 0xe1, 0x00, 0x06, 'h', 't', 't', 'p'}), 1},
+		{"segment too short", withSegments(jpg, []byte{0xff, 0xe1, 0x00, 0x01}), 1},
+		{"segment too long", withSegments(jpg[:2], []byte{0xff, 0xe1, 0xff, 0xff, 'E', 'x', 'i', 'f'}), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := jpegOrientation(tt.file); o != tt.want {
+				t.Errorf("orientation %d, want %d", o, tt.want)
+			}
+		})
+	}
+}
+
+func TestEXIFOrientationCorrupt(t *testing.T) {
+	tiff := exifSegment(binary.LittleEndian, 0x0112, 6)[10:]
+
+	// the IFD past the end of the segment
+	ifd := append([]byte(nil), tiff...)
+	binary.LittleEndian.PutUint32(ifd[4:], 0xfffffff0)
+	if o := exifOrientation(ifd); o != 1 {
+		t.Errorf("orientation %d with the IFD past the end of the segment", o)
+	}
+
+	// more entries than the segment holds
+	entries := append([]byte(nil), tiff...)
+	binary.LittleEndian.PutUint16(entries[8:], 0xffff)
+	if o := exifOrientation(entries); o != 6 {
+		t.Errorf("orientation %d with the entry count overstated, want 6", o)
+	}
+	binary.LittleEndian.PutUint16(entries[10:], 0x010f)
+	if o := exifOrientation(entries); o != 1 {
+		t.Errorf("orientation %d with no orientation and the entry count overstated", o)
+	}
+
+	if o := exifOrientation([]byte("XX\x2a\x00\x08\x00\x00\x00")); o != 1 {
+		t.Errorf("orientation %d for an invalid byte order", o)
+	}
+}
+
+// TestJPEGOrientationTruncated checks that a JPEG file truncated anywhere
+// is read without a panic, as upright until the whole EXIF segment is
+// there.
+func TestJPEGOrientationTruncated(t *testing.T) {
+	exif := exifSegment(binary.BigEndian, 0x010f, 1, 0x0112, 6)
+	b := withSegments(testJPEG(t, 8, 8), exif)
+
+	for n := 0; n <= len(b); n++ {
+		want := 1
+		if n >= 2+len(exif) {
+			want = 6
+		}
+		if o := jpegOrientation(b[:n]); o != want {
+			t.Errorf("orientation %d for the first %d bytes, want %d", o, n, want)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 3 by 2 image, marked at its top left and top right
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(2, 0, color.RGBA{G: 255, A: 255})
+
+	tests := []struct {
+		o          int
+		w, h       int
+		red, green image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0), image.Pt(2, 0)},
+		{2, 3, 2, image.Pt(2, 0), image.Pt(0, 0)},
+		{3, 3, 2, image.Pt(2, 1), image.Pt(0, 1)},
+		{4, 3, 2, image.Pt(0, 1), image.Pt(2, 1)},
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 2)},
+		{6, 2, 3, image.Pt(1, 0), image.Pt(1, 2)},
+		{7, 2, 3, image.Pt(1, 2), image.Pt(1, 0)},
+		{8, 2, 3, image.Pt(0, 2), image.Pt(0, 0)},
+	}
+
+	for _, tt := range tests {
+		out := orient(img, tt.o)
+		if b := out.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d is %dx%d, want %dx%d", tt.o, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if r, _, _, _ := out.At(tt.red.X, tt.red.Y).RGBA(); r != 0xffff {
+			t.Errorf("orientation %d moved the top left pixel from %v", tt.o, tt.red)
+		}
+		if _, g, _, _ := out.At(tt.green.X, tt.green.Y).RGBA(); g != 0xffff {
+			t.Errorf("orientation %d moved the top right pixel from %v", tt.o, tt.green)
+		}
+	}
+}
+
+// TestFileSourceOrientation checks that a FileSource turns a JPEG upright.
+func TestFileSourceOrientation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "turned.jpg")
+	jpg := withSegments(testJPEG(t, 16, 8), exifSegment(binary.LittleEndian, 0x0112, 6))
+	if err := ioutil.WriteFile(path, jpg, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := NewFileSource(path).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 16 {
+		t.Errorf("decoded a %dx%d image, want 8x16", b.Dx(), b.Dy())
+	}
+
+	s := NewFileSource(path)
+	s.IgnoreOrientation = true
+	if img, err := s.Next(); err != nil {
+		t.Fatal(err)
+	} else if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Errorf("decoded a %dx%d image ignoring its orientation, want 16x8", b.Dx(), b.Dy())
+	}
+}
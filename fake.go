@@ -0,0 +1,517 @@
+package mvnc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// FakeStick is a simulated Neural Compute Stick, for testing code using the
+// package on machines with no sticks attached.  Graphs allocated on it read
+// the graph file but otherwise ignore it, and run Infer in place of the
+// stick.
+type FakeStick struct {
+	// Name is the name reported by ListDevices.  It defaults to
+	// "1.<index+1>-ma2450", or -ma2480 for a MyriadX, the form libmvnc uses
+	// for USB sticks.
+	Name     string
+	Hardware HardwareVersion
+
+	// Firmware is the firmware version reported by the stick; it defaults to
+	// 2.10.1.0.  Temperature and Throttling are reported by Telemetry.
+	Firmware    Version
+	Temperature float32
+	Throttling  ThrottlingLevel
+
+	// Input and Output are the shapes of the tensors of every graph
+	// allocated on the stick; only their N, C, W and H are used.  Input
+	// defaults to a 224 by 224 RGB image, and Output to one value.
+	Input, Output TensorDescriptor
+
+	// Inputs and Outputs, if set, replace Input and Output for graphs with
+	// several input or output tensors.
+	Inputs, Outputs []TensorDescriptor
+
+	// Infer returns the output of an inference from its input, with the
+	// tensors of graphs with several of them concatenated in order.  Outputs
+	// shorter than the output tensors are padded with zeros, and longer ones
+	// truncated.  If Infer is nil, every output is zero.  The input is
+	// recycled once Infer returns, so Infer must not keep it.
+	Infer func(input []float32) []float32
+
+	// Latency is how long each inference takes.  The stick runs one
+	// inference at a time on each of the graph's Executors, so inferences
+	// queued together complete Latency, divided by the executors, apart.
+	Latency time.Duration
+
+	// Fail, if non-nil, is called before every NCAPI call made on the stick
+	// with the name of the NCAPI function, such as "ncFifoWriteElem" or
+	// "ncGraphQueueInference".  A status other than OK fails the call with
+	// that status, for testing error handling.
+	Fail func(call string) Status
+}
+
+// UseFake replaces the NCAPI with the given simulated sticks, at indices 0
+// and up, until the returned function is called to restore it.  Neither may
+// be called while a graph or device is open.
+func UseFake(sticks ...FakeStick) (restore func()) {
+	fake := &fakeAPI{}
+	for i, s := range sticks {
+		if s.Name == "" && s.Hardware == MyriadX {
+			s.Name = fmt.Sprintf("1.%d-ma2480", i+1)
+		} else if s.Name == "" {
+			s.Name = fmt.Sprintf("1.%d-ma2450", i+1)
+		}
+		if s.Firmware == (Version{}) {
+			s.Firmware = Version{2, 10, 1, 0}
+		}
+		if s.Input.Elements() == 0 {
+			s.Input = TensorDescriptor{N: 1, C: 3, W: 224, H: 224}
+		}
+		if s.Output.Elements() == 0 {
+			s.Output = TensorDescriptor{N: 1, C: 1, W: 1, H: 1}
+		}
+		if len(s.Inputs) == 0 {
+			s.Inputs = []TensorDescriptor{s.Input}
+		}
+		if len(s.Outputs) == 0 {
+			s.Outputs = []TensorDescriptor{s.Output}
+		}
+		fake.sticks = append(fake.sticks, &fakeStick{FakeStick: s})
+	}
+
+	previous := api
+	api = fake
+	return func() { api = previous }
+}
+
+// fakeMemorySize is the memory reported by a FakeStick, that of a Movidius
+// Neural Compute Stick.
+const fakeMemorySize = 500000000
+
+type fakeAPI struct {
+	sticks []*fakeStick
+}
+
+type fakeStick struct {
+	FakeStick
+
+	mu     sync.Mutex
+	memory int       // size of the graphs allocated
+	free   time.Time // when the last inference queued completes
+}
+
+func (s *fakeStick) fail(call string) Status {
+	// s is nil for fifos that were created but never allocated
+	if s == nil || s.Fail == nil {
+		return OK
+	}
+	return s.Fail(call)
+}
+
+type fakeDevice struct {
+	stick *fakeStick
+	open  bool
+}
+
+type fakeGraph struct {
+	executors int
+	stick     *fakeStick
+	allocated int // size of the graph file, once allocated
+}
+
+type fakeFifo struct {
+	input    bool // written by the host
+	stick    *fakeStick
+	dataType DataType
+	elements int
+
+	mu      sync.Mutex
+	pending []fakeElem    // written to an input fifo, but not yet queued
+	outputs chan fakeElem // queued to an output fifo, in order
+	free    [][]float32   // the data of elements already consumed
+}
+
+// buffer returns a buffer for the data of an element of ff, recycled so
+// that a steady stream of inferences makes no allocations.
+func (ff *fakeFifo) buffer() []float32 {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	if n := len(ff.free); n > 0 {
+		b := ff.free[n-1]
+		ff.free = ff.free[:n-1]
+		return b[:ff.elements]
+	}
+	return make([]float32, ff.elements)
+}
+
+// recycle returns the data of an element consumed from ff to its buffers.
+func (ff *fakeFifo) recycle(b []float32) {
+	ff.mu.Lock()
+	ff.free = append(ff.free, b)
+	ff.mu.Unlock()
+}
+
+type fakeElem struct {
+	data  []float32
+	id    uint64
+	ready time.Time
+}
+
+func (f *fakeAPI) apiVersion() (Version, Status) {
+	return Version{2, 10, 1, 0}, OK
+}
+
+func (f *fakeAPI) logLevel() (LogLevel, Status) {
+	return LogWarn, OK
+}
+
+func (f *fakeAPI) setLogLevel(level LogLevel) Status {
+	return OK
+}
+
+func (f *fakeAPI) deviceCreate(index int) (deviceHandle, Status) {
+	if index < 0 || index >= len(f.sticks) {
+		return nil, ErrDeviceNotFound
+	}
+	s := f.sticks[index]
+	if ret := s.fail("ncDeviceCreate"); ret != OK {
+		return nil, ret
+	}
+	return &fakeDevice{stick: s}, OK
+}
+
+func (f *fakeAPI) deviceOpen(h deviceHandle) Status {
+	d := h.(*fakeDevice)
+	if ret := d.stick.fail("ncDeviceOpen"); ret != OK {
+		return ret
+	}
+	d.open = true
+	return OK
+}
+
+func (f *fakeAPI) deviceClose(h deviceHandle) Status {
+	d := h.(*fakeDevice)
+	if ret := d.stick.fail("ncDeviceClose"); ret != OK {
+		return ret
+	}
+	d.open = false
+	return OK
+}
+
+func (f *fakeAPI) deviceDestroy(h deviceHandle) Status {
+	return h.(*fakeDevice).stick.fail("ncDeviceDestroy")
+}
+
+func (f *fakeAPI) deviceName(h deviceHandle) (string, Status) {
+	d := h.(*fakeDevice)
+	return d.stick.Name, d.stick.fail("ncDeviceGetOption")
+}
+
+func (f *fakeAPI) deviceFirmwareVersion(h deviceHandle) (Version, Status) {
+	d := h.(*fakeDevice)
+	return d.stick.Firmware, d.stick.fail("ncDeviceGetOption")
+}
+
+func (f *fakeAPI) deviceThermalStats(h deviceHandle) ([]float32, Status) {
+	d := h.(*fakeDevice)
+	if !d.open {
+		return nil, ErrUnauthorized
+	}
+	return []float32{d.stick.Temperature}, d.stick.fail("ncDeviceGetOption")
+}
+
+func (f *fakeAPI) deviceIntOption(h deviceHandle, option deviceOption) (int, Status) {
+	d := h.(*fakeDevice)
+	if ret := d.stick.fail("ncDeviceGetOption"); ret != OK {
+		return 0, ret
+	}
+
+	d.stick.mu.Lock()
+	defer d.stick.mu.Unlock()
+
+	switch option {
+	case optThrottlingLevel:
+		return int(d.stick.Throttling), OK
+	case optMemoryUsed:
+		return d.stick.memory, OK
+	case optMemorySize:
+		return fakeMemorySize, OK
+	case optHardwareVersion:
+		return int(d.stick.Hardware), OK
+	}
+	return 0, ErrInvalidParameters
+}
+
+func (f *fakeAPI) deviceDebugInfo(h deviceHandle) (string, Status) {
+	d := h.(*fakeDevice)
+	return "", d.stick.fail("ncDeviceGetOption")
+}
+
+func (f *fakeAPI) graphCreate(name string) (graphHandle, Status) {
+	return &fakeGraph{executors: 1}, OK
+}
+
+func (f *fakeAPI) graphSetIntOption(g graphHandle, option graphOption, v int) Status {
+	gr := g.(*fakeGraph)
+	if gr.stick != nil {
+		// options can only be set before the graph is allocated
+		return ErrUnauthorized
+	} else if option != optExecutors || v < 1 {
+		return ErrInvalidParameters
+	}
+	gr.executors = v
+	return OK
+}
+
+func (f *fakeAPI) graphAllocate(h deviceHandle, g graphHandle, file []byte) Status {
+	d := h.(*fakeDevice)
+	if ret := d.stick.fail("ncGraphAllocate"); ret != OK {
+		return ret
+	}
+	return d.stick.allocate(d, g.(*fakeGraph), file)
+}
+
+// allocate allocates gr on the stick through d.
+func (s *fakeStick) allocate(d *fakeDevice, gr *fakeGraph, file []byte) Status {
+	if !d.open {
+		return ErrUnauthorized
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.memory+len(file) > fakeMemorySize {
+		return ErrOutOfMemory
+	}
+	s.memory += len(file)
+	gr.stick, gr.allocated = s, len(file)
+
+	return OK
+}
+
+func (f *fakeAPI) graphAllocateWithFifos(h deviceHandle, g graphHandle, file []byte, in, out FifoConfig) (fifoHandle, fifoHandle, Status) {
+	d := h.(*fakeDevice)
+	s := d.stick
+	if ret := s.fail("ncGraphAllocateWithFifosEx"); ret != OK {
+		return nil, nil, ret
+	} else if ret := s.allocate(d, g.(*fakeGraph), file); ret != OK {
+		return nil, nil, ret
+	}
+
+	// the stick runs every queued inference, so the output fifo must hold
+	// as many as may be in flight
+	input := &fakeFifo{input: true, stick: s, dataType: in.DataType, elements: s.Inputs[0].Elements()}
+	output := &fakeFifo{stick: s, dataType: out.DataType, elements: s.Outputs[0].Elements(), outputs: make(chan fakeElem, in.depth()+out.depth())}
+
+	return input, output, OK
+}
+
+func (f *fakeAPI) graphDestroy(g graphHandle) Status {
+	gr := g.(*fakeGraph)
+	if gr.stick == nil {
+		return OK
+	}
+	if ret := gr.stick.fail("ncGraphDestroy"); ret != OK {
+		return ret
+	}
+
+	gr.stick.mu.Lock()
+	gr.stick.memory -= gr.allocated
+	gr.stick.mu.Unlock()
+
+	gr.allocated = 0
+	return OK
+}
+
+// descriptor fills in the sizes and strides of a tensor of shape d, with
+// its channels interleaved as the NCAPI stores them.
+func (s *fakeStick) descriptor(d TensorDescriptor) TensorDescriptor {
+	size := d.DataType.size()
+	d.WStride = d.C * size
+	d.HStride = d.W * d.WStride
+	d.CStride = size
+	d.TotalSize = d.Elements() * size
+	return d
+}
+
+func (f *fakeAPI) graphTensorDescriptors(g graphHandle, input bool) ([]TensorDescriptor, Status) {
+	gr := g.(*fakeGraph)
+	if ret := gr.stick.fail("ncGraphGetOption"); ret != OK {
+		return nil, ret
+	}
+
+	tensors := gr.stick.Outputs
+	if input {
+		tensors = gr.stick.Inputs
+	}
+
+	descs := make([]TensorDescriptor, len(tensors))
+	for i, t := range tensors {
+		descs[i] = gr.stick.descriptor(t)
+	}
+	return descs, OK
+}
+
+func (f *fakeAPI) graphTimeTaken(g graphHandle) ([]float32, Status) {
+	gr := g.(*fakeGraph)
+	return []float32{float32(gr.stick.Latency) / float32(time.Millisecond)}, gr.stick.fail("ncGraphGetOption")
+}
+
+func (f *fakeAPI) graphDebugInfo(g graphHandle) (string, Status) {
+	gr := g.(*fakeGraph)
+	return "", gr.stick.fail("ncGraphGetOption")
+}
+
+func (f *fakeAPI) queueInference(g graphHandle, inputs, outputs []fifoHandle) Status {
+	gr := g.(*fakeGraph)
+	s := gr.stick
+	if ret := s.fail("ncGraphQueueInference"); ret != OK {
+		return ret
+	}
+
+	// the input tensors are concatenated for Infer, in the first input's
+	// buffer
+	var e fakeElem
+	for i, h := range inputs {
+		in := h.(*fakeFifo)
+
+		in.mu.Lock()
+		if len(in.pending) == 0 {
+			in.mu.Unlock()
+			return ErrError
+		}
+		next := in.pending[0]
+		in.pending = in.pending[:copy(in.pending, in.pending[1:])]
+		in.mu.Unlock()
+
+		if i == 0 {
+			e = next
+		} else {
+			e.data = append(e.data, next.data...)
+			in.recycle(next.data)
+		}
+	}
+	input := e.data
+
+	var result []float32
+	if s.Infer != nil {
+		result = s.Infer(e.data)
+	}
+
+	s.mu.Lock()
+	if now := time.Now(); s.free.Before(now) {
+		s.free = now
+	}
+	s.free = s.free.Add(s.Latency / time.Duration(gr.executors))
+	e.ready = s.free
+	s.mu.Unlock()
+
+	for _, h := range outputs {
+		out := h.(*fakeFifo)
+
+		e.data = out.buffer()
+		n := copy(e.data, result)
+		for i := n; i < len(e.data); i++ {
+			e.data[i] = 0
+		}
+		result = result[n:]
+		out.outputs <- e
+	}
+
+	inputs[0].(*fakeFifo).recycle(input)
+	return OK
+}
+
+func (f *fakeAPI) fifoCreate(name string, input bool) (fifoHandle, Status) {
+	return &fakeFifo{input: input}, OK
+}
+
+func (f *fakeAPI) fifoAllocate(h fifoHandle, dh deviceHandle, desc TensorDescriptor, config FifoConfig) Status {
+	ff, d := h.(*fakeFifo), dh.(*fakeDevice)
+	if ret := d.stick.fail("ncFifoAllocate"); ret != OK {
+		return ret
+	} else if !d.open {
+		return ErrUnauthorized
+	}
+
+	ff.stick, ff.dataType, ff.elements = d.stick, config.DataType, desc.Elements()
+	if !ff.input {
+		ff.outputs = make(chan fakeElem, config.depth())
+	}
+	return OK
+}
+
+func (f *fakeAPI) fifoElementSize(h fifoHandle) (int, Status) {
+	ff := h.(*fakeFifo)
+	return ff.elements * ff.dataType.size(), ff.stick.fail("ncFifoGetOption")
+}
+
+func (f *fakeAPI) fifoWriteFillLevel(h fifoHandle) (int, Status) {
+	ff := h.(*fakeFifo)
+	if ret := ff.stick.fail("ncFifoGetOption"); ret != OK {
+		return 0, ret
+	}
+
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	return len(ff.pending), OK
+}
+
+func (f *fakeAPI) fifoReadFillLevel(h fifoHandle) (int, Status) {
+	ff := h.(*fakeFifo)
+	return len(ff.outputs), ff.stick.fail("ncFifoGetOption")
+}
+
+func (f *fakeAPI) fifoWrite(h fifoHandle, data unsafe.Pointer, size int, id uint64) Status {
+	ff := h.(*fakeFifo)
+	if ret := ff.stick.fail("ncFifoWriteElem"); ret != OK {
+		return ret
+	} else if !ff.input || ff.stick == nil {
+		return ErrUnauthorized
+	} else if size != ff.elements*ff.dataType.size() {
+		return ErrInvalidDataLength
+	}
+
+	e := fakeElem{data: ff.buffer(), id: id}
+	if ff.dataType == FP16 {
+		fromHalf(e.data, unsafe.Slice((*uint16)(data), ff.elements))
+	} else {
+		copy(e.data, unsafe.Slice((*float32)(data), ff.elements))
+	}
+
+	ff.mu.Lock()
+	ff.pending = append(ff.pending, e)
+	ff.mu.Unlock()
+
+	return OK
+}
+
+func (f *fakeAPI) fifoRead(h fifoHandle, data unsafe.Pointer, size int) (uint64, Status) {
+	ff := h.(*fakeFifo)
+	if ret := ff.stick.fail("ncFifoReadElem"); ret != OK {
+		return 0, ret
+	} else if ff.input || ff.stick == nil {
+		return 0, ErrUnauthorized
+	} else if size != ff.elements*ff.dataType.size() {
+		return 0, ErrInvalidDataLength
+	}
+
+	e := <-ff.outputs
+	time.Sleep(time.Until(e.ready))
+
+	if ff.dataType == FP16 {
+		toHalf(unsafe.Slice((*uint16)(data), ff.elements), e.data)
+	} else {
+		copy(unsafe.Slice((*float32)(data), ff.elements), e.data)
+	}
+	ff.recycle(e.data)
+
+	return e.id, OK
+}
+
+func (f *fakeAPI) fifoDestroy(h fifoHandle) Status {
+	return h.(*fakeFifo).stick.fail("ncFifoDestroy")
+}
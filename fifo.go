@@ -0,0 +1,315 @@
+package mvnc
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// AllocatedGraph is a graph allocated on a Device by AllocateGraph, without
+// any fifos.  It is the NCAPI's lower level: the caller creates the fifos
+// with CreateFifo and queues inferences between them, for instance to feed
+// several graphs from one input fifo or to size each fifo precisely.  Most
+// code should use Graph, which does all of this itself.
+type AllocatedGraph struct {
+	Name string
+
+	device          *Device
+	handle          graphHandle
+	inputs, outputs []TensorDescriptor
+}
+
+// AllocateGraph allocates the compiled graph in file on the device, as
+// ncGraphAllocate does.  The graph must be destroyed with Destroy before the
+// device is closed.
+func (d *Device) AllocateGraph(name string, file []byte) (*AllocatedGraph, error) {
+	if len(file) == 0 {
+		return nil, fmt.Errorf("graph file is empty")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handle == nil {
+		return nil, fmt.Errorf("device %d is closed", d.Index)
+	}
+
+	g := &AllocatedGraph{Name: name, device: d}
+
+	var ret Status
+	if g.handle, ret = api.graphCreate(name); ret != OK {
+		return nil, fmt.Errorf("could not create graph, %w", errorFor(ret))
+	}
+	if ret := api.graphAllocate(d.handle, g.handle, file); ret != OK {
+		api.graphDestroy(g.handle)
+		return nil, fmt.Errorf("error allocating graph: %w", errorFor(ret))
+	}
+
+	if g.inputs, ret = api.graphTensorDescriptors(g.handle, true); ret != OK {
+		api.graphDestroy(g.handle)
+		return nil, fmt.Errorf("error getting input tensor descriptor: %w", errorFor(ret))
+	}
+	if g.outputs, ret = api.graphTensorDescriptors(g.handle, false); ret != OK {
+		api.graphDestroy(g.handle)
+		return nil, fmt.Errorf("error getting output tensor descriptor: %w", errorFor(ret))
+	}
+
+	runtime.SetFinalizer(g, (*AllocatedGraph).finalize)
+	return g, nil
+}
+
+// InputDescriptor returns the shape of the graph's first input tensor, to be
+// passed to CreateFifo for the fifo its inputs are written to.
+func (g *AllocatedGraph) InputDescriptor() TensorDescriptor {
+	return g.inputs[0]
+}
+
+// OutputDescriptor returns the shape of the graph's first output tensor.
+func (g *AllocatedGraph) OutputDescriptor() TensorDescriptor {
+	return g.outputs[0]
+}
+
+// InputDescriptors and OutputDescriptors return the shapes of all of the
+// graph's input and output tensors, for graphs with more than one.
+func (g *AllocatedGraph) InputDescriptors() []TensorDescriptor {
+	return g.inputs
+}
+
+func (g *AllocatedGraph) OutputDescriptors() []TensorDescriptor {
+	return g.outputs
+}
+
+// QueueInference runs a graph with one input and one output tensor on the
+// next element written to input, writing its output to output.
+func (g *AllocatedGraph) QueueInference(input, output *Fifo) error {
+	return g.QueueInferenceTensors([]*Fifo{input}, []*Fifo{output})
+}
+
+// QueueInferenceTensors runs the graph on the next element written to each
+// of inputs, one fifo per input tensor in order, writing its outputs to
+// outputs, one fifo per output tensor.
+func (g *AllocatedGraph) QueueInferenceTensors(inputs, outputs []*Fifo) error {
+	if len(inputs) != len(g.inputs) || len(outputs) != len(g.outputs) {
+		return fmt.Errorf("graph has %d input and %d output tensors, given %d and %d fifos",
+			len(g.inputs), len(g.outputs), len(inputs), len(outputs))
+	}
+
+	handles := func(fifos []*Fifo) []fifoHandle {
+		hs := make([]fifoHandle, len(fifos))
+		for i, f := range fifos {
+			hs[i] = f.handle
+		}
+		return hs
+	}
+
+	ret := api.queueInference(g.handle, handles(inputs), handles(outputs))
+	// the finalizers must not destroy the handles during the call
+	runtime.KeepAlive(g)
+	runtime.KeepAlive(inputs)
+	runtime.KeepAlive(outputs)
+	if ret != OK {
+		return fmt.Errorf("error queuing inference, %w", errorFor(ret))
+	}
+	return nil
+}
+
+// Destroy deallocates the graph.  The fifos used with it are not destroyed.
+func (g *AllocatedGraph) Destroy() error {
+	g.device.mu.Lock()
+	defer g.device.mu.Unlock()
+
+	if g.handle == nil {
+		return nil
+	}
+
+	ret := api.graphDestroy(g.handle)
+	g.handle = nil
+	if ret != OK {
+		return fmt.Errorf("error destroying graph: %w", errorFor(ret))
+	}
+	return nil
+}
+
+// finalize destroys a graph dropped without being destroyed.
+func (g *AllocatedGraph) finalize() {
+	if g.handle != nil {
+		log.Printf("mvnc: graph %s was not destroyed", g.Name)
+		g.Destroy()
+	}
+}
+
+// FifoType is the direction of a fifo, as seen from the host.
+type FifoType int
+
+const (
+	// HostWrite fifos carry inputs written by the host to a graph.
+	HostWrite FifoType = iota
+
+	// HostRead fifos carry outputs from a graph to be read by the host.
+	HostRead
+)
+
+func (t FifoType) String() string {
+	switch t {
+	case HostWrite:
+		return "host-write"
+	case HostRead:
+		return "host-read"
+	default:
+		return fmt.Sprintf("FifoType(%d)", int(t))
+	}
+}
+
+// Fifo is a fifo created by CreateFifo, holding tensors on their way to or
+// from a graph.  Write and Read may be called from several goroutines, but
+// not concurrently with Destroy.
+type Fifo struct {
+	Name string
+	Type FifoType
+
+	device   *Device
+	handle   fifoHandle
+	dataType DataType
+	size     int // of an element, in bytes
+	length   int // of an element, in values
+
+	mu   sync.Mutex // guards half
+	half []uint16
+}
+
+// CreateFifo creates and allocates a fifo on the device for tensors of the
+// shape desc, usually the InputDescriptor or OutputDescriptor of an
+// AllocatedGraph, as ncFifoCreate and ncFifoAllocate do.  config gives the
+// number of elements the fifo holds and the data type the host reads or
+// writes them as.  The fifo must be destroyed with Destroy before the device
+// is closed.
+func (d *Device) CreateFifo(name string, t FifoType, desc TensorDescriptor, config FifoConfig) (*Fifo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handle == nil {
+		return nil, fmt.Errorf("device %d is closed", d.Index)
+	}
+
+	f := &Fifo{Name: name, Type: t, device: d, dataType: config.DataType}
+
+	var ret Status
+	if f.handle, ret = api.fifoCreate(name, t == HostWrite); ret != OK {
+		return nil, fmt.Errorf("could not create fifo, %w", errorFor(ret))
+	}
+	if ret := api.fifoAllocate(f.handle, d.handle, desc, config); ret != OK {
+		api.fifoDestroy(f.handle)
+		return nil, fmt.Errorf("error allocating fifo: %w", errorFor(ret))
+	}
+	if f.size, ret = api.fifoElementSize(f.handle); ret != OK {
+		api.fifoDestroy(f.handle)
+		return nil, fmt.Errorf("error getting fifo element size: %w", errorFor(ret))
+	}
+
+	f.length = f.size / f.dataType.size()
+	if f.dataType == FP16 {
+		f.half = make([]uint16, f.length)
+	}
+
+	runtime.SetFinalizer(f, (*Fifo).finalize)
+	return f, nil
+}
+
+// Len returns the number of values in each element of the fifo.
+func (f *Fifo) Len() int {
+	return f.length
+}
+
+// Write writes a tensor to a HostWrite fifo, converting it to the fifo's
+// data type.  id is returned by Read along with the output of the inference
+// made from it.
+func (f *Fifo) Write(data []float32, id uint64) error {
+	if len(data) != f.length {
+		return fmt.Errorf("tensor has %d elements, fifo expects %d", len(data), f.length)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	in := unsafe.Pointer(&data[0])
+	if f.dataType == FP16 {
+		toHalf(f.half, data)
+		in = unsafe.Pointer(&f.half[0])
+	}
+
+	if ret := api.fifoWrite(f.handle, in, f.size, id); ret != OK {
+		return fmt.Errorf("error writing fifo, %w", errorFor(ret))
+	}
+	return nil
+}
+
+// Read reads the next tensor from a HostRead fifo into data, blocking until
+// one is available, and returns the id it was written with.
+func (f *Fifo) Read(data []float32) (uint64, error) {
+	if len(data) != f.length {
+		return 0, fmt.Errorf("tensor has %d elements, fifo holds %d", len(data), f.length)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := unsafe.Pointer(&data[0])
+	if f.dataType == FP16 {
+		out = unsafe.Pointer(&f.half[0])
+	}
+
+	id, ret := api.fifoRead(f.handle, out, f.size)
+	if ret != OK {
+		return 0, fmt.Errorf("error reading fifo, %w", errorFor(ret))
+	}
+
+	if f.dataType == FP16 {
+		fromHalf(data, f.half)
+	}
+	return id, nil
+}
+
+// FillLevel returns the number of elements in the fifo: those waiting to be
+// read by the stick for HostWrite fifos, or by the host for HostRead ones.
+func (f *Fifo) FillLevel() (int, error) {
+	var level int
+	var ret Status
+	if f.Type == HostWrite {
+		level, ret = api.fifoWriteFillLevel(f.handle)
+	} else {
+		level, ret = api.fifoReadFillLevel(f.handle)
+	}
+	runtime.KeepAlive(f)
+	if ret != OK {
+		return 0, fmt.Errorf("error getting fifo fill level %w", errorFor(ret))
+	}
+	return level, nil
+}
+
+// Destroy destroys the fifo, as ncFifoDestroy does.  The graphs it was used
+// with are not destroyed.
+func (f *Fifo) Destroy() error {
+	f.device.mu.Lock()
+	defer f.device.mu.Unlock()
+
+	if f.handle == nil {
+		return nil
+	}
+
+	ret := api.fifoDestroy(f.handle)
+	f.handle = nil
+	if ret != OK {
+		return fmt.Errorf("error destroying fifo: %w", errorFor(ret))
+	}
+	return nil
+}
+
+// finalize destroys a fifo dropped without being destroyed.
+func (f *Fifo) finalize() {
+	if f.handle != nil {
+		log.Printf("mvnc: fifo %s was not destroyed", f.Name)
+		f.Destroy()
+	}
+}
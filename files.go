@@ -0,0 +1,100 @@
+package mvnc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the formats decoded by FileSource
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileImage is an image read by a FileSource, along with the file it was
+// read from.
+type FileImage struct {
+	image.Image
+	Path string
+}
+
+// FileSource is an ImageSource that decodes JPEG and PNG files in turn.
+// JPEG files are turned upright as recorded by their EXIF orientation, as
+// photos from phones usually need, unless IgnoreOrientation is set.
+type FileSource struct {
+	Paths []string
+
+	IgnoreOrientation bool
+
+	next int
+}
+
+// NewFileSource returns a FileSource reading the given files in order.
+func NewFileSource(paths ...string) *FileSource {
+	return &FileSource{Paths: paths}
+}
+
+// GlobSource returns a FileSource reading the files matching a
+// filepath.Match pattern, in lexical order.
+func GlobSource(pattern string) (*FileSource, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFileSource(paths...), nil
+}
+
+// DirSource returns a FileSource reading the JPEG and PNG files in dir, by
+// their extension, in lexical order.
+func DirSource(dir string) (*FileSource, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, info := range infos {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".jpg", ".jpeg", ".png":
+			if !info.IsDir() {
+				paths = append(paths, filepath.Join(dir, info.Name()))
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	return NewFileSource(paths...), nil
+}
+
+// Frames returns the files of s as a FrameSource.
+func (s *FileSource) Frames() FrameSource {
+	return Images(s)
+}
+
+// Next decodes the next file, returning a *FileImage.
+func (s *FileSource) Next() (image.Image, error) {
+	if s.next >= len(s.Paths) {
+		return nil, io.EOF
+	}
+
+	path := s.Paths[s.next]
+	s.next++
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	if format == "jpeg" && !s.IgnoreOrientation {
+		img = orient(img, jpegOrientation(b))
+	}
+
+	return &FileImage{Image: img, Path: path}, nil
+}
@@ -0,0 +1,45 @@
+package mvnc
+
+// filter remaps the names of the boxes of d, and of its detections from
+// the start'th on, and drops those Include and Exclude leave out.
+func (f *Graph) filter(d *decoding, start int) {
+	if len(f.Remap) == 0 && len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return
+	}
+
+	boxes := d.boxes[:0]
+	for _, b := range d.boxes {
+		if b.Name != "" {
+			b.Name = f.remap(b.Name)
+			if !f.included(b.Name) {
+				continue
+			}
+		}
+		boxes = append(boxes, b)
+	}
+	d.boxes = boxes
+
+	dets := d.dets[:start]
+	for _, det := range d.dets[start:] {
+		if det.name = f.remap(det.name); f.included(det.name) {
+			dets = append(dets, det)
+		}
+	}
+	d.dets = dets
+}
+
+// remap returns the name class is known by downstream.
+func (f *Graph) remap(class string) string {
+	if name, ok := f.Remap[class]; ok {
+		return name
+	}
+	return class
+}
+
+// included reports whether the detections of the remapped name are kept.
+func (f *Graph) included(name string) bool {
+	if len(f.Include) > 0 && !contains(f.Include, name) {
+		return false
+	}
+	return !contains(f.Exclude, name)
+}
@@ -0,0 +1,319 @@
+package mvnc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FrameHeader describes a stream of raw frames, sent at its start to a
+// FrameServer or read by NewStreamSource, so that the program producing the
+// frames and the graph need not agree on their size out of band.  It is a
+// single line of key=value pairs following the word mvnc:
+//
+//	mvnc width=640 height=480 format=i420 fps=30
+//
+// format is one of the PixelFormats, rgb24 if it is omitted, and fps, the
+// rate the frames are sent at, is optional.  Each frame follows as
+// PixelFormat stores it, with no framing.  With ffmpeg, for example:
+//
+//	(echo mvnc width=640 height=480 format=i420; ffmpeg -i rtsp://camera/stream -f rawvideo -pix_fmt yuv420p -s 640x480 -) | nc -U /run/mvnc.sock
+type FrameHeader struct {
+	Width, Height int
+	Format        PixelFormat
+	FPS           float64
+}
+
+// the longest header line read, and sizes of frame accepted
+const (
+	maxHeaderLine = 256
+	maxFrameSide  = 16384
+)
+
+func (h FrameHeader) String() string {
+	s := fmt.Sprintf("mvnc width=%d height=%d format=%s", h.Width, h.Height, strings.ToLower(h.Format.String()))
+	if h.FPS > 0 {
+		s += " fps=" + strconv.FormatFloat(h.FPS, 'g', -1, 64)
+	}
+	return s
+}
+
+// ReadFrameHeader reads a FrameHeader line from r.  It reads a byte at a
+// time, so that nothing of the frames following it is read.
+func ReadFrameHeader(r io.Reader) (FrameHeader, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return FrameHeader{}, err
+		}
+		if b[0] == '\n' {
+			break
+		}
+		if line = append(line, b[0]); len(line) > maxHeaderLine {
+			return FrameHeader{}, fmt.Errorf("frame header longer than %d bytes", maxHeaderLine)
+		}
+	}
+	return parseFrameHeader(strings.TrimSuffix(string(line), "\r"))
+}
+
+func parseFrameHeader(line string) (FrameHeader, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "mvnc" {
+		return FrameHeader{}, fmt.Errorf("invalid frame header '%s'", line)
+	}
+
+	var h FrameHeader
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return FrameHeader{}, fmt.Errorf("invalid frame header field '%s'", field)
+		}
+
+		var err error
+		switch kv[0] {
+		case "width":
+			h.Width, err = strconv.Atoi(kv[1])
+		case "height":
+			h.Height, err = strconv.Atoi(kv[1])
+		case "format":
+			h.Format, err = parsePixelFormat(kv[1])
+		case "fps":
+			h.FPS, err = strconv.ParseFloat(kv[1], 64)
+		default:
+			// unknown fields are left for later versions
+		}
+		if err != nil {
+			return FrameHeader{}, fmt.Errorf("invalid frame header field '%s': %w", field, err)
+		}
+	}
+
+	if h.Width <= 0 || h.Height <= 0 || h.Width > maxFrameSide || h.Height > maxFrameSide {
+		return FrameHeader{}, fmt.Errorf("invalid frame size %dx%d", h.Width, h.Height)
+	}
+	return h, nil
+}
+
+// NewStreamSource reads a FrameHeader from r, such as a named pipe, and
+// returns a FrameSource of the frames following it, as *RawRGBImages.
+func NewStreamSource(r io.Reader) (FrameSource, FrameHeader, error) {
+	h, err := ReadFrameHeader(r)
+	if err != nil {
+		return nil, h, err
+	}
+	return newStreamSource(r, h), h, nil
+}
+
+func newStreamSource(r io.Reader, h FrameHeader) *streamSource {
+	s := &streamSource{r: r, header: h}
+	if h.Format != RGB24 {
+		s.raw = make([]byte, h.Format.frameBytes(h.Width, h.Height))
+	}
+	return s
+}
+
+type streamSource struct {
+	r      io.Reader
+	header FrameHeader
+	raw    []byte // the YCbCr frame, converted to RGB
+}
+
+func (s *streamSource) Next(ctx context.Context) (Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return Frame{}, err
+	}
+
+	w, h := s.header.Width, s.header.Height
+	b := make([]byte, w*h*3)
+	if s.raw == nil {
+		if _, err := io.ReadFull(s.r, b); err != nil {
+			return Frame{}, err
+		}
+	} else {
+		if _, err := io.ReadFull(s.r, s.raw); err != nil {
+			return Frame{}, err
+		}
+		s.header.Format.toRGB(b, s.raw, w, h)
+	}
+	return Frame{Image: &RawRGBImage{bytes: b, width: w, height: h}, Time: time.Now()}, nil
+}
+
+// FrameServer is a FrameSource of the frames streamed to a listener, such as
+// a unix socket, by ffmpeg, gstreamer or the like.  Each connection starts
+// with a FrameHeader, which the server answers with a line of "ok", or of
+// "error" and the reason it was refused, before the frames follow.
+// Connections are streamed one at a time, later ones waiting for the one
+// streaming to end, and the server keeps accepting them until it is
+// closed, so that the program sending the frames can be restarted.
+type FrameServer struct {
+	listener net.Listener
+	frames   chan Frame
+	done     chan struct{} // closed by Close
+	stopped  chan struct{} // closed once the server stops accepting
+
+	mu     sync.Mutex
+	conn   net.Conn
+	header FrameHeader
+	err    error // why the server stopped
+	closed bool
+}
+
+// ListenFrames listens on the given network and address, such as "unix"
+// and the path of a socket, and serves the frames streamed to it.  The
+// file of a unix socket left by a previous server is removed first.
+func ListenFrames(network, addr string) (*FrameServer, error) {
+	if network == "unix" {
+		if fi, err := os.Stat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return ServeFrames(l), nil
+}
+
+// ServeFrames serves the frames streamed to the connections accepted by l,
+// which it closes when the server is closed.
+func ServeFrames(l net.Listener) *FrameServer {
+	s := &FrameServer{
+		listener: l,
+		frames:   make(chan Frame),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Addr returns the address the server is listening on.
+func (s *FrameServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Header returns the header of the connection streaming, if there is one.
+func (s *FrameServer) Header() (FrameHeader, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.header, s.conn != nil
+}
+
+// Next returns the next frame streamed to the server, waiting for a
+// connection if there is none.  It returns io.EOF once the server is
+// closed.
+func (s *FrameServer) Next(ctx context.Context) (Frame, error) {
+	select {
+	case fr := <-s.frames:
+		return fr, nil
+	case <-s.stopped:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.err != nil {
+			return Frame{}, s.err
+		}
+		return Frame{}, io.EOF
+	case <-ctx.Done():
+		return Frame{}, ctx.Err()
+	}
+}
+
+// Close stops the server, closing the connection streaming, if any.
+func (s *FrameServer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	<-s.stopped
+	return err
+}
+
+func (s *FrameServer) run() {
+	defer close(s.stopped)
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			if !s.closed && !errors.Is(err, net.ErrClosed) {
+				s.err = fmt.Errorf("error accepting frames: %w", err)
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		s.serve(conn)
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+	}
+}
+
+// serve streams the frames of conn until it ends or the server is closed.
+func (s *FrameServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// a client sending no header is not waited for forever
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	h, err := ReadFrameHeader(conn)
+	if err != nil {
+		fmt.Fprintf(conn, "error %v\n", err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if _, err := io.WriteString(conn, "ok\n"); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.conn, s.header = conn, h
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	src := newStreamSource(conn, h)
+	for {
+		fr, err := src.Next(context.Background())
+		if err != nil {
+			return
+		}
+
+		select {
+		case s.frames <- fr:
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"time"
+)
+
+// Frame is a frame produced by a FrameSource, and the time it was captured.
+type Frame struct {
+	Image image.Image
+	Time  time.Time
+}
+
+// FrameSource produces the frames run through a graph, such as the files of
+// a dataset or the frames of a camera.  Next returns io.EOF once the source
+// is exhausted, and ctx.Err() if ctx is done before a frame is ready.
+//
+// New capture backends need only implement FrameSource to be run with
+// ProcessSource or Score.  The adapters RawSource, Images, FileSource.Frames,
+// MJPEGReader.Frames and the Frames methods of the rtsp and v4l2 sources
+// turn the package's other inputs into FrameSources, and FrameServer and
+// NewStreamSource read frames streamed with a FrameHeader.
+type FrameSource interface {
+	Next(ctx context.Context) (Frame, error)
+}
+
+// ImageSource is a source of decoded images which knows nothing of
+// contexts, such as a FileSource.  Next returns io.EOF once the source is
+// exhausted.
+type ImageSource interface {
+	Next() (image.Image, error)
+}
+
+// Images returns a FrameSource of the images of src, timed as they are
+// returned.  ctx is checked before each image, but a call to src.Next
+// already waiting is not interrupted.
+func Images(src ImageSource) FrameSource {
+	return imageSource{src}
+}
+
+type imageSource struct {
+	src ImageSource
+}
+
+func (s imageSource) Next(ctx context.Context) (Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return Frame{}, err
+	}
+
+	img, err := s.src.Next()
+	if err != nil {
+		return Frame{}, err
+	}
+	return Frame{Image: img, Time: time.Now()}, nil
+}
+
+// RawSource returns a FrameSource of the raw RGB frames of width by height
+// pixels read from r, in the format Process reads, as *RawRGBImages.  A
+// partial frame at the end of r is returned as io.ErrUnexpectedEOF.
+func RawSource(r io.Reader, width, height int) FrameSource {
+	return &rawSource{r: r, width: width, height: height}
+}
+
+type rawSource struct {
+	r             io.Reader
+	width, height int
+}
+
+func (s *rawSource) Next(ctx context.Context) (Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return Frame{}, err
+	}
+
+	// each frame has its own buffer, since the caller may keep it
+	b := make([]byte, s.width*s.height*3)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return Frame{}, err
+	}
+	return Frame{Image: &RawRGBImage{bytes: b, width: s.width, height: s.height}, Time: time.Now()}, nil
+}
+
+// NewImageReader returns the images of src as the raw RGB frames read by
+// Process, resized to width by height.
+func NewImageReader(src FrameSource, width, height int) *ImageReader {
+	return &ImageReader{Width: width, Height: height, Next: func() (image.Image, error) {
+		fr, err := src.Next(context.Background())
+		return fr.Image, err
+	}}
+}
+
+// ProcessSource runs the frames of src through the graph as Process does,
+// resized to the graph's Width and Height, or to its input tensor if they
+// are not set.  The stream ends, and the channel is closed, once src is
+// exhausted or ctx is done.  The frames are passed to Process as fixed size
+// RGB24 frames, so ProcessSource returns an error if the graph's
+// PixelFormat or Framing is set to anything else.
+func (f *Graph) ProcessSource(ctx context.Context, src FrameSource) (<-chan string, error) {
+	if f.PixelFormat != RGB24 {
+		return nil, fmt.Errorf("ProcessSource passes %v frames to Process, the graph reads %v", RGB24, f.PixelFormat)
+	} else if f.Framing != FixedSize {
+		return nil, fmt.Errorf("ProcessSource passes fixed size frames to Process, the graph reads length prefixed frames")
+	}
+
+	width, height := f.Width, f.Height
+	if width == 0 || height == 0 {
+		desc, err := f.InputDescriptor()
+		if err != nil {
+			return nil, err
+		}
+		width, height = f.frameSize(desc)
+	}
+
+	return f.Process(&ImageReader{Width: width, Height: height, Next: func() (image.Image, error) {
+		fr, err := src.Next(ctx)
+		if err != nil && ctx.Err() != nil {
+			// the caller stopped the stream
+			return nil, io.EOF
+		}
+		return fr.Image, err
+	}}), nil
+}
+
+// Score runs every frame of src through the graph in turn, calling fn with
+// each image and the graph's output for it, until src is exhausted, fn
+// returns an error, or ctx is done.  Unlike Process no frames are skipped,
+// which makes it suited to scoring datasets.
+func (f *Graph) Score(ctx context.Context, src FrameSource, fn func(img image.Image, output []float32) error) error {
+	for {
+		fr, err := src.Next(ctx)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		output, err := f.InferImage(ctx, fr.Image)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(fr.Image, output); err != nil {
+			return err
+		}
+	}
+}
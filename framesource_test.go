@@ -0,0 +1,58 @@
+package mvnc
+
+import (
+	"context"
+	"image"
+	"io"
+	"testing"
+)
+
+// images is an ImageSource of uniform gray images.
+type images []uint8
+
+func (s *images) Next() (image.Image, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = (*s)[0]
+	}
+	*s = (*s)[1:]
+	return img, nil
+}
+
+func TestProcessSource(t *testing.T) {
+	defer UseFake(testStick(0))()
+
+	g := testGraph(t)
+	g.Backpressure = Block
+	defer g.Close()
+
+	src := images{0, 255, 255}
+	ch, err := g.ProcessSource(context.Background(), Images(&src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := collect(t, ch)
+	if len(names) != 3 || names[0] != "cat" || names[1] != "dog" || names[2] != "dog" {
+		t.Errorf("detected %q, want [cat dog dog]", names)
+	}
+}
+
+// TestProcessSourceFormat checks that ProcessSource refuses a graph reading
+// frames other than the fixed size RGB24 frames it passes to Process.
+func TestProcessSourceFormat(t *testing.T) {
+	defer UseFake(testStick(0))()
+
+	for _, g := range []*Graph{
+		{GraphFile: "test.graph", PixelFormat: I420},
+		{GraphFile: "test.graph", Framing: LengthPrefixed},
+	} {
+		src := images{0}
+		if _, err := g.ProcessSource(context.Background(), Images(&src)); err == nil {
+			t.Errorf("ProcessSource ran a graph reading %v frames framed as %d", g.PixelFormat, g.Framing)
+		}
+		g.Close()
+	}
+}
@@ -0,0 +1,106 @@
+package mvnc
+
+import (
+	"math"
+	"sync"
+)
+
+// Gallery holds named reference embeddings, such as one per known face, and
+// matches new embeddings against them by cosine similarity.  The zero value
+// is an empty gallery, and a Gallery is safe for concurrent use.
+type Gallery struct {
+	mu      sync.RWMutex
+	names   []string
+	vectors [][]float32 // normalized to unit length
+}
+
+// Match is the nearest reference embedding in a Gallery.
+type Match struct {
+	Name string
+
+	// Similarity is the cosine similarity to the reference, from -1 to 1,
+	// and Distance is one minus it.
+	Similarity float32
+	Distance   float32
+}
+
+// Add registers a reference embedding under name.  A name may have several
+// references, for example pictures of a face from different angles.
+func (g *Gallery) Add(name string, embedding []float32) {
+	v := normalized(embedding)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.names = append(g.names, name)
+	g.vectors = append(g.vectors, v)
+}
+
+// Remove removes every reference registered under name.
+func (g *Gallery) Remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	j := 0
+	for i, n := range g.names {
+		if n != name {
+			g.names[j], g.vectors[j] = n, g.vectors[i]
+			j++
+		}
+	}
+	g.names, g.vectors = g.names[:j], g.vectors[:j]
+}
+
+// Len returns the number of references in the gallery.
+func (g *Gallery) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.names)
+}
+
+// Match returns the reference nearest to embedding.  ok is false if the
+// gallery has no references of the same length as embedding.
+func (g *Gallery) Match(embedding []float32) (m Match, ok bool) {
+	v := normalized(embedding)
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for i, ref := range g.vectors {
+		if len(ref) != len(v) {
+			continue
+		}
+
+		var dot float32
+		for k := range ref {
+			dot += ref[k] * v[k]
+		}
+
+		if !ok || dot > m.Similarity {
+			m = Match{Name: g.names[i], Similarity: dot, Distance: 1 - dot}
+			ok = true
+		}
+	}
+
+	return m, ok
+}
+
+// normalized returns a copy of v scaled to unit length.
+func normalized(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+
+	n := make([]float32, len(v))
+	if sum == 0 {
+		return n
+	}
+
+	scale := float32(1 / math.Sqrt(sum))
+	for i, x := range v {
+		n[i] = x * scale
+	}
+	return n
+}
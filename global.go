@@ -0,0 +1,53 @@
+package mvnc
+
+import "fmt"
+
+// LogLevel is the verbosity of the NCSDK's own logging, which it writes to
+// stderr independently of Graph.Logger.
+type LogLevel int
+
+// The values of ncLogLevel_t, from most to least verbose.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+	LogFatal
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	case LogFatal:
+		return "fatal"
+	default:
+		return fmt.Sprintf("unknown log level %d", int(l))
+	}
+}
+
+// SetLogLevel sets the verbosity of the NCSDK's logging, including its USB
+// diagnostics, for the whole process.
+func SetLogLevel(level LogLevel) error {
+	if ret := api.setLogLevel(level); ret != OK {
+		return fmt.Errorf("could not set log level to %v: %w", level, errorFor(ret))
+	}
+
+	return nil
+}
+
+// GetLogLevel returns the verbosity of the NCSDK's logging.
+func GetLogLevel() (LogLevel, error) {
+	level, ret := api.logLevel()
+	if ret != OK {
+		return 0, fmt.Errorf("could not get log level: %w", errorFor(ret))
+	}
+
+	return level, nil
+}
@@ -1 +1,3 @@
 module github.com/donniet/mvnc
+
+go 1.27.1
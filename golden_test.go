@@ -0,0 +1,34 @@
+package mvnc_test
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/donniet/mvnc/internal/golden"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected results of testdata/golden from the current decoding")
+
+// TestGolden checks the decoding of the outputs recorded in testdata/golden.
+// After a deliberate change to the decoding, run
+//
+//	go test -run Golden -update
+//
+// and review the changes to the expected results before committing them.
+func TestGolden(t *testing.T) {
+	paths, err := golden.Files(filepath.Join("testdata", "golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			if err := golden.Check(path, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
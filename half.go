@@ -0,0 +1,101 @@
+package mvnc
+
+import (
+	"math"
+	"sync"
+)
+
+// float32ToHalf converts f to an IEEE 754 half precision value, rounding to
+// nearest even.  Values too large for a half become infinity.
+func float32ToHalf(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int32(b>>23&0xff) - 127 + 15
+	mant := b & 0x7fffff
+
+	switch {
+	case b&0x7fffffff > 0x7f800000:
+		// NaN
+		return sign | 0x7e00
+	case exp >= 0x1f:
+		// too large, or infinity
+		return sign | 0x7c00
+	case exp <= 0:
+		// subnormal, or too small
+		if exp < -10 {
+			return sign
+		}
+
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		h := mant >> shift
+		rem, halfway := mant&(1<<shift-1), uint32(1)<<(shift-1)
+		if rem > halfway || (rem == halfway && h&1 == 1) {
+			h++
+		}
+		return sign | uint16(h)
+	}
+
+	// a carry out of the mantissa correctly bumps the exponent, up to infinity
+	h := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		h++
+	}
+	return sign | uint16(h)
+}
+
+// halfToFloat32 converts an IEEE 754 half precision value to a float32,
+// which is always exact.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0x1f:
+		// infinity or NaN
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+
+		// renormalize the subnormal
+		exp = 127 - 15 + 1
+		for mant&0x400 == 0 {
+			mant <<= 1
+			exp--
+		}
+		return math.Float32frombits(sign | exp<<23 | (mant&0x3ff)<<13)
+	}
+
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+var (
+	halfTableOnce sync.Once
+	halfTable     []float32
+)
+
+// toHalf converts src into the half precision values of dst.
+func toHalf(dst []uint16, src []float32) {
+	for i, f := range src {
+		dst[i] = float32ToHalf(f)
+	}
+}
+
+// fromHalf converts the half precision values of src into dst, using a table
+// of all 65536 values since it runs on every output tensor.
+func fromHalf(dst []float32, src []uint16) {
+	halfTableOnce.Do(func() {
+		halfTable = make([]float32, 1<<16)
+		for i := range halfTable {
+			halfTable[i] = halfToFloat32(uint16(i))
+		}
+	})
+
+	for i, h := range src {
+		dst[i] = halfTable[h]
+	}
+}
@@ -0,0 +1,222 @@
+package mvnc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// MapConfig describes the output of a Segmentation or Heatmap graph, a map
+// with a value, or a score for each class, per pixel.
+type MapConfig struct {
+	// Width and Height are the size of the map, by default the W and H of
+	// the graph's output tensor.  Graph.Decode, which does not know the
+	// tensor's shape, needs them set.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	// Layout is the layout of the output: HWC stores the channels of each
+	// pixel together, while CHW stores each channel as a whole plane.
+	Layout Layout `json:"layout,omitempty"`
+
+	// Colormap colors the values of a Heatmap, after scaling them from
+	// [Min, Max] to [0, 1].  If Min and Max are equal, each map is scaled
+	// from its own smallest value to its largest.
+	Colormap Colormap `json:"colormap,omitempty"`
+	Min      float32  `json:"min,omitempty"`
+	Max      float32  `json:"max,omitempty"`
+
+	// Palette colors the classes of a Segmentation map by index.  It
+	// defaults to the palette of the PASCAL VOC dataset.
+	Palette [][3]uint8 `json:"palette,omitempty"`
+}
+
+// Colormap maps the values of a heatmap onto colors.
+type Colormap int
+
+// Colormaps: Gray runs from black to white, Jet from blue through green to
+// red, and Hot from black through red and yellow to white.
+const (
+	Gray Colormap = iota
+	Jet
+	Hot
+)
+
+func (c Colormap) String() string {
+	switch c {
+	case Gray:
+		return "gray"
+	case Jet:
+		return "jet"
+	case Hot:
+		return "hot"
+	default:
+		return "unknown"
+	}
+}
+
+func (c Colormap) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText parses gray, jet or hot, so that a Colormap can be read
+// from a Config.
+func (c *Colormap) UnmarshalText(b []byte) error {
+	for _, m := range []Colormap{Gray, Jet, Hot} {
+		if strings.EqualFold(string(b), m.String()) {
+			*c = m
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown colormap '%s'", b)
+}
+
+// Color returns the color of v, from 0 to 1.
+func (c Colormap) Color(v float32) color.RGBA {
+	v = unit(v)
+	switch c {
+	case Jet:
+		return color.RGBA{channel(1.5 - abs32(4*v-3)), channel(1.5 - abs32(4*v-2)), channel(1.5 - abs32(4*v-1)), 255}
+	case Hot:
+		return color.RGBA{channel(3 * v), channel(3*v - 1), channel(3*v - 2), 255}
+	default:
+		g := channel(v)
+		return color.RGBA{g, g, g, 255}
+	}
+}
+
+// channel returns v, clamped to [0, 1], as an 8-bit color channel.
+func channel(v float32) uint8 {
+	return uint8(unit(v)*255 + 0.5)
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// vocPalette returns the palette of n classes used by the PASCAL VOC
+// dataset, which spreads the bits of each index over the three channels.
+func vocPalette(n int) color.Palette {
+	p := make(color.Palette, n)
+	for i := range p {
+		var r, g, b uint8
+		for c, shift := i, 7; c > 0; c, shift = c>>3, shift-1 {
+			r |= uint8(c&1) << uint(shift)
+			g |= uint8(c>>1&1) << uint(shift)
+			b |= uint8(c>>2&1) << uint(shift)
+		}
+		p[i] = color.RGBA{r, g, b, 255}
+	}
+	return p
+}
+
+// outputMap returns the map of a Segmentation or Heatmap graph's output:
+// an *image.Paletted of the class of each pixel, whose Pix are the class
+// indices, or the heatmap colored by its Colormap.  It returns nil if the
+// size of the map is unknown or does not fit the output.
+func (f *Graph) outputMap(bout []float32, meta FrameMeta) image.Image {
+	var cfg MapConfig
+	if f.Map != nil {
+		cfg = *f.Map
+	}
+
+	w, h := cfg.Width, cfg.Height
+	if w == 0 || h == 0 {
+		w, h = meta.Output.W, meta.Output.H
+	}
+	if w <= 0 || h <= 0 || len(bout) < w*h {
+		return nil
+	}
+
+	channels := len(bout) / (w * h)
+	at := func(x, y, c int) float32 {
+		if cfg.Layout == CHW {
+			return bout[(c*h+y)*w+x]
+		}
+		return bout[(y*w+x)*channels+c]
+	}
+
+	if f.OutputFormat == Heatmap {
+		lo, hi := cfg.Min, cfg.Max
+		if lo == hi {
+			lo, hi = at(0, 0, 0), at(0, 0, 0)
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					lo, hi = min32(lo, at(x, y, 0)), max32(hi, at(x, y, 0))
+				}
+			}
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := float32(0)
+				if hi > lo {
+					v = (at(x, y, 0) - lo) / (hi - lo)
+				}
+				img.SetRGBA(x, y, cfg.Colormap.Color(v))
+			}
+		}
+		return img
+	}
+
+	classes := channels
+	if channels == 1 || classes > 256 {
+		// a single channel holds the class the graph chose itself
+		classes = 256
+	}
+	palette := vocPalette(256)
+	for i, c := range cfg.Palette {
+		if i < len(palette) {
+			palette[i] = color.RGBA{c[0], c[1], c[2], 255}
+		}
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), palette[:classes])
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			best := 0
+			if channels == 1 {
+				best = int(at(x, y, 0))
+			} else {
+				for c := 1; c < channels; c++ {
+					if at(x, y, c) > at(x, y, best) {
+						best = c
+					}
+				}
+			}
+			if best >= 0 && best < 256 {
+				img.Pix[y*img.Stride+x] = uint8(best)
+			}
+		}
+	}
+	return img
+}
+
+// segments appends to dets the named classes of a segmentation map which
+// cover more than their threshold of it, the fraction covered being their
+// confidence, in index order.
+func (f *Graph) segments(m *image.Paletted, dets []detection) []detection {
+	var counts [256]int
+	for y := 0; y < m.Rect.Dy(); y++ {
+		for _, c := range m.Pix[y*m.Stride : y*m.Stride+m.Rect.Dx()] {
+			counts[c]++
+		}
+	}
+
+	total := float32(m.Rect.Dx() * m.Rect.Dy())
+	for i, n := range counts {
+		name, ok := f.Names[i]
+		if !ok || n == 0 {
+			continue
+		}
+		if frac := float32(n) / total; frac > f.threshold(i) {
+			dets = append(dets, detection{name: name, confidence: frac})
+		}
+	}
+	return dets
+}
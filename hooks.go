@@ -0,0 +1,125 @@
+package mvnc
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Detection is a single name detected in a frame.  Class is the index of
+// its class, and Box and Keypoints, as returned by a Postprocessor, are
+// where it was found in the frame if the graph locates what it detects,
+// and Attributes what else it estimated of it.  Source is the id of the
+// Multiplexer source the frame was read from.  The handlers of OnDetection
+// are sent the FrameID, Source, Name and Confidence alone.
+type Detection struct {
+	FrameID    uint64
+	Source     string
+	Class      int
+	Name       string
+	Confidence float32
+	Box        *BoundingBox
+	Keypoints  []Keypoint
+	Attributes map[string]Attribute
+}
+
+// hooks are the handlers registered with OnDetection, OnFrame and OnError,
+// and the sinks attached with AddSink.
+type hooks struct {
+	mu        sync.Mutex
+	detection []func(Detection)
+	frame     []func(Result)
+	err       []func(error)
+	sinks     []*BufferedSink
+}
+
+// OnDetection registers fn to be called with every name Process, a Pool or
+// a Multiplexer sends, after Smoothing.
+//
+// Handlers registered with OnDetection and OnFrame are called from the
+// goroutine completing each inference, in the order the frames were read
+// for Process and a Pool, and for each source of a Multiplexer.  For each
+// frame the OnDetection handlers are called with each name, then the
+// OnFrame handlers with the Result, before the names are sent on the
+// channel.  A handler which blocks holds up the pipeline just as an unread
+// channel does; AddSink buffers a slow consumer instead.  Handlers may be
+// registered at any time, including from another handler, and are called in
+// the order they were registered.
+func (f *Graph) OnDetection(fn func(Detection)) {
+	f.hooks.mu.Lock()
+	defer f.hooks.mu.Unlock()
+
+	f.hooks.detection = append(f.hooks.detection, fn)
+}
+
+// OnFrame registers fn to be called with the Result of every frame run by
+// Process, a Pool or a Multiplexer, as described for OnDetection.
+func (f *Graph) OnFrame(fn func(Result)) {
+	f.hooks.mu.Lock()
+	defer f.hooks.mu.Unlock()
+
+	f.hooks.frame = append(f.hooks.frame, fn)
+}
+
+// OnError registers fn to be called with every error which stops or
+// restarts Process, a Pool, a Multiplexer source or a Pipeline, other than
+// the reader reaching io.EOF.  The errors are also logged.  fn is called
+// from the goroutine that failed, which may be the drain goroutine of one
+// of the graph's sticks.
+func (f *Graph) OnError(fn func(error)) {
+	f.hooks.mu.Lock()
+	defer f.hooks.mu.Unlock()
+
+	f.hooks.err = append(f.hooks.err, fn)
+}
+
+// handlers returns the handlers currently registered, so they can be called
+// without holding the lock.
+func (h *hooks) handlers() ([]func(Detection), []func(Result), []func(error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.detection, h.frame, h.err
+}
+
+// attached returns the sinks currently attached, as handlers does.
+func (h *hooks) attached() []*BufferedSink {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.sinks
+}
+
+// detach removes b from the sinks.  The slice is copied, since attached may
+// have returned it to a goroutine still ranging over it.
+func (h *hooks) detach(b *BufferedSink) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sinks := make([]*BufferedSink, 0, len(h.sinks))
+	for _, s := range h.sinks {
+		if s != b {
+			sinks = append(sinks, s)
+		}
+	}
+	h.sinks = sinks
+}
+
+// fail logs err and passes it to the OnError handlers.
+func (f *Graph) fail(err error) {
+	f.logf("%v", err)
+	f.notify(err)
+}
+
+// notify passes err to the OnError handlers, unless it is the end of the
+// reader.
+func (f *Graph) notify(err error) {
+	if errors.Is(err, io.EOF) {
+		return
+	}
+
+	_, _, errs := f.hooks.handlers()
+	for _, fn := range errs {
+		fn(err)
+	}
+}
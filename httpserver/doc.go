@@ -0,0 +1,37 @@
+// Package httpserver serves a graph over HTTP, turning a host with a Neural
+// Compute Stick into an inference appliance.
+//
+//	graph := &mvnc.Graph{GraphFile: "graph", Names: names, Threshold: 0.5}
+//	log.Fatal(http.ListenAndServe(":8080", &httpserver.Server{Graph: graph}))
+//
+// POST a JPEG or PNG image to /infer to run it through the graph:
+//
+//	curl --data-binary @dog.jpg http://pi:8080/infer
+//
+// and the detections are returned as JSON:
+//
+//	{"detections":[{"name":"dog","confidence":0.93}],"latency_ms":41.2}
+//
+// Add ?output=1 to also return the graph's raw output tensor.  GET /stats
+// returns the graph's Stats.
+//
+// A Feed pushes the results of a graph processing a stream to browsers over
+// a WebSocket, served at /live when set as the Server's Feed:
+//
+//	feed := &httpserver.Feed{}
+//	results, frames := make(chan mvnc.Result), make(chan image.Image)
+//	graph.Results = results
+//	graph.Annotate = &mvnc.Annotator{Images: frames}
+//	go feed.Run(results)
+//	go feed.RunFrames(frames)
+//	go graph.Process(camera)
+//
+//	log.Fatal(http.ListenAndServe(":8080", &httpserver.Server{Graph: graph, Feed: feed}))
+//
+// Each result is sent as a JSON text message:
+//
+//	{"frame_id":42,"detections":[{"name":"dog","confidence":0.93}],"latency_ms":41.2}
+//
+// and connecting to /live?frames=1 also receives each annotated frame as a
+// binary message holding a JPEG.
+package httpserver
@@ -0,0 +1,217 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// DefaultFeedBuffer is the number of messages queued for each client of a
+// Feed if Feed.Buffer is zero.
+const DefaultFeedBuffer = 8
+
+// Feed pushes the results of a graph processing a stream to WebSocket
+// clients as they are produced, for live dashboards.  Each result is sent as
+// a text message holding an Update.  Clients connecting with ?frames=1 are
+// also sent the annotated frames, as binary messages holding a JPEG.
+//
+// Clients which fall behind miss messages rather than holding up the
+// stream.
+type Feed struct {
+	// Quality is the quality of the JPEG frames, from 1 to 100.  It defaults
+	// to jpeg.DefaultQuality.
+	Quality int
+
+	// Buffer is the number of messages queued for each client.
+	Buffer int
+
+	mu      sync.Mutex
+	clients map[*feedClient]struct{}
+	closed  bool
+}
+
+// Update is the message sent to clients of a Feed for each result.
+type Update struct {
+	FrameID    uint64      `json:"frame_id"`
+	Detections []Detection `json:"detections"`
+	Boxes      []Box       `json:"boxes,omitempty"`
+	LatencyMS  float64     `json:"latency_ms"`
+}
+
+type feedMessage struct {
+	op      byte
+	payload []byte
+}
+
+type feedClient struct {
+	ws     *wsConn
+	frames bool
+	send   chan feedMessage
+}
+
+// Run sends every result received to the clients until results is closed,
+// then closes their connections.  Connect it to a graph through
+// Graph.Results.
+func (f *Feed) Run(results <-chan mvnc.Result) {
+	defer f.close()
+
+	for res := range results {
+		u := Update{
+			FrameID:   res.FrameID,
+			LatencyMS: float64(res.Timing.Latency) / float64(time.Millisecond),
+		}
+		u.Detections, u.Boxes = convert(res)
+
+		payload, err := json.Marshal(u)
+		if err != nil {
+			continue
+		}
+		f.broadcast(feedMessage{op: opText, payload: payload}, false)
+	}
+}
+
+// RunFrames sends every frame received to the clients which asked for
+// frames, until frames is closed.  Connect it to a graph through
+// Annotator.Images.
+func (f *Feed) RunFrames(frames <-chan image.Image) {
+	quality := f.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	for img := range frames {
+		if !f.wantFrames() {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			continue
+		}
+		f.broadcast(feedMessage{op: opBinary, payload: buf.Bytes()}, true)
+	}
+}
+
+func (f *Feed) wantFrames() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for c := range f.clients {
+		if c.frames {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcast queues m for every client, or only those which asked for
+// frames, dropping it for clients whose queue is full.
+func (f *Feed) broadcast(m feedMessage, frame bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for c := range f.clients {
+		if frame && !c.frames {
+			continue
+		}
+		select {
+		case c.send <- m:
+		default:
+		}
+	}
+}
+
+func (f *Feed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for c := range f.clients {
+		close(c.send)
+		delete(f.clients, c)
+	}
+}
+
+// wantsFrames reports whether the client asked for the frames, with the
+// frames parameter of r.
+func wantsFrames(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("frames")
+	if v == "" {
+		return false, nil
+	}
+
+	frames, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid frames parameter '%s'", v)
+	}
+	return frames, nil
+}
+
+func (f *Feed) remove(c *feedClient) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.clients[c]; ok {
+		close(c.send)
+		delete(f.clients, c)
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket and sends it the feed.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	frames, err := wantsFrames(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ws := upgrade(w, r)
+	if ws == nil {
+		return
+	}
+
+	buffer := f.Buffer
+	if buffer == 0 {
+		buffer = DefaultFeedBuffer
+	}
+	c := &feedClient{
+		ws:     ws,
+		frames: frames,
+		send:   make(chan feedMessage, buffer),
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		ws.close(1001)
+		return
+	}
+	if f.clients == nil {
+		f.clients = make(map[*feedClient]struct{})
+	}
+	f.clients[c] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		ws.readLoop()
+		f.remove(c)
+		ws.conn.Close()
+	}()
+
+	for m := range c.send {
+		if err := ws.write(m.op, m.payload); err != nil {
+			f.remove(c)
+			ws.conn.Close()
+			return
+		}
+	}
+	ws.close(1001)
+}
@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWantsFrames(t *testing.T) {
+	tests := []struct {
+		query  string
+		frames bool
+		ok     bool
+	}{
+		{"", false, true},
+		{"?frames=1", true, true},
+		{"?frames=true", true, true},
+		{"?frames=0", false, true},
+		{"?frames=false", false, true},
+		{"?frames=yes", false, false},
+	}
+
+	for _, tt := range tests {
+		frames, err := wantsFrames(httptest.NewRequest(http.MethodGet, "/feed"+tt.query, nil))
+		if (err == nil) != tt.ok || frames != tt.frames {
+			t.Errorf("%q wants frames %v (%v), want %v", tt.query, frames, err, tt.frames)
+		}
+	}
+}
+
+func TestFeedBadFrames(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&Feed{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed?frames=yes", nil))
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "frames") {
+		t.Errorf("returned %d %s, want 400 for the frames parameter", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,161 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// DefaultMaxBytes is the largest image accepted if Server.MaxBytes is zero.
+const DefaultMaxBytes = 10 << 20
+
+// Server is an http.Handler running the images posted to it through Graph.
+// Requests are served concurrently, and their inferences pipelined through
+// the graph's fifos.
+type Server struct {
+	Graph *mvnc.Graph
+
+	// MaxBytes limits the size of the images accepted.
+	MaxBytes int64
+
+	// Feed, if non-nil, is served as a WebSocket at /live.
+	Feed *Feed
+}
+
+// Detection is a name detected in an image and its confidence.
+type Detection struct {
+	Name       string  `json:"name"`
+	Confidence float32 `json:"confidence"`
+}
+
+// Box is a bounding box found by an SSD or YOLO graph, with coordinates
+// normalized to [0, 1] across the image.
+type Box struct {
+	Class      int     `json:"class"`
+	Name       string  `json:"name,omitempty"`
+	Confidence float32 `json:"confidence"`
+	XMin       float32 `json:"xmin"`
+	YMin       float32 `json:"ymin"`
+	XMax       float32 `json:"xmax"`
+	YMax       float32 `json:"ymax"`
+}
+
+// Response is the body returned by /infer.
+type Response struct {
+	Detections []Detection `json:"detections"`
+	Boxes      []Box       `json:"boxes,omitempty"`
+	Output     []float32   `json:"output,omitempty"`
+	LatencyMS  float64     `json:"latency_ms"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/infer":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST to send an image"))
+			return
+		}
+		s.infer(w, r)
+
+	case "/stats":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET for stats"))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Graph.Stats())
+
+	case "/live":
+		if s.Feed == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no live feed is configured"))
+			return
+		}
+		s.Feed.ServeHTTP(w, r)
+
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	}
+}
+
+func (s *Server) infer(w http.ResponseWriter, r *http.Request) {
+	max := s.MaxBytes
+	if max == 0 {
+		max = DefaultMaxBytes
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, fmt.Errorf("error reading image: %w", err))
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error decoding image: %w", err))
+		return
+	}
+
+	start := time.Now()
+	output, err := s.Graph.InferImage(r.Context(), img)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	latency := time.Since(start)
+
+	res, err := s.Graph.Decode(output)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := Response{LatencyMS: float64(latency) / float64(time.Millisecond)}
+	resp.Detections, resp.Boxes = convert(res)
+	if r.URL.Query().Get("output") != "" {
+		resp.Output = output
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// convert returns the detections and boxes of res as they are sent to
+// clients.
+func convert(res mvnc.Result) ([]Detection, []Box) {
+	dets := make([]Detection, len(res.Names))
+	for i, name := range res.Names {
+		dets[i] = Detection{Name: name, Confidence: res.Confidences[i]}
+	}
+
+	var boxes []Box
+	for _, b := range res.Boxes {
+		boxes = append(boxes, Box{Class: b.Class, Name: b.Name, Confidence: b.Confidence, XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax})
+	}
+	return dets, boxes
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
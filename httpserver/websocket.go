@@ -0,0 +1,190 @@
+package httpserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// the opcodes of RFC 6455 frames
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xa
+)
+
+// maxControlPayload is the largest payload of a control frame, and the
+// largest frame read from a client, since clients only need to send
+// control frames.
+const maxControlPayload = 125
+
+// wsConn is the server side of a WebSocket connection.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu sync.Mutex // held while writing a frame
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgrade completes the WebSocket handshake of r, or writes an error and
+// returns nil.
+func upgrade(w http.ResponseWriter, r *http.Request) *wsConn {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET to open a WebSocket"))
+		return nil
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("this endpoint is a WebSocket"))
+		return nil
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, fmt.Errorf("unsupported WebSocket version '%s'", r.Header.Get("Sec-WebSocket-Version")))
+		return nil
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing Sec-WebSocket-Key"))
+		return nil
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("connection cannot be upgraded to a WebSocket"))
+		return nil
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("error upgrading to a WebSocket: %w", err))
+		return nil
+	}
+
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil
+	}
+
+	return &wsConn{conn: conn, br: rw.Reader}
+}
+
+// write sends a single unfragmented frame; servers don't mask their frames.
+func (c *wsConn) write(op byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | op
+
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// close sends a close frame with the given status code and closes the
+// connection.
+func (c *wsConn) close(code uint16) {
+	c.write(opClose, binary.BigEndian.AppendUint16(nil, code))
+	c.conn.Close()
+}
+
+var errClientClosed = errors.New("client closed the WebSocket")
+
+// readLoop reads the frames sent by the client, answering pings, until
+// the client closes the connection or breaks the protocol.  Clients of a
+// feed have nothing to say, so data frames are discarded.
+func (c *wsConn) readLoop() error {
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(c.br, h[:]); err != nil {
+			return err
+		}
+
+		op := h[0] & 0x0f
+		if h[1]&0x80 == 0 {
+			c.close(1002)
+			return fmt.Errorf("client sent an unmasked frame")
+		}
+
+		n := uint64(h[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if n > maxControlPayload {
+			c.close(1009)
+			return fmt.Errorf("client sent a frame of %d bytes", n)
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return err
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case opClose:
+			c.close(1000)
+			return errClientClosed
+		case opPing:
+			if err := c.write(opPong, payload); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package mvnc
+
+import (
+	"image"
+)
+
+// ImageReader turns a sequence of decoded images, such as those of a
+// FrameSource, into the raw RGB frames read by Process.  Each image
+// returned by Next is cropped about its center to the aspect ratio of the
+// frames and resized to Width by Height, which should match the graph's
+// Width and Height, or its input tensor.
+type ImageReader struct {
+	Width, Height int
+
+	// Next returns the next image, or an error such as io.EOF to end the
+	// stream.
+	Next func() (image.Image, error)
+
+	frame []byte
+	pos   int
+}
+
+// Read reads the raw RGB bytes of the frames.
+func (r *ImageReader) Read(p []byte) (int, error) {
+	if size := r.Width * r.Height * 3; len(r.frame) != size {
+		r.frame, r.pos = make([]byte, size), size
+	}
+
+	if r.pos == len(r.frame) {
+		img, err := r.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		resizeRGB(r.frame, r.Width, r.Height, img)
+		r.pos = 0
+	}
+
+	n := copy(p, r.frame[r.pos:])
+	r.pos += n
+	return n, nil
+}
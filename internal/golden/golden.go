@@ -0,0 +1,144 @@
+// Package golden checks the decoding of recorded output tensors against the
+// detections expected of them, for the golden test of the mvnc package and
+// the mvnc-golden command.
+//
+// Each JSON file of a corpus is a Case: the config of the graph, as read by
+// mvnc.ParseConfig, the output tensor, and the names, confidences and boxes
+// Graph.Decode is expected to return for it.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"github.com/donniet/mvnc"
+)
+
+// Case is a golden file.
+type Case struct {
+	Description string          `json:"description,omitempty"`
+	Config      json.RawMessage `json:"config"`
+	Output      []float32       `json:"output"`
+	Want        Want            `json:"want"`
+}
+
+// Want is the expected decoding of a case's output.
+type Want struct {
+	Names       []string  `json:"names"`
+	Confidences []float32 `json:"confidences"`
+	Boxes       []Box     `json:"boxes,omitempty"`
+}
+
+// Box is the JSON form of a bounding box.
+type Box struct {
+	Class      int     `json:"class"`
+	Name       string  `json:"name,omitempty"`
+	Confidence float32 `json:"confidence"`
+	XMin       float32 `json:"xmin"`
+	YMin       float32 `json:"ymin"`
+	XMax       float32 `json:"xmax"`
+	YMax       float32 `json:"ymax"`
+}
+
+// Tolerance is the largest difference allowed between an expected and a
+// decoded value, to allow for the rounding of the golden files.
+const Tolerance = 1e-5
+
+// Files returns the paths of the cases in dir, in order.
+func Files(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	} else if len(paths) == 0 {
+		return nil, fmt.Errorf("no golden files in %s", dir)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Check decodes the output of the case at path and compares it with, or
+// with update writes it to, its expected results.
+func Check(path string, update bool) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var c Case
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+
+	cfg, err := mvnc.ParseConfig(c.Config)
+	if err != nil {
+		return fmt.Errorf("error parsing config: %w", err)
+	}
+	if cfg.Graph == "" {
+		// the graph is never opened, Decode only needs its config
+		cfg.Graph = "golden.graph"
+	}
+
+	g, err := cfg.NewGraph()
+	if err != nil {
+		return err
+	}
+
+	res, err := g.Decode(c.Output)
+	if err != nil {
+		return err
+	}
+	got := Want{Names: res.Names, Confidences: res.Confidences}
+	for _, b := range res.Boxes {
+		got.Boxes = append(got.Boxes, Box{Class: b.Class, Name: b.Name, Confidence: b.Confidence, XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax})
+	}
+
+	if update {
+		c.Want = got
+		return write(path, &c)
+	}
+	return compare(got, c.Want)
+}
+
+func write(path string, c *Case) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func compare(got, want Want) error {
+	if len(got.Names) != len(want.Names) {
+		return fmt.Errorf("decoded names %q, want %q", got.Names, want.Names)
+	}
+	for i := range want.Names {
+		if got.Names[i] != want.Names[i] || !near(got.Confidences[i], want.Confidences[i]) {
+			return fmt.Errorf("detection %d is %s (%v), want %s (%v)", i,
+				got.Names[i], got.Confidences[i], want.Names[i], want.Confidences[i])
+		}
+	}
+
+	if len(got.Boxes) != len(want.Boxes) {
+		return fmt.Errorf("decoded %d boxes, want %d", len(got.Boxes), len(want.Boxes))
+	}
+	for i, w := range want.Boxes {
+		b := got.Boxes[i]
+		if b.Class != w.Class || b.Name != w.Name || !near(b.Confidence, w.Confidence) ||
+			!near(b.XMin, w.XMin) || !near(b.YMin, w.YMin) || !near(b.XMax, w.XMax) || !near(b.YMax, w.YMax) {
+			return fmt.Errorf("box %d is %+v, want %+v", i, b, w)
+		}
+	}
+
+	return nil
+}
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a)-float64(b)) <= Tolerance
+}
@@ -0,0 +1,108 @@
+package mvnc
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSONLWriter writes the detections of each Result as JSON Lines, one
+// object per detection, so that they can be piped into jq, files or log
+// shippers:
+//
+//	{"frame_id":42,"time":"2019-06-01T12:00:00.5Z","name":"dog","confidence":0.93,"box":{"class":12,"xmin":0.1,"ymin":0.2,"xmax":0.5,"ymax":0.9}}
+//
+// The detections of an SSD or YOLO graph are its named boxes, and those of
+// other graphs the names in the Result.  It is safe for concurrent use.
+type JSONLWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLWriter returns a JSONLWriter writing to w.
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{w: w}
+}
+
+type jsonlDetection struct {
+	FrameID    uint64    `json:"frame_id"`
+	Source     string    `json:"source,omitempty"`
+	Time       time.Time `json:"time"`
+	Name       string    `json:"name"`
+	Confidence float32   `json:"confidence"`
+	Box        *jsonlBox `json:"box,omitempty"`
+}
+
+type jsonlBox struct {
+	Class int     `json:"class"`
+	XMin  float32 `json:"xmin"`
+	YMin  float32 `json:"ymin"`
+	XMax  float32 `json:"xmax"`
+	YMax  float32 `json:"ymax"`
+}
+
+// Write writes a line for each detection in r.  Each line is written with a
+// single call to the underlying writer.
+func (j *JSONLWriter) Write(r Result) error {
+	dets := jsonlDetections(r)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, d := range dets {
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+
+		if _, err := j.w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying writer if it is an io.Closer, such as the
+// file the lines are written to.
+func (j *JSONLWriter) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// jsonlDetections returns the detections of r.
+func jsonlDetections(r Result) []jsonlDetection {
+	var dets []jsonlDetection
+	if r.Boxes != nil {
+		for _, b := range r.Boxes {
+			if b.Name == "" {
+				continue
+			}
+			dets = append(dets, jsonlDetection{
+				FrameID: r.FrameID, Source: r.Source, Time: r.Time, Name: b.Name, Confidence: b.Confidence,
+				Box: &jsonlBox{Class: b.Class, XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax},
+			})
+		}
+	} else {
+		for i, name := range r.Names {
+			dets = append(dets, jsonlDetection{FrameID: r.FrameID, Source: r.Source, Time: r.Time, Name: name, Confidence: r.Confidences[i]})
+		}
+	}
+	return dets
+}
+
+// Run writes every result received until results is closed or writing
+// fails.  Connect it to a graph through Results.
+func (j *JSONLWriter) Run(results <-chan Result) error {
+	for r := range results {
+		if err := j.Write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
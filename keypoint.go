@@ -0,0 +1,124 @@
+package mvnc
+
+import (
+	"image"
+)
+
+// Keypoint is a landmark found by a facial-landmark or pose-estimation
+// graph, such as the corner of an eye or a wrist.  Like a BoundingBox its
+// coordinates are normalized to [0, 1] across the frame the graph saw, with
+// (0, 0) at the top left, and are mapped back to the whole frame where it
+// was cropped to its region of interest or letterboxed.
+type Keypoint struct {
+	Name       string
+	X, Y       float32
+	Confidence float32
+}
+
+// Point returns the keypoint in pixels, given the rectangle r of the frame
+// the graph saw.
+func (k Keypoint) Point(r image.Rectangle) image.Point {
+	return image.Pt(r.Min.X+int(k.X*float32(r.Dx())+0.5), r.Min.Y+int(k.Y*float32(r.Dy())+0.5))
+}
+
+// within maps k, relative to the rectangle r of a frame with the given
+// bounds, to be relative to the whole frame.
+func (k Keypoint) within(r, bounds image.Rectangle) Keypoint {
+	b := BoundingBox{XMin: k.X, YMin: k.Y}.within(r, bounds)
+	k.X, k.Y = b.XMin, b.YMin
+	return k
+}
+
+// KeypointConfig describes the output of a Keypoints graph: a tuple of
+// (x, y) or (x, y, confidence) for each keypoint of an object, and the
+// tuples of each object in turn for graphs finding several.
+type KeypointConfig struct {
+	// Names are the names of the keypoints of an object, in the order of
+	// the output, and give their number.  Without Names the whole output is
+	// the unnamed keypoints of a single object.
+	Names []string `json:"names,omitempty"`
+
+	// Confidence is set if each tuple ends with the keypoint's confidence.
+	// Keypoints not above the graph's Threshold are then left out.
+	Confidence bool `json:"confidence,omitempty"`
+
+	// InputW and InputH, if set, are the size of the graph's input, for
+	// graphs giving coordinates in input pixels rather than normalized.
+	InputW int `json:"input_w,omitempty"`
+	InputH int `json:"input_h,omitempty"`
+}
+
+// Decode decodes the output of a Keypoints graph into the keypoints of each
+// object, leaving out those with a confidence not above threshold, and
+// objects with none left.
+func (k *KeypointConfig) Decode(output []float32, threshold float32) [][]Keypoint {
+	var cfg KeypointConfig
+	if k != nil {
+		cfg = *k
+	}
+
+	stride := 2
+	if cfg.Confidence {
+		stride = 3
+	}
+	n := len(cfg.Names)
+	if n == 0 {
+		n = len(output) / stride
+	}
+	if n == 0 {
+		return nil
+	}
+
+	var objects [][]Keypoint
+	for o := 0; (o+1)*n*stride <= len(output); o++ {
+		var points []Keypoint
+		for i := 0; i < n; i++ {
+			t := output[(o*n+i)*stride:][:stride]
+			if !finite(t...) {
+				continue
+			}
+
+			p := Keypoint{X: t[0], Y: t[1], Confidence: 1}
+			if i < len(cfg.Names) {
+				p.Name = cfg.Names[i]
+			}
+			if cfg.Confidence {
+				if p.Confidence = t[2]; p.Confidence <= threshold {
+					continue
+				}
+			}
+			if cfg.InputW > 0 && cfg.InputH > 0 {
+				p.X, p.Y = p.X/float32(cfg.InputW), p.Y/float32(cfg.InputH)
+			}
+			points = append(points, p)
+		}
+
+		if len(points) > 0 {
+			objects = append(objects, points)
+		}
+	}
+	return objects
+}
+
+// KeypointPostprocessor decodes the output of a Keypoints graph described
+// by Config, like the Keypoints OutputFormat, into a Detection of each
+// object, named Name, whose confidence is the mean of its keypoints'.
+type KeypointPostprocessor struct {
+	Config    *KeypointConfig
+	Name      string
+	Threshold float32
+}
+
+func (p *KeypointPostprocessor) Decode(output []float32, meta FrameMeta) []Detection {
+	objects := p.Config.Decode(output, p.Threshold)
+
+	dets := make([]Detection, len(objects))
+	for i, points := range objects {
+		var sum float32
+		for _, k := range points {
+			sum += k.Confidence
+		}
+		dets[i] = Detection{FrameID: meta.FrameID, Name: p.Name, Confidence: sum / float32(len(points)), Keypoints: points}
+	}
+	return dets
+}
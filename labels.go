@@ -0,0 +1,178 @@
+package mvnc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LoadLabels reads a label file, ready to assign to Graph.Names.  Three
+// formats are recognized from the contents of the file:
+//
+//   - plain text, with the label of class i on line i, counting from zero;
+//   - ImageNet synset_words.txt, with a synset id and a comma separated list
+//     of names on each line, of which the first is used;
+//   - TensorFlow label_map.pbtxt, using each item's display_name, or its
+//     name if it has none, at its id.
+func LoadLabels(path string) (map[int]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := ReadLabels(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error reading labels from %s: %w", path, err)
+	}
+
+	return names, nil
+}
+
+var (
+	synsetLine = regexp.MustCompile(`^n[0-9]{8}\s+`)
+
+	// a label map may start with comments, such as a license header
+	pbtxtStart = regexp.MustCompile(`^(\s*#[^\n]*\n)*\s*item\s*\{`)
+)
+
+// ReadLabels is like LoadLabels, reading the labels from r.
+func ReadLabels(r io.Reader) (map[int]string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if pbtxtStart.Match(b) {
+		return readLabelMap(string(b))
+	}
+
+	names := make(map[int]string)
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for i := 0; s.Scan(); i++ {
+		line := strings.TrimSpace(s.Text())
+
+		if loc := synsetLine.FindStringIndex(line); loc != nil {
+			line = strings.TrimSpace(strings.SplitN(line[loc[1]:], ",", 2)[0])
+		}
+
+		if line != "" {
+			names[i] = line
+		}
+	}
+
+	return names, s.Err()
+}
+
+// readLabelMap parses the items of a TensorFlow label_map.pbtxt.
+func readLabelMap(text string) (map[int]string, error) {
+	names := make(map[int]string)
+
+	toks, err := pbtxtTokens(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		depth             int
+		id                = -1
+		name, displayName string
+	)
+
+	for i := 0; i < len(toks); i++ {
+		switch tok := toks[i]; tok {
+		case "{":
+			depth++
+			if depth == 1 {
+				id, name, displayName = -1, "", ""
+			}
+		case "}":
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced '}' in label map")
+			} else if depth > 0 {
+				continue
+			}
+
+			if id < 0 {
+				return nil, fmt.Errorf("label map item without an id")
+			} else if displayName != "" {
+				names[id] = displayName
+			} else {
+				names[id] = name
+			}
+		case "id", "name", "display_name":
+			if depth != 1 || i+2 >= len(toks) || toks[i+1] != ":" {
+				continue
+			}
+			value := toks[i+2]
+			i += 2
+
+			switch tok {
+			case "id":
+				if id, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("invalid label map id '%s'", value)
+				}
+			case "name":
+				name = value
+			case "display_name":
+				displayName = value
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("unterminated item in label map")
+	}
+
+	return names, nil
+}
+
+// pbtxtTokens splits protobuf text format into braces, colons, identifiers
+// and the contents of quoted strings, dropping comments.  Escapes within
+// strings are left as they are.
+func pbtxtTokens(text string) ([]string, error) {
+	var toks []string
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ',' || c == ';':
+			i++
+		case c == '#':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ':':
+			toks = append(toks, string(c))
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(text) && text[j] != c {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("unterminated string in label map")
+			}
+			toks = append(toks, text[i+1:j])
+			i = j + 1
+		default:
+			j := i
+			for j < len(text) && !strings.ContainsRune(" \t\r\n{}:,;#\"'", rune(text[j])) {
+				j++
+			}
+			toks = append(toks, text[i:j])
+			i = j
+		}
+	}
+
+	return toks, nil
+}
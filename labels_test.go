@@ -0,0 +1,36 @@
+package mvnc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want map[int]string
+	}{
+		{"plain text", "background\nperson\n\ncar\n", map[int]string{0: "background", 1: "person", 3: "car"}},
+		{"synset words", "n01440764 tench, Tinca tinca\nn01443537 goldfish, Carassius auratus\n", map[int]string{0: "tench", 1: "goldfish"}},
+		{"label map", "item {\n  id: 1\n  name: 'person'\n}\nitem {\n  id: 3\n  name: '/m/0k4j'\n  display_name: 'car'\n}\n", map[int]string{1: "person", 3: "car"}},
+		{"commented label map", "# Copyright 2018 The TensorFlow Authors.\n#\n# Licensed under the Apache License.\n\nitem {\n  id: 1\n  name: 'person'\n}\n", map[int]string{1: "person"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := ReadLabels(strings.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(names) != len(tt.want) {
+				t.Errorf("read %v, want %v", names, tt.want)
+			}
+			for i, name := range tt.want {
+				if names[i] != name {
+					t.Errorf("label %d is %q, want %q", i, names[i], name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package mvnc
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LeakCheck starts recording every device, graph and fifo handle the package
+// creates, and returns a function which stops recording and reports those
+// not destroyed since, with the stack they were created from.  It is meant
+// for tests, to check that everything opened is closed again:
+//
+//	check := mvnc.LeakCheck()
+//	defer func() {
+//		if err := check(); err != nil {
+//			t.Error(err)
+//		}
+//	}()
+//
+// Like UseFake, LeakCheck must not be called while the package is in use,
+// and when both are used UseFake is called first.
+func LeakCheck() (check func() error) {
+	l := &leakAPI{deviceAPI: api, open: make(map[interface{}]leak)}
+	api = l
+
+	return func() error {
+		api = l.deviceAPI
+		return l.err()
+	}
+}
+
+// leakAPI wraps a deviceAPI, recording the handles created through it until
+// they are destroyed.
+type leakAPI struct {
+	deviceAPI
+
+	mu   sync.Mutex
+	seq  int
+	open map[interface{}]leak
+}
+
+// leak is a handle recorded by leakAPI.
+type leak struct {
+	seq   int // order of creation
+	kind  string
+	stack []byte
+}
+
+func (l *leakAPI) created(kind string, h interface{}, ret Status) {
+	if ret != OK || h == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	l.open[h] = leak{seq: l.seq, kind: kind, stack: debug.Stack()}
+}
+
+func (l *leakAPI) destroyed(h interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.open, h)
+}
+
+// err describes the handles still open, or returns nil if there are none.
+func (l *leakAPI) err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.open) == 0 {
+		return nil
+	}
+
+	leaks := make([]leak, 0, len(l.open))
+	for _, lk := range l.open {
+		leaks = append(leaks, lk)
+	}
+	sort.Slice(leaks, func(i, j int) bool { return leaks[i].seq < leaks[j].seq })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d handles were not destroyed:", len(leaks))
+	for _, lk := range leaks {
+		fmt.Fprintf(&b, "\n\n%s created at:\n%s", lk.kind, lk.stack)
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+func (l *leakAPI) deviceCreate(index int) (deviceHandle, Status) {
+	d, ret := l.deviceAPI.deviceCreate(index)
+	l.created(fmt.Sprintf("device %d", index), d, ret)
+	return d, ret
+}
+
+func (l *leakAPI) deviceDestroy(d deviceHandle) Status {
+	l.destroyed(d)
+	return l.deviceAPI.deviceDestroy(d)
+}
+
+func (l *leakAPI) graphCreate(name string) (graphHandle, Status) {
+	g, ret := l.deviceAPI.graphCreate(name)
+	l.created(fmt.Sprintf("graph %q", name), g, ret)
+	return g, ret
+}
+
+func (l *leakAPI) graphAllocateWithFifos(d deviceHandle, g graphHandle, file []byte, in, out FifoConfig) (input, output fifoHandle, _ Status) {
+	input, output, ret := l.deviceAPI.graphAllocateWithFifos(d, g, file, in, out)
+	l.created("input fifo", input, ret)
+	l.created("output fifo", output, ret)
+	return input, output, ret
+}
+
+func (l *leakAPI) graphDestroy(g graphHandle) Status {
+	l.destroyed(g)
+	return l.deviceAPI.graphDestroy(g)
+}
+
+func (l *leakAPI) fifoCreate(name string, input bool) (fifoHandle, Status) {
+	f, ret := l.deviceAPI.fifoCreate(name, input)
+	l.created(fmt.Sprintf("fifo %q", name), f, ret)
+	return f, ret
+}
+
+func (l *leakAPI) fifoDestroy(f fifoHandle) Status {
+	l.destroyed(f)
+	return l.deviceAPI.fifoDestroy(f)
+}
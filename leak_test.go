@@ -0,0 +1,84 @@
+package mvnc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLeakCheck(t *testing.T) {
+	defer UseFake(testStick(0))()
+	check := LeakCheck()
+
+	g := testGraph(t)
+	if _, err := g.Infer(context.Background(), make([]float32, testFrameSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDevice(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag, err := d.AllocateGraph("test", []byte("graph"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := d.CreateFifo("in", HostWrite, ag.InputDescriptor(), FifoConfig{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := d.CreateFifo("out", HostRead, ag.OutputDescriptor(), FifoConfig{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.Write(make([]float32, in.Len()), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ag.QueueInference(in, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.Read(make([]float32, out.Len())); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, destroy := range []func() error{in.Destroy, out.Destroy, ag.Destroy, d.Close} {
+		if err := destroy(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLeakCheckReports(t *testing.T) {
+	defer UseFake(testStick(0))()
+	check := LeakCheck()
+
+	d, err := OpenDevice(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	f, err := d.CreateFifo("in", HostWrite, TensorDescriptor{N: 1, C: 3, W: 2, H: 2}, FifoConfig{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Destroy()
+
+	err = check()
+	if err == nil {
+		t.Fatal("the device and fifo left open were not reported")
+	}
+	for _, want := range []string{"2 handles were not destroyed", "device 0 created at", `fifo "in" created at`, "TestLeakCheckReports"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("the report does not contain %q:\n%v", want, err)
+		}
+	}
+}
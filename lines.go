@@ -0,0 +1,158 @@
+package mvnc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CountingLine is a virtual line across the frame, from From to To in the
+// normalized coordinates of boxes, which counts the tracked objects
+// crossing it.  An object's position is the middle of the bottom edge of
+// its box, where it stands, or its center if Center is set.
+type CountingLine struct {
+	Name   string     `json:"name"`
+	From   [2]float32 `json:"from"`
+	To     [2]float32 `json:"to"`
+	Center bool       `json:"center,omitempty"`
+
+	// Classes, if non-empty, limits the line to the objects of the
+	// classes it names.
+	Classes []string `json:"classes,omitempty"`
+}
+
+// Direction is the direction in which an object crosses a CountingLine.
+type Direction int
+
+// Directions: Forward crosses the line from its left to its right, facing
+// from From to To along it, and Backward the other way, so that a line
+// drawn left to right across a doorway at the top of the frame counts
+// people coming down into the frame as Forward.
+const (
+	Forward Direction = iota
+	Backward
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Forward:
+		return "forward"
+	case Backward:
+		return "backward"
+	default:
+		return "unknown"
+	}
+}
+
+func (d Direction) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parses forward or backward.
+func (d *Direction) UnmarshalText(b []byte) error {
+	for _, v := range []Direction{Forward, Backward} {
+		if strings.EqualFold(string(b), v.String()) {
+			*d = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown direction '%s'", b)
+}
+
+// CrossingEvent is a tracked object crossing a CountingLine, in the frame
+// with the given FrameID and Time.
+type CrossingEvent struct {
+	Line      string
+	TrackID   uint64
+	Name      string
+	Direction Direction
+	FrameID   uint64
+	Time      time.Time
+}
+
+// point returns the position of b for the line.
+func (l *CountingLine) point(b BoundingBox) [2]float32 {
+	if l.Center {
+		return [2]float32{(b.XMin + b.XMax) / 2, (b.YMin + b.YMax) / 2}
+	}
+	return [2]float32{(b.XMin + b.XMax) / 2, b.YMax}
+}
+
+// side returns which side of the line p is on: true for the right, facing
+// from From to To, and for the line itself, so that an object stopping on
+// the line crosses it once.
+func (l *CountingLine) side(p [2]float32) bool {
+	return side(l.From, l.To, p) >= 0
+}
+
+// crosses reports whether the object moving from p to q crosses the line,
+// and in which direction.
+func (l *CountingLine) crosses(p, q [2]float32) (Direction, bool) {
+	from, to := l.side(p), l.side(q)
+	if from == to {
+		return 0, false
+	}
+
+	// within the ends of the line
+	if a, b := side(p, q, l.From), side(p, q, l.To); a > 0 && b > 0 || a < 0 && b < 0 {
+		return 0, false
+	}
+
+	if to {
+		return Forward, true
+	}
+	return Backward, true
+}
+
+// side returns the cross product of b - a and p - a, positive if p is to
+// the right of the line from a to b with y pointing down.
+func side(a, b, p [2]float32) float32 {
+	return (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+}
+
+// cross returns the crossings of the track's counting lines by tr since it
+// was last reported, recording where it is now.
+func (t *Tracker) cross(tr *track, frameID uint64, at time.Time) []CrossingEvent {
+	if len(t.Lines) == 0 {
+		return nil
+	}
+	if tr.positions == nil {
+		tr.positions = make([][2]float32, len(t.Lines))
+		for i := range t.Lines {
+			tr.positions[i] = t.Lines[i].point(tr.Box)
+		}
+		return nil
+	}
+
+	var events []CrossingEvent
+	for i := range t.Lines {
+		l := &t.Lines[i]
+		q := l.point(tr.Box)
+		if len(l.Classes) > 0 && !contains(l.Classes, tr.Box.Name) {
+			tr.positions[i] = q
+			continue
+		}
+
+		if d, ok := l.crosses(tr.positions[i], q); ok {
+			events = append(events, CrossingEvent{Line: l.Name, TrackID: tr.ID, Name: tr.Box.Name, Direction: d, FrameID: frameID, Time: at})
+			if t.crossings == nil {
+				t.crossings = make(map[string][2]int)
+			}
+			n := t.crossings[l.Name]
+			n[d]++
+			t.crossings[l.Name] = n
+		}
+		tr.positions[i] = q
+	}
+	return events
+}
+
+// Crossings returns the number of objects the tracker has seen crossing
+// the counting line named line, Forward and Backward.
+func (t *Tracker) Crossings(line string) (forward, backward int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.crossings[line]
+	return n[Forward], n[Backward]
+}
@@ -0,0 +1,117 @@
+// Package metrics exports the statistics of graphs and the health of their
+// sticks as Prometheus metrics, so deployments can be monitored in Grafana.
+//
+//	collector := metrics.NewCollector(graph, nil, graph.Device)
+//	prometheus.MustRegister(collector)
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// It is a separate module so that the mvnc package itself does not depend
+// on the Prometheus client.
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/donniet/mvnc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector reporting the Stats, fifo fill levels
+// and pipeline depth of a graph, and the telemetry of the sticks given.  The
+// fill levels and pipeline are only reported while the graph is open, and
+// the telemetry of sticks while they are open.
+//
+// To collect several graphs, use one Collector each, distinguished by their
+// constant labels.
+type Collector struct {
+	Graph   *mvnc.Graph
+	Devices []*mvnc.Device
+
+	frames, dropped, inferences, errors *prometheus.Desc
+	latency                             *prometheus.Desc
+	fill, depth, inflight, waiting      *prometheus.Desc
+	temperature, throttling, memory     *prometheus.Desc
+}
+
+// NewCollector returns a Collector for graph and devices, whose metrics
+// have the given constant labels.
+func NewCollector(graph *mvnc.Graph, labels prometheus.Labels, devices ...*mvnc.Device) *Collector {
+	return &Collector{
+		Graph:   graph,
+		Devices: devices,
+
+		frames:      prometheus.NewDesc("mvnc_frames_total", "Frames read from the graph's sources.", nil, labels),
+		dropped:     prometheus.NewDesc("mvnc_frames_dropped_total", "Frames read but not run, because they were throttled or the pipeline was full.", nil, labels),
+		inferences:  prometheus.NewDesc("mvnc_inferences_total", "Inferences completed.", nil, labels),
+		errors:      prometheus.NewDesc("mvnc_errors_total", "Failed NCAPI calls, by status.", []string{"status"}, labels),
+		latency:     prometheus.NewDesc("mvnc_inference_latency_seconds", "Time from writing each input to the fifo to reading its output.", nil, labels),
+		fill:        prometheus.NewDesc("mvnc_fifo_fill_level", "Elements waiting in the graph's fifos.", []string{"fifo"}, labels),
+		depth:       prometheus.NewDesc("mvnc_pipeline_depth", "Inferences that may be in flight at once.", nil, labels),
+		inflight:    prometheus.NewDesc("mvnc_inferences_in_flight", "Inferences written to the stick and not yet read.", nil, labels),
+		waiting:     prometheus.NewDesc("mvnc_inferences_waiting", "Inferences waiting for one in flight to finish.", nil, labels),
+		temperature: prometheus.NewDesc("mvnc_device_temperature_celsius", "Most recent temperature of the stick.", []string{"device"}, labels),
+		throttling:  prometheus.NewDesc("mvnc_device_throttling_level", "Thermal throttling level of the stick, from 0 (none) to 2.", []string{"device"}, labels),
+		memory:      prometheus.NewDesc("mvnc_device_memory_used_bytes", "Memory in use on the stick.", []string{"device"}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.frames, c.dropped, c.inferences, c.errors, c.latency, c.fill, c.depth, c.inflight, c.waiting, c.temperature, c.throttling, c.memory} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.Graph.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.frames, prometheus.CounterValue, float64(st.Frames))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(st.Dropped))
+	ch <- prometheus.MustNewConstMetric(c.inferences, prometheus.CounterValue, float64(st.Inferences))
+
+	for status, n := range st.Errors {
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(n), statusName(status))
+	}
+
+	// Prometheus buckets are cumulative
+	buckets := make(map[float64]uint64, len(mvnc.LatencyBuckets))
+	var count uint64
+	for i, n := range st.Latencies {
+		count += uint64(n)
+		if i < len(mvnc.LatencyBuckets) {
+			buckets[mvnc.LatencyBuckets[i].Seconds()] = count
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.latency, count, st.TotalLatency.Seconds(), buckets)
+
+	if st.Depth > 0 {
+		ch <- prometheus.MustNewConstMetric(c.fill, prometheus.GaugeValue, float64(st.InputFill), "input")
+		ch <- prometheus.MustNewConstMetric(c.fill, prometheus.GaugeValue, float64(st.OutputFill), "output")
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(st.Depth))
+		ch <- prometheus.MustNewConstMetric(c.inflight, prometheus.GaugeValue, float64(st.InFlight))
+		ch <- prometheus.MustNewConstMetric(c.waiting, prometheus.GaugeValue, float64(st.Waiting))
+	}
+
+	for _, d := range c.Devices {
+		t, err := d.Telemetry()
+		if err != nil {
+			continue
+		}
+
+		if len(t.Temperatures) > 0 {
+			ch <- prometheus.MustNewConstMetric(c.temperature, prometheus.GaugeValue, float64(t.Temperatures[0]), d.Name)
+		}
+		ch <- prometheus.MustNewConstMetric(c.throttling, prometheus.GaugeValue, float64(t.Throttling), d.Name)
+		ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(t.MemoryUsed), d.Name)
+	}
+}
+
+// statusName returns the name of the status, such as NC_TIMEOUT.
+func statusName(s mvnc.Status) string {
+	if name := strings.SplitN(s.Error(), ":", 2)[0]; strings.HasPrefix(name, "NC_") {
+		return name
+	}
+	return fmt.Sprint(int(s))
+}
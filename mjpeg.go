@@ -0,0 +1,151 @@
+package mvnc
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// MJPEGReader decodes a multipart/x-mixed-replace MJPEG stream, as served by
+// most IP cameras, into raw RGB frames of a fixed size, so that it can be
+// passed directly to Process.  Each JPEG is cropped about its center to the
+// aspect ratio of the frames and resized.
+type MJPEGReader struct {
+	ImageReader
+
+	parts  *multipart.Reader
+	closer io.Closer
+}
+
+// NewMJPEGReader reads an MJPEG stream with the given multipart boundary
+// from r, producing frames of width by height pixels.  These should match
+// the graph's Width and Height, or its input tensor.
+func NewMJPEGReader(r io.Reader, boundary string, width, height int) *MJPEGReader {
+	m := &MJPEGReader{
+		parts: multipart.NewReader(r, strings.TrimPrefix(boundary, "--")),
+	}
+	m.ImageReader = ImageReader{Width: width, Height: height, Next: m.next}
+	return m
+}
+
+// OpenMJPEG requests an MJPEG stream from a camera at url.  Close the
+// reader to end the request.
+func OpenMJPEG(url string, width, height int) (*MJPEGReader, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error requesting %s: %s", url, resp.Status)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error parsing content type of %s: %w", url, err)
+	} else if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s is not an MJPEG stream, its content type is '%s'", url, mediaType)
+	}
+
+	m := NewMJPEGReader(resp.Body, params["boundary"], width, height)
+	m.closer = resp.Body
+	return m, nil
+}
+
+// next decodes the next JPEG in the stream.
+func (m *MJPEGReader) next() (image.Image, error) {
+	for {
+		part, err := m.parts.NextPart()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := part.Header.Get("Content-Type"); t != "" && t != "image/jpeg" {
+			// some cameras interleave other parts, such as audio
+			continue
+		}
+
+		img, err := jpeg.Decode(part)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding MJPEG frame: %w", err)
+		}
+
+		return img, nil
+	}
+}
+
+// Frames returns the JPEGs of the stream as a FrameSource of decoded images,
+// at their own size, rather than as raw frames.  Reading the MJPEGReader
+// itself as well skips frames.
+func (m *MJPEGReader) Frames() FrameSource {
+	return Images(imageFunc(m.next))
+}
+
+// imageFunc is an ImageSource calling a function.
+type imageFunc func() (image.Image, error)
+
+func (fn imageFunc) Next() (image.Image, error) {
+	return fn()
+}
+
+// Close closes the underlying stream if it was opened by OpenMJPEG.
+func (m *MJPEGReader) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+	return m.closer.Close()
+}
+
+// MJPEGWriter writes images as a multipart/x-mixed-replace MJPEG stream,
+// which browsers show as live video.  It is the counterpart of MJPEGReader.
+type MJPEGWriter struct {
+	// Quality is the JPEG quality of the frames, from 1 to 100.  It defaults
+	// to jpeg.DefaultQuality.
+	Quality int
+
+	w     io.Writer
+	parts *multipart.Writer
+}
+
+// NewMJPEGWriter returns a writer of an MJPEG stream to w.  If w is an
+// http.ResponseWriter, set its Content-Type to ContentType before the first
+// frame; it is flushed after every frame.
+func NewMJPEGWriter(w io.Writer) *MJPEGWriter {
+	return &MJPEGWriter{w: w, parts: multipart.NewWriter(w)}
+}
+
+// ContentType returns the content type of the stream, including its
+// boundary.
+func (m *MJPEGWriter) ContentType() string {
+	return "multipart/x-mixed-replace; boundary=" + m.parts.Boundary()
+}
+
+// WriteImage encodes img as JPEG and writes it as the next frame.
+func (m *MJPEGWriter) WriteImage(img image.Image) error {
+	quality := m.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	part, err := m.parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"image/jpeg"}})
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(part, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+
+	if f, ok := m.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
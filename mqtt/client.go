@@ -0,0 +1,431 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures the connection to the broker.
+type Options struct {
+	// ClientID identifies the client to the broker.  If empty the broker
+	// assigns one, and the session is always clean.
+	ClientID string
+
+	Username, Password string
+
+	// KeepAlive is the interval of pings sent to the broker.  It defaults
+	// to a minute.
+	KeepAlive time.Duration
+
+	// Timeout bounds connecting and waiting for each acknowledgement.  It
+	// defaults to ten seconds.
+	Timeout time.Duration
+
+	// Will, if it has a Topic, is the message the broker publishes if the
+	// connection is lost without Close, and Birth one published at QoS 0
+	// whenever the client connects, such as "online" to a topic whose Will
+	// is "offline".
+	Will  Message
+	Birth Message
+}
+
+// Message is a message published by the client on its own.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// packet types
+const (
+	connect    = 1
+	connack    = 2
+	publish    = 3
+	puback     = 4
+	pubrec     = 5
+	pubrel     = 6
+	pubcomp    = 7
+	pingreq    = 12
+	pingresp   = 13
+	disconnect = 14
+)
+
+var errClosed = errors.New("mqtt: client was closed")
+
+// Client is a connection to an MQTT broker that messages can be published
+// on.  It is safe for concurrent use.  If the connection is lost it is
+// reestablished by the next Publish.
+type Client struct {
+	addr string
+	opts Options
+
+	mu     sync.Mutex // held while writing, and while using conn
+	conn   net.Conn
+	closed bool
+	nextID uint16
+	acks   map[uint16]chan byte // the type of the packet acknowledging each id
+	done   chan struct{}        // closed when the connection is lost or closed
+}
+
+// Dial connects to the broker at addr, given as tcp://host:port or
+// host:port.  The port defaults to 1883.
+func Dial(addr string, opts Options) (*Client, error) {
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		} else if u.Scheme != "tcp" && u.Scheme != "mqtt" {
+			return nil, fmt.Errorf("mqtt: unsupported scheme '%s'", u.Scheme)
+		}
+		addr = u.Host
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "1883")
+	}
+
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = time.Minute
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	c := &Client{addr: addr, opts: opts}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect opens the connection; the caller must hold c.mu.
+func (c *Client) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, c.opts.Timeout)
+	if err != nil {
+		return fmt.Errorf("mqtt: error connecting to %s: %w", c.addr, err)
+	}
+
+	var flags byte
+	var payload []byte
+	payload = appendString(payload, c.opts.ClientID)
+	if c.opts.ClientID == "" {
+		flags |= 0x02 // clean session
+	}
+	if w := c.opts.Will; w.Topic != "" {
+		flags |= 0x04 | (w.QoS&3)<<3
+		if w.Retain {
+			flags |= 0x20
+		}
+		payload = appendString(payload, w.Topic)
+		payload = appendString(payload, string(w.Payload))
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+		payload = appendString(payload, c.opts.Password)
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.opts.KeepAlive/time.Second))
+	body = append(body, payload...)
+
+	conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	br := bufio.NewReader(conn)
+	if err := writePacket(conn, connect<<4, body); err != nil {
+		conn.Close()
+		return fmt.Errorf("mqtt: error connecting to %s: %w", c.addr, err)
+	}
+
+	typ, resp, err := readPacket(br)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mqtt: error connecting to %s: %w", c.addr, err)
+	} else if typ>>4 != connack || len(resp) != 2 {
+		conn.Close()
+		return fmt.Errorf("mqtt: unexpected packet type %d connecting to %s", typ>>4, c.addr)
+	} else if resp[1] != 0 {
+		conn.Close()
+		return fmt.Errorf("mqtt: %s refused connection: %s", c.addr, connackReason(resp[1]))
+	}
+	conn.SetDeadline(time.Time{})
+
+	// nothing is reading acknowledgements yet
+	if b := c.opts.Birth; b.Topic != "" {
+		header := byte(publish << 4)
+		if b.Retain {
+			header |= 1
+		}
+		if err := writePacket(conn, header, append(appendString(nil, b.Topic), b.Payload...)); err != nil {
+			conn.Close()
+			return fmt.Errorf("mqtt: error publishing to %s: %w", b.Topic, err)
+		}
+	}
+
+	c.conn = conn
+	c.acks = make(map[uint16]chan byte)
+	c.done = make(chan struct{})
+
+	go c.read(conn, br, c.done)
+	go c.ping(conn, c.done)
+
+	return nil
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	default:
+		return fmt.Sprintf("return code %d", code)
+	}
+}
+
+// read dispatches the acknowledgements read from conn until it fails.
+func (c *Client) read(conn net.Conn, br *bufio.Reader, done chan struct{}) {
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+		close(done)
+	}()
+
+	for {
+		typ, body, err := readPacket(br)
+		if err != nil {
+			return
+		}
+
+		switch typ >> 4 {
+		case puback, pubrec, pubcomp:
+			if len(body) < 2 {
+				return
+			}
+			id := binary.BigEndian.Uint16(body)
+
+			c.mu.Lock()
+			ack := c.acks[id]
+			c.mu.Unlock()
+
+			if ack != nil {
+				ack <- typ >> 4
+			}
+		}
+	}
+}
+
+// ping keeps the connection alive until done is closed.
+func (c *Client) ping(conn net.Conn, done chan struct{}) {
+	t := time.NewTicker(c.opts.KeepAlive)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			c.mu.Lock()
+			err := writePacket(conn, pingreq<<4, nil)
+			c.mu.Unlock()
+			if err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// Publish sends payload to topic and, for a qos of 1 or 2, waits for the
+// broker to acknowledge it.  retain asks the broker to keep the message
+// for clients subscribing later.
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 2 {
+		return fmt.Errorf("mqtt: invalid QoS %d", qos)
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClosed
+	}
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
+	conn, done := c.conn, c.done
+
+	header := byte(publish<<4) | qos<<1
+	if retain {
+		header |= 1
+	}
+
+	body := appendString(nil, topic)
+
+	var id uint16
+	var ack chan byte
+	if qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		ack = make(chan byte, 2)
+		c.acks[id] = ack
+		defer func() {
+			c.mu.Lock()
+			delete(c.acks, id)
+			c.mu.Unlock()
+		}()
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+
+	err := writePacket(conn, header, body)
+	c.mu.Unlock()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mqtt: error publishing to %s: %w", topic, err)
+	}
+
+	switch qos {
+	case 1:
+		return c.wait(ack, done, puback, topic)
+	case 2:
+		if err := c.wait(ack, done, pubrec, topic); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		err := writePacket(conn, pubrel<<4|0x02, binary.BigEndian.AppendUint16(nil, id))
+		c.mu.Unlock()
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("mqtt: error publishing to %s: %w", topic, err)
+		}
+		return c.wait(ack, done, pubcomp, topic)
+	}
+	return nil
+}
+
+// wait waits for the acknowledgement of type want.
+func (c *Client) wait(ack <-chan byte, done <-chan struct{}, want byte, topic string) error {
+	t := time.NewTimer(c.opts.Timeout)
+	defer t.Stop()
+
+	select {
+	case typ := <-ack:
+		if typ != want {
+			return fmt.Errorf("mqtt: unexpected packet type %d acknowledging message to %s", typ, topic)
+		}
+		return nil
+	case <-done:
+		return fmt.Errorf("mqtt: connection lost publishing to %s", topic)
+	case <-t.C:
+		return fmt.Errorf("mqtt: timed out waiting for broker to acknowledge message to %s", topic)
+	}
+}
+
+// Close disconnects from the broker.  The broker discards the Will of a
+// client disconnecting cleanly, so Close publishes it itself, at QoS 0.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+
+	if w := c.opts.Will; w.Topic != "" {
+		header := byte(publish << 4)
+		if w.Retain {
+			header |= 1
+		}
+		writePacket(c.conn, header, append(appendString(nil, w.Topic), w.Payload...))
+	}
+	writePacket(c.conn, disconnect<<4, nil)
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func writePacket(w io.Writer, header byte, body []byte) error {
+	b := make([]byte, 0, 5+len(body))
+	b = append(b, header)
+
+	// the remaining length, seven bits at a time
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+
+	_, err := w.Write(append(b, body...))
+	return err
+}
+
+func readPacket(br *bufio.Reader) (byte, []byte, error) {
+	header, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n, shift := 0, uint(0)
+	for {
+		digit, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, fmt.Errorf("mqtt: malformed remaining length")
+		}
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
@@ -0,0 +1,21 @@
+// Package mqtt publishes detections to an MQTT broker, the usual way for
+// home automation systems to consume events such as "person detected at
+// the front door".  It includes a minimal MQTT 3.1.1 client, supporting
+// publishing at every QoS level, so that no other dependencies are needed.
+//
+//	client, err := mqtt.Dial("tcp://broker:1883", mqtt.Options{ClientID: "front-door"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	results := make(chan mvnc.Result)
+//	graph.Results = results
+//	go graph.Process(reader)
+//
+//	p := &mqtt.Publisher{Client: client, Topic: "cameras/front-door/{name}", QoS: 1, Source: "front-door"}
+//	log.Fatal(p.Run(results))
+//
+// HomeAssistant announces the classes to Home Assistant by MQTT discovery
+// instead, as binary sensors turned on and off by the detections.
+package mqtt
@@ -0,0 +1,217 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// HomeAssistant announces the classes detected by a graph to Home Assistant
+// by MQTT discovery, each as a binary sensor of a device for the camera, so
+// that they appear without any configuration in Home Assistant, and keeps
+// their states: ON once a class is detected, and OFF once it has not been
+// for OffDelay.  The sensors are available while the client is connected,
+// if it was dialed with the Options of WithAvailability.  It is safe for
+// concurrent use.
+type HomeAssistant struct {
+	Client *Client
+
+	// Prefix is the discovery prefix Home Assistant subscribes to, by
+	// default "homeassistant".
+	Prefix string
+
+	// NodeID identifies the camera in the sensors' unique IDs and topics,
+	// and Name is the name of its device, by default NodeID.
+	NodeID string
+	Name   string
+
+	// Topic is the topic the states and availability are published under,
+	// by default "mvnc/" and NodeID.
+	Topic string
+
+	// Classes are the names announced as sensors.  Detections of other
+	// names are ignored.
+	Classes []string
+
+	// DeviceClass is the device class of the sensors, by default
+	// "occupancy".
+	DeviceClass string
+
+	// OffDelay is how long a class must go undetected for its sensor to
+	// turn off, by default 5s.
+	OffDelay time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time // when each class whose sensor is on was last detected
+}
+
+// the payloads of the states and availability
+const (
+	stateOn  = "ON"
+	stateOff = "OFF"
+	online   = "online"
+	offline  = "offline"
+)
+
+func (h *HomeAssistant) base() string {
+	if h.Topic != "" {
+		return h.Topic
+	}
+	return "mvnc/" + objectID(h.NodeID)
+}
+
+// AvailabilityTopic returns the topic the availability of the sensors is
+// published to, as online or offline.
+func (h *HomeAssistant) AvailabilityTopic() string {
+	return h.base() + "/availability"
+}
+
+// StateTopic returns the topic the state of the sensor of class is
+// published to, as ON or OFF.
+func (h *HomeAssistant) StateTopic(class string) string {
+	return h.base() + "/" + objectID(class) + "/state"
+}
+
+// WithAvailability returns opts with a Birth publishing online to the
+// AvailabilityTopic whenever the client connects, and a Will publishing
+// offline, for the client dialed for h.
+func (h *HomeAssistant) WithAvailability(opts Options) Options {
+	topic := h.AvailabilityTopic()
+	opts.Birth = Message{Topic: topic, Payload: []byte(online), Retain: true}
+	opts.Will = Message{Topic: topic, Payload: []byte(offline), QoS: 1, Retain: true}
+	return opts
+}
+
+type haDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+	Model       string   `json:"model,omitempty"`
+}
+
+type haConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	PayloadOn         string   `json:"payload_on"`
+	PayloadOff        string   `json:"payload_off"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+// Announce publishes the discovery config of the sensor of every class,
+// retained so that Home Assistant finds them when it restarts, and turns
+// them off.
+func (h *HomeAssistant) Announce() error {
+	if h.NodeID == "" {
+		return fmt.Errorf("mqtt: Home Assistant discovery needs a node ID")
+	}
+
+	prefix, name, class := h.Prefix, h.Name, h.DeviceClass
+	if prefix == "" {
+		prefix = "homeassistant"
+	}
+	if name == "" {
+		name = h.NodeID
+	}
+	if class == "" {
+		class = "occupancy"
+	}
+	node := objectID(h.NodeID)
+	device := haDevice{Identifiers: []string{"mvnc_" + node}, Name: name, Model: "Neural Compute Stick"}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, c := range h.Classes {
+		payload, err := json.Marshal(haConfig{
+			Name:              c,
+			UniqueID:          "mvnc_" + node + "_" + objectID(c),
+			StateTopic:        h.StateTopic(c),
+			AvailabilityTopic: h.AvailabilityTopic(),
+			PayloadOn:         stateOn,
+			PayloadOff:        stateOff,
+			DeviceClass:       class,
+			Device:            device,
+		})
+		if err != nil {
+			return err
+		}
+
+		topic := prefix + "/binary_sensor/" + node + "/" + objectID(c) + "/config"
+		if err := h.Client.Publish(topic, payload, 1, true); err != nil {
+			return err
+		}
+		if err := h.Client.Publish(h.StateTopic(c), []byte(stateOff), 1, true); err != nil {
+			return err
+		}
+	}
+	h.last = nil
+	return nil
+}
+
+// PublishResult publishes the changes in the states of the sensors the
+// detections of r make.
+func (h *HomeAssistant) PublishResult(r mvnc.Result) error {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	delay := h.OffDelay
+	if delay <= 0 {
+		delay = 5 * time.Second
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, c := range h.Classes {
+		detected := false
+		for _, name := range r.Names {
+			if name == c {
+				detected = true
+				break
+			}
+		}
+
+		last, on := h.last[c]
+		switch {
+		case detected:
+			if h.last == nil {
+				h.last = make(map[string]time.Time)
+			}
+			h.last[c] = t
+			if !on {
+				if err := h.Client.Publish(h.StateTopic(c), []byte(stateOn), 1, true); err != nil {
+					delete(h.last, c)
+					return err
+				}
+			}
+		case on && t.Sub(last) >= delay:
+			if err := h.Client.Publish(h.StateTopic(c), []byte(stateOff), 1, true); err != nil {
+				return err
+			}
+			delete(h.last, c)
+		}
+	}
+	return nil
+}
+
+// objectID returns s as an ID for Home Assistant, which allows only
+// letters, digits, hyphens and underscores.
+func objectID(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,144 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// Detection is the JSON message published for each name detected.
+type Detection struct {
+	Name       string    `json:"name"`
+	Confidence float32   `json:"confidence"`
+	Source     string    `json:"source,omitempty"`
+	FrameID    uint64    `json:"frame_id,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Publisher publishes the detections of a graph as JSON messages.
+type Publisher struct {
+	Client *Client
+
+	// Topic is the topic each detection is published to, in which {name}
+	// is replaced by the name detected and {source} by Source, for example
+	// "cameras/{source}/{name}".
+	Topic string
+
+	// QoS and Retain are used for every message.
+	QoS    byte
+	Retain bool
+
+	// Source identifies the camera or stream in the messages.
+	Source string
+}
+
+// Publish publishes a single detection.
+func (p *Publisher) Publish(d Detection) error {
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	if d.Source == "" {
+		d.Source = p.Source
+	}
+
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	topic := strings.NewReplacer("{name}", d.Name, "{source}", d.Source).Replace(p.Topic)
+	return p.Client.Publish(topic, payload, p.QoS, p.Retain)
+}
+
+// PublishResult publishes each name detected in r.
+func (p *Publisher) PublishResult(r mvnc.Result) error {
+	for i, name := range r.Names {
+		d := Detection{Name: name, Source: r.Source, FrameID: r.FrameID, Time: r.Time}
+		if i < len(r.Confidences) {
+			d.Confidence = r.Confidences[i]
+		}
+		if err := p.Publish(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run publishes every result received until results is closed or
+// publishing fails.
+func (p *Publisher) Run(results <-chan mvnc.Result) error {
+	for r := range results {
+		if err := p.PublishResult(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attach connects to the broker described by c, such as the MQTT sink of a
+// mvnc.Config, and adds a sink to graph publishing every result, buffered
+// as described by opts so that a slow broker does not hold up the graph.
+// If c has a HomeAssistant config, the classes are announced to Home
+// Assistant and kept as its sensors, as well as published to Topic if that
+// is set.  Errors publishing are logged to the graph's Logger.  Close the
+// returned client to disconnect.
+func Attach(graph *mvnc.Graph, c *mvnc.MQTTConfig, opts mvnc.SinkOptions) (*Client, error) {
+	o := Options{ClientID: c.ClientID, Username: c.Username, Password: c.Password}
+
+	var ha *HomeAssistant
+	if hc := c.HomeAssistant; hc != nil {
+		ha = &HomeAssistant{Prefix: hc.Prefix, NodeID: hc.NodeID, Name: hc.Name, Classes: hc.Classes, DeviceClass: hc.DeviceClass, OffDelay: time.Duration(hc.OffDelay)}
+		if ha.NodeID == "" {
+			ha.NodeID = c.Source
+		}
+		if ha.NodeID == "" {
+			ha.NodeID = c.ClientID
+		}
+		if len(ha.Classes) == 0 {
+			ha.Classes = classes(graph.Names)
+		}
+		o = ha.WithAvailability(o)
+	}
+
+	client, err := Dial(c.Broker, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if ha != nil {
+		ha.Client = client
+		if err := ha.Announce(); err != nil {
+			client.Close()
+			return nil, err
+		}
+		graph.AddSink(mvnc.SinkFunc(ha.PublishResult), opts)
+	}
+	if ha == nil || c.Topic != "" {
+		p := &Publisher{Client: client, Topic: c.Topic, QoS: c.QoS, Retain: c.Retain, Source: c.Source}
+		graph.AddSink(mvnc.SinkFunc(p.PublishResult), opts)
+	}
+
+	return client, nil
+}
+
+// classes returns the distinct names of a graph, sorted.
+func classes(names map[int]string) []string {
+	var out []string
+	for _, name := range names {
+		if name != "" {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+
+	distinct := out[:0]
+	for i, name := range out {
+		if i == 0 || name != out[i-1] {
+			distinct = append(distinct, name)
+		}
+	}
+	return distinct
+}
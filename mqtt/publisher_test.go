@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// testBroker accepts a single client on a local port, and sends the topic
+// and payload of every message it publishes at QoS 0 on the returned
+// channel.
+func testBroker(t *testing.T) (addr string, messages <-chan Message) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	ch := make(chan Message, 16)
+	go func() {
+		defer close(ch)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		br := bufio.NewReader(conn)
+		if typ, _, err := readPacket(br); err != nil || typ>>4 != connect {
+			return
+		}
+		if err := writePacket(conn, connack<<4, []byte{0, 0}); err != nil {
+			return
+		}
+		for {
+			typ, body, err := readPacket(br)
+			if err != nil || typ>>4 == disconnect {
+				return
+			} else if typ>>4 != publish || len(body) < 2 {
+				continue
+			}
+			n := int(binary.BigEndian.Uint16(body))
+			ch <- Message{Topic: string(body[2 : 2+n]), Payload: body[2+n:]}
+		}
+	}()
+
+	return l.Addr().String(), ch
+}
+
+// TestPublishResultSource checks that the results of a Multiplexer are
+// published under their own source, and the others under the Publisher's.
+func TestPublishResultSource(t *testing.T) {
+	addr, messages := testBroker(t)
+	client, err := Dial(addr, Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := &Publisher{Client: client, Topic: "cameras/{source}/{name}", Source: "default"}
+	results := []mvnc.Result{
+		{FrameID: 1, Source: "porch", Names: []string{"person"}, Confidences: []float32{0.9}},
+		{FrameID: 2, Names: []string{"cat"}, Confidences: []float32{0.8}},
+	}
+	for _, r := range results {
+		if err := p.PublishResult(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []struct{ topic, source string }{
+		{"cameras/porch/person", "porch"},
+		{"cameras/default/cat", "default"},
+	} {
+		select {
+		case m := <-messages:
+			var d Detection
+			if err := json.Unmarshal(m.Payload, &d); err != nil {
+				t.Fatal(err)
+			}
+			if m.Topic != want.topic || d.Source != want.source {
+				t.Errorf("published %s from source %q, want %s from %q", m.Topic, d.Source, want.topic, want.source)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not published", want.topic)
+		}
+	}
+}
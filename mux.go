@@ -0,0 +1,229 @@
+package mvnc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Multiplexer runs the frames of several sources, such as cameras, through
+// a single graph.  Frames from every source are interleaved in the graph's
+// fifos, and the detections of each are returned on a channel of its own,
+// so that one stick can serve several cameras with low frame rates.
+//
+// Each source has at most one frame in flight: a frame read while the
+// previous one from the same source is still on the stick is skipped, so a
+// fast source cannot starve the others.  Throttle, PaceReads, Smoothing
+// and the Tracker apply to each source separately.  The Results and the
+// Detections passed to the graph's OnFrame and OnDetection handlers carry
+// the id of the source of their frame, as do the lines of a JSONLWriter.
+type Multiplexer struct {
+	Graph *Graph
+
+	mu      sync.Mutex
+	sources map[string]struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Add starts reading raw frames from reader, as Process does, tagged with
+// id, and returns the channel of names detected in them.  The graph is
+// opened by the first call.  The channel is closed once reader returns an
+// error, the graph fails, or the Multiplexer is closed.
+func (m *Multiplexer) Add(id string, reader io.Reader) (<-chan string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sources == nil {
+		m.sources = make(map[string]struct{})
+	}
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+	}
+	select {
+	case <-m.stop:
+		return nil, errClosed
+	default:
+	}
+	if _, ok := m.sources[id]; ok {
+		return nil, fmt.Errorf("source '%s' already added", id)
+	}
+
+	a, err := m.Graph.opened(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.imageInput(); err != nil {
+		return nil, err
+	}
+
+	m.sources[id] = struct{}{}
+
+	r := make(chan string)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(r)
+
+		for {
+			err := m.run(id, a, reader, r)
+			if errors.Is(err, errClosed) {
+				if next := m.Graph.reloaded(a); next != nil {
+					a = next
+					if err = a.imageInput(); err == nil {
+						continue
+					}
+				}
+			}
+			if err != nil {
+				m.Graph.fail(fmt.Errorf("source '%s': %w", id, err))
+			}
+			break
+		}
+
+		m.mu.Lock()
+		delete(m.sources, id)
+		m.mu.Unlock()
+	}()
+
+	return r, nil
+}
+
+// Sources returns the ids of the sources still being read.
+func (m *Multiplexer) Sources() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.sources))
+	for id := range m.sources {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// run processes the frames of a single source until reader returns an
+// error, the graph fails, or the Multiplexer or graph is stopped.
+func (m *Multiplexer) run(id string, a *allocation, reader io.Reader, detected chan<- string) error {
+	f := m.Graph
+
+	var sm *Smoothing
+	if f.Smoothing != nil {
+		sm = &Smoothing{Hits: f.Smoothing.Hits, Window: f.Smoothing.Window, Alpha: f.Smoothing.Alpha, Level: f.Smoothing.Level}
+	}
+	var tr *Tracker
+	if f.Tracker != nil {
+		tr = &Tracker{IoU: f.Tracker.IoU, MinHits: f.Tracker.MinHits, MaxMissed: f.Tracker.MaxMissed, Lines: f.Tracker.Lines}
+	}
+
+	desc := a.inputDesc
+	width, height := f.frameSize(desc)
+	frames := f.frameReader(reader, width, height)
+	conv := f.converter()
+
+	var pending sync.WaitGroup
+	defer pending.Wait()
+
+	failed := make(chan error, 1)
+
+	// the frame in flight and the one being read
+	free := make(chan *frame, 2)
+	for i := 0; i < cap(free); i++ {
+		fr := &frame{
+			request: request{
+				input:  make([]float32, a.inputLen),
+				output: make([]float32, a.outputLen),
+				source: id,
+			},
+			img:     &RawRGBImage{bytes: make([]byte, width*height*3), width: width, height: height},
+			scratch: make([]byte, desc.W*desc.H*3),
+		}
+		fr.request.img = fr.img
+
+		fr.done = func(r *request) {
+			defer pending.Done()
+
+			if r.err != nil {
+				f.trace(nil, r, time.Time{})
+				select {
+				case failed <- r.err:
+				default:
+				}
+			} else {
+				f.emit(r, sm, tr, detected)
+			}
+
+			free <- fr
+		}
+
+		free <- fr
+	}
+
+	lim := &limiter{interval: f.Throttle}
+
+	for {
+		fr := <-free
+
+		if f.PaceReads && !lim.wait(m.stop, f.stop) {
+			return nil
+		}
+
+		if err := frames.read(fr.img.bytes); err != nil {
+			return err
+		}
+		f.tag(&fr.request)
+
+		select {
+		case <-m.stop:
+			return nil
+		case <-f.stop:
+			return nil
+		case err := <-failed:
+			return err
+		default:
+		}
+
+		now := time.Now()
+		if !lim.due(now) || len(free) == 0 {
+			// throttled, or the previous frame is still in flight
+			f.stats.drop()
+			free <- fr
+			continue
+		}
+
+		fr.times.started = now
+		conv.Convert(fr.input, f.cropPixels(&fr.request, fr.img, desc, fr.scratch))
+		fr.times.preprocessed = time.Now()
+
+		pending.Add(1)
+		if err := a.submit(context.Background(), &fr.request); err != nil {
+			pending.Done()
+			return err
+		}
+
+		lim.take(now)
+	}
+}
+
+// Close stops reading every source, waits for the frames in flight to
+// deliver their detections, and closes the graph.  Sources are stopped
+// once they have returned the frame they are reading, and Add fails once
+// the Multiplexer is closed.
+func (m *Multiplexer) Close() error {
+	m.mu.Lock()
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+	}
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return m.Graph.Close()
+}
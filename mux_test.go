@@ -0,0 +1,123 @@
+package mvnc
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMultiplexerSource checks that the results of each source of a
+// Multiplexer are tagged with its id.
+func TestMultiplexerSource(t *testing.T) {
+	defer UseFake(testStick(0))()
+
+	g := testGraph(t)
+	m := &Multiplexer{Graph: g}
+	defer m.Close()
+
+	var mu sync.Mutex
+	results := map[string][]string{}
+	g.OnFrame(func(r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[r.Source] = append(results[r.Source], r.Names...)
+	})
+	detections := map[string]int{}
+	g.OnDetection(func(d Detection) {
+		mu.Lock()
+		defer mu.Unlock()
+		detections[d.Source]++
+	})
+
+	sources := map[string][]byte{
+		"cats": testFrames(0, 0, 0),
+		"dogs": testFrames(255, 255, 255),
+	}
+	// the sources share the graph's pipeline, so their channels are read
+	// together
+	var wg sync.WaitGroup
+	for id, frames := range sources {
+		ch, err := m.Add(id, bytes.NewReader(frames))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ch {
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(results) != 2 {
+		t.Errorf("results came from sources %v, want cats and dogs", results)
+	}
+	for id, want := range map[string]string{"cats": "cat", "dogs": "dog"} {
+		if len(results[id]) == 0 {
+			t.Errorf("no results from %s", id)
+		}
+		for _, name := range results[id] {
+			if name != want {
+				t.Errorf("source %s detected %s, want %s", id, name, want)
+			}
+		}
+		if detections[id] != len(results[id]) {
+			t.Errorf("source %s has %d detections and %d names in its results", id, detections[id], len(results[id]))
+		}
+	}
+}
+
+// TestMultiplexerShutdownPaced checks that Shutdown stops a source waiting
+// for its next frame to be due.
+func TestMultiplexerShutdownPaced(t *testing.T) {
+	defer UseFake(testStick(0))()
+
+	g := testGraph(t)
+	g.Throttle = time.Hour
+	g.PaceReads = true
+	m := &Multiplexer{Graph: g}
+	defer m.Close()
+
+	ch, err := m.Add("camera", bytes.NewReader(testFrames(0, 0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := <-ch; name != "cat" {
+		t.Fatalf("detected %s, want cat", name)
+	}
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the source was not stopped by Shutdown")
+	}
+}
+
+// TestMultiplexerCloseBeforeAdd checks that a Multiplexer closed before
+// any source was added does not open the graph for a later one.
+func TestMultiplexerCloseBeforeAdd(t *testing.T) {
+	defer UseFake(testStick(0))()
+
+	g := testGraph(t)
+	m := &Multiplexer{Graph: g}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Add("camera", bytes.NewReader(testFrames(0))); err != errClosed {
+		t.Errorf("Add after Close returned %v, want %v", err, errClosed)
+	}
+	if g.alloc != nil {
+		t.Error("Add after Close opened the graph")
+		g.Close()
+	}
+}
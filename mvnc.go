@@ -1,105 +1,815 @@
 package mvnc
 
-// #include <stdio.h>
-// #include <stdlib.h>
-// #cgo LDFLAGS: -lmvnc
-// #include <mvnc.h>
-import "C"
-
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"image/jpeg"
 	"io"
-	"io/ioutil"
 	"log"
-	"math"
 	"os"
-	"time"
-	"unsafe"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Logger is the interface used to report diagnostics.  *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Graph is a compiled graph run on a stick.  Its exported fields configure
+// it, and must be set before the first call to any of its methods.
+//
+// The methods are safe to call from several goroutines.  Infer,
+// InferTensors, InferImage and Warmup may run concurrently with each other
+// and with Process, their inferences pipelined through the graph's fifos.
+// Open, Close, Reload and Shutdown wait for each other, and for the
+// inferences in flight to complete; an Infer made after Close reopens the
+// graph.  Process runs one reader at a time: while it is running, a second
+// call fails with ErrAlreadyRunning.  Stats, Image and the On handlers may
+// be called at any time.
 type Graph struct {
 	GraphFile string
 	Names     map[int]string
 	Threshold float32
+	Mean      float32
+	Stddev    float32
+
+	// Preprocess, if set, replaces Mean and Stddev with per-channel
+	// normalization of the input pixels.
+	Preprocess *Preprocess
+
+	// Converter, if non-nil, replaces Preprocess, Mean and Stddev with a
+	// custom conversion of the input pixels.
+	Converter Converter
+
+	// Width and Height are the size of the raw frames read by Process.
+	// They default to the size of the graph's input tensor; frames of any
+	// other size are resized to fit it.
+	Width, Height int
+
+	// ROI, if not empty, is the region of interest of the frames read by
+	// Process, a Pool or a Multiplexer, in pixels: only the part of each
+	// frame within it is resized and run through the graph, for cameras
+	// where only part of the view matters.  FrameROI, if non-nil, returns
+	// the region of each frame instead, for instance one found by motion
+	// detection; img is only valid during the call.  Regions are clipped to
+	// the frame, and an empty one runs the whole frame.  The Boxes found
+	// are mapped back to the whole frame.
+	ROI      image.Rectangle
+	FrameROI func(id uint64, img image.Image) image.Rectangle
+
+	// Fit is how frames and images of another aspect ratio than the input
+	// tensor are fitted to it, and PadColor the color Letterbox pads them
+	// with, by default the mid-gray YOLO graphs are trained with.  The
+	// boxes found in letterboxed frames by Process, a Pool, a Multiplexer,
+	// InferBatch, InferTiled and Pipeline are mapped back to the frame;
+	// those decoded from the output of InferImage are not.
+	Fit      Fit
+	PadColor color.Color
+
+	// Framing is how the frames read by Process are delimited, and
+	// PixelFormat how their pixels are stored.
+	Framing     Framing
+	PixelFormat PixelFormat
+
+	// InputFifo and OutputFifo configure the fifos used to send frames to
+	// the graph and read its results.
+	InputFifo, OutputFifo FifoConfig
+
+	// Throttle, if positive, limits Process to one frame per Throttle.
+	// Frames read sooner are discarded, which keeps a live source from
+	// falling behind; with PaceReads, Process instead sleeps until the next
+	// frame is due before reading it, for sources that can wait, such as
+	// files.
 	Throttle  time.Duration
-	Mean float32
-	Stddev float32
+	PaceReads bool
+
+	// Backpressure is what Process does with a frame read while every
+	// inference slot is in use.  The default, DropLatest, suits live video;
+	// Block loses no frames.
+	Backpressure Backpressure
+
+	// WarmupFrames, if positive, is the number of zero tensors run through
+	// the graph each time it is opened, before any real frame, since the
+	// first inferences after allocating a graph are much slower than the
+	// rest.  They are not counted in Stats.
+	WarmupFrames int
+
+	// Name is the name the graph is created with, which the NCAPI reports
+	// in its debug info; when set it also prefixes the graph's diagnostics,
+	// to tell several graphs apart.  It defaults to the base name of
+	// GraphFile, and is truncated to 27 bytes, the NCAPI's limit.
+	Name string
+
+	// Executors, if positive, is the number of executors the graph runs on
+	// (NC_RW_GRAPH_EXECUTORS_NUM).  Myriad X sticks can run inferences of
+	// the same graph on several at once; allocating the graph on a Myriad 2
+	// with more than one fails with ErrUnsupportedFeature.  Zero leaves the
+	// NCAPI's default.
+	Executors int
+
+	// DeviceIndex selects which stick the graph runs on, as reported by
+	// ListDevices.  DeviceName, if set, takes precedence over DeviceIndex.
+	DeviceIndex int
+	DeviceName  string
+
+	// Device, if non-nil, is an already opened stick to allocate the graph
+	// on, in place of DeviceIndex and DeviceName.  Several graphs may share
+	// one Device, for example a detector and a classifier run on the same
+	// stick.  Closing the graph leaves the device open; close it once every
+	// graph using it has been closed.
+	Device *Device
+
+	// FrameTap, if non-nil, is called with every frame read by Process
+	// before it is sent to the stick, for example to dump frames while
+	// debugging.  The image is only valid until FrameTap returns, and
+	// FrameTap should return quickly since it delays the frame.
+	FrameTap func(img image.Image)
+
+	// Supervise, if non-nil, keeps Process running through failures of the
+	// stick itself, such as a USB reset: the graph, its fifos and the device
+	// are destroyed and reopened, waiting between attempts as described by
+	// the backoff.  The backoff starts over once an inference succeeds.
+	Supervise *Backoff
+
+	// Retry, if non-nil, retries fifo writes and reads and inference
+	// queueing that fail with a transient status such as NC_BUSY, instead
+	// of treating them as fatal.
+	Retry *RetryPolicy
+
+	// InferenceTimeout, if positive, is how long the stick has to return
+	// the output of an inference before it is considered hung, since
+	// reading the output fifo would otherwise block forever.  The
+	// inference, and those queued after it, then fail with ErrTimeout, and
+	// closing the graph resets the stick, unless it is shared through
+	// Device; with Supervise, Process then reopens the graph and resumes.
+	InferenceTimeout time.Duration
+
+	// Profile reads the time the stick spent in each layer after every
+	// inference, at the cost of an extra call per inference.  Timings, if
+	// non-nil, receives the timing of every successful inference, and Stats
+	// aggregates them.
+	Profile bool
+	Timings chan<- Timing
+
+	// Logger receives the graph's diagnostics.  It defaults to the standard
+	// logger; use log.New(ioutil.Discard, "", 0) to silence them.
+	Logger Logger
+
+	// Thresholds and NamedThresholds override Threshold for individual
+	// classes, by index or by name in Names.  An index takes precedence over
+	// a name.
+	Thresholds      map[int]float32
+	NamedThresholds map[string]float32
+
+	// TopK, if positive, limits the classes sent by Process for each
+	// inference with the Classification OutputFormat to the TopK best
+	// scoring, best first, rather than every class above Threshold in index
+	// order.  Softmax applies a softmax to the outputs first, for graphs
+	// whose outputs are logits; Threshold then applies to the probabilities.
+	TopK    int
+	Softmax bool
+
+	// Calibration, if non-nil, calibrates the confidences of the
+	// Classification, SSD and YOLO formats before the thresholds are
+	// applied, so that the thresholds are probabilities.
+	Calibration *Calibration
+
+	// Remap renames classes, so that several can be collapsed into one,
+	// such as car, truck and bus into vehicle.  Include, if non-empty,
+	// then keeps only the detections and boxes of the names it lists, and
+	// Exclude drops those of the names it lists, both matching the names
+	// after remapping.  Thresholds still apply to the names of Names.
+	Remap   map[string]string
+	Include []string
+	Exclude []string
+
+	// Zones are regions of the frame with rules for the boxes in them,
+	// applied after Remap, Include and Exclude.  Boxes in a zone which
+	// suppresses them are dropped, and the rest are annotated with the
+	// names of the zones they are in, in Result.Zones.  With OnlyInZones
+	// boxes in no zone are dropped too, so that a single zone such as
+	// {Name: "driveway", Classes: []string{"person"}} reports only the
+	// people in the driveway.
+	Zones       []Zone
+	OnlyInZones bool
+
+	// Smoothing, if non-nil, debounces the names sent by Process across
+	// frames.
+	Smoothing *Smoothing
+
+	// Tracker, if non-nil, tracks the boxes of each frame, sending the
+	// tracks and their crossings of its counting lines in its Result.
+	Tracker *Tracker
+
+	// OutputFormat selects how the output tensor is turned into detections.
+	// With SSD or YOLO, Process sends the name of every box with a
+	// confidence above Threshold, and Detections receives the boxes
+	// themselves.
+	OutputFormat OutputFormat
+
+	// Postprocessor, if non-nil, decodes the output tensor in place of
+	// OutputFormat.
+	Postprocessor Postprocessor
+
+	// YOLO describes the graph's output when OutputFormat is YOLO.
+	YOLO *YOLOConfig
+
+	// Map describes the graph's output when OutputFormat is Segmentation
+	// or Heatmap, and Maps, if non-nil, receives the map of every
+	// inference.  With Segmentation, Process sends the name of each class
+	// covering more than Threshold of the map, with the fraction it covers.
+	Map  *MapConfig
+	Maps chan<- image.Image
+
+	// Keypoints describes the graph's output when OutputFormat is
+	// Keypoints.  The keypoints of each frame are sent in its Result.
+	Keypoints *KeypointConfig
+
+	// Attributes describes the graph's output when OutputFormat is
+	// Attributes.  The attributes of each frame are sent in its Result,
+	// and Process sends the labels of the categorical ones above
+	// Threshold.
+	Attributes *AttributeConfig
+
+	// Gallery holds the reference embeddings matched when OutputFormat is
+	// Embedding.  Process sends the name of the nearest reference if its
+	// similarity is above Threshold, and Matches, if non-nil, receives every
+	// such match.
+	Gallery *Gallery
+	Matches chan<- Match
+
+	// Detections, if non-nil, receives the boxes decoded from every
+	// inference, which may be empty, when OutputFormat is SSD or YOLO.
+	Detections chan<- []BoundingBox
+
+	// Outputs, if non-nil, receives a copy of the complete output tensor of
+	// every inference, before the Names/Threshold postprocessing is applied.
+	Outputs chan<- []float32
+
+	// Results, if non-nil, receives everything produced by each inference
+	// run on a frame read by Process, a Pool or a Multiplexer, tagged with
+	// the frame's sequence number so that it can be correlated with the
+	// exact frame.  FrameUser, if non-nil, is called with the sequence number
+	// of every frame as it is read, and its value is passed through with the
+	// frame to Result.User.
+	Results   chan<- Result
+	FrameUser func(id uint64) interface{}
+
+	// Tracer, if non-nil, is sent the time every frame and every call to
+	// Infer or InferImage spent in each stage of the pipeline.
+	Tracer Tracer
+
+	// Annotate, if non-nil, draws the detections of every frame read by
+	// Process, a Pool or a Multiplexer onto a copy of the frame and sends
+	// it on.
+	Annotate *Annotator
+
+	// Record, if non-nil, records every frame read by Process, a Pool or a
+	// Multiplexer along with its output tensor, for Replay.
+	Record *Recorder
+
+	// Snapshots, if non-nil, takes snapshots of the frames read by Process,
+	// a Pool or a Multiplexer in which something is detected.
+	Snapshots *Snapshotter
+
+	// Clips, if non-nil, keeps the recent frames read by Process, a Pool or
+	// a Multiplexer to save clips of those around detections.
+	Clips *Clipper
+
 	currentImage image.Image
-	lock sync.Locker
+	imageShared  bool        // currentImage has been returned by Image
+	lock         sync.Locker // guards currentImage, imageShared and running
+	running      bool        // Process is running
+
+	once     sync.Once
+	sem      chan struct{} // held while using device and alloc
+	device   *Device
+	owned    bool // device was opened by the graph rather than shared
+	alloc    *allocation
+	stop     chan struct{} // closed by Shutdown
+	stopOnce sync.Once
+	shutdown bool
+	stats    stats
+	hooks    hooks
+	sinks    []*BufferedSink // attached by NewGraph, closed by Shutdown
 }
 
+// Image returns the most recent frame read by Process.
 func (f *Graph) Image() image.Image {
-	if f.lock == nil {
-		return nil
-	}
+	f.init()
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	f.imageShared = true
 	return f.currentImage
 }
 
+// setImage copies img for Image, reusing the previous copy unless it has been
+// handed out.
+func (f *Graph) setImage(img *RawRGBImage) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	cur, _ := f.currentImage.(*RawRGBImage)
+	if cur == nil || f.imageShared || len(cur.bytes) != len(img.bytes) {
+		cur = &RawRGBImage{bytes: make([]byte, len(img.bytes))}
+		f.currentImage = cur
+		f.imageShared = false
+	}
+
+	copy(cur.bytes, img.bytes)
+	cur.width, cur.height = img.width, img.height
+}
+
+// Process reads raw frames from reader, runs each through the graph, and
+// returns a channel of the names detected in them.  The channel is closed
+// once reader returns an error, the graph fails, or Shutdown is called.
+//
+// Once running, the per-frame path makes no allocations for a Classification
+// graph: frames, tensors and postprocessing buffers are all recycled.  The
+// exceptions are values handed to the caller, such as the slices sent on
+// Outputs, Detections and Timings, and the copy of the frame made after
+// Image has been called.
+//
+// Only one Process runs on a graph at a time.  If it is already running,
+// Process passes ErrAlreadyRunning to the OnError handlers and returns a
+// closed channel; once the channel of the first call is closed, Process may
+// be called again.
 func (f *Graph) Process(reader io.Reader) <-chan string {
-	if f.lock != nil {
-		panic(fmt.Errorf("can only call Process once on a graph"))
+	f.init()
+
+	r := make(chan string)
+
+	f.lock.Lock()
+	running := f.running
+	f.running = true
+	f.lock.Unlock()
+
+	if running {
+		f.fail(ErrAlreadyRunning)
+		close(r)
+		return r
+	}
+
+	go f.thread(f.converter(), reader, r)
+
+	return r
+}
+
+func (f *Graph) init() {
+	f.once.Do(func() {
+		f.sem = make(chan struct{}, 1)
+		f.stop = make(chan struct{})
+		f.lock = &sync.Mutex{}
+		f.setDefaults()
+	})
+}
+
+func (f *Graph) acquire(ctx context.Context) error {
+	f.init()
+
+	select {
+	case f.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Graph) release() {
+	<-f.sem
+}
+
+// Open opens the graph's device and allocates the graph on it.  Calling Open
+// is optional: Infer and Process open the graph on first use.
+func (f *Graph) Open() error {
+	f.acquire(context.Background())
+	defer f.release()
+
+	return f.open()
+}
+
+func (f *Graph) open() error {
+	if f.shutdown {
+		return errClosed
+	} else if err := f.checkFormat(); err != nil {
+		return err
+	} else if f.alloc != nil && !f.alloc.stalled() {
+		return nil
+	} else if f.alloc != nil {
+		// the watchdog gave up on the stick, so close it to reset it
+		f.logf("resetting the device: %v", f.alloc.hungErr)
+		f.close()
+	}
+
+	device, owned := f.Device, false
+	if device == nil {
+		var err error
+		if device, err = f.openDevice(); err != nil {
+			return err
+		}
+		owned = true
+	}
+
+	a, err := f.allocateWarm(device)
+	if err != nil {
+		if owned {
+			device.Close()
+		}
+		return err
+	}
+
+	f.device, f.owned, f.alloc = device, owned, a
+	return nil
+}
+
+// Close waits for any inferences in flight, then deallocates the graph and
+// closes its device, unless the device was shared through Device.  A later
+// call to Infer reopens it.
+func (f *Graph) Close() error {
+	f.acquire(context.Background())
+	defer f.release()
+
+	return f.close()
+}
+
+func (f *Graph) close() error {
+	if f.alloc == nil {
+		return nil
+	}
+
+	err := f.alloc.close()
+	if f.owned {
+		if derr := f.device.Close(); err == nil {
+			err = derr
+		}
+	}
+
+	f.device, f.alloc = nil, nil
+	return err
+}
+
+// Reload replaces the graph with the one compiled in path, for instance a
+// retrained network, without stopping Process or the Multiplexer's sources.
+// The frames already on the stick complete against the old graph, which is
+// then deallocated, and the new graph and its fifos are allocated on the
+// same device; frames read meanwhile are dropped.  GraphFile is set to path.
+//
+// If the new graph cannot be allocated, the old one is allocated again and
+// the error returned.  If the graph is not open, Reload only sets GraphFile.
+func (f *Graph) Reload(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	f.acquire(context.Background())
+	defer f.release()
+
+	if f.shutdown {
+		return errClosed
+	}
+
+	old := f.GraphFile
+	f.GraphFile = path
+	if f.alloc == nil {
+		return nil
+	}
+
+	if err := f.alloc.close(); err != nil {
+		f.logf("%v", err)
+	}
+
+	a, err := f.allocateWarm(f.device)
+	if err == nil {
+		f.alloc = a
+		return nil
+	}
+
+	f.GraphFile = old
+	if f.alloc, err = f.allocateWarm(f.device); err != nil {
+		f.logf("error reallocating %s: %v", old, err)
+		if f.owned {
+			f.device.Close()
+		}
+		f.device, f.alloc = nil, nil
+	}
+
+	return fmt.Errorf("error reloading graph: %w", err)
+}
+
+// reloaded returns the allocation that replaced a in a call to Reload, or nil
+// if the graph was closed instead.
+func (f *Graph) reloaded(a *allocation) *allocation {
+	f.acquire(context.Background())
+	defer f.release()
+
+	if f.alloc == a {
+		return nil
+	}
+	return f.alloc
+}
+
+// Shutdown stops Process from accepting new frames, waits for the inferences
+// already in flight to complete and deliver their results, and then destroys
+// the fifos, the graph and the device, in that order, returning the first
+// error encountered.  The graph cannot be reopened afterwards.  The sinks
+// attached by Config.NewGraph are then closed.
+//
+// Results are still delivered while draining, so the channel returned by
+// Process must keep being read.  If ctx is done before the drain completes
+// Shutdown returns ctx.Err() and the handles are destroyed in the background.
+// The channel returned by Process is closed once the reader has returned the
+// frame it was reading.
+func (f *Graph) Shutdown(ctx context.Context) error {
+	f.init()
+	f.stopOnce.Do(func() { close(f.stop) })
+
+	done := make(chan error, 1)
+	go func() {
+		f.acquire(context.Background())
+		defer f.release()
+
+		f.shutdown = true
+		err := f.close()
+		for _, b := range f.sinks {
+			if serr := b.Close(); err == nil {
+				err = serr
+			}
+		}
+		f.sinks = nil
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// opened returns the graph's allocation, opening the graph if necessary.
+func (f *Graph) opened(ctx context.Context) (*allocation, error) {
+	if err := f.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer f.release()
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f.alloc, nil
+}
+
+// Infer runs a single inference on input, which must already be normalized
+// and sized to the graph's input tensor, and returns the graph's output.  It
+// is safe to call from several goroutines, and while Process is running;
+// concurrent inferences are pipelined through the graph's fifos, and while
+// the fifos are full wait their turn in order of the Priority set on ctx by
+// WithPriority, ahead of the frames of Process by default.  For graphs
+// with several input or output tensors, input and the output are the
+// tensors concatenated in order; see InferTensors.
+func (f *Graph) Infer(ctx context.Context, input []float32) ([]float32, error) {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input) != a.inputLen {
+		return nil, fmt.Errorf("input has %d elements, graph expects %d", len(input), a.inputLen)
+	}
+
+	r := &request{input: input, output: make([]float32, a.outputLen), priority: priorityOf(ctx)}
+	err = a.do(ctx, r)
+	f.traceDone(ctx, r, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.output, nil
+}
+
+// InferTensors is Infer for graphs with several input or output tensors,
+// such as detectors with separate boxes and scores.  It takes one slice for
+// each input tensor, in the order of InputDescriptors, and returns one for
+// each output tensor.
+func (f *Graph) InferTensors(ctx context.Context, inputs ...[]float32) ([][]float32, error) {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inputs) != len(a.inputs) {
+		return nil, fmt.Errorf("given %d input tensors, graph expects %d", len(inputs), len(a.inputs))
+	}
+	input := make([]float32, 0, a.inputLen)
+	for i, t := range a.inputs {
+		if len(inputs[i]) != t.length {
+			return nil, fmt.Errorf("input tensor %d has %d elements, graph expects %d", i, len(inputs[i]), t.length)
+		}
+		input = append(input, inputs[i]...)
+	}
+
+	r := &request{input: input, output: make([]float32, a.outputLen), priority: priorityOf(ctx)}
+	err = a.do(ctx, r)
+	f.traceDone(ctx, r, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitTensors(a.outputs, r.output), nil
+}
+
+// InferImage resizes img to the graph's input tensor, fitted to the input as
+// described by Fit, normalizes it as described by Converter, Preprocess, or
+// Mean and Stddev, and runs a single inference on it.  Like Infer, it is
+// safe to call concurrently.
+func (f *Graph) InferImage(ctx context.Context, img image.Image) ([]float32, error) {
+	return f.inferRect(ctx, img, img.Bounds())
+}
+
+// inferRect is InferImage on the pixels of img within r.
+func (f *Graph) inferRect(ctx context.Context, img image.Image, r image.Rectangle) ([]float32, error) {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := a.inputDesc
+	if err := a.imageInput(); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	input := getFloat32s(a.inputLen)
+	f.imageTensor(*input, desc, img, r)
+
+	req := &request{input: *input, output: make([]float32, a.outputLen), priority: priorityOf(ctx)}
+	req.times.started, req.times.preprocessed = started, time.Now()
+	err = a.do(ctx, req)
+	f.traceDone(ctx, req, err)
+	if err != nil {
+		// the inference may still be reading input in the background
+		return nil, err
+	}
+	float32Pool.Put(input)
+
+	return req.output, nil
+}
+
+// imageTensor fills input with the pixels of img within r, resized to desc
+// and converted by the graph's Converter.
+func (f *Graph) imageTensor(input []float32, desc TensorDescriptor, img image.Image, r image.Rectangle) {
+	bb := getBytes(desc.W * desc.H * 3)
+	f.fit(*bb, desc, img, r)
+	f.converter().Convert(input, *bb)
+	bytePool.Put(bb)
+}
+
+// InputTensor returns the input tensor InferImage would run for img, without
+// running it, so that the graph's preprocessing can be compared with that of
+// the pipeline the model was trained with, for example by saving it with
+// SaveNPY.  Its Shape is (1, H, W, C) or (1, C, H, W), as the Layout of
+// Preprocess stores it.
+func (f *Graph) InputTensor(ctx context.Context, img image.Image) (Array, error) {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return Array{}, err
+	}
+	if err := a.imageInput(); err != nil {
+		return Array{}, err
+	}
+
+	desc := a.inputDesc
+	input := make([]float32, a.inputLen)
+	f.imageTensor(input, desc, img, img.Bounds())
+
+	shape := []int{1, desc.H, desc.W, desc.C}
+	if f.Converter == nil && f.preprocessing().Layout == CHW {
+		shape = []int{1, desc.C, desc.H, desc.W}
+	}
+	return Array{Shape: shape, Data: input}, nil
+}
+
+// Warmup runs n inferences of a zero tensor through the graph, opening it if
+// necessary, so that the inferences after it run at full speed.  Like
+// WarmupFrames, the inferences are not counted in Stats.
+func (f *Graph) Warmup(ctx context.Context, n int) error {
+	a, err := f.opened(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.warmup(ctx, n)
+}
+
+// InputDescriptor returns the shape of the graph's input tensor, opening the
+// graph if necessary.
+func (f *Graph) InputDescriptor() (TensorDescriptor, error) {
+	a, err := f.opened(context.Background())
+	if err != nil {
+		return TensorDescriptor{}, err
+	}
+
+	return a.inputDesc, nil
+}
+
+// OutputDescriptor returns the shape of the graph's output tensor, opening
+// the graph if necessary.
+func (f *Graph) OutputDescriptor() (TensorDescriptor, error) {
+	a, err := f.opened(context.Background())
+	if err != nil {
+		return TensorDescriptor{}, err
+	}
+
+	return a.outputDesc, nil
+}
+
+// InputDescriptors and OutputDescriptors return the shapes of all of the
+// graph's input and output tensors, opening the graph if necessary.  Most
+// graphs have one of each, the ones returned by InputDescriptor and
+// OutputDescriptor.
+func (f *Graph) InputDescriptors() ([]TensorDescriptor, error) {
+	a, err := f.opened(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return a.descriptors(a.inputs), nil
+}
+
+func (f *Graph) OutputDescriptors() ([]TensorDescriptor, error) {
+	a, err := f.opened(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return a.descriptors(a.outputs), nil
+}
+
+// FillLevels returns the number of elements waiting in the graph's input
+// fifo and output fifo.  It returns an error if the graph is not open.
+func (f *Graph) FillLevels() (input, output int, err error) {
+	f.acquire(context.Background())
+	defer f.release()
+
+	if f.alloc == nil {
+		return 0, 0, ErrNotAllocated
 	}
 
-	f.lock = &sync.Mutex{}
+	if input, err = f.alloc.inputFillLevel(); err != nil {
+		return 0, 0, err
+	}
+	if output, err = f.alloc.outputFillLevel(); err != nil {
+		return 0, 0, err
+	}
+	return input, output, nil
+}
+
+func (f *Graph) logf(format string, v ...interface{}) {
+	if f.Name != "" {
+		format = f.Name + ": " + format
+	}
+
+	if f.Logger != nil {
+		f.Logger.Printf(format, v...)
+	} else {
+		log.Printf(format, v...)
+	}
+}
+
+func (f *Graph) setDefaults() {
 	if f.Mean == 0. {
 		f.Mean = 128.
 	}
 	if f.Stddev == 0. {
 		f.Stddev = 256.
 	}
+}
 
-	r := make(chan string)
-
-	go f.thread(f.Mean, f.Stddev, reader, r)
-
-	return r
+// frameSize returns the size of the raw frames read by Process for a graph
+// with the given input tensor.
+func (f *Graph) frameSize(desc TensorDescriptor) (int, int) {
+	width, height := f.Width, f.Height
+	if width == 0 || height == 0 {
+		width, height = desc.W, desc.H
+	}
+	return width, height
 }
 
-func errorFor(status C.ncStatus_t) error {
-	switch status {
-	case C.NC_OK:
-		return nil
-	case C.NC_BUSY:
-		return fmt.Errorf("NC_BUSY: The device is busy; retry later.")
-	case C.NC_ERROR:
-		return fmt.Errorf("NC_ERROR: An unexpected error was encountered during the function call.")
-	case C.NC_OUT_OF_MEMORY:
-		return fmt.Errorf("NC_OUT_OF_MEMORY: The host is out of memory.")
-	case C.NC_DEVICE_NOT_FOUND:
-		return fmt.Errorf("NC_DEVICE_NOT_FOUND: There is no device at the given index or name.")
-	case C.NC_INVALID_PARAMETERS:
-		return fmt.Errorf("NC_INVALID_PARAMETERS: At least one of the given parameters is invalid in the context of the function call.")
-	case C.NC_TIMEOUT:
-		return fmt.Errorf("NC_TIMEOUT: Timeout in the communication with the device.")
-	case C.NC_MVCMD_NOT_FOUND:
-		return fmt.Errorf("NC_MVCMD_NOT_FOUND: The file to boot the device was not found. This file typically has the extension .mvcmd and should be installed during the NCSDK installation. This message may mean that the installation failed.")
-	case C.NC_NOT_ALLOCATED:
-		return fmt.Errorf("NC_NOT_ALLOCATED: The graph or fifo has not been allocated.")
-	case C.NC_UNAUTHORIZED:
-		return fmt.Errorf("NC_UNAUTHORIZED: An unauthorized operation has been attempted.")
-	case C.NC_UNSUPPORTED_GRAPH_FILE:
-		return fmt.Errorf("NC_UNSUPPORTED_GRAPH_FILE: The graph file may have been created with an incompatible prior version of the Toolkit. Try to recompile the graph file with the version of the Toolkit that corresponds to the API version.")
-	case C.NC_UNSUPPORTED_CONFIGURATION_FILE:
-		return fmt.Errorf("NC_UNSUPPORTED_CONFIGURATION_FILE: Unsupported configuration file")
-	case C.NC_UNSUPPORTED_FEATURE:
-		return fmt.Errorf("NC_UNSUPPORTED_FEATURE: Operation attempted a feature that is not supported by this firmware version.")
-	case C.NC_MYRIAD_ERROR:
-		return fmt.Errorf("NC_MYRIAD_ERROR: An error has been reported by Intel® Movidius™ VPU. Use ncGraphGetOption() for NC_RO_GRAPH_DEBUG_INFO and ncDeviceGetOption for NC_RO_DEVICE_DEBUG_INFO to get more information on the error.")
-	case C.NC_INVALID_DATA_LENGTH:
-		return fmt.Errorf("NC_INVALID_DATA_LENGTH: An invalid data length has been passed when getting or setting an option.")
-	case C.NC_INVALID_HANDLE:
-		return fmt.Errorf("NC_INVALID_HANDLE: An invalid handle has been passed to a function.")
-	default:
-		return fmt.Errorf("unknown MVNC error: '%v'", status)
+func (f *Graph) openDevice() (*Device, error) {
+	if f.DeviceName != "" {
+		return OpenDeviceByName(f.DeviceName)
 	}
+	return OpenDevice(f.DeviceIndex)
 }
 
 type RawRGBImage struct {
@@ -108,6 +818,17 @@ type RawRGBImage struct {
 	height int
 }
 
+// NewRawRGBImage wraps the raw RGB bytes of a width by height frame, three
+// bytes per pixel and row by row, without copying them.
+func NewRawRGBImage(bytes []byte, width, height int) (*RawRGBImage, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid RGB frame size %dx%d", width, height)
+	} else if width > len(bytes) || height > len(bytes) || len(bytes) != width*height*3 {
+		return nil, fmt.Errorf("%dx%d RGB frame should be %d bytes, not %d", width, height, width*height*3, len(bytes))
+	}
+	return &RawRGBImage{bytes: bytes, width: width, height: height}, nil
+}
+
 func (r *RawRGBImage) ColorModel() color.Model {
 	return color.RGBAModel
 }
@@ -124,151 +845,631 @@ func (r *RawRGBImage) At(x, y int) color.Color {
 		r.bytes[pos],
 		r.bytes[pos+1],
 		r.bytes[pos+2],
-		1.0,
+		0xff,
 	}
 }
 
-func (f *Graph) thread(mean float32, stddev float32, reader io.Reader, detected chan<- string) {
-	last := time.Now()
+// Framing is how raw frames are delimited in the stream read by Process.
+type Framing int
 
-	defer close(detected)
+// Framings: FixedSize frames follow each other directly, each exactly the
+// size of a frame, as written by ffmpeg -f rawvideo -pix_fmt rgb24.
+// LengthPrefixed frames are each preceded by their length in bytes as a
+// 32-bit big-endian integer, which must match the frame size.
+const (
+	FixedSize Framing = iota
+	LengthPrefixed
+)
+
+// frameReader reads the frames of a stream as RGB, converting them from the
+// graph's PixelFormat.
+type frameReader struct {
+	reader        io.Reader
+	framing       Framing
+	format        PixelFormat
+	width, height int
+	raw           []byte // the frame as read, if it is not RGB
+}
+
+func (f *Graph) frameReader(reader io.Reader, width, height int) *frameReader {
+	fr := &frameReader{reader: reader, framing: f.Framing, format: f.PixelFormat, width: width, height: height}
+	if f.PixelFormat != RGB24 {
+		fr.raw = make([]byte, f.PixelFormat.frameBytes(width, height))
+	}
+	return fr
+}
+
+// read fills bb with the next frame as RGB.
+func (fr *frameReader) read(bb []byte) error {
+	raw := bb
+	if fr.raw != nil {
+		raw = fr.raw
+	}
+
+	if fr.framing == LengthPrefixed {
+		// read the prefix into raw, which is always larger, to avoid
+		// allocating
+		if _, err := io.ReadFull(fr.reader, raw[:4]); err != nil {
+			return err
+		}
+		if n := binary.BigEndian.Uint32(raw); int64(n) != int64(len(raw)) {
+			return fmt.Errorf("frame of %d bytes, expected %d", n, len(raw))
+		}
+	}
+
+	if _, err := io.ReadFull(fr.reader, raw); err != nil {
+		return err
+	}
 
-	var deviceHandle *C.struct_ncDeviceHandle_t
-	var graphHandle *C.struct_ncGraphHandle_t
+	if fr.raw != nil {
+		fr.format.toRGB(bb, raw, fr.width, fr.height)
+	}
+	return nil
+}
+
+// pixels returns the pixels of img at the size of the input tensor, resizing
+// into scratch if the sizes differ.
+func pixels(img *RawRGBImage, desc TensorDescriptor, scratch []byte) []byte {
+	if img.width == desc.W && img.height == desc.H {
+		return img.bytes
+	}
+
+	resizeRGB(scratch, desc.W, desc.H, img)
+	return scratch
+}
 
-	if ret := C.ncDeviceCreate(0, &deviceHandle); ret != C.NC_OK {
-		log.Printf("could not get device name,  %v", errorFor(ret))
-		return
+// cropPixels is pixels for the frame of r, cropped to its region of
+// interest, which is recorded in r for mapping its boxes back to the frame.
+func (f *Graph) cropPixels(r *request, img *RawRGBImage, desc TensorDescriptor, scratch []byte) []byte {
+	roi := f.ROI
+	if f.FrameROI != nil {
+		roi = f.FrameROI(r.id, img)
 	}
-	defer C.ncDeviceDestroy(&deviceHandle)
 
-	if ret := C.ncDeviceOpen(deviceHandle); ret != C.NC_OK {
-		log.Printf("could not open device: %v", errorFor(ret))
-		return
+	bounds := img.Bounds()
+	if roi = roi.Intersect(bounds); roi.Empty() {
+		roi = bounds
 	}
-	defer C.ncDeviceClose(deviceHandle)
 
-	if ret := C.ncGraphCreate(C.CString("faces"), &graphHandle); ret != C.NC_OK {
-		log.Printf("could not create graph, %v", errorFor(ret))
-		return
+	r.roi = image.Rectangle{}
+	if roi == bounds && (f.Fit == Crop || img.width == desc.W && img.height == desc.H) {
+		return pixels(img, desc, scratch)
 	}
-	defer C.ncGraphDestroy(&graphHandle)
 
-	var inputFifo, outputFifo *C.struct_ncFifoHandle_t
+	r.roi, r.bounds = f.fit(scratch, desc, img, roi), bounds
+	return scratch
+}
 
-	if b, err := ioutil.ReadFile(f.GraphFile); err != nil {
-		log.Println(err)
-		return
-	} else if ret := C.ncGraphAllocateWithFifos(deviceHandle, graphHandle, unsafe.Pointer(&b[0]), C.uint(len(b)), &inputFifo, &outputFifo); ret != C.NC_OK {
-		log.Printf("error allocating graph: %v", errorFor(ret))
-		return
+// fit resizes the pixels of img within r into dst, at the size of desc, as
+// described by Fit, and returns the rectangle of img the graph sees, which
+// extends beyond r if it was letterboxed.
+func (f *Graph) fit(dst []byte, desc TensorDescriptor, img image.Image, r image.Rectangle) image.Rectangle {
+	if f.Fit == Letterbox {
+		pad := f.PadColor
+		if pad == nil {
+			pad = color.RGBA{127, 127, 127, 255}
+		}
+		return letterboxRGB(dst, desc.W, desc.H, img, r, pad)
 	}
 
-	defer C.ncFifoDestroy(&inputFifo)
-	defer C.ncFifoDestroy(&outputFifo)
+	resizeRectRGB(dst, desc.W, desc.H, img, r)
+	return centerCrop(r, desc.W, desc.H)
+}
+
+// seen returns the rectangle of an image the graph sees when run on its
+// pixels within r.
+func (f *Graph) seen(r image.Rectangle, desc TensorDescriptor) image.Rectangle {
+	if f.Fit == Letterbox {
+		_, seen := letterbox(r, desc.W, desc.H)
+		return seen
+	}
+	return centerCrop(r, desc.W, desc.H)
+}
 
-	fifoOutputSize := C.uint(0)
-	fifoInputSize := C.uint(0)
-	optionDataLen := C.uint(4)
+// emit sends the outputs and detections of a single inference, debouncing
+// the detections with sm if it is not nil.
+func (f *Graph) emit(r *request, sm *Smoothing, tr *Tracker, detected chan<- string) {
+	post := time.Now()
+	bout := r.output
 
-	C.ncFifoGetOption(outputFifo, C.NC_RO_FIFO_ELEMENT_DATA_SIZE, unsafe.Pointer(&fifoOutputSize), &optionDataLen)
-	C.ncFifoGetOption(inputFifo, C.NC_RO_FIFO_ELEMENT_DATA_SIZE, unsafe.Pointer(&fifoInputSize), &optionDataLen)
+	if f.Record != nil {
+		f.Record.record(f, r)
+	}
+	if f.Outputs != nil {
+		raw := make([]float32, len(bout))
+		copy(raw, bout)
+		f.Outputs <- raw
+	}
 
-	log.Printf("fifo input/output sizes: %d/%d", fifoInputSize, fifoOutputSize)
-	// data expected by the fifo is floats (4 bytes per channel), but the image is read in as 1 byte per channel
-	readerInputSize := fifoInputSize / 4
+	sc := getScratch()
+	defer putScratch(sc)
 
-	bb := make([]byte, readerInputSize)
-	input := make([]float32, readerInputSize)
+	meta := FrameMeta{FrameID: r.id, Time: r.readAt, User: r.user}
+	if len(r.outputs) > 0 {
+		meta.Output = r.outputs[0].desc
+	}
+	if f.Postprocessor != nil || f.OutputFormat == Attributes {
+		meta.Outputs = make([]TensorDescriptor, len(r.outputs))
+		for i, t := range r.outputs {
+			meta.Outputs[i] = t.desc
+		}
+	}
+	d, err := f.detect(bout, meta, r.roi, r.bounds, sc, sc.dets[:0])
+	if err != nil {
+		f.logf("error decoding frame %d: %v", r.id, err)
+	}
+	boxes, dets := d.boxes, d.dets
 
-	log.Printf("reader input size: %d", readerInputSize)
+	var tracks []Track
+	var crossings []CrossingEvent
+	if tr != nil {
+		tracks, crossings = tr.Update(r.id, r.readAt, boxes)
+	}
 
-	if int(fifoOutputSize)/4 > len(f.Names) {
-		log.Printf("outputsize %d greater than names %d", fifoOutputSize/4, len(f.Names))
+	if f.Detections != nil && f.decodeBoxes() {
+		f.Detections <- boxes
+	}
+	if d.matched && f.Matches != nil {
+		f.Matches <- d.match
+	}
+	if f.Maps != nil && (f.OutputFormat == Segmentation || f.OutputFormat == Heatmap) {
+		f.Maps <- d.mp
 	}
 
-	bout := make([]float32, fifoOutputSize/4)
+	if sm != nil {
+		dets = sm.filter(dets)
+	}
+	sc.dets = dets
 
-	for {
-		cur := 0
-		for {
-			if n, err := reader.Read(bb[cur:]); err != nil {
-				log.Println(err)
-				return
-			} else if cur+n == len(bb) {
-				break
-			} else {
-				cur += n
+	onDetection, onFrame, _ := f.hooks.handlers()
+	sinks := f.hooks.attached()
+
+	var res Result
+	if f.Results != nil || len(onFrame) > 0 || len(sinks) > 0 {
+		res = Result{FrameID: r.id, Source: r.source, Time: r.readAt, User: r.user, Boxes: boxes, Zones: d.zones, Tracks: tracks, Crossings: crossings, Map: d.mp, Keypoints: d.keypoints, Attributes: d.attributes, Timing: r.timing}
+		res.Output = make([]float32, len(bout))
+		copy(res.Output, bout)
+		if len(r.outputs) > 1 {
+			res.Tensors = splitTensors(r.outputs, res.Output)
+		}
+		res.Names, res.Confidences = names(dets)
+	}
+	if f.Results != nil {
+		f.Results <- res
+	}
+
+	if f.Snapshots != nil && r.img != nil && len(dets) > 0 {
+		f.Snapshots.take(f, r, boxes, dets)
+	}
+	if f.Clips != nil && r.img != nil {
+		f.Clips.add(f, r, dets)
+	}
+
+	if f.Annotate != nil && r.img != nil {
+		// boxes are labelled with their own names
+		var labels []string
+		if boxes == nil {
+			for _, d := range dets {
+				labels = append(labels, fmt.Sprintf("%s %.0f%%", d.name, d.confidence*100))
 			}
 		}
-		// if n, err := reader.Read(bb); err != nil {
-		// 	log.Println(err)
-		// 	return
-		// } else if n < len(bb) {
-		// 	log.Printf("not enough data read: %d expected %d", n, len(bb))
-		// 	return
-		// }
+		f.Annotate.emit(f, r.img, boxes, labels)
+	}
 
-		size := int(math.Sqrt(float64(len(bb) / 3)))
-		img := &RawRGBImage{
-			bytes:  bb,
-			width:  size,
-			height: size,
+	for _, fn := range onDetection {
+		for _, d := range dets {
+			fn(Detection{FrameID: r.id, Source: r.source, Name: d.name, Confidence: d.confidence})
 		}
+	}
+	for _, fn := range onFrame {
+		fn(res)
+	}
+	for _, b := range sinks {
+		b.enqueue(res)
+	}
 
+	for _, d := range dets {
+		detected <- d.name
+	}
+
+	f.trace(nil, r, post)
+}
 
+// match returns the nearest reference to embedding in the gallery, if its
+// similarity is above Threshold.
+func (f *Graph) match(embedding []float32) (Match, bool) {
+	if f.Gallery == nil {
+		return Match{}, false
+	}
 
-		fifoWriteFillLevel := C.int(0)
-		fifoWriteFillLevelSize := C.uint(4)
+	m, ok := f.Gallery.Match(embedding)
+	return m, ok && m.Similarity > f.Threshold
+}
 
-		if now := time.Now(); now.Sub(last) < f.Throttle {
-			log.Printf("throttling")
-			continue
-		} else if ret := C.ncFifoGetOption(inputFifo, C.NC_RO_FIFO_WRITE_FILL_LEVEL, unsafe.Pointer(&fifoWriteFillLevel), &fifoWriteFillLevelSize); ret != C.NC_OK {
-			log.Printf("error getting fifo fill level %v", errorFor(ret))
+// decoding is what detect decodes from an output tensor: the boxes found,
+// the match of an Embedding graph, the map of a Segmentation or Heatmap
+// graph, the keypoints of a Keypoints graph or the attributes of an
+// Attributes graph, and the names detected.
+type decoding struct {
+	boxes      []BoundingBox
+	dets       []detection
+	match      Match
+	matched    bool
+	mp         image.Image
+	keypoints  [][]Keypoint
+	attributes map[string]Attribute
+	zones      [][]string
+}
+
+// detect decodes bout with the Postprocessor or as described by
+// OutputFormat, appending the names detected to dets, remapped and filtered
+// by Remap, Include and Exclude.  If roi is not empty, the boxes and
+// keypoints are mapped from it to the whole frame, of the given bounds,
+// before the Zones are applied to the boxes.  An output that cannot be
+// decoded returns an error, along with a decoding of no detections.
+func (f *Graph) detect(bout []float32, meta FrameMeta, roi, bounds image.Rectangle, sc *scratch, dets []detection) (decoding, error) {
+	d := decoding{dets: dets}
+	if f.Postprocessor != nil {
+		f.postprocess(bout, meta, &d)
+	} else if err := f.decode(bout, meta, sc, &d); err != nil {
+		return decoding{dets: dets}, err
+	}
+	f.filter(&d, len(dets))
+
+	if !roi.Empty() {
+		for i := range d.boxes {
+			d.boxes[i] = d.boxes[i].within(roi, bounds)
+		}
+		for _, points := range d.keypoints {
+			for i := range points {
+				points[i] = points[i].within(roi, bounds)
+			}
+		}
+	}
+	f.zone(&d)
+
+	for _, b := range d.boxes {
+		if b.Name != "" {
+			d.dets = append(d.dets, detection{name: b.Name, confidence: b.Confidence})
+		}
+	}
+	return d, nil
+}
+
+// checkFormat returns an error if the description of the graph's output
+// its OutputFormat or built-in Postprocessor needs is missing.
+func (f *Graph) checkFormat() error {
+	if f.Postprocessor != nil {
+		return checkPostprocessor(f.Postprocessor)
+	}
+	if f.OutputFormat == YOLO && f.YOLO == nil {
+		return fmt.Errorf("a yolo graph needs a YOLO config")
+	} else if f.OutputFormat == YOLO {
+		return f.YOLO.check()
+	} else if f.OutputFormat == Attributes && f.Attributes == nil {
+		return fmt.Errorf("an attributes graph needs an Attributes config")
+	}
+	return nil
+}
+
+// decode decodes bout as described by OutputFormat into d.
+func (f *Graph) decode(bout []float32, meta FrameMeta, sc *scratch, d *decoding) error {
+	if err := f.checkFormat(); err != nil {
+		return err
+	}
+	if (f.OutputFormat == Segmentation || f.OutputFormat == Heatmap) && (f.Map == nil || f.Map.Width == 0 || f.Map.Height == 0) && meta.Output.Elements() == 0 {
+		return fmt.Errorf("the map size of a %v output decoded without its tensor's shape must be set in Map", f.OutputFormat)
+	}
+
+	switch f.OutputFormat {
+	case SSD:
+		d.boxes = f.keepBoxes(DecodeSSD(bout, f.decodeThreshold(), f.Names))
+	case YOLO:
+		d.boxes = f.keepBoxes(f.YOLO.Decode(bout, f.decodeThreshold(), f.Names))
+	case Embedding:
+		if d.match, d.matched = f.match(bout); d.matched {
+			d.dets = append(d.dets, detection{name: d.match.Name, confidence: d.match.Similarity})
+		}
+	case Segmentation:
+		if d.mp = f.outputMap(bout, meta); d.mp != nil {
+			d.dets = f.segments(d.mp.(*image.Paletted), d.dets)
+		}
+	case Heatmap:
+		d.mp = f.outputMap(bout, meta)
+	case Keypoints:
+		d.keypoints = f.Keypoints.Decode(bout, f.Threshold)
+	case Attributes:
+		var err error
+		if d.attributes, d.dets, err = f.attributes(bout, meta, d.dets); err != nil {
+			return err
+		}
+	default:
+		idx, scores := f.classes(bout, sc)
+		for _, i := range idx {
+			d.dets = append(d.dets, detection{name: f.Names[i], confidence: scores[i]})
+		}
+	}
+	return nil
+}
+
+// keepBoxes calibrates the confidences of boxes and returns those above
+// their class's threshold.
+func (f *Graph) keepBoxes(boxes []BoundingBox) []BoundingBox {
+	if f.Calibration != nil {
+		for i := range boxes {
+			boxes[i].Confidence = f.Calibration.Calibrate(boxes[i].Class, boxes[i].Confidence)
+		}
+	}
+	if f.Calibration != nil || len(f.Thresholds) > 0 || len(f.NamedThresholds) > 0 {
+		kept := boxes[:0]
+		for _, b := range boxes {
+			if b.Confidence > f.threshold(b.Class) {
+				kept = append(kept, b)
+			}
+		}
+		boxes = kept
+	}
+	return boxes
+}
+
+// frame is a raw frame read by Process, and the inference request made from
+// it.
+type frame struct {
+	request
+
+	img     *RawRGBImage
+	scratch []byte
+}
+
+func (f *Graph) thread(conv Converter, reader io.Reader, detected chan<- string) {
+	defer close(detected)
+	defer func() {
+		f.lock.Lock()
+		f.running = false
+		f.lock.Unlock()
+	}()
+	defer f.Close()
+
+	for attempt := 0; ; attempt++ {
+		progressed, err := f.run(conv, reader, detected)
+		if err == nil {
 			return
-		} else if fifoWriteFillLevel > 0 {
-			log.Println("fifo has elements, skipping this frame")
+		} else if err == errReloaded {
+			attempt = 0
 			continue
-		} else {
-			last = now
+		} else if f.Supervise == nil || !recoverable(err) {
+			f.fail(err)
+			return
 		}
 
-		// convert bytes read in into floats for the movidius-- I wish we could do this on the device...
-		for i, c := range bb {
-			input[i] = (float32(c) - mean) / stddev
+		if progressed {
+			attempt = 0
+		}
+		if err := f.Close(); err != nil {
+			f.logf("%v", err)
 		}
 
-		go func() {
-			out, _ := os.OpenFile("test.jpg", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-			jpeg.Encode(out, img, &jpeg.Options{75})
-			out.Close()
+		wait := f.Supervise.delay(attempt)
+		f.notify(err)
+		f.logf("%v; reopening graph in %v", err, wait)
 
-			f.lock.Lock()
-			defer f.lock.Unlock()
+		select {
+		case <-time.After(wait):
+		case <-f.stop:
+			return
+		}
+	}
+}
 
-			f.currentImage = img
-		}()
+// run opens the graph and processes frames from reader until it fails, the
+// reader returns an error, or the graph is shut down.  progressed reports
+// whether any inference succeeded.
+func (f *Graph) run(conv Converter, reader io.Reader, detected chan<- string) (progressed bool, err error) {
+	lim := &limiter{interval: f.Throttle}
 
-		user := unsafe.Pointer(nil)
+	a, err := f.opened(context.Background())
+	if err != nil {
+		return false, err
+	}
 
-		if ret := C.ncFifoWriteElem(inputFifo, unsafe.Pointer(&input[0]), &fifoInputSize, unsafe.Pointer(nil)); ret != C.NC_OK {
-			log.Printf("error writing fifo, %v", errorFor(ret))
-			return
-		} else if ret := C.ncGraphQueueInference(graphHandle, &inputFifo, 1, &outputFifo, 1); ret != C.NC_OK {
-			log.Printf("error queuing inference, %v", errorFor(ret))
+	// the allocation is closed under the frames by Reload
+	defer func() {
+		if errors.Is(err, errClosed) && f.reloaded(a) != nil {
+			err = errReloaded
+		}
+	}()
+
+	desc := a.inputDesc
+	if err := a.imageInput(); err != nil {
+		return false, err
+	}
+	width, height := f.frameSize(desc)
+
+	f.logf("reader input size: %d (%dx%d %v)", f.PixelFormat.frameBytes(width, height), width, height, f.PixelFormat)
+
+	frames := f.frameReader(reader, width, height)
+
+	// with DropOldest, the newest frame read while the pipeline was full,
+	// submitted as soon as an inference completes
+	var waitMu sync.Mutex
+	var waiting *frame
+
+	// detections are emitted from the fifo's drain goroutine, so wait for
+	// the frames in flight before closing the channel
+	var pending sync.WaitGroup
+	var succeeded int32
+	defer func() {
+		// a slot freed by the last frame in flight may have gone to another
+		// inference, such as a call to Infer, so the frame still waiting is
+		// submitted once there is room, unless the graph is shutting down
+		waitMu.Lock()
+		fr := waiting
+		waiting = nil
+		waitMu.Unlock()
+
+		if fr != nil {
+			select {
+			case <-f.stop:
+				pending.Done()
+				f.stats.drop()
+			default:
+				if err := a.submit(context.Background(), &fr.request); err != nil {
+					pending.Done()
+					f.stats.drop()
+				}
+			}
+		}
+
+		pending.Wait()
+		progressed = atomic.LoadInt32(&succeeded) > 0
+	}()
+
+	failed := make(chan error, 1)
+
+	// one frame for each inference in flight, plus the one being read and,
+	// with DropOldest, the one waiting.  The frames and their callbacks are
+	// made once up front, so that the loop below makes no allocations.
+	free := make(chan *frame, a.slots.size+2)
+
+	submitWaiting := func() {
+		waitMu.Lock()
+		defer waitMu.Unlock()
+
+		if waiting == nil {
 			return
-		} else if ret := C.ncFifoReadElem(outputFifo, unsafe.Pointer(&bout[0]), &fifoOutputSize, &user); ret != C.NC_OK {
-			log.Printf("error reading output of inference, %v", errorFor(ret))
+		}
+		fr := waiting
+		if err := a.trySubmit(&fr.request); err == errSkipped {
 			return
+		} else if err != nil {
+			pending.Done()
+			select {
+			case failed <- err:
+			default:
+			}
+			free <- fr
+		}
+		waiting = nil
+	}
+
+	for i := 0; i < cap(free); i++ {
+		// data expected by the fifo is floats, but the image is read in as 1 byte per channel
+		fr := &frame{
+			request: request{
+				input:  make([]float32, a.inputLen),
+				output: make([]float32, a.outputLen),
+			},
+			img:     &RawRGBImage{bytes: make([]byte, width*height*3), width: width, height: height},
+			scratch: make([]byte, desc.W*desc.H*3),
+		}
+		fr.request.img = fr.img
+
+		fr.done = func(r *request) {
+			defer pending.Done()
+
+			// this inference's slot is free, so keep the stick busy before
+			// emitting its results
+			if f.Backpressure == DropOldest {
+				submitWaiting()
+			}
+
+			if r.err != nil {
+				f.trace(nil, r, time.Time{})
+				select {
+				case failed <- r.err:
+				default:
+				}
+			} else {
+				atomic.AddInt32(&succeeded, 1)
+				f.emit(r, f.Smoothing, f.Tracker, detected)
+			}
+
+			free <- fr
+		}
+
+		free <- fr
+	}
+
+	for {
+		fr := <-free
+
+		if f.PaceReads && !lim.wait(f.stop, nil) {
+			return false, nil
+		}
+
+		if err := frames.read(fr.img.bytes); err != nil {
+			return false, err
 		}
+		f.tag(&fr.request)
 
-		// log.Printf("mvnc: %v", bout)
+		select {
+		case <-f.stop:
+			return false, nil
+		case err := <-failed:
+			return false, err
+		default:
+		}
 
-		for i, r := range bout {
-			if n, ok := f.Names[i]; ok && r > f.Threshold {
-				detected <- n
+		now := time.Now()
+		if !lim.due(now) {
+			f.stats.drop()
+			free <- fr
+			continue
+		} else if f.Backpressure == DropLatest && a.full() {
+			f.logf("%v", errSkipped)
+			f.stats.drop()
+			free <- fr
+			continue
+		}
+
+		fr.times.started = now
+		conv.Convert(fr.input, f.cropPixels(&fr.request, fr.img, desc, fr.scratch))
+		fr.times.preprocessed = time.Now()
+
+		if f.FrameTap != nil {
+			f.FrameTap(fr.img)
+		}
+		f.setImage(fr.img)
+
+		pending.Add(1)
+		switch f.Backpressure {
+		case Block:
+			if err := a.submit(context.Background(), &fr.request); err != nil {
+				pending.Done()
+				return false, err
+			}
+		case DropOldest:
+			waitMu.Lock()
+			if waiting != nil {
+				pending.Done()
+				f.logf("dropping oldest frame")
+				f.stats.drop()
+				free <- waiting
+				waiting = nil
+			}
+			err := a.trySubmit(&fr.request)
+			if err == errSkipped {
+				waiting, err = fr, nil
+			}
+			waitMu.Unlock()
+
+			if err != nil {
+				pending.Done()
+				return false, err
+			}
+		default:
+			if err := a.trySubmit(&fr.request); err == errSkipped {
+				pending.Done()
+				f.logf("%v", err)
+				f.stats.drop()
+				free <- fr
+				continue
+			} else if err != nil {
+				pending.Done()
+				return false, err
 			}
 		}
+
+		lim.take(now)
 	}
 }
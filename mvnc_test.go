@@ -0,0 +1,591 @@
+package mvnc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// the frames of the tests are 2x2 RGB images, classified by testStick as a
+// dog if their first pixel is bright and as a cat otherwise
+const testFrameSize = 2 * 2 * 3
+
+var testLogger = log.New(ioutil.Discard, "", 0)
+
+// testStick returns a FakeStick classifying the frames of the tests, each
+// inference taking latency.
+func testStick(latency time.Duration) FakeStick {
+	return FakeStick{
+		Input:  TensorDescriptor{N: 1, C: 3, W: 2, H: 2},
+		Output: TensorDescriptor{N: 1, C: 2, W: 1, H: 1},
+		Infer: func(input []float32) []float32 {
+			if input[0] > 0 {
+				return []float32{0, 1}
+			}
+			return []float32{1, 0}
+		},
+		Latency: latency,
+	}
+}
+
+// testGraph returns a graph classifying the frames of the tests, with a
+// graph file in a temporary directory.
+func testGraph(t testing.TB) *Graph {
+	path := filepath.Join(t.TempDir(), "test.graph")
+	if err := ioutil.WriteFile(path, []byte("graph"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Graph{
+		GraphFile: path,
+		Names:     map[int]string{0: "cat", 1: "dog"},
+		Threshold: 0.5,
+		Logger:    testLogger,
+	}
+}
+
+// testFrames returns a stream of frames, each uniformly of the given value.
+func testFrames(values ...byte) []byte {
+	b := make([]byte, 0, len(values)*testFrameSize)
+	for _, v := range values {
+		b = append(b, bytes.Repeat([]byte{v}, testFrameSize)...)
+	}
+	return b
+}
+
+// collect returns the names sent on ch until it is closed.
+func collect(t *testing.T, ch <-chan string) []string {
+	t.Helper()
+
+	var names []string
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case name, ok := <-ch:
+			if !ok {
+				return names
+			}
+			names = append(names, name)
+		case <-timeout:
+			t.Fatalf("Process did not finish, %d names read", len(names))
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	defer UseFake(testStick(0))()
+
+	g := testGraph(t)
+	g.Backpressure = Block
+	defer g.Close()
+
+	var results []Result
+	g.OnFrame(func(r Result) { results = append(results, r) })
+
+	names := collect(t, g.Process(bytes.NewReader(testFrames(0, 255, 0, 255))))
+
+	want := []string{"cat", "dog", "cat", "dog"}
+	if len(names) != len(want) {
+		t.Fatalf("detected %q, want %q", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("frame %d detected %s, want %s", i, names[i], want[i])
+		}
+	}
+
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.FrameID != uint64(i+1) {
+			t.Errorf("result %d is of frame %d, want %d", i, r.FrameID, i+1)
+		}
+		if len(r.Output) != 2 {
+			t.Errorf("result %d has %d outputs, want 2", i, len(r.Output))
+		}
+	}
+
+	s := g.Stats()
+	if s.Frames != 4 || s.Inferences != 4 || s.Dropped != 0 {
+		t.Errorf("stats report %d frames, %d inferences and %d dropped, want 4, 4 and 0", s.Frames, s.Inferences, s.Dropped)
+	}
+}
+
+func TestInferConcurrent(t *testing.T) {
+	stick := testStick(time.Millisecond)
+	stick.Output = TensorDescriptor{N: 1, C: 1, W: 1, H: 1}
+	stick.Infer = func(input []float32) []float32 {
+		return []float32{input[0] * 2}
+	}
+	defer UseFake(stick)()
+
+	g := testGraph(t)
+	defer g.Close()
+
+	const goroutines, inferences = 8, 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			input := make([]float32, testFrameSize)
+			for j := 0; j < inferences; j++ {
+				input[0] = float32(i*inferences + j)
+				output, err := g.Infer(context.Background(), input)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if output[0] != input[0]*2 {
+					t.Errorf("inference of %v returned %v, want %v", input[0], output[0], input[0]*2)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if s := g.Stats(); s.Inferences != goroutines*inferences {
+		t.Errorf("stats report %d inferences, want %d", s.Inferences, goroutines*inferences)
+	}
+}
+
+// TestPipelineLetterbox checks that a Pipeline runs a letterboxing detector
+// on a frame of another aspect ratio than its input, and maps the regions it
+// finds back to the frame, and that Run fails without Boxes.
+func TestPipelineLetterbox(t *testing.T) {
+	stick := testStick(0)
+	stick.Output = TensorDescriptor{N: 1, C: 1, W: 1, H: 1}
+	stick.Infer = func(input []float32) []float32 { return []float32{input[0]} }
+	defer UseFake(stick)()
+
+	detector := testGraph(t)
+	detector.Fit = Letterbox
+	defer detector.Close()
+	classifier := testGraph(t)
+	defer classifier.Close()
+
+	p := &Pipeline{
+		Detector: detector,
+		Boxes: func(output []float32) []BoundingBox {
+			return []BoundingBox{{XMin: 0.25, YMin: 0.25, XMax: 0.75, YMax: 0.75}}
+		},
+		Classifier: classifier,
+	}
+
+	// letterboxed into the 2x2 input, the 8x4 frame is seen as 8x8
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	results, err := p.Run(context.Background(), img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d results, want 1", len(results))
+	}
+	if want := image.Rect(2, 0, 6, 4); results[0].Rect != want {
+		t.Errorf("region %v, want %v", results[0].Rect, want)
+	}
+	if len(results[0].Output) != 1 {
+		t.Errorf("classifier returned %d outputs, want 1", len(results[0].Output))
+	}
+
+	p.Boxes = nil
+	if _, err := p.Run(context.Background(), img); err == nil {
+		t.Error("Run succeeded without Boxes")
+	}
+}
+
+// TestPoolOrder checks that a Pool sends the detections of its frames in
+// the order they were read, though one of its sticks is much slower.
+func TestPoolOrder(t *testing.T) {
+	defer UseFake(testStick(5*time.Millisecond), testStick(0))()
+
+	g := testGraph(t)
+	g.Smoothing = &Smoothing{Hits: 1}
+	defer g.Close()
+
+	var ids []uint64
+	g.OnFrame(func(r Result) { ids = append(ids, r.FrameID) })
+
+	values := make([]byte, 20)
+	var want []string
+	for i := range values {
+		if i%3 == 0 {
+			values[i] = 255
+			want = append(want, "dog")
+		} else {
+			want = append(want, "cat")
+		}
+	}
+
+	p := &Pool{Graph: g}
+	names := collect(t, p.Process(bytes.NewReader(testFrames(values...))))
+	if len(names) != len(want) {
+		t.Fatalf("detected %q, want %q", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("frame %d detected %s, want %s", i, names[i], want[i])
+		}
+	}
+	for i, id := range ids {
+		if id != uint64(i+1) {
+			t.Errorf("result %d is of frame %d, want %d", i, id, i+1)
+		}
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	// every frame but the last is a cat, so that the last is seen to be run
+	values := make([]byte, 40)
+	values = append(values, 255)
+
+	for _, b := range []Backpressure{Block, DropLatest, DropOldest} {
+		t.Run(b.String(), func(t *testing.T) {
+			defer UseFake(testStick(5 * time.Millisecond))()
+
+			g := testGraph(t)
+			g.Backpressure = b
+			defer g.Close()
+
+			names := collect(t, g.Process(bytes.NewReader(testFrames(values...))))
+
+			s := g.Stats()
+			if s.Frames != len(values) {
+				t.Errorf("read %d frames, want %d", s.Frames, len(values))
+			}
+			if s.Inferences+s.Dropped != s.Frames || len(names) != s.Inferences {
+				t.Errorf("%d frames read, %d run, %d dropped and %d detected", s.Frames, s.Inferences, s.Dropped, len(names))
+			}
+
+			switch b {
+			case Block:
+				if s.Dropped != 0 {
+					t.Errorf("dropped %d frames", s.Dropped)
+				}
+			case DropLatest, DropOldest:
+				if s.Dropped == 0 {
+					t.Errorf("dropped no frames")
+				}
+			}
+			if b != DropLatest && (len(names) == 0 || names[len(names)-1] != "dog") {
+				t.Errorf("the last frame was not run")
+			}
+		})
+	}
+}
+
+// TestDropOldestInfer checks that Process returns with DropOldest while
+// calls to Infer take the slots its frames free.
+func TestDropOldestInfer(t *testing.T) {
+	defer UseFake(testStick(time.Millisecond))()
+
+	for i := 0; i < 10; i++ {
+		g := testGraph(t)
+		g.Backpressure = DropOldest
+		g.InputFifo.Depth = 1
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx := WithPriority(context.Background(), HighPriority)
+			input := make([]float32, testFrameSize)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				g.Infer(ctx, input)
+			}
+		}()
+
+		collect(t, g.Process(bytes.NewReader(testFrames(make([]byte, 20)...))))
+		close(stop)
+		wg.Wait()
+
+		if err := g.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// zeros is an endless stream of black frames.
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func TestShutdown(t *testing.T) {
+	defer UseFake(testStick(time.Millisecond))()
+
+	g := testGraph(t)
+	ch := g.Process(zeros{})
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-ch:
+		case <-time.After(10 * time.Second):
+			t.Fatal("no detections")
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		done <- g.Shutdown(ctx)
+	}()
+
+	collect(t, ch)
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+
+	if _, err := g.Infer(context.Background(), make([]float32, testFrameSize)); err == nil {
+		t.Error("Infer succeeded after Shutdown")
+	}
+	if err := g.Open(); err == nil {
+		t.Error("Open succeeded after Shutdown")
+	}
+}
+
+func TestFakeFailures(t *testing.T) {
+	tests := []struct {
+		call   string
+		status Status
+		open   bool // whether the graph opens
+	}{
+		{"ncDeviceOpen", ErrBusy, false},
+		{"ncGraphAllocateWithFifosEx", ErrOutOfMemory, false},
+		{"ncFifoWriteElem", ErrError, true},
+		{"ncGraphQueueInference", ErrMyriadError, true},
+		{"ncFifoReadElem", ErrTimeout, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.call, func(t *testing.T) {
+			stick := testStick(0)
+			stick.Fail = func(call string) Status {
+				if call == tt.call {
+					return tt.status
+				}
+				return OK
+			}
+			defer UseFake(stick)()
+
+			g := testGraph(t)
+			defer g.Close()
+
+			err := g.Open()
+			if !tt.open {
+				if !errors.Is(err, tt.status) {
+					t.Fatalf("Open returned %v, want %v", err, tt.status)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Open returned %v", err)
+			}
+
+			_, err = g.Infer(context.Background(), make([]float32, testFrameSize))
+			if !errors.Is(err, tt.status) {
+				t.Fatalf("Infer returned %v, want %v", err, tt.status)
+			}
+			var debug *DebugError
+			if errors.As(err, &debug) != (tt.status == ErrMyriadError) {
+				t.Errorf("Infer returned %T, a DebugError for NC_MYRIAD_ERROR only", err)
+			}
+			if n := g.Stats().Errors[tt.status]; n != 1 {
+				t.Errorf("stats count %d errors of %v, want 1", n, tt.status)
+			}
+		})
+	}
+}
+
+func TestFakeFailuresProcess(t *testing.T) {
+	var failing int32
+	stick := testStick(0)
+	stick.Fail = func(call string) Status {
+		if call == "ncGraphQueueInference" && atomic.LoadInt32(&failing) != 0 {
+			return ErrMyriadError
+		}
+		return OK
+	}
+	defer UseFake(stick)()
+
+	g := testGraph(t)
+	g.Backpressure = Block
+	defer g.Close()
+
+	var errs []error
+	g.OnError(func(err error) { errs = append(errs, err) })
+
+	// a frame is read before the stick fails, so it is open when it does
+	g.FrameUser = func(id uint64) interface{} {
+		if id == 3 {
+			atomic.StoreInt32(&failing, 1)
+		}
+		return nil
+	}
+	names := collect(t, g.Process(bytes.NewReader(testFrames(make([]byte, 10)...))))
+
+	if len(names) != 2 {
+		t.Errorf("detected %d names before the failure, want 2", len(names))
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrMyriadError) {
+		t.Errorf("OnError was called with %v, want an NC_MYRIAD_ERROR", errs)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	const latency = 200 * time.Millisecond
+	var writes int32
+	stick := testStick(latency)
+	stick.Fail = func(call string) Status {
+		if call == "ncFifoWriteElem" {
+			atomic.AddInt32(&writes, 1)
+		}
+		return OK
+	}
+	defer UseFake(stick)()
+
+	g := testGraph(t)
+	g.InferenceTimeout = 20 * time.Millisecond
+
+	start := time.Now()
+	_, err := g.Infer(context.Background(), make([]float32, testFrameSize))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Infer returned %v, want %v", err, ErrTimeout)
+	}
+	if elapsed := time.Since(start); elapsed >= latency {
+		t.Errorf("Infer returned after %v, the inference takes %v", elapsed, latency)
+	}
+	if n := g.Stats().Errors[ErrTimeout]; n != 1 {
+		t.Errorf("stats count %d timeouts, want 1", n)
+	}
+	hung := []*allocation{g.alloc}
+
+	// nothing more is written to the hung stick, though the watchdog freed
+	// the slots
+	before := atomic.LoadInt32(&writes)
+	r := &request{input: make([]float32, testFrameSize), output: make([]float32, 2), done: func(*request) {}}
+	if err := hung[0].submit(context.Background(), r); !errors.Is(err, ErrTimeout) {
+		t.Errorf("submit to the hung stick returned %v, want %v", err, ErrTimeout)
+	}
+	if n := atomic.LoadInt32(&writes) - before; n != 0 {
+		t.Errorf("submit wrote %d inputs to the hung stick", n)
+	}
+
+	// the graph is reopened, resetting the stick, but still times out
+	if _, err := g.Infer(context.Background(), make([]float32, testFrameSize)); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Infer on the reopened graph returned %v, want %v", err, ErrTimeout)
+	} else if g.alloc == hung[0] {
+		t.Errorf("the graph was not reopened")
+	}
+	hung = append(hung, g.alloc)
+
+	if err := g.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// the reads the watchdog gave up on still use the fake, so they must
+	// return before it is restored
+	for _, a := range hung {
+		select {
+		case <-a.drained:
+		case <-time.After(10 * time.Second):
+			t.Fatal("the read of a hung inference did not return")
+		}
+	}
+}
+
+// frameFeed is a reader handing out the frames sent on it, one at a time.
+type frameFeed struct {
+	frames chan []byte
+	cur    []byte
+}
+
+func (r *frameFeed) Read(b []byte) (int, error) {
+	if len(r.cur) == 0 {
+		cur, ok := <-r.frames
+		if !ok {
+			return 0, io.EOF
+		}
+		r.cur = cur
+	}
+	n := copy(b, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// TestProcessAllocs checks that Process makes no allocations for each frame
+// of a Classification graph once running.
+func TestProcessAllocs(t *testing.T) {
+	stick := testStick(0)
+	dog := []float32{0, 1}
+	stick.Infer = func(input []float32) []float32 { return dog }
+	defer UseFake(stick)()
+
+	g := testGraph(t)
+	g.Backpressure = Block
+	defer g.Close()
+
+	feed := &frameFeed{frames: make(chan []byte)}
+	defer close(feed.frames)
+	ch := g.Process(feed)
+
+	frame := testFrames(255)
+	allocs := testing.AllocsPerRun(100, func() {
+		feed.frames <- frame
+		<-ch
+	})
+	if allocs != 0 {
+		t.Errorf("Process made %v allocations per frame, want 0", allocs)
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	stick := testStick(0)
+	dog := []float32{0, 1}
+	stick.Infer = func(input []float32) []float32 { return dog }
+	defer UseFake(stick)()
+
+	g := testGraph(b)
+	g.Backpressure = Block
+	defer g.Close()
+
+	feed := &frameFeed{frames: make(chan []byte)}
+	defer close(feed.frames)
+	ch := g.Process(feed)
+
+	frame := testFrames(255)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		feed.frames <- frame
+		<-ch
+	}
+}
@@ -0,0 +1,397 @@
+//go:build !mvnc_stub
+// +build !mvnc_stub
+
+package mvnc
+
+// #cgo LDFLAGS: -lmvnc
+// #include <stdint.h>
+// #include <stdlib.h>
+// #include <mvnc.h>
+//
+// // the user parameter of a fifo element carries the id of the request,
+// // which is converted to and from a pointer here since it is not a Go
+// // pointer
+// static ncStatus_t fifoWriteElemID(struct ncFifoHandle_t* fifo, const void* input, unsigned int length, uintptr_t id) {
+// 	return ncFifoWriteElem(fifo, input, &length, (void*)id);
+// }
+//
+// static ncStatus_t fifoReadElemID(struct ncFifoHandle_t* fifo, void* output, unsigned int length, uintptr_t* id) {
+// 	void* user = NULL;
+// 	ncStatus_t ret = ncFifoReadElem(fifo, output, &length, &user);
+// 	*id = (uintptr_t)user;
+// 	return ret;
+// }
+import "C"
+
+import "unsafe"
+
+// api is the NCAPI used by the package.
+var api deviceAPI = ncapi{}
+
+// ncapi is the deviceAPI of libmvnc.  Its handles are *ncDevice, *ncGraph
+// and *ncFifo, holding the NCAPI's own, so that destroying one nulls the
+// handle kept rather than a copy of it.
+type ncapi struct{}
+
+// ncDevice and ncGraph are device and graph handles.
+type ncDevice struct {
+	handle *C.struct_ncDeviceHandle_t
+}
+
+type ncGraph struct {
+	handle *C.struct_ncGraphHandle_t
+}
+
+// ncFifo is a fifo handle, along with the request id read from it, kept
+// here rather than on the stack since passing its address to C would move it
+// to the heap on every call.
+type ncFifo struct {
+	handle *C.struct_ncFifoHandle_t
+	readID C.uintptr_t
+}
+
+func deviceOf(d deviceHandle) *C.struct_ncDeviceHandle_t {
+	return d.(*ncDevice).handle
+}
+
+func graphOf(g graphHandle) *C.struct_ncGraphHandle_t {
+	return g.(*ncGraph).handle
+}
+
+func fifoOf(f fifoHandle) *ncFifo {
+	return f.(*ncFifo)
+}
+
+// cString returns a C copy of s, and a function to free it, deferred until
+// the NCAPI call it is passed to has returned.  The NCAPI copies the names
+// it is given, so none of them is needed after the call.
+func cString(s string) (*C.char, func()) {
+	cs := C.CString(s)
+	return cs, func() { C.free(unsafe.Pointer(cs)) }
+}
+
+// goString returns the NUL-terminated string in buf, an option read by the
+// NCAPI, or all of buf if the NCAPI filled it without a terminator.
+func goString(buf []C.char) string {
+	for i, c := range buf {
+		if c == 0 {
+			return C.GoStringN(&buf[0], C.int(i))
+		}
+	}
+	return C.GoStringN(&buf[0], C.int(len(buf)))
+}
+
+func versionOf(v [C.NC_VERSION_MAX_SIZE]C.uint) Version {
+	return Version{uint32(v[0]), uint32(v[1]), uint32(v[2]), uint32(v[3])}
+}
+
+func (ncapi) apiVersion() (Version, Status) {
+	var v [C.NC_VERSION_MAX_SIZE]C.uint
+	vLen := C.uint(unsafe.Sizeof(v))
+
+	ret := C.ncGlobalGetOption(C.NC_RO_API_VERSION, unsafe.Pointer(&v[0]), &vLen)
+	return versionOf(v), Status(ret)
+}
+
+func (ncapi) logLevel() (LogLevel, Status) {
+	v := C.int(0)
+	vLen := C.uint(unsafe.Sizeof(v))
+
+	ret := C.ncGlobalGetOption(C.NC_RW_LOG_LEVEL, unsafe.Pointer(&v), &vLen)
+	return LogLevel(v), Status(ret)
+}
+
+func (ncapi) setLogLevel(level LogLevel) Status {
+	v := C.int(level)
+	return Status(C.ncGlobalSetOption(C.NC_RW_LOG_LEVEL, unsafe.Pointer(&v), C.uint(unsafe.Sizeof(v))))
+}
+
+func (ncapi) deviceCreate(index int) (deviceHandle, Status) {
+	d := &ncDevice{}
+	if ret := C.ncDeviceCreate(C.int(index), &d.handle); ret != C.NC_OK {
+		return nil, Status(ret)
+	}
+	return d, OK
+}
+
+func (ncapi) deviceOpen(d deviceHandle) Status {
+	return Status(C.ncDeviceOpen(deviceOf(d)))
+}
+
+func (ncapi) deviceClose(d deviceHandle) Status {
+	return Status(C.ncDeviceClose(deviceOf(d)))
+}
+
+func (ncapi) deviceDestroy(d deviceHandle) Status {
+	return Status(C.ncDeviceDestroy(&d.(*ncDevice).handle))
+}
+
+func (ncapi) deviceName(d deviceHandle) (string, Status) {
+	var name [C.NC_MAX_NAME_SIZE]C.char
+	nameLen := C.uint(len(name))
+
+	if ret := C.ncDeviceGetOption(deviceOf(d), C.NC_RO_DEVICE_NAME, unsafe.Pointer(&name[0]), &nameLen); ret != C.NC_OK {
+		return "", Status(ret)
+	}
+	return goString(name[:]), OK
+}
+
+func (ncapi) deviceFirmwareVersion(d deviceHandle) (Version, Status) {
+	var v [C.NC_VERSION_MAX_SIZE]C.uint
+	vLen := C.uint(unsafe.Sizeof(v))
+
+	ret := C.ncDeviceGetOption(deviceOf(d), C.NC_RO_DEVICE_FW_VERSION, unsafe.Pointer(&v[0]), &vLen)
+	return versionOf(v), Status(ret)
+}
+
+func (ncapi) deviceThermalStats(d deviceHandle) ([]float32, Status) {
+	temps := make([]float32, C.NC_THERMAL_BUFFER_SIZE)
+	tempsLen := C.uint(len(temps) * 4)
+
+	if ret := C.ncDeviceGetOption(deviceOf(d), C.NC_RO_DEVICE_THERMAL_STATS, unsafe.Pointer(&temps[0]), &tempsLen); ret != C.NC_OK {
+		return nil, Status(ret)
+	}
+	return temps[:int(tempsLen)/4], OK
+}
+
+var deviceOptions = map[deviceOption]C.int{
+	optThrottlingLevel: C.NC_RO_DEVICE_THERMAL_THROTTLING_LEVEL,
+	optMemoryUsed:      C.NC_RO_DEVICE_CURRENT_MEMORY_USED,
+	optMemorySize:      C.NC_RO_DEVICE_MEMORY_SIZE,
+	optHardwareVersion: C.NC_RO_DEVICE_HW_VERSION,
+}
+
+func (ncapi) deviceIntOption(d deviceHandle, option deviceOption) (int, Status) {
+	v := C.int(0)
+	vLen := C.uint(4)
+
+	ret := C.ncDeviceGetOption(deviceOf(d), deviceOptions[option], unsafe.Pointer(&v), &vLen)
+	return int(v), Status(ret)
+}
+
+func (ncapi) deviceDebugInfo(d deviceHandle) (string, Status) {
+	var info [C.NC_DEBUG_BUFFER_SIZE]C.char
+	infoLen := C.uint(len(info))
+
+	if ret := C.ncDeviceGetOption(deviceOf(d), C.NC_RO_DEVICE_DEBUG_INFO, unsafe.Pointer(&info[0]), &infoLen); ret != C.NC_OK {
+		return "", Status(ret)
+	}
+	return goString(info[:]), OK
+}
+
+func (ncapi) graphCreate(name string) (graphHandle, Status) {
+	cname, free := cString(name)
+	defer free()
+
+	g := &ncGraph{}
+	if ret := C.ncGraphCreate(cname, &g.handle); ret != C.NC_OK {
+		return nil, Status(ret)
+	}
+	return g, OK
+}
+
+func fifoDataType(t DataType) C.ncFifoDataType_t {
+	if t == FP16 {
+		return C.NC_FIFO_FP16
+	}
+	return C.NC_FIFO_FP32
+}
+
+func dataTypeFor(t C.ncFifoDataType_t) DataType {
+	if t == C.NC_FIFO_FP16 {
+		return FP16
+	}
+	return FP32
+}
+
+func (ncapi) graphAllocate(d deviceHandle, g graphHandle, file []byte) Status {
+	return Status(C.ncGraphAllocate(deviceOf(d), graphOf(g), unsafe.Pointer(&file[0]), C.uint(len(file))))
+}
+
+func (ncapi) graphAllocateWithFifos(d deviceHandle, g graphHandle, file []byte, in, out FifoConfig) (fifoHandle, fifoHandle, Status) {
+	input, output := &ncFifo{}, &ncFifo{}
+
+	if ret := C.ncGraphAllocateWithFifosEx(deviceOf(d), graphOf(g), unsafe.Pointer(&file[0]), C.uint(len(file)),
+		&input.handle, C.NC_FIFO_HOST_WO, C.int(in.depth()), fifoDataType(in.DataType),
+		&output.handle, C.NC_FIFO_HOST_RO, C.int(out.depth()), fifoDataType(out.DataType)); ret != C.NC_OK {
+		return nil, nil, Status(ret)
+	}
+	return input, output, OK
+}
+
+func (ncapi) graphDestroy(g graphHandle) Status {
+	return Status(C.ncGraphDestroy(&g.(*ncGraph).handle))
+}
+
+var graphOptions = map[graphOption]C.int{
+	optExecutors: C.NC_RW_GRAPH_EXECUTORS_NUM,
+}
+
+func (ncapi) graphSetIntOption(g graphHandle, option graphOption, v int) Status {
+	cv := C.int(v)
+	return Status(C.ncGraphSetOption(graphOf(g), graphOptions[option], unsafe.Pointer(&cv), C.uint(unsafe.Sizeof(cv))))
+}
+
+func (ncapi) graphTensorDescriptors(g graphHandle, input bool) ([]TensorDescriptor, Status) {
+	countOption, option := C.NC_RO_GRAPH_OUTPUT_COUNT, C.NC_RO_GRAPH_OUTPUT_TENSOR_DESCRIPTORS
+	if input {
+		countOption, option = C.NC_RO_GRAPH_INPUT_COUNT, C.NC_RO_GRAPH_INPUT_TENSOR_DESCRIPTORS
+	}
+
+	count := C.int(0)
+	countLen := C.uint(4)
+	if ret := C.ncGraphGetOption(graphOf(g), C.int(countOption), unsafe.Pointer(&count), &countLen); ret != C.NC_OK {
+		return nil, Status(ret)
+	} else if count < 1 {
+		return nil, ErrError
+	}
+
+	descs := make([]C.struct_ncTensorDescriptor_t, count)
+	descLen := C.uint(uintptr(len(descs)) * unsafe.Sizeof(descs[0]))
+
+	if ret := C.ncGraphGetOption(graphOf(g), C.int(option), unsafe.Pointer(&descs[0]), &descLen); ret != C.NC_OK {
+		return nil, Status(ret)
+	}
+
+	tensors := make([]TensorDescriptor, len(descs))
+	for i, desc := range descs {
+		tensors[i] = tensorDescriptorOf(desc)
+	}
+	return tensors, OK
+}
+
+func tensorDescriptorOf(desc C.struct_ncTensorDescriptor_t) TensorDescriptor {
+	return TensorDescriptor{
+		N:         int(desc.n),
+		C:         int(desc.c),
+		W:         int(desc.w),
+		H:         int(desc.h),
+		TotalSize: int(desc.totalSize),
+		CStride:   int(desc.cStride),
+		WStride:   int(desc.wStride),
+		HStride:   int(desc.hStride),
+		DataType:  dataTypeFor(desc.dataType),
+	}
+}
+
+func (ncapi) graphTimeTaken(g graphHandle) ([]float32, Status) {
+	size := C.uint(0)
+	sizeLen := C.uint(4)
+
+	if ret := C.ncGraphGetOption(graphOf(g), C.NC_RO_GRAPH_TIME_TAKEN_ARRAY_SIZE, unsafe.Pointer(&size), &sizeLen); ret != C.NC_OK {
+		return nil, Status(ret)
+	} else if size < 4 {
+		// not even one time to read
+		return nil, OK
+	}
+
+	ms := make([]float32, int(size)/4)
+	size = C.uint(len(ms) * 4)
+	ret := C.ncGraphGetOption(graphOf(g), C.NC_RO_GRAPH_TIME_TAKEN, unsafe.Pointer(&ms[0]), &size)
+	return ms, Status(ret)
+}
+
+func (ncapi) graphDebugInfo(g graphHandle) (string, Status) {
+	var info [C.NC_DEBUG_BUFFER_SIZE]C.char
+	infoLen := C.uint(len(info))
+
+	if ret := C.ncGraphGetOption(graphOf(g), C.NC_RO_GRAPH_DEBUG_INFO, unsafe.Pointer(&info[0]), &infoLen); ret != C.NC_OK {
+		return "", Status(ret)
+	}
+	return goString(info[:]), OK
+}
+
+func (ncapi) queueInference(g graphHandle, inputs, outputs []fifoHandle) Status {
+	if len(inputs) == 1 && len(outputs) == 1 {
+		return Status(C.ncGraphQueueInference(graphOf(g), &fifoOf(inputs[0]).handle, 1, &fifoOf(outputs[0]).handle, 1))
+	}
+
+	ins := make([]*C.struct_ncFifoHandle_t, len(inputs))
+	for i, f := range inputs {
+		ins[i] = fifoOf(f).handle
+	}
+	outs := make([]*C.struct_ncFifoHandle_t, len(outputs))
+	for i, f := range outputs {
+		outs[i] = fifoOf(f).handle
+	}
+	return Status(C.ncGraphQueueInference(graphOf(g), &ins[0], C.uint(len(ins)), &outs[0], C.uint(len(outs))))
+}
+
+func (ncapi) fifoCreate(name string, input bool) (fifoHandle, Status) {
+	kind := C.ncFifoType_t(C.NC_FIFO_HOST_RO)
+	if input {
+		kind = C.NC_FIFO_HOST_WO
+	}
+
+	cname, free := cString(name)
+	defer free()
+
+	f := &ncFifo{}
+	if ret := C.ncFifoCreate(cname, kind, &f.handle); ret != C.NC_OK {
+		return nil, Status(ret)
+	}
+	return f, OK
+}
+
+func (ncapi) fifoAllocate(f fifoHandle, d deviceHandle, desc TensorDescriptor, config FifoConfig) Status {
+	handle := fifoOf(f).handle
+
+	// the data type can only be set before the fifo is allocated
+	dataType := C.int(fifoDataType(config.DataType))
+	if ret := C.ncFifoSetOption(handle, C.NC_RW_FIFO_DATA_TYPE, unsafe.Pointer(&dataType), C.uint(unsafe.Sizeof(dataType))); ret != C.NC_OK {
+		return Status(ret)
+	}
+
+	cdesc := C.struct_ncTensorDescriptor_t{
+		n:         C.uint(desc.N),
+		c:         C.uint(desc.C),
+		w:         C.uint(desc.W),
+		h:         C.uint(desc.H),
+		totalSize: C.uint(desc.TotalSize),
+		cStride:   C.uint(desc.CStride),
+		wStride:   C.uint(desc.WStride),
+		hStride:   C.uint(desc.HStride),
+		dataType:  fifoDataType(desc.DataType),
+	}
+	return Status(C.ncFifoAllocate(handle, deviceOf(d), &cdesc, C.uint(config.depth())))
+}
+
+func (ncapi) fifoElementSize(f fifoHandle) (int, Status) {
+	size := C.uint(0)
+	sizeLen := C.uint(4)
+
+	ret := C.ncFifoGetOption(fifoOf(f).handle, C.NC_RO_FIFO_ELEMENT_DATA_SIZE, unsafe.Pointer(&size), &sizeLen)
+	return int(size), Status(ret)
+}
+
+func fifoIntOption(f fifoHandle, option C.int) (int, Status) {
+	v := C.int(0)
+	vLen := C.uint(4)
+
+	ret := C.ncFifoGetOption(fifoOf(f).handle, option, unsafe.Pointer(&v), &vLen)
+	return int(v), Status(ret)
+}
+
+func (ncapi) fifoWriteFillLevel(f fifoHandle) (int, Status) {
+	return fifoIntOption(f, C.NC_RO_FIFO_WRITE_FILL_LEVEL)
+}
+
+func (ncapi) fifoReadFillLevel(f fifoHandle) (int, Status) {
+	return fifoIntOption(f, C.NC_RO_FIFO_READ_FILL_LEVEL)
+}
+
+func (ncapi) fifoWrite(f fifoHandle, data unsafe.Pointer, size int, id uint64) Status {
+	return Status(C.fifoWriteElemID(fifoOf(f).handle, data, C.uint(size), C.uintptr_t(id)))
+}
+
+func (ncapi) fifoRead(f fifoHandle, data unsafe.Pointer, size int) (uint64, Status) {
+	ff := fifoOf(f)
+
+	ff.readID = 0
+	ret := C.fifoReadElemID(ff.handle, data, C.uint(size), &ff.readID)
+	return uint64(ff.readID), Status(ret)
+}
+
+func (ncapi) fifoDestroy(f fifoHandle) Status {
+	return Status(C.ncFifoDestroy(&fifoOf(f).handle))
+}
@@ -0,0 +1,132 @@
+package mvnc
+
+import (
+	"math"
+)
+
+// NMSMethod selects how NMS treats a box that overlaps a more confident one.
+type NMSMethod int
+
+// NMS methods: HardNMS drops the box, while the soft-NMS methods instead
+// decay its confidence, linearly by one minus the overlap or by a gaussian
+// of the overlap, so that nearby objects are not lost.
+const (
+	HardNMS NMSMethod = iota
+	LinearSoftNMS
+	GaussianSoftNMS
+)
+
+// NMSOptions configures NMS.
+type NMSOptions struct {
+	Method NMSMethod
+
+	// IoUThreshold is the overlap, as intersection over union, above which
+	// a box is suppressed by HardNMS or decayed by LinearSoftNMS.
+	IoUThreshold float32
+
+	// Sigma is the width of the GaussianSoftNMS decay.  It defaults to 0.5.
+	Sigma float32
+
+	// ScoreThreshold drops boxes whose confidence, after any decay, is
+	// below it.
+	ScoreThreshold float32
+
+	// AcrossClasses suppresses overlapping boxes regardless of their class,
+	// rather than only boxes of the same class.
+	AcrossClasses bool
+}
+
+// NMS performs non-maximum suppression on boxes, returning the boxes that
+// survive in descending order of confidence.  boxes itself is not modified.
+func NMS(boxes []BoundingBox, opts NMSOptions) []BoundingBox {
+	sigma := opts.Sigma
+	if sigma == 0 {
+		sigma = 0.5
+	}
+
+	rest := make([]BoundingBox, 0, len(boxes))
+	for _, b := range boxes {
+		if b.Confidence >= opts.ScoreThreshold {
+			rest = append(rest, b)
+		}
+	}
+
+	var kept []BoundingBox
+	for len(rest) > 0 {
+		best := 0
+		for i, b := range rest {
+			if b.Confidence > rest[best].Confidence {
+				best = i
+			}
+		}
+
+		b := rest[best]
+		kept = append(kept, b)
+		rest = append(rest[:best], rest[best+1:]...)
+
+		j := 0
+		for _, r := range rest {
+			if opts.AcrossClasses || r.Class == b.Class {
+				o := IoU(b, r)
+
+				switch opts.Method {
+				case HardNMS:
+					if o > opts.IoUThreshold {
+						continue
+					}
+				case LinearSoftNMS:
+					if o > opts.IoUThreshold {
+						r.Confidence *= 1 - o
+					}
+				case GaussianSoftNMS:
+					r.Confidence *= float32(math.Exp(-float64(o*o) / float64(sigma)))
+				}
+
+				if r.Confidence < opts.ScoreThreshold {
+					continue
+				}
+			}
+
+			rest[j] = r
+			j++
+		}
+		rest = rest[:j]
+	}
+
+	return kept
+}
+
+// IoU returns the intersection over union of two boxes.
+func IoU(a, b BoundingBox) float32 {
+	iw := min32(a.XMax, b.XMax) - max32(a.XMin, b.XMin)
+	ih := min32(a.YMax, b.YMax) - max32(a.YMin, b.YMin)
+	if iw <= 0 || ih <= 0 {
+		return 0
+	}
+
+	inter := iw * ih
+	union := a.area() + b.area() - inter
+	if union <= 0 {
+		return 0
+	}
+
+	return inter / union
+}
+
+func (b BoundingBox) area() float32 {
+	return (b.XMax - b.XMin) * (b.YMax - b.YMin)
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,335 @@
+package mvnc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Array is a tensor as stored in a NumPy .npy file: its values, in row
+// major (C) order, and its shape.  It lets tensors be passed between the
+// graph and the Python tooling of the framework a model was trained in, for
+// example to run a tensor preprocessed by the training pipeline with Infer,
+// or to compare the output of the graph, or its InputTensor, with the
+// original model's:
+//
+//	a, err := mvnc.LoadNPY("input.npy")
+//	...
+//	output, err := graph.Infer(ctx, a.Data)
+//	...
+//	err = mvnc.SaveNPY("output.npy", mvnc.Array{Shape: []int{len(output)}, Data: output})
+type Array struct {
+	Shape []int
+	Data  []float32
+}
+
+// npyMagic starts every .npy file.
+const npyMagic = "\x93NUMPY"
+
+// maxNPYHeader and maxNPYElements bound the header and the number of values
+// of the arrays ReadNPY reads, so that a corrupt file cannot make it
+// allocate without bound.  No tensor of a graph comes near either.
+const (
+	maxNPYHeader   = 1 << 20
+	maxNPYElements = 1 << 28
+)
+
+// LoadNPY reads the .npy file at path.
+func LoadNPY(path string) (Array, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Array{}, err
+	}
+	defer file.Close()
+
+	a, err := ReadNPY(bufio.NewReader(file))
+	if err != nil {
+		return Array{}, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return a, nil
+}
+
+// ReadNPY reads an array in the .npy format from r.  Arrays of floats,
+// integers and booleans of any size and byte order are read, converted to
+// float32, as are arrays in Fortran order, which are reordered.
+func ReadNPY(r io.Reader) (Array, error) {
+	var pre [8]byte
+	if _, err := io.ReadFull(r, pre[:]); err != nil {
+		return Array{}, err
+	}
+	if string(pre[:6]) != npyMagic {
+		return Array{}, fmt.Errorf("not a .npy file")
+	}
+
+	var n int
+	switch pre[6] {
+	case 1:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return Array{}, err
+		}
+		n = int(binary.LittleEndian.Uint16(b[:]))
+	case 2, 3:
+		var b [4]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return Array{}, err
+		}
+		n = int(binary.LittleEndian.Uint32(b[:]))
+	default:
+		return Array{}, fmt.Errorf("unsupported .npy version %d.%d", pre[6], pre[7])
+	}
+	if n > maxNPYHeader {
+		return Array{}, fmt.Errorf(".npy header of %d bytes is too large", n)
+	}
+
+	header := make([]byte, n)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Array{}, err
+	}
+	descr, fortran, shape, err := parseNPYHeader(string(header))
+	if err != nil {
+		return Array{}, err
+	}
+
+	order, kind, size, err := parseNPYType(descr)
+	if err != nil {
+		return Array{}, err
+	}
+
+	count, err := npyCount(shape)
+	if err != nil {
+		return Array{}, err
+	}
+	// read rather than allocated up front, so that a truncated file does
+	// not allocate for all of its header's shape
+	raw, err := ioutil.ReadAll(io.LimitReader(r, int64(count*size)))
+	if err != nil {
+		return Array{}, err
+	} else if len(raw) < count*size {
+		return Array{}, io.ErrUnexpectedEOF
+	}
+
+	data := make([]float32, count)
+	for i := range data {
+		data[i] = npyValue(raw[i*size:(i+1)*size], order, kind)
+	}
+	if fortran {
+		data = fromFortran(data, shape)
+	}
+	return Array{Shape: shape, Data: data}, nil
+}
+
+// npyCount returns the number of values of an array of the given shape, or
+// an error if it is more than maxNPYElements.
+func npyCount(shape []int) (int, error) {
+	for _, d := range shape {
+		if d == 0 {
+			return 0, nil
+		}
+	}
+
+	count := 1
+	for _, d := range shape {
+		if count > maxNPYElements/d {
+			return 0, fmt.Errorf("array of shape %v is too large", shape)
+		}
+		count *= d
+	}
+	return count, nil
+}
+
+// parseNPYHeader parses the Python dict literal of a .npy header, such as
+// {'descr': '<f4', 'fortran_order': False, 'shape': (1, 3, 224, 224), }.
+func parseNPYHeader(h string) (descr string, fortran bool, shape []int, err error) {
+	value := func(key string) (string, bool) {
+		i := strings.Index(h, "'"+key+"'")
+		if i < 0 {
+			return "", false
+		}
+		v := strings.TrimSpace(h[i+len(key)+2:])
+		if !strings.HasPrefix(v, ":") {
+			return "", false
+		}
+		return strings.TrimSpace(v[1:]), true
+	}
+
+	v, ok := value("descr")
+	if !ok || len(v) < 2 || v[0] != '\'' || strings.IndexByte(v[1:], '\'') < 0 {
+		return "", false, nil, fmt.Errorf("invalid .npy header %q", h)
+	}
+	descr = v[1 : 1+strings.IndexByte(v[1:], '\'')]
+
+	if v, ok = value("fortran_order"); !ok {
+		return "", false, nil, fmt.Errorf("invalid .npy header %q", h)
+	}
+	fortran = strings.HasPrefix(v, "True")
+
+	if v, ok = value("shape"); !ok || !strings.HasPrefix(v, "(") || strings.IndexByte(v, ')') < 0 {
+		return "", false, nil, fmt.Errorf("invalid .npy header %q", h)
+	}
+	shape = []int{}
+	for _, d := range strings.Split(v[1:strings.IndexByte(v, ')')], ",") {
+		if d = strings.TrimSpace(d); d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 {
+			return "", false, nil, fmt.Errorf("invalid .npy shape %q", v)
+		}
+		shape = append(shape, n)
+	}
+	return descr, fortran, shape, nil
+}
+
+// parseNPYType parses a NumPy type string such as <f4 into its byte order,
+// kind and size.
+func parseNPYType(descr string) (binary.ByteOrder, byte, int, error) {
+	if len(descr) < 3 {
+		return nil, 0, 0, fmt.Errorf("unsupported .npy type '%s'", descr)
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if descr[0] == '>' {
+		order = binary.BigEndian
+	}
+	kind := descr[1]
+	size, err := strconv.Atoi(descr[2:])
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("unsupported .npy type '%s'", descr)
+	}
+
+	switch {
+	case kind == 'f' && (size == 2 || size == 4 || size == 8),
+		(kind == 'i' || kind == 'u') && (size == 1 || size == 2 || size == 4 || size == 8),
+		kind == 'b' && size == 1:
+		return order, kind, size, nil
+	}
+	return nil, 0, 0, fmt.Errorf("unsupported .npy type '%s'", descr)
+}
+
+// npyValue returns the value stored in b.
+func npyValue(b []byte, order binary.ByteOrder, kind byte) float32 {
+	switch kind {
+	case 'f':
+		switch len(b) {
+		case 2:
+			return halfToFloat32(order.Uint16(b))
+		case 4:
+			return math.Float32frombits(order.Uint32(b))
+		default:
+			return float32(math.Float64frombits(order.Uint64(b)))
+		}
+	case 'i':
+		switch len(b) {
+		case 1:
+			return float32(int8(b[0]))
+		case 2:
+			return float32(int16(order.Uint16(b)))
+		case 4:
+			return float32(int32(order.Uint32(b)))
+		default:
+			return float32(int64(order.Uint64(b)))
+		}
+	default:
+		switch len(b) {
+		case 1:
+			return float32(b[0])
+		case 2:
+			return float32(order.Uint16(b))
+		case 4:
+			return float32(order.Uint32(b))
+		default:
+			return float32(order.Uint64(b))
+		}
+	}
+}
+
+// fromFortran returns the values of a column major array of the given shape
+// in row major order.
+func fromFortran(data []float32, shape []int) []float32 {
+	out := make([]float32, len(data))
+	index := make([]int, len(shape))
+	for i := range out {
+		// the offset of index in column major order
+		offset, stride := 0, 1
+		for d := range shape {
+			offset += index[d] * stride
+			stride *= shape[d]
+		}
+		out[i] = data[offset]
+
+		// the next index in row major order
+		for d := len(shape) - 1; d >= 0; d-- {
+			if index[d]++; index[d] < shape[d] {
+				break
+			}
+			index[d] = 0
+		}
+	}
+	return out
+}
+
+// SaveNPY writes a to a .npy file at path.
+func SaveNPY(path string, a Array) error {
+	var b bytes.Buffer
+	if err := WriteNPY(&b, a); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+// WriteNPY writes a to w in the .npy format, as little endian float32s.  A
+// nil Shape is written as the shape of a vector of a's Data.
+func WriteNPY(w io.Writer, a Array) error {
+	shape := a.Shape
+	if shape == nil {
+		shape = []int{len(a.Data)}
+	}
+	count := 1
+	for _, d := range shape {
+		count *= d
+	}
+	if count != len(a.Data) {
+		return fmt.Errorf("array of shape %v has %d values", shape, len(a.Data))
+	}
+
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		dims[i] = strconv.Itoa(d)
+	}
+	tuple := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		tuple += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }", tuple)
+
+	// the data is aligned to 64 bytes, after the magic, version, header
+	// length and header ending with a newline
+	pad := 64 - (len(npyMagic)+4+len(header)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	header += strings.Repeat(" ", pad) + "\n"
+	if len(header) > math.MaxUint16 {
+		return fmt.Errorf("array of %d dimensions is too large for a .npy header", len(shape))
+	}
+
+	buf := make([]byte, 0, len(npyMagic)+4+len(header)+4*len(a.Data))
+	buf = append(buf, npyMagic...)
+	buf = append(buf, 1, 0)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(header)))
+	buf = append(buf, header...)
+	for _, v := range a.Data {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
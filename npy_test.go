@@ -0,0 +1,132 @@
+package mvnc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// npyFile returns a version 1.0 .npy file of the given header and data.
+func npyFile(header string, data []byte) []byte {
+	b := []byte(npyMagic)
+	b = append(b, 1, 0)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(header)))
+	b = append(b, header...)
+	return append(b, data...)
+}
+
+func TestNPYRoundTrip(t *testing.T) {
+	want := Array{Shape: []int{2, 3}, Data: []float32{0, 1, -2.5, 3, float32(math.Inf(1)), 1e-7}}
+
+	var b bytes.Buffer
+	if err := WriteNPY(&b, want); err != nil {
+		t.Fatal(err)
+	}
+	if start := b.Len() - 4*len(want.Data); start%64 != 0 {
+		t.Errorf("data starts at %d, not aligned to 64 bytes", start)
+	}
+
+	got, err := ReadNPY(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "a.npy")
+	if err := SaveNPY(path, Array{Data: []float32{4, 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadNPY(path); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(got, Array{Shape: []int{2}, Data: []float32{4, 5}}) {
+		t.Errorf("loaded %v, want a vector of 4 and 5", got)
+	}
+
+	if err := WriteNPY(&b, Array{Shape: []int{2, 2}, Data: []float32{1}}); err == nil {
+		t.Error("wrote an array of shape (2, 2) with 1 value")
+	}
+}
+
+func TestReadNPY(t *testing.T) {
+	be := func(values ...float32) []byte {
+		var b []byte
+		for _, v := range values {
+			b = binary.BigEndian.AppendUint32(b, math.Float32bits(v))
+		}
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		data   []byte
+		want   Array
+	}{
+		{"big endian", "{'descr': '>f4', 'fortran_order': False, 'shape': (3,), }", be(1, -2, 0.5), Array{Shape: []int{3}, Data: []float32{1, -2, 0.5}}},
+		{"fortran order", "{'descr': '>f4', 'fortran_order': True, 'shape': (2, 3), }", be(1, 4, 2, 5, 3, 6), Array{Shape: []int{2, 3}, Data: []float32{1, 2, 3, 4, 5, 6}}},
+		{"fortran order 3d", "{'descr': '|u1', 'fortran_order': True, 'shape': (2, 2, 2), }", []byte{0, 4, 2, 6, 1, 5, 3, 7}, Array{Shape: []int{2, 2, 2}, Data: []float32{0, 1, 2, 3, 4, 5, 6, 7}}},
+		{"int16", "{'descr': '<i2', 'fortran_order': False, 'shape': (2,), }", []byte{0xff, 0xff, 0x00, 0x01}, Array{Shape: []int{2}, Data: []float32{-1, 256}}},
+		{"big endian int32", "{'descr': '>i4', 'fortran_order': False, 'shape': (1,), }", []byte{0xff, 0xff, 0xff, 0xfe}, Array{Shape: []int{1}, Data: []float32{-2}}},
+		{"uint8", "{'descr': '|u1', 'fortran_order': False, 'shape': (2, 1), }", []byte{7, 255}, Array{Shape: []int{2, 1}, Data: []float32{7, 255}}},
+		{"bool", "{'descr': '|b1', 'fortran_order': False, 'shape': (2,), }", []byte{1, 0}, Array{Shape: []int{2}, Data: []float32{1, 0}}},
+		{"float16", "{'descr': '<f2', 'fortran_order': False, 'shape': (2,), }", []byte{0x00, 0x3c, 0x00, 0xc0}, Array{Shape: []int{2}, Data: []float32{1, -2}}},
+		{"float64", "{'descr': '<f8', 'fortran_order': False, 'shape': (), }", binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.25)), Array{Shape: []int{}, Data: []float32{0.25}}},
+		{"empty", "{'descr': '<f4', 'fortran_order': False, 'shape': (0, 1000000000000), }", nil, Array{Shape: []int{0, 1000000000000}, Data: []float32{}}},
+		{"keys in any order", "{'shape': (1,), 'fortran_order': False, 'descr': '<f4'}", binary.LittleEndian.AppendUint32(nil, math.Float32bits(3)), Array{Shape: []int{1}, Data: []float32{3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := ReadNPY(bytes.NewReader(npyFile(tt.header, tt.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(a, tt.want) {
+				t.Errorf("read %v, want %v", a, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadNPYErrors(t *testing.T) {
+	f4 := func(shape string) string {
+		return "{'descr': '<f4', 'fortran_order': False, 'shape': " + shape + ", }"
+	}
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{"not npy", []byte("PK\x03\x04 not an npy file"), "not a .npy file"},
+		{"short", []byte(npyMagic), io.ErrUnexpectedEOF.Error()},
+		{"version", append([]byte(npyMagic), 4, 0, 0, 0), "unsupported .npy version 4.0"},
+		{"huge header", append([]byte(npyMagic), 2, 0, 0xff, 0xff, 0xff, 0xff), "too large"},
+		{"truncated header", npyFile(f4("(2,)"), nil)[:20], io.ErrUnexpectedEOF.Error()},
+		{"no shape", npyFile("{'descr': '<f4', 'fortran_order': False}", nil), "invalid .npy header"},
+		{"negative shape", npyFile(f4("(-2, -3)"), nil), "invalid .npy shape"},
+		{"type", npyFile("{'descr': '<c8', 'fortran_order': False, 'shape': (1,), }", make([]byte, 8)), "unsupported .npy type '<c8'"},
+		{"too large", npyFile(f4("(65536, 65536)"), nil), "array of shape [65536 65536] is too large"},
+		{"overflow", npyFile(f4("(4294967296, 4294967296, 4294967296)"), nil), "is too large"},
+		{"truncated data", npyFile(f4("(1000000,)"), make([]byte, 12)), io.ErrUnexpectedEOF.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := ReadNPY(bytes.NewReader(tt.file))
+			if err == nil {
+				t.Fatalf("read %v, want an error", a)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("returned %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
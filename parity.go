@@ -0,0 +1,75 @@
+package mvnc
+
+import (
+	"fmt"
+	"math"
+)
+
+// Parity is how closely a tensor computed by the graph matches one expected
+// of it, such as the output of the NCSDK's Python API for the same image,
+// from CompareTensors.  A graph matching the Python pipeline differs only by
+// the rounding of FP16; larger differences point to preprocessing, channel
+// order or layout bugs.
+type Parity struct {
+	Elements int
+
+	// MaxAbsDiff is the largest absolute difference of an element, at
+	// MaxIndex, where the tensors held Got and Want.  An element that is
+	// NaN in only one of the tensors differs by +Inf.
+	MaxAbsDiff float32
+	MaxIndex   int
+	Got, Want  float32
+
+	// MeanAbsDiff is the mean absolute difference of the elements, and
+	// Exceeding the number of elements differing by more than the
+	// tolerance.
+	MeanAbsDiff float32
+	Exceeding   int
+}
+
+// OK reports whether no element differed by more than the tolerance.
+func (p Parity) OK() bool {
+	return p.Exceeding == 0
+}
+
+func (p Parity) String() string {
+	return fmt.Sprintf("%d elements, max abs diff %g at %d (got %g, want %g), mean abs diff %g, %d exceeding tolerance",
+		p.Elements, p.MaxAbsDiff, p.MaxIndex, p.Got, p.Want, p.MeanAbsDiff, p.Exceeding)
+}
+
+// CompareTensors compares got with want element by element, counting the
+// elements differing by more than tolerance.
+func CompareTensors(got, want []float32, tolerance float32) (Parity, error) {
+	if len(got) != len(want) {
+		return Parity{}, fmt.Errorf("tensor has %d elements, expected %d", len(got), len(want))
+	}
+
+	p := Parity{Elements: len(got)}
+	var sum float64
+	for i := range got {
+		a, b := got[i], want[i]
+
+		var d float32
+		switch an, bn := a != a, b != b; {
+		case an && bn:
+		case an || bn:
+			d = float32(math.Inf(1))
+		case a == b:
+			// infinities of the same sign
+		default:
+			d = abs32(a - b)
+		}
+
+		if i == 0 || d > p.MaxAbsDiff {
+			p.MaxAbsDiff, p.MaxIndex, p.Got, p.Want = d, i, a, b
+		}
+		if d > tolerance {
+			p.Exceeding++
+		}
+		sum += float64(d)
+	}
+	if len(got) > 0 {
+		p.MeanAbsDiff = float32(sum / float64(len(got)))
+	}
+	return p, nil
+}
@@ -0,0 +1,142 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+)
+
+// Pipeline cascades two graphs: Detector finds regions of each frame, which
+// are cropped from the frame, resized and run through Classifier.  This is
+// the usual face detection followed by recognition pattern, and the two
+// graphs may share a stick through their Device fields.
+type Pipeline struct {
+	Detector *Graph
+
+	// Boxes decodes the detector's output tensor into the regions to
+	// classify.  It is required: Run fails without it.
+	Boxes func(output []float32) []BoundingBox
+
+	Classifier *Graph
+}
+
+// PipelineResult is a region found by the detector together with the
+// classifier's output for it.
+type PipelineResult struct {
+	Box BoundingBox
+
+	// Rect is the region in pixels of the source frame.
+	Rect image.Rectangle
+
+	Output []float32
+
+	// Match is the nearest reference in the classifier's Gallery when its
+	// OutputFormat is Embedding, or nil if none is similar enough.
+	Match *Match
+}
+
+// Run runs the detector on img and the classifier on every region it finds.
+// The detector sees img fitted to its input tensor as described by its Fit,
+// and each region is likewise fitted to the classifier's input as described
+// by the classifier's.  The regions are classified concurrently, so they
+// are pipelined through the classifier's fifos.
+func (p *Pipeline) Run(ctx context.Context, img image.Image) ([]PipelineResult, error) {
+	if p.Boxes == nil {
+		return nil, fmt.Errorf("a pipeline needs Boxes to decode the detector's output")
+	}
+
+	desc, err := p.Detector.InputDescriptor()
+	if err != nil {
+		return nil, err
+	}
+
+	// seen maps the boxes back to img, and may extend beyond it when the
+	// detector letterboxes
+	seen := p.Detector.seen(img.Bounds(), desc)
+
+	output, err := p.Detector.inferRect(ctx, img, img.Bounds())
+	if err != nil {
+		return nil, fmt.Errorf("error running detector: %w", err)
+	}
+
+	var results []PipelineResult
+	for _, box := range p.Boxes(output) {
+		r := box.Rect(seen).Intersect(img.Bounds())
+		if r.Empty() {
+			continue
+		}
+		results = append(results, PipelineResult{Box: box, Rect: r})
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res := &results[i]
+			if res.Output, errs[i] = p.Classifier.inferRect(ctx, img, res.Rect); errs[i] != nil {
+				return
+			}
+			if p.Classifier.OutputFormat == Embedding {
+				if m, ok := p.Classifier.match(res.Output); ok {
+					res.Match = &m
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("error running classifier: %w", err)
+		}
+	}
+
+	return results, nil
+}
+
+// Process reads raw frames from reader, sized and formatted as described by
+// the detector's Width, Height and PixelFormat, and returns the results of
+// running each through the pipeline.  The channel is closed, and both graphs
+// closed, once reader is exhausted or either graph fails.
+func (p *Pipeline) Process(reader io.Reader) <-chan []PipelineResult {
+	r := make(chan []PipelineResult)
+
+	go p.thread(reader, r)
+
+	return r
+}
+
+func (p *Pipeline) thread(reader io.Reader, results chan<- []PipelineResult) {
+	defer close(results)
+	defer p.Classifier.Close()
+	defer p.Detector.Close()
+
+	desc, err := p.Detector.InputDescriptor()
+	if err != nil {
+		p.Detector.fail(err)
+		return
+	}
+	width, height := p.Detector.frameSize(desc)
+
+	img := &RawRGBImage{bytes: make([]byte, width*height*3), width: width, height: height}
+	frames := p.Detector.frameReader(reader, width, height)
+
+	for {
+		if err := frames.read(img.bytes); err != nil {
+			p.Detector.fail(err)
+			return
+		}
+
+		res, err := p.Run(context.Background(), img)
+		if err != nil {
+			p.Detector.fail(err)
+			return
+		}
+
+		results <- res
+	}
+}
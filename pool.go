@@ -0,0 +1,260 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Pool runs the same graph on several sticks and spreads incoming frames
+// across them.  Each frame is handed to the first stick to become idle, so
+// faster or less loaded sticks take more of the work.  The detections of
+// the frames are still sent in the order they were read, so that Smoothing
+// and the Tracker see the frames as Process would.
+type Pool struct {
+	// Graph describes the graph to allocate on each stick.  Its DeviceIndex
+	// and DeviceName fields are ignored.
+	Graph *Graph
+
+	// Devices lists the indices of the sticks to use.  If empty, every stick
+	// reported by ListDevices is used.
+	Devices []int
+}
+
+// Process reads raw frames from reader and returns a single channel of
+// detections from all of the sticks in the pool.  The channel is closed when
+// reader is exhausted or every stick has failed.
+func (p *Pool) Process(reader io.Reader) <-chan string {
+	p.Graph.init()
+
+	r := make(chan string)
+
+	go p.thread(reader, r)
+
+	return r
+}
+
+type poolWorker struct {
+	device *Device
+	alloc  *allocation
+}
+
+func (p *Pool) open() []*poolWorker {
+	indices := p.Devices
+	if len(indices) == 0 {
+		infos, err := ListDevices()
+		if err != nil {
+			p.Graph.logf("%v", err)
+		}
+		for _, info := range infos {
+			indices = append(indices, info.Index)
+		}
+	}
+
+	var workers []*poolWorker
+	for _, i := range indices {
+		device, err := OpenDevice(i)
+		if err != nil {
+			p.Graph.logf("%v", err)
+			continue
+		}
+
+		a, err := p.Graph.allocateWarm(device)
+		if err != nil {
+			p.Graph.logf("device %d: %v", i, err)
+			device.Close()
+			continue
+		}
+
+		workers = append(workers, &poolWorker{device: device, alloc: a})
+	}
+
+	return workers
+}
+
+func (p *Pool) thread(reader io.Reader, detected chan<- string) {
+	defer close(detected)
+
+	workers := p.open()
+	if len(workers) == 0 {
+		p.Graph.fail(fmt.Errorf("no devices available for pool"))
+		return
+	}
+	defer func() {
+		for _, w := range workers {
+			w.alloc.close()
+			w.device.Close()
+		}
+	}()
+
+	desc := workers[0].alloc.inputDesc
+	if err := workers[0].alloc.imageInput(); err != nil {
+		p.Graph.fail(err)
+		return
+	}
+	width, height := p.Graph.frameSize(desc)
+
+	// each stick runs as many workers as it has inferences in flight, and
+	// every worker holds at most one buffer, so there is always one free to
+	// read into
+	depth := p.Graph.InputFifo.depth()
+	free := make(chan []byte, len(workers)*depth+1)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]byte, width*height*3)
+	}
+
+	frames := make(chan poolFrame)
+	seq := newSequencer()
+	dead := make(chan struct{})
+	raw := p.Graph.frameReader(reader, width, height)
+
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		for i := 0; i < depth; i++ {
+			wg.Add(1)
+			go func(w *poolWorker) {
+				defer wg.Done()
+				p.work(w, frames, free, seq, detected)
+			}(w)
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(dead)
+	}()
+	defer func() {
+		close(frames)
+		<-dead
+	}()
+
+	lim := &limiter{interval: p.Graph.Throttle}
+
+	for n := uint64(0); ; {
+		bb := <-free
+
+		if p.Graph.PaceReads && !lim.wait(dead, p.Graph.stop) {
+			select {
+			case <-p.Graph.stop:
+			default:
+				p.Graph.fail(fmt.Errorf("all devices in pool have failed"))
+			}
+			return
+		}
+
+		if err := raw.read(bb); err != nil {
+			p.Graph.fail(err)
+			return
+		}
+		fr := poolFrame{bytes: bb, seq: n}
+		p.Graph.tag(&fr.request)
+
+		if now := time.Now(); !lim.due(now) {
+			p.Graph.stats.drop()
+			free <- bb
+			continue
+		} else {
+			select {
+			case <-p.Graph.stop:
+				return
+			case <-dead:
+				p.Graph.fail(fmt.Errorf("all devices in pool have failed"))
+				return
+			case frames <- fr:
+				lim.take(now)
+				n++
+			}
+		}
+	}
+}
+
+// poolFrame is a raw frame read by the pool, and the id and user value of
+// the inference to be made from it.  seq counts the frames handed to the
+// workers, which emit them in that order.
+type poolFrame struct {
+	request
+	bytes []byte
+	seq   uint64
+}
+
+// sequencer lets the workers of a Pool, which complete their frames out of
+// order, take turns in the order the frames were handed out.
+type sequencer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	next uint64
+}
+
+func newSequencer() *sequencer {
+	s := &sequencer{}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// wait blocks until it is the turn of frame seq.
+func (s *sequencer) wait(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.next != seq {
+		s.cond.Wait()
+	}
+}
+
+// done passes the turn to the next frame.
+func (s *sequencer) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	s.cond.Broadcast()
+}
+
+// keepsFrames reports whether emit uses the frame of each inference, to
+// annotate, snapshot or clip it, so that a Pool must keep a copy of it.
+func (f *Graph) keepsFrames() bool {
+	return f.Annotate != nil || f.Snapshots != nil || f.Clips != nil
+}
+
+func (p *Pool) work(w *poolWorker, frames <-chan poolFrame, free chan<- []byte, seq *sequencer, detected chan<- string) {
+	desc := w.alloc.inputDesc
+	width, height := p.Graph.frameSize(desc)
+
+	conv := p.Graph.converter()
+	scratch := make([]byte, desc.W*desc.H*3)
+	input := make([]float32, w.alloc.inputLen)
+	bout := make([]float32, w.alloc.outputLen)
+
+	for fr := range frames {
+		r := &fr.request
+		r.times.started = time.Now()
+
+		img := &RawRGBImage{bytes: fr.bytes, width: width, height: height}
+		conv.Convert(input, p.Graph.cropPixels(r, img, desc, scratch))
+		r.times.preprocessed = time.Now()
+
+		r.input, r.output = input, bout
+		if p.Graph.keepsFrames() {
+			// the frame is reused once returned to free
+			copied := &RawRGBImage{bytes: make([]byte, len(fr.bytes)), width: width, height: height}
+			copy(copied.bytes, fr.bytes)
+			r.img = copied
+		}
+		free <- fr.bytes
+
+		err := w.alloc.do(context.Background(), r)
+
+		seq.wait(fr.seq)
+		if err != nil {
+			// the frames after this one are not held up by it
+			seq.done()
+			r.err = err
+			p.Graph.trace(nil, r, time.Time{})
+			p.Graph.fail(fmt.Errorf("device %d: %w", w.device.Index, err))
+			return
+		}
+		p.Graph.emit(r, p.Graph.Smoothing, p.Graph.Tracker, detected)
+		seq.done()
+	}
+}
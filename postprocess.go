@@ -0,0 +1,190 @@
+package mvnc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Postprocessor turns the output tensor of a graph into the detections of
+// a frame, for networks whose output none of the OutputFormats describe.
+// Set Graph.Postprocessor to use one in place of OutputFormat.  Detections
+// with a Box are sent to Detections and drawn by Annotate, their Keypoints
+// and Attributes are added to the Result, and their names, or those of
+// their boxes, are sent by Process; Smoothing applies to them as to the
+// built-in formats.  Decode may be called concurrently, from every stick of
+// a Pool.
+type Postprocessor interface {
+	Decode(output []float32, meta FrameMeta) []Detection
+}
+
+// FrameMeta describes the frame an output passed to a Postprocessor was
+// inferred from.  Outputs are the shapes of the graph's output tensors,
+// concatenated in the output, and Output the shape of the first.  It is
+// zero for outputs passed to Graph.Decode.
+type FrameMeta struct {
+	FrameID uint64
+	Time    time.Time
+	User    interface{}
+	Output  TensorDescriptor
+	Outputs []TensorDescriptor
+}
+
+// ClassificationPostprocessor decodes each output as the score of the class
+// with that index in Names, like the Classification OutputFormat.
+type ClassificationPostprocessor struct {
+	Names     map[int]string
+	Threshold float32
+
+	// TopK, if positive, keeps only the TopK best scoring classes, best
+	// first.  Softmax applies a softmax to the outputs first.
+	TopK    int
+	Softmax bool
+}
+
+func (p *ClassificationPostprocessor) Decode(output []float32, meta FrameMeta) []Detection {
+	scores := output
+	if p.Softmax {
+		scores = append([]float32(nil), output...)
+		softmax(scores)
+	}
+
+	var dets []Detection
+	for i, s := range scores {
+		if name, ok := p.Names[i]; ok && s > p.Threshold {
+			dets = append(dets, Detection{FrameID: meta.FrameID, Class: i, Name: name, Confidence: s})
+		}
+	}
+
+	if p.TopK > 0 {
+		sort.SliceStable(dets, func(i, j int) bool { return dets[i].Confidence > dets[j].Confidence })
+		if len(dets) > p.TopK {
+			dets = dets[:p.TopK]
+		}
+	}
+	return dets
+}
+
+// SSDPostprocessor decodes the output of an SSD graph with DecodeSSD, like
+// the SSD OutputFormat.
+type SSDPostprocessor struct {
+	Names     map[int]string
+	Threshold float32
+}
+
+func (p *SSDPostprocessor) Decode(output []float32, meta FrameMeta) []Detection {
+	return boxDetections(DecodeSSD(output, p.Threshold, p.Names), meta)
+}
+
+// YOLOPostprocessor decodes the output of a YOLO graph described by Config,
+// like the YOLO OutputFormat.
+type YOLOPostprocessor struct {
+	Config    *YOLOConfig
+	Names     map[int]string
+	Threshold float32
+}
+
+// Decode decodes no detections if Config is nil.
+func (p *YOLOPostprocessor) Decode(output []float32, meta FrameMeta) []Detection {
+	if p.Config == nil {
+		return nil
+	}
+	return boxDetections(p.Config.Decode(output, p.Threshold, p.Names), meta)
+}
+
+// checkPostprocessor returns an error if p is one of the built-in
+// Postprocessors and cannot decode an output.  Other Postprocessors are
+// assumed to be able to.
+func checkPostprocessor(p Postprocessor) error {
+	switch p := p.(type) {
+	case *ClassificationPostprocessor:
+		if len(p.Names) == 0 {
+			return fmt.Errorf("a classification postprocessor needs Names")
+		} else if p.TopK < 0 {
+			return fmt.Errorf("a classification postprocessor needs a TopK of zero or more, not %d", p.TopK)
+		}
+	case *SSDPostprocessor:
+		if len(p.Names) == 0 {
+			return fmt.Errorf("an ssd postprocessor needs Names")
+		}
+	case *YOLOPostprocessor:
+		if p.Config == nil {
+			return fmt.Errorf("a yolo postprocessor needs a YOLO config")
+		}
+		return p.Config.check()
+	}
+	return nil
+}
+
+func boxDetections(boxes []BoundingBox, meta FrameMeta) []Detection {
+	dets := make([]Detection, len(boxes))
+	for i := range boxes {
+		b := boxes[i]
+		dets[i] = Detection{FrameID: meta.FrameID, Class: b.Class, Name: b.Name, Confidence: b.Confidence, Box: &b}
+	}
+	return dets
+}
+
+var postprocessors = struct {
+	sync.Mutex
+	m map[string]func(*Graph) (Postprocessor, error)
+}{m: make(map[string]func(*Graph) (Postprocessor, error))}
+
+// RegisterPostprocessor makes a Postprocessor available to configuration
+// files under name, as their output_format.  NewGraph calls fn with the
+// graph it has configured, its Names and Threshold set, to make the
+// graph's Postprocessor.  Names are case insensitive, and must not be one
+// of the built-in OutputFormats.
+func RegisterPostprocessor(name string, fn func(*Graph) (Postprocessor, error)) {
+	if _, err := parseOutputFormat(name); err == nil {
+		panic(fmt.Sprintf("mvnc: postprocessor %q is a built-in output format", name))
+	}
+
+	postprocessors.Lock()
+	defer postprocessors.Unlock()
+
+	postprocessors.m[strings.ToLower(name)] = fn
+}
+
+// registeredPostprocessor returns the function registered under name.
+func registeredPostprocessor(name string) (func(*Graph) (Postprocessor, error), bool) {
+	postprocessors.Lock()
+	defer postprocessors.Unlock()
+
+	fn, ok := postprocessors.m[strings.ToLower(name)]
+	return fn, ok
+}
+
+// decodeBoxes reports whether the graph's outputs are decoded into boxes.
+func (f *Graph) decodeBoxes() bool {
+	return f.Postprocessor != nil || f.OutputFormat == SSD || f.OutputFormat == YOLO
+}
+
+// postprocess decodes bout with the graph's Postprocessor into d.  The
+// names of detections with a Box are taken from their boxes by detect, once
+// the Zones have been applied.
+func (f *Graph) postprocess(bout []float32, meta FrameMeta, d *decoding) {
+	for _, det := range f.Postprocessor.Decode(bout, meta) {
+		if det.Box != nil {
+			b := *det.Box
+			if b.Name == "" {
+				b.Name, b.Confidence = det.Name, det.Confidence
+			}
+			d.boxes = append(d.boxes, b)
+		}
+		if len(det.Keypoints) > 0 {
+			d.keypoints = append(d.keypoints, det.Keypoints)
+		}
+		for name, a := range det.Attributes {
+			if d.attributes == nil {
+				d.attributes = make(map[string]Attribute)
+			}
+			d.attributes[name] = a
+		}
+		if det.Name != "" && det.Box == nil {
+			d.dets = append(d.dets, detection{name: det.Name, confidence: det.Confidence})
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mvnc
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCheckPostprocessor checks that graphs with a built-in Postprocessor
+// which cannot decode an output are rejected when they are opened.
+func TestCheckPostprocessor(t *testing.T) {
+	names := map[int]string{0: "cat", 1: "dog"}
+	yolo := &YOLOConfig{Version: 2, Classes: 2, Layers: []YOLOLayer{{GridW: 1, GridH: 1, Anchors: [][2]float32{{1, 1}}}}}
+
+	tests := []struct {
+		name string
+		p    Postprocessor
+		want string
+	}{
+		{"classification", &ClassificationPostprocessor{Names: names}, ""},
+		{"classification without names", &ClassificationPostprocessor{}, "Names"},
+		{"negative top k", &ClassificationPostprocessor{Names: names, TopK: -1}, "TopK"},
+		{"ssd", &SSDPostprocessor{Names: names}, ""},
+		{"ssd without names", &SSDPostprocessor{}, "Names"},
+		{"yolo", &YOLOPostprocessor{Config: yolo, Names: names}, ""},
+		{"yolo without a config", &YOLOPostprocessor{Names: names}, "YOLO config"},
+		{"invalid yolo config", &YOLOPostprocessor{Config: &YOLOConfig{Version: 2}, Names: names}, "classes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Graph{Postprocessor: tt.p}).checkFormat()
+			if tt.want == "" && err != nil {
+				t.Errorf("checkFormat returned %v", err)
+			} else if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("checkFormat returned %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+
+	if dets := (&YOLOPostprocessor{}).Decode(make([]float32, 8), FrameMeta{}); len(dets) != 0 {
+		t.Errorf("decoded %v without a YOLO config", dets)
+	}
+}
@@ -0,0 +1,202 @@
+package mvnc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChannelOrder is the order of the color channels in the input tensor.
+type ChannelOrder int
+
+// Channel orders.
+const (
+	RGB ChannelOrder = iota
+	BGR
+)
+
+// Layout is the memory layout of the input tensor.
+type Layout int
+
+// Layouts: HWC interleaves the channels of each pixel, while CHW (planar)
+// stores each whole channel in turn.
+const (
+	HWC Layout = iota
+	CHW
+)
+
+func (l Layout) String() string {
+	switch l {
+	case HWC:
+		return "hwc"
+	case CHW:
+		return "chw"
+	default:
+		return "unknown"
+	}
+}
+
+func (l Layout) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses hwc or chw, so that a Layout can be read from a
+// Config.
+func (l *Layout) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "hwc":
+		*l = HWC
+	case "chw":
+		*l = CHW
+	default:
+		return fmt.Errorf("unknown layout '%s'", b)
+	}
+	return nil
+}
+
+// Preprocess describes how the 8-bit RGB pixels of a frame are converted into
+// the graph's float input tensor.  Each channel value c becomes
+// (c - Mean[i]) * Scale[i], with the channels indexed in R, G, B order
+// regardless of Order, and is then stored in the tensor according to Order
+// and Layout.  A graph taking a single channel is given the luma of each
+// pixel, weighted as in ITU-R BT.601, which becomes (y - Mean[0]) * Scale[0].
+//
+// For example a Caffe model trained with the ImageNet mean subtracted
+// would use Mean: [3]float32{123.68, 116.78, 103.94} and Scale: [3]float32{1, 1, 1},
+// while a typical TensorFlow model scaling to [-1, 1] would use a Mean of
+// 127.5 and a Scale of 1/127.5 for every channel.
+type Preprocess struct {
+	Mean  [3]float32
+	Scale [3]float32
+
+	Order  ChannelOrder
+	Layout Layout
+}
+
+// preprocessing returns the effective preprocessing for the graph, derived
+// from Mean and Stddev if Preprocess is not set.
+func (f *Graph) preprocessing() *Preprocess {
+	if f.Preprocess != nil {
+		return f.Preprocess
+	}
+
+	return &Preprocess{
+		Mean:  [3]float32{f.Mean, f.Mean, f.Mean},
+		Scale: [3]float32{1 / f.Stddev, 1 / f.Stddev, 1 / f.Stddev},
+	}
+}
+
+// Converter converts the 8-bit RGB pixels of a frame, interleaved in R, G, B
+// order, into the graph's float input tensor.  For a graph taking a single
+// channel, input holds one value per pixel rather than three.  Set
+// Graph.Converter to replace the built-in conversion, for example with one
+// using SIMD instructions.
+type Converter interface {
+	Convert(input []float32, pixels []byte)
+}
+
+// converter returns the Converter the graph uses: Converter if set, or else
+// a table-driven conversion described by Preprocess, or Mean and Stddev.
+func (f *Graph) converter() Converter {
+	if f.Converter != nil {
+		return f.Converter
+	}
+
+	return f.preprocessing().Converter()
+}
+
+// Convert fills input with the pixels of bb, interleaved RGB bytes, each
+// channel less its Mean and times its Scale, stored in Order and as Layout
+// describes, or as their luma if input holds a single channel.  It computes
+// each value directly; the Converter returned by p.Converter gives the same
+// results about twice as fast.
+func (p *Preprocess) Convert(input []float32, bb []byte) {
+	n := len(bb) / 3
+	if len(input) < len(bb) {
+		for i := 0; i < n; i++ {
+			input[i] = (float32(luma(bb[i*3:i*3+3])) - p.Mean[0]) * p.Scale[0]
+		}
+		return
+	}
+
+	// offsets of the red, green and blue values of a pixel, and the distance between pixels
+	r, g, b, stride := 0, 1, 2, 3
+	if p.Order == BGR {
+		r, b = 2, 0
+	}
+	if p.Layout == CHW {
+		r, g, b, stride = r*n, g*n, b*n, 1
+	}
+
+	for i := 0; i < n; i++ {
+		c, o := bb[i*3:i*3+3], i*stride
+
+		input[o+r] = (float32(c[0]) - p.Mean[0]) * p.Scale[0]
+		input[o+g] = (float32(c[1]) - p.Mean[1]) * p.Scale[1]
+		input[o+b] = (float32(c[2]) - p.Mean[2]) * p.Scale[2]
+	}
+}
+
+// Converter returns a Converter equivalent to p that looks each value up in
+// a table of the 256 possible results per channel, rather than computing
+// it.  Later changes to p do not affect it.
+func (p *Preprocess) Converter() Converter {
+	t := &tableConverter{order: p.Order, layout: p.Layout}
+
+	for c := 0; c < 3; c++ {
+		for v := 0; v < 256; v++ {
+			t.lut[c][v] = (float32(v) - p.Mean[c]) * p.Scale[c]
+		}
+	}
+
+	return t
+}
+
+type tableConverter struct {
+	lut    [3][256]float32 // indexed by R, G, B and then the byte value
+	order  ChannelOrder
+	layout Layout
+}
+
+func (t *tableConverter) Convert(input []float32, bb []byte) {
+	n := len(bb) / 3
+	if len(input) < len(bb) {
+		lut := &t.lut[0]
+		for i := range input[:n] {
+			input[i] = lut[luma(bb[i*3:i*3+3:i*3+3])]
+		}
+		return
+	}
+	bb, input = bb[:n*3], input[:n*3]
+
+	// the tables for the first, second and third value of each pixel in
+	// the tensor
+	first, second, third := &t.lut[0], &t.lut[1], &t.lut[2]
+	i0, i2 := 0, 2
+	if t.order == BGR {
+		first, third = third, first
+		i0, i2 = 2, 0
+	}
+
+	if t.layout == CHW {
+		p0, p1, p2 := input[:n], input[n:2*n], input[2*n:3*n]
+		for i := 0; i < n; i++ {
+			c := bb[i*3 : i*3+3 : i*3+3]
+			p0[i] = first[c[i0]]
+			p1[i] = second[c[1]]
+			p2[i] = third[c[i2]]
+		}
+		return
+	}
+
+	for i := 0; i < len(bb); i += 3 {
+		c, o := bb[i:i+3:i+3], input[i:i+3:i+3]
+		o[0] = first[c[i0]]
+		o[1] = second[c[1]]
+		o[2] = third[c[i2]]
+	}
+}
+
+// luma returns the BT.601 luma of an RGB pixel, in fixed point.
+func luma(c []byte) byte {
+	return byte((77*int(c[0]) + 150*int(c[1]) + 29*int(c[2]) + 128) >> 8)
+}
@@ -0,0 +1,175 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Priority orders the inferences waiting for room in a graph's input fifo
+// while it is full.  Whenever an inference completes, its slot goes to the
+// waiting inference of the highest priority, and among those of the same
+// priority to the one which has waited longest.  Inferences already written
+// to the fifo are never preempted, so a call to Infer overtakes the frames of
+// a stream at the next frame boundary.
+type Priority int
+
+const (
+	// StreamPriority is the priority of the frames read by Process and by
+	// a Multiplexer, and of warmup inferences.
+	StreamPriority Priority = iota
+
+	// NormalPriority is the priority of Infer, InferTensors, InferImage,
+	// InferBatch and Benchmark, unless their context was given another by
+	// WithPriority.
+	NormalPriority
+
+	// HighPriority overtakes every other inference.
+	HighPriority
+)
+
+func (p Priority) String() string {
+	switch p {
+	case StreamPriority:
+		return "stream"
+	case NormalPriority:
+		return "normal"
+	case HighPriority:
+		return "high"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx which runs the inferences it is passed
+// to, such as by Infer, at priority p.  To keep offline work such as
+// InferBatch from holding up a live stream, pass StreamPriority.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityOf returns the priority given to ctx by WithPriority, or
+// NormalPriority.
+func priorityOf(ctx context.Context) Priority {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	if !ok {
+		return NormalPriority
+	} else if p < StreamPriority {
+		return StreamPriority
+	} else if p > HighPriority {
+		return HighPriority
+	}
+	return p
+}
+
+// lanes hands out the slots of an allocation's pipeline, one for each
+// request in flight.  A request which finds them all in use waits in the
+// lane of its priority, and each slot released is handed straight to the
+// first request of the highest lane waiting.
+type lanes struct {
+	size int
+
+	mu      sync.Mutex
+	used    int
+	waiting [HighPriority + 1][]chan struct{}
+}
+
+func newLanes(size int) *lanes {
+	return &lanes{size: size}
+}
+
+// acquire takes a slot for r, waiting in its lane until one is handed to it,
+// and reports whether it did before stop or cancel was closed.
+func (l *lanes) acquire(r *request, cancel, stop <-chan struct{}) bool {
+	l.mu.Lock()
+	if l.used < l.size {
+		l.used++
+		l.mu.Unlock()
+		return true
+	}
+
+	// the channel is kept with the request, which Process reuses, so that
+	// waiting makes no allocations
+	if r.ready == nil {
+		r.ready = make(chan struct{}, 1)
+	}
+	l.waiting[r.priority] = append(l.waiting[r.priority], r.ready)
+	l.mu.Unlock()
+
+	select {
+	case <-r.ready:
+		return true
+	case <-cancel:
+	case <-stop:
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lane := l.waiting[r.priority]
+	for i, ready := range lane {
+		if ready == r.ready {
+			l.waiting[r.priority] = append(lane[:i], lane[i+1:]...)
+			return false
+		}
+	}
+
+	// a slot was handed over meanwhile, so pass it on
+	<-r.ready
+	l.releaseLocked()
+	return false
+}
+
+// tryAcquire takes a slot if one is free.
+func (l *lanes) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.used == l.size {
+		return false
+	}
+	l.used++
+	return true
+}
+
+// release frees a slot, handing it to the first waiting request of the
+// highest priority.
+func (l *lanes) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releaseLocked()
+}
+
+func (l *lanes) releaseLocked() {
+	for p := len(l.waiting) - 1; p >= 0; p-- {
+		if lane := l.waiting[p]; len(lane) > 0 {
+			ready := lane[0]
+			l.waiting[p] = append(lane[:0], lane[1:]...)
+			ready <- struct{}{}
+			return
+		}
+	}
+	l.used--
+}
+
+// depth returns the number of slots in use and of requests waiting for one.
+func (l *lanes) depth() (used, waiting int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, lane := range l.waiting {
+		waiting += len(lane)
+	}
+	return l.used, waiting
+}
+
+// full reports whether every slot is in use.
+func (l *lanes) full() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.used == l.size
+}
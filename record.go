@@ -0,0 +1,194 @@
+package mvnc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"sync"
+	"time"
+)
+
+// RecordedFrame is a frame of a recording made by a Recorder: the frame as a
+// JPEG and the raw output tensor the graph produced for it, from which
+// Replay reproduces its detections.
+type RecordedFrame struct {
+	FrameID uint64    `json:"frame_id"`
+	Time    time.Time `json:"time"`
+	Image   []byte    `json:"image,omitempty"`
+	Output  []float32 `json:"output"`
+
+	// ROI is the rectangle of the frame the graph saw, as [x0, y0, x1, y1],
+	// if it was cropped to its region of interest or letterboxed, and
+	// Bounds are the frame's.
+	ROI    []int `json:"roi,omitempty"`
+	Bounds []int `json:"bounds,omitempty"`
+}
+
+// DecodeImage decodes the frame, or returns nil if it was not recorded.
+func (fr *RecordedFrame) DecodeImage() (image.Image, error) {
+	if len(fr.Image) == 0 {
+		return nil, nil
+	}
+	return jpeg.Decode(bytes.NewReader(fr.Image))
+}
+
+// Recorder records the frames run through a graph and their raw output
+// tensors, as JSON Lines of RecordedFrames, so that a session can later be
+// replayed through the graph's postprocessing by Replay without a stick.
+// Set Graph.Record to record every frame run by Process, a Pool or a
+// Multiplexer.  It is safe for concurrent use.
+type Recorder struct {
+	// Quality is the JPEG quality of the frames, from 1 to 100.  It defaults
+	// to jpeg.DefaultQuality.  NoImages leaves the frames out, recording
+	// the output tensors alone.
+	Quality  int
+	NoImages bool
+
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+	err error
+}
+
+// NewRecorder returns a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Write writes fr as a line of the recording.
+func (rec *Recorder) Write(fr *RecordedFrame) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.buf.Reset()
+	if err := json.NewEncoder(&rec.buf).Encode(fr); err != nil {
+		return err
+	}
+	_, err := rec.w.Write(rec.buf.Bytes())
+	return err
+}
+
+// Err returns the error which stopped the recording of a graph's frames,
+// if any.
+func (rec *Recorder) Err() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.err
+}
+
+// record writes the frame and output of r, logging the first error, after
+// which nothing more is recorded.
+func (rec *Recorder) record(f *Graph, r *request) {
+	if rec.Err() != nil {
+		return
+	}
+
+	fr := &RecordedFrame{FrameID: r.id, Time: r.readAt, Output: r.output}
+	if !r.roi.Empty() {
+		fr.ROI = []int{r.roi.Min.X, r.roi.Min.Y, r.roi.Max.X, r.roi.Max.Y}
+		fr.Bounds = []int{r.bounds.Min.X, r.bounds.Min.Y, r.bounds.Max.X, r.bounds.Max.Y}
+	}
+
+	err := func() error {
+		if r.img != nil && !rec.NoImages {
+			quality := rec.Quality
+			if quality == 0 {
+				quality = jpeg.DefaultQuality
+			}
+
+			var b bytes.Buffer
+			if err := jpeg.Encode(&b, r.img, &jpeg.Options{Quality: quality}); err != nil {
+				return err
+			}
+			fr.Image = b.Bytes()
+		}
+		return rec.Write(fr)
+	}()
+	if err != nil {
+		rec.mu.Lock()
+		rec.err = err
+		rec.mu.Unlock()
+		f.logf("error recording frame %d, recording stopped: %v", r.id, err)
+	}
+}
+
+// RecordingReader reads the frames of a recording made by a Recorder.
+type RecordingReader struct {
+	s *bufio.Scanner
+}
+
+// NewRecordingReader returns a RecordingReader reading from r.
+func NewRecordingReader(r io.Reader) *RecordingReader {
+	s := bufio.NewScanner(r)
+	// the lines hold whole JPEGs
+	s.Buffer(nil, 64<<20)
+	return &RecordingReader{s: s}
+}
+
+// Next returns the next frame of the recording, or io.EOF at its end.
+func (rr *RecordingReader) Next() (*RecordedFrame, error) {
+	for rr.s.Scan() {
+		if len(bytes.TrimSpace(rr.s.Bytes())) == 0 {
+			continue
+		}
+
+		fr := &RecordedFrame{}
+		if err := json.Unmarshal(rr.s.Bytes(), fr); err != nil {
+			return nil, err
+		}
+		return fr, nil
+	}
+
+	if err := rr.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Replay runs the frames of a recording made by a Recorder through the
+// graph's postprocessing, as Process would have for the recorded output
+// tensors, without opening the graph: the names detected are sent on the
+// returned channel, after Smoothing, and the frames' Results, boxes and
+// annotated images are sent to the graph's channels, sinks and handlers
+// as usual.  With a changed Threshold, Postprocessor or Smoothing, it shows
+// what the graph would have reported of the same session.  The channel is
+// closed at the end of the recording, or on an error, which is passed to
+// the OnError handlers.
+func (f *Graph) Replay(recording io.Reader) <-chan string {
+	detected := make(chan string)
+
+	go func() {
+		defer close(detected)
+
+		rr := NewRecordingReader(recording)
+		for {
+			fr, err := rr.Next()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				f.fail(err)
+				return
+			}
+
+			r := &request{id: fr.FrameID, readAt: fr.Time, output: fr.Output}
+			if len(fr.ROI) == 4 && len(fr.Bounds) == 4 {
+				r.roi = image.Rect(fr.ROI[0], fr.ROI[1], fr.ROI[2], fr.ROI[3])
+				r.bounds = image.Rect(fr.Bounds[0], fr.Bounds[1], fr.Bounds[2], fr.Bounds[3])
+			}
+			if f.FrameUser != nil {
+				r.user = f.FrameUser(r.id)
+			}
+			if r.img, err = fr.DecodeImage(); err != nil {
+				f.logf("error decoding frame %d of the recording: %v", fr.FrameID, err)
+			}
+
+			f.emit(r, f.Smoothing, f.Tracker, detected)
+		}
+	}()
+
+	return detected
+}
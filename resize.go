@@ -0,0 +1,187 @@
+package mvnc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Fit is how an image of another aspect ratio than the input tensor is
+// fitted to it.
+type Fit int
+
+const (
+	// Crop crops the image about its center to the aspect ratio of the
+	// tensor, losing its edges.
+	Crop Fit = iota
+
+	// Letterbox scales the whole image to fit within the tensor, centered,
+	// and pads the rest with PadColor, as YOLO graphs are trained.
+	Letterbox
+)
+
+func (f Fit) String() string {
+	switch f {
+	case Crop:
+		return "crop"
+	case Letterbox:
+		return "letterbox"
+	default:
+		return fmt.Sprintf("Fit(%d)", int(f))
+	}
+}
+
+// resizeRGB writes img into dst as interleaved 8-bit RGB pixels of the given
+// width and height.  img is cropped about its center to the aspect ratio of
+// the destination and then scaled with bilinear interpolation.
+func resizeRGB(dst []byte, width, height int, img image.Image) {
+	resizeRectRGB(dst, width, height, img, img.Bounds())
+}
+
+// resizeRectRGB is like resizeRGB, but only uses the pixels of img within r.
+func resizeRectRGB(dst []byte, width, height int, img image.Image, r image.Rectangle) {
+	resizeInto(dst, width, image.Rect(0, 0, width, height), img, centerCrop(r, width, height))
+}
+
+// letterboxRGB is like resizeRectRGB, but scales all of r to fit within the
+// destination, centered, and pads the rest with pad.  It returns the
+// rectangle around r, in the coordinates of img, the whole destination
+// covers.
+func letterboxRGB(dst []byte, width, height int, img image.Image, r image.Rectangle, pad color.Color) image.Rectangle {
+	dr, seen := letterbox(r, width, height)
+
+	pr, pg, pb, _ := pad.RGBA()
+	for i := 0; i+2 < len(dst); i += 3 {
+		dst[i], dst[i+1], dst[i+2] = byte(pr>>8), byte(pg>>8), byte(pb>>8)
+	}
+
+	resizeInto(dst, width, dr, img, r)
+	return seen
+}
+
+// letterbox returns the rectangle of a width by height destination that r
+// is scaled into without cropping, and the rectangle around r the whole
+// destination covers.
+func letterbox(r image.Rectangle, width, height int) (dr, seen image.Rectangle) {
+	w, h := r.Dx(), r.Dy()
+	dr, seen = image.Rect(0, 0, width, height), r
+
+	if w*height > h*width {
+		dh := (h*width + w/2) / w
+		if dh < 1 {
+			dh = 1
+		}
+		dr.Min.Y = (height - dh) / 2
+		dr.Max.Y = dr.Min.Y + dh
+
+		sh := (w*height + width/2) / width
+		seen.Min.Y -= (sh - h) / 2
+		seen.Max.Y = seen.Min.Y + sh
+	} else if w*height < h*width {
+		dw := (w*height + h/2) / h
+		if dw < 1 {
+			dw = 1
+		}
+		dr.Min.X = (width - dw) / 2
+		dr.Max.X = dr.Min.X + dw
+
+		sw := (h*width + height/2) / height
+		seen.Min.X -= (sw - w) / 2
+		seen.Max.X = seen.Min.X + sw
+	}
+
+	return dr, seen
+}
+
+// resizeInto scales the pixels of img within src into the rectangle dr of
+// dst, an image stride pixels wide, with bilinear interpolation.
+func resizeInto(dst []byte, stride int, dr image.Rectangle, img image.Image, src image.Rectangle) {
+	width, height := dr.Dx(), dr.Dy()
+
+	sx := float64(src.Dx()) / float64(width)
+	sy := float64(src.Dy()) / float64(height)
+
+	for y := 0; y < height; y++ {
+		fy := (float64(y)+0.5)*sy - 0.5
+		y0, wy := split(fy, src.Dy())
+		y1 := clamp(y0+1, src.Dy())
+
+		for x := 0; x < width; x++ {
+			fx := (float64(x)+0.5)*sx - 0.5
+			x0, wx := split(fx, src.Dx())
+			x1 := clamp(x0+1, src.Dx())
+
+			r00, g00, b00 := rgbAt(img, src.Min.X+x0, src.Min.Y+y0)
+			r10, g10, b10 := rgbAt(img, src.Min.X+x1, src.Min.Y+y0)
+			r01, g01, b01 := rgbAt(img, src.Min.X+x0, src.Min.Y+y1)
+			r11, g11, b11 := rgbAt(img, src.Min.X+x1, src.Min.Y+y1)
+
+			pos := ((dr.Min.Y+y)*stride + dr.Min.X + x) * 3
+			dst[pos] = lerp2(r00, r10, r01, r11, wx, wy)
+			dst[pos+1] = lerp2(g00, g10, g01, g11, wx, wy)
+			dst[pos+2] = lerp2(b00, b10, b01, b11, wx, wy)
+		}
+	}
+}
+
+// centerCrop returns the largest rectangle centered in r with the aspect
+// ratio width:height.
+func centerCrop(r image.Rectangle, width, height int) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+
+	if w*height > h*width {
+		cw := h * width / height
+		r.Min.X += (w - cw) / 2
+		r.Max.X = r.Min.X + cw
+	} else if w*height < h*width {
+		ch := w * height / width
+		r.Min.Y += (h - ch) / 2
+		r.Max.Y = r.Min.Y + ch
+	}
+
+	return r
+}
+
+// split returns the integer sample position at or below f, clamped to
+// [0, n), and the weight of the following sample.
+func split(f float64, n int) (int, float64) {
+	if f <= 0 {
+		return 0, 0
+	}
+
+	i := int(f)
+	if i >= n-1 {
+		return n - 1, 0
+	}
+
+	return i, f - float64(i)
+}
+
+func clamp(i, n int) int {
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+func lerp2(v00, v10, v01, v11 float64, wx, wy float64) byte {
+	top := v00 + (v10-v00)*wx
+	bottom := v01 + (v11-v01)*wx
+
+	return byte(top + (bottom-top)*wy + 0.5)
+}
+
+// rgbAt returns the 8-bit color components of img at (x, y).
+func rgbAt(img image.Image, x, y int) (float64, float64, float64) {
+	switch m := img.(type) {
+	case *image.RGBA:
+		i := m.PixOffset(x, y)
+		return float64(m.Pix[i]), float64(m.Pix[i+1]), float64(m.Pix[i+2])
+	case *RawRGBImage:
+		i := (y*m.width + x) * 3
+		return float64(m.bytes[i]), float64(m.bytes[i+1]), float64(m.bytes[i+2])
+	}
+
+	r, g, b, _ := img.At(x, y).RGBA()
+	return float64(r >> 8), float64(g >> 8), float64(b >> 8)
+}
@@ -0,0 +1,127 @@
+package mvnc
+
+import (
+	"context"
+	"image"
+	"time"
+)
+
+// Result is everything produced by a single inference on a frame.
+type Result struct {
+	// FrameID is the sequence number of the frame, counting from 1 for the
+	// first frame the graph read.  It is passed through the fifos with the
+	// frame and checked when the output is read.
+	FrameID uint64
+
+	// Source is the id of the source of a Multiplexer the frame was read
+	// from, and empty for frames read otherwise.
+	Source string
+
+	// Time is when the frame was read.
+	Time time.Time
+
+	// User is the value FrameUser returned for the frame.
+	User interface{}
+
+	// Names are the names sent by Process for the frame, after Smoothing,
+	// and Confidences their scores, similarities or box confidences.
+	Names       []string
+	Confidences []float32
+
+	// Boxes are the boxes sent to Detections, if OutputFormat is SSD or YOLO
+	// or the Postprocessor found any.  If the graph has Zones, Zones holds
+	// the names of the zones each box is in.
+	Boxes []BoundingBox
+	Zones [][]string
+
+	// Tracks are the tracks of the Boxes matched by the graph's Tracker,
+	// and Crossings their crossings of its counting lines in the frame.
+	Tracks    []Track
+	Crossings []CrossingEvent
+
+	// Map is the map of the frame made of the output of a Segmentation or
+	// Heatmap graph, at the size of the map.
+	Map image.Image
+
+	// Keypoints are the keypoints of each object found by a Keypoints
+	// graph, or by a Postprocessor.
+	Keypoints [][]Keypoint
+
+	// Attributes are the attributes decoded by an Attributes graph, or by a
+	// Postprocessor, by name.  DecodeAttributes stores them in a struct.
+	Attributes map[string]Attribute
+
+	// Output is a copy of the output tensor.  For graphs with several
+	// output tensors it is their concatenation, and Tensors holds each of
+	// them, sharing Output's memory.
+	Output  []float32
+	Tensors [][]float32
+
+	Timing Timing
+}
+
+// tag records that the frame of r was read, and sets its id and user value.
+func (f *Graph) tag(r *request) {
+	r.id = f.stats.read()
+	r.readAt = time.Now()
+	r.times = stageTimes{}
+	r.user = nil
+	if f.FrameUser != nil {
+		r.user = f.FrameUser(r.id)
+	}
+}
+
+// Decode turns an output of the graph, such as one returned by Infer or
+// InferImage, into the names and boxes Process would report for it, as
+// described by its Postprocessor or OutputFormat.  Smoothing is not applied.
+// If the graph is open, the output is decoded with the shapes of its output
+// tensors.  Otherwise Decode needs no stick, and the output is decoded as a
+// single tensor of unknown shape: a Segmentation or Heatmap graph must then
+// have the size of its Map set.  It returns an error if the output cannot
+// be decoded as described.
+func (f *Graph) Decode(output []float32) (Result, error) {
+	return f.decodeRect(output, f.outputMeta(), image.Rectangle{}, image.Rectangle{})
+}
+
+// outputMeta describes the output tensors of the graph, if it is open,
+// without opening it.
+func (f *Graph) outputMeta() FrameMeta {
+	f.acquire(context.Background())
+	defer f.release()
+
+	if f.alloc == nil {
+		return FrameMeta{}
+	}
+	outputs := f.alloc.descriptors(f.alloc.outputs)
+	return FrameMeta{Output: outputs[0], Outputs: outputs}
+}
+
+// decodeRect is Decode on the output for the rectangle roi of a frame with
+// the given bounds, of the output tensors described by meta, mapping the
+// boxes and keypoints to the whole frame.
+func (f *Graph) decodeRect(output []float32, meta FrameMeta, roi, bounds image.Rectangle) (Result, error) {
+	sc := getScratch()
+	defer putScratch(sc)
+
+	d, err := f.detect(output, meta, roi, bounds, sc, sc.dets[:0])
+	sc.dets = d.dets
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Boxes: d.boxes, Zones: d.zones, Map: d.mp, Keypoints: d.keypoints, Attributes: d.attributes, Output: output}
+	res.Names, res.Confidences = names(d.dets)
+	return res, nil
+}
+
+func names(dets []detection) ([]string, []float32) {
+	if len(dets) == 0 {
+		return nil, nil
+	}
+
+	names, confidences := make([]string, len(dets)), make([]float32, len(dets))
+	for i, d := range dets {
+		names[i], confidences[i] = d.name, d.confidence
+	}
+	return names, confidences
+}
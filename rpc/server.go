@@ -0,0 +1,190 @@
+// Package rpc is a gRPC service running frames through a graph, for
+// integrating the stick into larger pipelines of services.  The service is
+// defined in mvnc.proto; NewInferenceClient is its generated client.
+//
+//	lis, err := net.Listen("tcp", ":50051")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	s := grpc.NewServer()
+//	rpc.RegisterInferenceServer(s, &rpc.Server{Graph: graph})
+//	log.Fatal(s.Serve(lis))
+//
+// The package is a separate module so that the mvnc package itself does not
+// depend on gRPC.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative mvnc.proto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"time"
+
+	"github.com/donniet/mvnc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDepth is the number of frames of a stream run at once if
+// Server.Depth is zero.
+const DefaultDepth = 4
+
+// Server implements the Inference service with a graph.
+type Server struct {
+	UnimplementedInferenceServer
+
+	Graph *mvnc.Graph
+
+	// Output returns the raw output tensor in every result.
+	Output bool
+
+	// Depth is the number of frames of each stream run at once, which
+	// should be at least the depth of the graph's input fifo to keep the
+	// stick busy.
+	Depth int
+}
+
+// Infer runs a single frame through the graph.
+func (s *Server) Infer(ctx context.Context, frame *Frame) (*Result, error) {
+	return s.infer(ctx, frame)
+}
+
+// Stream runs the frames of a stream through the graph, returning their
+// results in order.
+func (s *Server) Stream(stream Inference_StreamServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	depth := s.Depth
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+
+	// each frame is run in its own goroutine, and the results sent in the
+	// order the frames were received
+	pending := make(chan chan result, depth)
+
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(pending)
+
+		for {
+			frame, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF {
+					recvErr <- err
+				}
+				return
+			}
+
+			r := make(chan result, 1)
+			select {
+			case pending <- r:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				res, err := s.infer(ctx, frame)
+				r <- result{res, err}
+			}()
+		}
+	}()
+
+	for r := range pending {
+		res := <-r
+		if res.err != nil {
+			return res.err
+		}
+		if err := stream.Send(res.Result); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+type result struct {
+	*Result
+	err error
+}
+
+func (s *Server) infer(ctx context.Context, frame *Frame) (*Result, error) {
+	img, err := decode(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	output, err := s.Graph.InferImage(ctx, img)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+
+	res := &Result{Id: frame.Id, LatencyUs: time.Since(start).Microseconds()}
+
+	decoded, err := s.Graph.Decode(output)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+	for i, name := range decoded.Names {
+		res.Detections = append(res.Detections, &Detection{Name: name, Confidence: decoded.Confidences[i]})
+	}
+	for _, b := range decoded.Boxes {
+		res.Boxes = append(res.Boxes, &Box{Class: int32(b.Class), Name: b.Name, Confidence: b.Confidence, Xmin: b.XMin, Ymin: b.YMin, Xmax: b.XMax, Ymax: b.YMax})
+	}
+	if s.Output {
+		res.Output = output
+	}
+
+	return res, nil
+}
+
+func decode(frame *Frame) (image.Image, error) {
+	switch data := frame.Data.(type) {
+	case *Frame_Image:
+		img, _, err := image.Decode(bytes.NewReader(data.Image))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "error decoding frame %d: %v", frame.Id, err)
+		}
+		return img, nil
+
+	case *Frame_Raw:
+		if data.Raw.Width <= 0 || data.Raw.Height <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "frame %d is %dx%d", frame.Id, data.Raw.Width, data.Raw.Height)
+		}
+		img, err := mvnc.NewRawRGBImage(data.Raw.Rgb, int(data.Raw.Width), int(data.Raw.Height))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "frame %d: %v", frame.Id, err)
+		}
+		return img, nil
+
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "frame %d has no image", frame.Id)
+	}
+}
+
+// statusFor maps an error from the graph to a gRPC status.
+func statusFor(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, mvnc.ErrDeviceNotFound), errors.Is(err, mvnc.ErrBusy), errors.Is(err, mvnc.ErrTimeout):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
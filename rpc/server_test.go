@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDecodeRaw(t *testing.T) {
+	rgb := make([]byte, 2*2*3)
+
+	tests := []struct {
+		width, height int32
+		code          codes.Code
+	}{
+		{2, 2, codes.OK},
+		{4, 1, codes.OK},
+		{-2, -2, codes.InvalidArgument},
+		{-1, -4, codes.InvalidArgument},
+		{0, 0, codes.InvalidArgument},
+		{2, 3, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		frame := &Frame{Id: 1, Data: &Frame_Raw{Raw: &RawImage{Width: tt.width, Height: tt.height, Rgb: rgb}}}
+		img, err := decode(frame)
+		if code := status.Code(err); code != tt.code {
+			t.Errorf("decoding a %dx%d frame returned %v, want %v", tt.width, tt.height, err, tt.code)
+		} else if err == nil && (img.Bounds().Dx() != int(tt.width) || img.Bounds().Dy() != int(tt.height)) {
+			t.Errorf("decoding a %dx%d frame returned an image of %v", tt.width, tt.height, img.Bounds())
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package rtsp
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client is an RTSP session with RTP interleaved on the TCP connection,
+// which avoids the firewall and packet loss problems of UDP.
+type client struct {
+	url  *url.URL
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu      sync.Mutex // held while writing requests
+	cseq    int
+	session string
+	timeout time.Duration // for each request and packet
+	expiry  time.Duration // of the session, if no keepalives are sent
+	auth    func(method, uri string) string
+
+	// the media found by DESCRIBE
+	control string
+	codec   string
+}
+
+type response struct {
+	code   int
+	status string
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+const defaultPort = "554"
+
+// dial connects to the server of rawurl and DESCRIBEs, SETs UP and PLAYs its
+// first video stream.
+func dial(rawurl string, timeout time.Duration) (*client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "rtsp" {
+		return nil, fmt.Errorf("unsupported scheme '%s' in %s", u.Scheme, u.Redacted())
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", u.Redacted(), err)
+	}
+
+	c := &client{url: u, conn: conn, br: bufio.NewReader(conn), timeout: timeout, expiry: 60 * time.Second}
+	if err := c.start(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// uri is the url sent in requests, without the credentials.
+func (c *client) uri() string {
+	u := *c.url
+	u.User = nil
+	return u.String()
+}
+
+func (c *client) start() error {
+	resp, err := c.request("DESCRIBE", c.uri(), map[string]string{"Accept": "application/sdp"})
+	if err != nil {
+		return err
+	}
+
+	base := resp.header.Get("Content-Base")
+	if base == "" {
+		base = c.uri()
+	}
+	if err := c.parseSDP(string(resp.body), base); err != nil {
+		return err
+	}
+
+	resp, err = c.request("SETUP", c.control, map[string]string{"Transport": "RTP/AVP/TCP;unicast;interleaved=0-1"})
+	if err != nil {
+		return err
+	}
+
+	session := resp.header.Get("Session")
+	if i := strings.IndexByte(session, ';'); i >= 0 {
+		for _, param := range strings.Split(session[i+1:], ";") {
+			if v := strings.TrimPrefix(strings.TrimSpace(param), "timeout="); v != param {
+				if s, err := strconv.Atoi(v); err == nil && s > 0 {
+					c.expiry = time.Duration(s) * time.Second
+				}
+			}
+		}
+		session = session[:i]
+	}
+	c.session = strings.TrimSpace(session)
+
+	_, err = c.request("PLAY", c.uri(), map[string]string{"Range": "npt=0.000-"})
+	return err
+}
+
+// parseSDP finds the control url and codec of the first video media in an
+// SDP description.
+func (c *client) parseSDP(sdp, base string) error {
+	video, payload := false, ""
+	codecs := map[string]string{}
+
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "m=") {
+			if video {
+				// only the first video media is used
+				break
+			}
+			fields := strings.Fields(line[2:])
+			if video = len(fields) >= 4 && fields[0] == "video"; video {
+				payload = fields[3]
+			}
+			continue
+		} else if !video {
+			continue
+		}
+
+		if v := strings.TrimPrefix(line, "a=rtpmap:"); v != line {
+			if fields := strings.Fields(v); len(fields) == 2 {
+				codecs[fields[0]] = strings.ToUpper(strings.SplitN(fields[1], "/", 2)[0])
+			}
+		} else if v := strings.TrimPrefix(line, "a=control:"); v != line {
+			c.control = v
+		}
+	}
+
+	if !video {
+		return fmt.Errorf("%s has no video stream", c.uri())
+	}
+
+	c.codec = codecs[payload]
+	if c.codec == "" && payload == "26" {
+		// the static payload type of RFC 3551
+		c.codec = "JPEG"
+	}
+	if c.codec != "JPEG" && c.codec != "H264" {
+		return fmt.Errorf("unsupported video codec '%s' in %s", c.codec, c.uri())
+	}
+
+	switch {
+	case c.control == "" || c.control == "*":
+		c.control = base
+	case strings.HasPrefix(c.control, "rtsp://"):
+	default:
+		c.control = strings.TrimSuffix(base, "/") + "/" + c.control
+	}
+
+	return nil
+}
+
+// request sends an RTSP request and reads its response, authenticating and
+// retrying once if the server asks for credentials.
+func (c *client) request(method, uri string, header map[string]string) (*response, error) {
+	resp, err := c.send(method, uri, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.code == 401 && c.auth == nil && c.url.User != nil {
+		if err := c.authenticate(resp.header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+		if resp, err = c.send(method, uri, header); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.code != 200 {
+		return nil, fmt.Errorf("%s %s: %d %s", method, uri, resp.code, resp.status)
+	}
+	return resp, nil
+}
+
+func (c *client) send(method, uri string, header map[string]string) (*response, error) {
+	if err := c.write(method, uri, header); err != nil {
+		return nil, err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	return readResponse(c.br)
+}
+
+// write writes a request without waiting for its response.
+func (c *client) write(method, uri string, header map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cseq++
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s RTSP/1.0\r\nCSeq: %d\r\nUser-Agent: mvnc\r\n", method, uri, c.cseq)
+	if c.session != "" {
+		fmt.Fprintf(&b, "Session: %s\r\n", c.session)
+	}
+	if c.auth != nil {
+		fmt.Fprintf(&b, "Authorization: %s\r\n", c.auth(method, uri))
+	}
+	for k, v := range header {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	b.WriteString("\r\n")
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+func readResponse(br *bufio.Reader) (*response, error) {
+	tp := textproto.NewReader(br)
+
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "RTSP/") {
+		return nil, fmt.Errorf("malformed RTSP status line '%s'", line)
+	}
+
+	resp := &response{}
+	if resp.code, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, fmt.Errorf("malformed RTSP status line '%s'", line)
+	}
+	if len(fields) == 3 {
+		resp.status = fields[2]
+	}
+
+	if resp.header, err = tp.ReadMIMEHeader(); err != nil {
+		return nil, err
+	}
+
+	if n, _ := strconv.Atoi(resp.header.Get("Content-Length")); n > 0 {
+		resp.body = make([]byte, n)
+		if _, err := io.ReadFull(tp.R, resp.body); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// authenticate sets up Basic or Digest authentication with the credentials
+// of the url, as challenged by the server.
+func (c *client) authenticate(challenge string) error {
+	user := c.url.User.Username()
+	pass, _ := c.url.User.Password()
+
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	switch scheme {
+	case "basic":
+		token := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+		c.auth = func(string, string) string { return "Basic " + token }
+
+	case "digest":
+		params := map[string]string{}
+		for _, param := range strings.Split(challenge[len(scheme):], ",") {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 {
+				params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+		}
+		realm, nonce := params["realm"], params["nonce"]
+		ha1 := md5hex(user + ":" + realm + ":" + pass)
+		c.auth = func(method, uri string) string {
+			response := md5hex(ha1 + ":" + nonce + ":" + md5hex(method+":"+uri))
+			return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, user, realm, nonce, uri, response)
+		}
+
+	default:
+		return fmt.Errorf("unsupported RTSP authentication '%s'", challenge)
+	}
+
+	return nil
+}
+
+func md5hex(s string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+}
+
+// readPacket returns the next RTP packet of the video stream, skipping RTCP
+// and the responses to keepalives.
+func (c *client) readPacket() ([]byte, error) {
+	var header [4]byte
+
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	for {
+		b, err := c.br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if b[0] != '$' {
+			if _, err := readResponse(c.br); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return nil, err
+		}
+		packet := make([]byte, binary.BigEndian.Uint16(header[2:]))
+		if _, err := io.ReadFull(c.br, packet); err != nil {
+			return nil, err
+		}
+
+		if header[1] == 0 {
+			return packet, nil
+		}
+	}
+}
+
+// keepalive sends an OPTIONS request well within the session timeout until
+// done is closed.
+func (c *client) keepalive(done <-chan struct{}) {
+	t := time.NewTicker(c.expiry / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if err := c.write("OPTIONS", c.uri(), nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// close tears down the session and closes the connection.
+func (c *client) close() error {
+	c.write("TEARDOWN", c.uri(), nil)
+	return c.conn.Close()
+}
@@ -0,0 +1,109 @@
+package rtsp
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// Decoder decodes the access units of an H.264 stream, in Annex B format,
+// into images.  Decode may return a nil image and no error while it needs
+// more data, such as before the first keyframe, and may lag the stream by a
+// few access units.
+type Decoder interface {
+	Decode(au []byte) (image.Image, error)
+}
+
+// FFmpegDecoder is a Decoder that pipes the stream through an ffmpeg
+// process, which must be on the PATH.  Frames are scaled by ffmpeg to a
+// fixed size, and only the latest is kept if they are not consumed quickly
+// enough.
+type FFmpegDecoder struct {
+	width, height int
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu     sync.Mutex
+	latest image.Image
+	err    error
+}
+
+// NewFFmpegDecoder starts ffmpeg decoding to frames of width by height
+// pixels.  These should match the graph's Width and Height, or its input
+// tensor, so that the frames need not be resized again.
+func NewFFmpegDecoder(width, height int) (*FFmpegDecoder, error) {
+	d := &FFmpegDecoder{width: width, height: height}
+
+	d.cmd = exec.Command("ffmpeg", "-loglevel", "error",
+		"-fflags", "nobuffer", "-flags", "low_delay",
+		"-f", "h264", "-i", "pipe:0",
+		"-vf", "scale="+strconv.Itoa(width)+":"+strconv.Itoa(height),
+		"-f", "rawvideo", "-pix_fmt", "rgb24", "pipe:1")
+
+	var err error
+	if d.stdin, err = d.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := d.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+
+	go d.read(stdout)
+
+	return d, nil
+}
+
+// read reads the decoded frames from ffmpeg until it exits.
+func (d *FFmpegDecoder) read(stdout io.Reader) {
+	for {
+		rgb := make([]byte, d.width*d.height*3)
+		if _, err := io.ReadFull(stdout, rgb); err != nil {
+			d.mu.Lock()
+			d.err = fmt.Errorf("ffmpeg exited: %w", err)
+			d.mu.Unlock()
+			return
+		}
+
+		img := image.NewNRGBA(image.Rect(0, 0, d.width, d.height))
+		for i, j := 0, 0; i < len(rgb); i, j = i+3, j+4 {
+			img.Pix[j], img.Pix[j+1], img.Pix[j+2], img.Pix[j+3] = rgb[i], rgb[i+1], rgb[i+2], 0xff
+		}
+
+		d.mu.Lock()
+		d.latest = img
+		d.mu.Unlock()
+	}
+}
+
+// Decode writes au to ffmpeg and returns the latest frame it has decoded
+// since the last call, if any.
+func (d *FFmpegDecoder) Decode(au []byte) (image.Image, error) {
+	d.mu.Lock()
+	img, err := d.latest, d.err
+	d.latest = nil
+	d.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.stdin.Write(au); err != nil {
+		return nil, fmt.Errorf("error writing to ffmpeg: %w", err)
+	}
+
+	return img, nil
+}
+
+// Close stops ffmpeg.
+func (d *FFmpegDecoder) Close() error {
+	d.stdin.Close()
+	return d.cmd.Wait()
+}
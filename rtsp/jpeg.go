@@ -0,0 +1,270 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// jpegAssembler rebuilds the JPEG images of an RTP/JPEG stream, as described
+// by RFC 2435.  The packets carry only the entropy coded scan and a few
+// parameters, so the headers of each frame are reconstructed from the
+// standard tables.
+type jpegAssembler struct {
+	typ, q        byte
+	width, height int
+	restart       uint16
+	quant         []byte
+	data          []byte
+	started       bool
+}
+
+// push adds the payload of one RTP packet to the frame, returning the
+// complete JPEG if marker ends it.
+func (j *jpegAssembler) push(payload []byte, marker bool) ([]byte, error) {
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("RTP/JPEG packet too short: %d bytes", len(payload))
+	}
+
+	offset := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+	typ, q := payload[4], payload[5]
+	width, height := int(payload[6])*8, int(payload[7])*8
+	payload = payload[8:]
+
+	var restart uint16
+	if typ >= 64 && typ < 128 {
+		if len(payload) < 4 {
+			return nil, fmt.Errorf("RTP/JPEG restart header too short")
+		}
+		restart = binary.BigEndian.Uint16(payload)
+		payload = payload[4:]
+		typ -= 64
+	}
+	if typ > 1 {
+		return nil, fmt.Errorf("unsupported RTP/JPEG type %d", typ)
+	}
+
+	if offset == 0 {
+		j.typ, j.q, j.width, j.height, j.restart = typ, q, width, height, restart
+		j.data = j.data[:0]
+		j.started = true
+
+		if q >= 128 {
+			if len(payload) < 4 {
+				return nil, fmt.Errorf("RTP/JPEG quantization header too short")
+			}
+			precision, length := payload[1], int(binary.BigEndian.Uint16(payload[2:]))
+			payload = payload[4:]
+			if precision != 0 {
+				return nil, fmt.Errorf("16 bit RTP/JPEG quantization tables are not supported")
+			} else if length > len(payload) || length%64 != 0 || length == 0 {
+				return nil, fmt.Errorf("invalid RTP/JPEG quantization table length %d", length)
+			}
+			j.quant = append(j.quant[:0], payload[:length]...)
+			payload = payload[length:]
+		} else {
+			j.quant = scaledQuant(j.quant[:0], q)
+		}
+	} else if !j.started || offset != len(j.data) {
+		// a packet was lost, so wait for the start of the next frame
+		j.started = false
+		return nil, nil
+	}
+
+	j.data = append(j.data, payload...)
+
+	if !marker {
+		return nil, nil
+	}
+	j.started = false
+
+	if j.width == 0 || j.height == 0 {
+		return nil, fmt.Errorf("RTP/JPEG frames larger than 2040 pixels are not supported")
+	}
+	return j.image(), nil
+}
+
+// scaledQuant appends the standard quantization tables scaled for a quality
+// factor q between 1 and 99, as in appendix A of RFC 2435.
+func scaledQuant(b []byte, q byte) []byte {
+	factor := int(q)
+	if factor < 1 {
+		factor = 1
+	} else if factor > 99 {
+		factor = 99
+	}
+
+	scale := 200 - factor*2
+	if factor < 50 {
+		scale = 5000 / factor
+	}
+
+	for _, table := range standardQuant {
+		for _, v := range table {
+			s := (int(v)*scale + 50) / 100
+			if s < 1 {
+				s = 1
+			} else if s > 255 {
+				s = 255
+			}
+			b = append(b, byte(s))
+		}
+	}
+	return b
+}
+
+// image returns the frame as a complete JFIF image.
+func (j *jpegAssembler) image() []byte {
+	tables := len(j.quant) / 64
+	b := make([]byte, 0, len(j.data)+1024)
+
+	b = append(b, 0xff, 0xd8)
+
+	b = appendMarker(b, 0xdb, 65*tables)
+	for i := 0; i < tables; i++ {
+		b = append(b, byte(i))
+		b = append(b, j.quant[i*64:(i+1)*64]...)
+	}
+
+	if j.restart != 0 {
+		b = appendMarker(b, 0xdd, 2)
+		b = append(b, byte(j.restart>>8), byte(j.restart))
+	}
+
+	// type 0 is 4:2:2 and type 1 is 4:2:0; the chrominance components share
+	// the second quantization table when there is one
+	sampling := byte(0x21)
+	if j.typ == 1 {
+		sampling = 0x22
+	}
+	chroma := byte(0)
+	if tables > 1 {
+		chroma = 1
+	}
+	b = appendMarker(b, 0xc0, 15)
+	b = append(b, 8, byte(j.height>>8), byte(j.height), byte(j.width>>8), byte(j.width), 3)
+	b = append(b, 1, sampling, 0, 2, 0x11, chroma, 3, 0x11, chroma)
+
+	size := 0
+	for _, h := range standardHuffman {
+		size += 17 + len(h.values)
+	}
+	b = appendMarker(b, 0xc4, size)
+	for _, h := range standardHuffman {
+		b = append(b, h.class)
+		b = append(b, h.counts[:]...)
+		b = append(b, h.values...)
+	}
+
+	b = appendMarker(b, 0xda, 10)
+	b = append(b, 3, 1, 0x00, 2, 0x11, 3, 0x11, 0, 63, 0)
+
+	b = append(b, j.data...)
+	if n := len(b); n < 2 || b[n-2] != 0xff || b[n-1] != 0xd9 {
+		b = append(b, 0xff, 0xd9)
+	}
+
+	return b
+}
+
+// appendMarker appends a marker and the length of a segment with size bytes
+// of parameters.
+func appendMarker(b []byte, marker byte, size int) []byte {
+	size += 2
+	return append(b, 0xff, marker, byte(size>>8), byte(size))
+}
+
+// huffmanTable is a DHT table: its class and destination, the number of
+// codes of each length, and their values.
+type huffmanTable struct {
+	class  byte
+	counts [16]byte
+	values []byte
+}
+
+// the quantization tables of the JPEG standard, in zigzag order, which
+// RFC 2435 scales by Q
+var standardQuant = [2][64]byte{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// the Huffman tables of the JPEG standard, which RFC 2435 assumes every
+// frame is encoded with
+var standardHuffman = []huffmanTable{
+	// luminance DC
+	{
+		class:  0x00,
+		counts: [16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		values: []byte{
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+		},
+	},
+	// luminance AC
+	{
+		class:  0x10,
+		counts: [16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		values: []byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12, 0x21, 0x31, 0x41, 0x06,
+			0x13, 0x51, 0x61, 0x07, 0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0, 0x24, 0x33, 0x62, 0x72,
+			0x82, 0x09, 0x0a, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3a, 0x43, 0x44, 0x45,
+			0x46, 0x47, 0x48, 0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x73, 0x74, 0x75,
+			0x76, 0x77, 0x78, 0x79, 0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3,
+			0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9,
+			0xca, 0xd2, 0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea, 0xf1, 0xf2, 0xf3, 0xf4,
+			0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa,
+		},
+	},
+	// chrominance DC
+	{
+		class:  0x01,
+		counts: [16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		values: []byte{
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+		},
+	},
+	// chrominance AC
+	{
+		class:  0x11,
+		counts: [16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		values: []byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21, 0x31, 0x06, 0x12, 0x41,
+			0x51, 0x07, 0x61, 0x71, 0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0, 0x15, 0x62, 0x72, 0xd1,
+			0x0a, 0x16, 0x24, 0x34, 0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3a, 0x43, 0x44,
+			0x45, 0x46, 0x47, 0x48, 0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x73, 0x74,
+			0x75, 0x76, 0x77, 0x78, 0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9a,
+			0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7,
+			0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea, 0xf2, 0xf3, 0xf4,
+			0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa,
+		},
+	},
+}
@@ -0,0 +1,122 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// rtpPacket is the part of an RTP packet the depacketizers need.
+type rtpPacket struct {
+	marker   bool
+	sequence uint16
+	payload  []byte
+}
+
+// parseRTP parses the header of an RTP packet, skipping any contributing
+// sources, header extension and padding.
+func parseRTP(b []byte) (rtpPacket, error) {
+	var p rtpPacket
+
+	if len(b) < 12 {
+		return p, fmt.Errorf("RTP packet too short: %d bytes", len(b))
+	} else if b[0]>>6 != 2 {
+		return p, fmt.Errorf("unsupported RTP version %d", b[0]>>6)
+	}
+
+	p.marker = b[1]&0x80 != 0
+	p.sequence = binary.BigEndian.Uint16(b[2:])
+
+	start := 12 + 4*int(b[0]&0x0f)
+	if b[0]&0x10 != 0 {
+		if len(b) < start+4 {
+			return p, fmt.Errorf("RTP header extension too short")
+		}
+		start += 4 + 4*int(binary.BigEndian.Uint16(b[start+2:]))
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 && end > 0 {
+		end -= int(b[end-1])
+	}
+	if start > end {
+		return p, fmt.Errorf("RTP packet too short for its header")
+	}
+
+	p.payload = b[start:end]
+	return p, nil
+}
+
+// h264Assembler rebuilds the access units of an RTP/H.264 stream, as
+// described by RFC 6184, as Annex B byte streams.  Single NAL unit, STAP-A
+// and FU-A packets are supported, which covers the non-interleaved mode
+// used by IP cameras.
+type h264Assembler struct {
+	au   []byte
+	lost bool
+	// whether a fragmented NAL unit is being reassembled
+	fragment bool
+}
+
+var startCode = []byte{0, 0, 0, 1}
+
+// push adds the payload of one RTP packet to the access unit, returning it
+// if marker ends it.  lost reports a gap in the sequence numbers, after which
+// the access unit is dropped.
+func (h *h264Assembler) push(payload []byte, marker, lost bool) ([]byte, error) {
+	if lost {
+		h.lost = true
+	}
+	if len(payload) < 1 {
+		return nil, fmt.Errorf("empty RTP/H.264 packet")
+	}
+
+	switch typ := payload[0] & 0x1f; {
+	case typ >= 1 && typ <= 23:
+		h.au = append(h.au, startCode...)
+		h.au = append(h.au, payload...)
+
+	case typ == 24:
+		for b := payload[1:]; len(b) > 0; {
+			if len(b) < 2 {
+				return nil, fmt.Errorf("truncated STAP-A packet")
+			}
+			n := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+n {
+				return nil, fmt.Errorf("truncated STAP-A packet")
+			}
+			h.au = append(h.au, startCode...)
+			h.au = append(h.au, b[2:2+n]...)
+			b = b[2+n:]
+		}
+
+	case typ == 28:
+		if len(payload) < 2 {
+			return nil, fmt.Errorf("truncated FU-A packet")
+		}
+		fu := payload[1]
+		if fu&0x80 != 0 {
+			h.au = append(h.au, startCode...)
+			h.au = append(h.au, payload[0]&0xe0|fu&0x1f)
+			h.fragment = true
+		} else if !h.fragment {
+			h.lost = true
+		}
+		h.au = append(h.au, payload[2:]...)
+		if fu&0x40 != 0 {
+			h.fragment = false
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported RTP/H.264 packet type %d", typ)
+	}
+
+	if !marker {
+		return nil, nil
+	}
+
+	au, dropped := h.au, h.lost
+	h.au, h.lost, h.fragment = nil, false, false
+	if dropped {
+		return nil, nil
+	}
+	return au, nil
+}
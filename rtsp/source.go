@@ -0,0 +1,156 @@
+package rtsp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"sync"
+	"time"
+
+	"github.com/donniet/mvnc"
+)
+
+// Timeout bounds each request to the camera and the wait for each packet
+// of its stream.
+var Timeout = 10 * time.Second
+
+// Source is an mvnc.ImageSource reading the video of an RTSP stream.
+type Source struct {
+	// URL is the stream, as given to Dial but without its credentials.
+	URL string
+
+	decoder   Decoder
+	client    *client
+	done      chan struct{}
+	closeOnce sync.Once
+
+	jpeg     jpegAssembler
+	h264     h264Assembler
+	sequence uint16
+	started  bool
+}
+
+// Dial opens the RTSP stream at url, which may include credentials for
+// Basic or Digest authentication, and starts playing its first video
+// stream over TCP.  Motion JPEG streams are decoded natively; H.264 streams
+// are passed to dec, which may be nil for JPEG streams only.
+func Dial(url string, dec Decoder) (*Source, error) {
+	c, err := dial(url, Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error opening RTSP stream: %w", err)
+	}
+
+	if c.codec == "H264" && dec == nil {
+		c.close()
+		return nil, fmt.Errorf("%s is an H.264 stream, which needs a Decoder", c.uri())
+	}
+
+	s := &Source{URL: c.uri(), decoder: dec, client: c, done: make(chan struct{})}
+	go c.keepalive(s.done)
+
+	return s, nil
+}
+
+// Codec returns the codec of the stream, "JPEG" or "H264".
+func (s *Source) Codec() string {
+	return s.client.codec
+}
+
+// Next returns the next frame of the stream.
+func (s *Source) Next() (image.Image, error) {
+	for {
+		b, err := s.client.readPacket()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", s.URL, err)
+		}
+
+		p, err := parseRTP(b)
+		if err != nil {
+			return nil, err
+		}
+
+		lost := s.started && p.sequence != s.sequence+1
+		s.sequence, s.started = p.sequence, true
+
+		switch s.client.codec {
+		case "JPEG":
+			b, err := s.jpeg.push(p.payload, p.marker)
+			if err != nil {
+				return nil, err
+			} else if b == nil {
+				continue
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(b))
+			if err != nil {
+				return nil, fmt.Errorf("error decoding frame of %s: %w", s.URL, err)
+			}
+			return img, nil
+
+		case "H264":
+			au, err := s.h264.push(p.payload, p.marker, lost)
+			if err != nil {
+				return nil, err
+			} else if au == nil {
+				continue
+			}
+
+			img, err := s.decoder.Decode(au)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding frame of %s: %w", s.URL, err)
+			} else if img != nil {
+				return img, nil
+			}
+		}
+	}
+}
+
+// Frames returns the frames of the stream as an mvnc.FrameSource.
+func (s *Source) Frames() mvnc.FrameSource {
+	return mvnc.Images(s)
+}
+
+// Close tears down the RTSP session.  The decoder is not closed.  Calls
+// after the first do nothing.
+func (s *Source) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.client.close()
+	})
+	return err
+}
+
+// Detection is a name detected in a frame of a stream.
+type Detection struct {
+	URL  string
+	Name string
+}
+
+// Process runs the frames of src through graph, as mvnc.Graph.Process does,
+// and returns the names detected tagged with the URL of the stream.  The
+// frames are resized to the graph's Width and Height, or to its input
+// tensor if they are not set.
+func Process(graph *mvnc.Graph, src *Source) (<-chan Detection, error) {
+	width, height := graph.Width, graph.Height
+	if width == 0 || height == 0 {
+		desc, err := graph.InputDescriptor()
+		if err != nil {
+			return nil, err
+		}
+		width, height = desc.W, desc.H
+	}
+
+	names := graph.Process(mvnc.NewImageReader(src.Frames(), width, height))
+
+	r := make(chan Detection)
+	go func() {
+		defer close(r)
+		for name := range names {
+			r <- Detection{URL: src.URL, Name: name}
+		}
+	}()
+
+	return r, nil
+}
@@ -0,0 +1,38 @@
+package rtsp
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceCloseTwice(t *testing.T) {
+	conn, camera := net.Pipe()
+	u, _ := url.Parse("rtsp://camera/stream")
+	s := &Source{client: &client{url: u, conn: conn, br: bufio.NewReader(conn), timeout: time.Second}, done: make(chan struct{})}
+
+	teardowns := make(chan int)
+	go func() {
+		n := 0
+		sc := bufio.NewScanner(camera)
+		for sc.Scan() {
+			if strings.HasPrefix(sc.Text(), "TEARDOWN ") {
+				n++
+			}
+		}
+		teardowns <- n
+	}()
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("the second Close returned %v", err)
+	}
+	if n := <-teardowns; n != 1 {
+		t.Errorf("sent %d TEARDOWN requests, want 1", n)
+	}
+}
@@ -0,0 +1,156 @@
+package mvnc
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Sink is a consumer of the Results of a graph, such as a JSONLWriter.
+type Sink interface {
+	Write(r Result) error
+}
+
+// SinkFunc is a Sink calling a function, such as the PublishResult method of
+// an mqtt.Publisher.
+type SinkFunc func(r Result) error
+
+func (fn SinkFunc) Write(r Result) error {
+	return fn(r)
+}
+
+// ChannelSink returns a Sink sending every Result on ch.
+func ChannelSink(ch chan<- Result) Sink {
+	return SinkFunc(func(r Result) error {
+		ch <- r
+		return nil
+	})
+}
+
+// SinkOptions describe how the Results waiting for a Sink are buffered.
+type SinkOptions struct {
+	// Buffer is the number of Results held for the sink while it is busy.
+	// It defaults to 64.
+	Buffer int
+
+	// Backpressure selects which Result is dropped when the buffer is full:
+	// DropLatest drops the Result of the frame just run, and DropOldest the
+	// longest waiting.  Block instead holds up the graph until the sink
+	// catches up, as a handler registered with OnFrame does.
+	Backpressure Backpressure
+}
+
+// BufferedSink is a Sink attached to a graph by AddSink, which it writes to
+// from a goroutine of its own.
+type BufferedSink struct {
+	graph   *Graph
+	sink    Sink
+	opts    SinkOptions
+	results chan Result
+	logf    func(format string, v ...interface{})
+
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	dropped uint64
+}
+
+// AddSink attaches s to the Results of every frame run by Process, a Pool or
+// a Multiplexer.  Unlike a handler registered with OnFrame, s is written to
+// from its own goroutine, with a buffer of its own, so that a slow sink such
+// as a file on a busy disk or a distant MQTT broker only loses Results, as
+// described by opts, rather than stalling the graph and the other sinks.
+// Errors writing to s are logged.  s is sent each Result after the OnFrame
+// handlers.  Close the returned BufferedSink to detach it.
+func (f *Graph) AddSink(s Sink, opts SinkOptions) *BufferedSink {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+
+	b := &BufferedSink{
+		graph:   f,
+		sink:    s,
+		opts:    opts,
+		results: make(chan Result, opts.Buffer),
+		logf:    f.logf,
+		done:    make(chan struct{}),
+	}
+	go b.run()
+
+	f.hooks.mu.Lock()
+	f.hooks.sinks = append(f.hooks.sinks, b)
+	f.hooks.mu.Unlock()
+	return b
+}
+
+func (b *BufferedSink) run() {
+	defer close(b.done)
+
+	for r := range b.results {
+		if err := b.sink.Write(r); err != nil {
+			b.logf("error writing to sink: %v", err)
+		}
+	}
+}
+
+// enqueue buffers r for the sink, dropping a Result if the buffer is full.
+func (b *BufferedSink) enqueue(r Result) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+
+	if b.opts.Backpressure == Block {
+		b.results <- r
+		return
+	}
+
+	for {
+		select {
+		case b.results <- r:
+			return
+		default:
+		}
+
+		if b.opts.Backpressure == DropLatest {
+			atomic.AddUint64(&b.dropped, 1)
+			return
+		}
+
+		// DropOldest makes room, unless the sink just did
+		select {
+		case <-b.results:
+			atomic.AddUint64(&b.dropped, 1)
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of Results dropped because the buffer was
+// full.
+func (b *BufferedSink) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Close detaches the sink from the graph, waiting for the Results already
+// buffered to be written, then closes the sink if it is an io.Closer, such
+// as a JSONLWriter of a file.  Closing it again does nothing.
+func (b *BufferedSink) Close() error {
+	b.graph.hooks.detach(b)
+
+	b.mu.Lock()
+	closed := b.closed
+	if !closed {
+		b.closed = true
+		close(b.results)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+	if c, ok := b.sink.(io.Closer); ok && !closed {
+		return c.Close()
+	}
+	return nil
+}
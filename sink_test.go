@@ -0,0 +1,79 @@
+package mvnc
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// closingSink is a Sink recording the Results written to it, whose Close
+// returns err.
+type closingSink struct {
+	results []Result
+	closed  int
+	err     error
+}
+
+func (s *closingSink) Write(r Result) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+func (s *closingSink) Close() error {
+	s.closed++
+	return s.err
+}
+
+// TestBufferedSinkClose checks that closing a BufferedSink detaches it from
+// the graph and closes the sink it wraps, once.
+func TestBufferedSinkClose(t *testing.T) {
+	f := &Graph{Logger: testLogger}
+	s := &closingSink{err: errors.New("close failed")}
+
+	for i := 0; i < 3; i++ {
+		f.AddSink(s, SinkOptions{Backpressure: Block}).Close()
+	}
+	b := f.AddSink(s, SinkOptions{Backpressure: Block})
+	if n := len(f.hooks.attached()); n != 1 {
+		t.Fatalf("%d sinks attached, want 1", n)
+	}
+
+	b.enqueue(Result{FrameID: 1})
+	if err := b.Close(); err != s.err {
+		t.Errorf("Close returned %v, want %v", err, s.err)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("closing again returned %v", err)
+	}
+
+	if n := len(f.hooks.attached()); n != 0 {
+		t.Errorf("%d sinks attached after Close, want 0", n)
+	}
+	if s.closed != 4 {
+		t.Errorf("sink closed %d times, want 4", s.closed)
+	}
+	if len(s.results) != 1 || s.results[0].FrameID != 1 {
+		t.Errorf("sink was written %v, want the result of frame 1", s.results)
+	}
+}
+
+// TestShutdownSinks checks that Shutdown closes the sinks NewGraph
+// attached.
+func TestShutdownSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	f, err := (&Config{Graph: "test.graph", Sinks: SinkConfig{JSONL: path}}).NewGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.hooks.attached()); n != 1 {
+		t.Fatalf("%d sinks attached, want 1", n)
+	}
+
+	if err := f.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.hooks.attached()); n != 0 {
+		t.Errorf("%d sinks attached after Shutdown, want 0", n)
+	}
+}
@@ -0,0 +1,145 @@
+package mvnc
+
+import (
+	"sync"
+)
+
+// Smoothing debounces the detections sent by Process across frames, so that
+// a class flickering in and out of single frames is not reported.
+type Smoothing struct {
+	// Hits and Window require a name to have been detected in at least Hits
+	// of the last Window frames before it is sent.  Window defaults to Hits.
+	Hits   int `json:"hits,omitempty"`
+	Window int `json:"window,omitempty"`
+
+	// Alpha, if non-zero, instead smooths the confidence of each name with an
+	// exponential moving average, giving the newest frame a weight of Alpha
+	// and counting frames the name was not detected in as zero.  The name is
+	// sent while its average is above Level.
+	Alpha float32 `json:"alpha,omitempty"`
+	Level float32 `json:"level,omitempty"`
+
+	mu     sync.Mutex
+	frames [][]string // the names detected in the last Window frames
+	next   int
+	counts map[string]int
+	ema    map[string]float32
+}
+
+// detection is a name detected in a single frame and its confidence.
+type detection struct {
+	name       string
+	confidence float32
+}
+
+// filter records the detections of a frame and returns those to send,
+// reusing dets.
+func (s *Smoothing) filter(dets []detection) []detection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Alpha != 0 {
+		return s.average(dets)
+	}
+	return s.window(dets)
+}
+
+func (s *Smoothing) average(dets []detection) []detection {
+	if s.ema == nil {
+		s.ema = make(map[string]float32)
+	}
+
+	for name, v := range s.ema {
+		s.ema[name] = v * (1 - s.Alpha)
+	}
+	for i, d := range dets {
+		if !seenBefore(dets[:i], d.name) {
+			s.ema[d.name] += best(dets[i:], d.name) * s.Alpha
+		}
+	}
+
+	out := dets[:0]
+	for _, d := range dets {
+		if v, ok := s.ema[d.name]; ok && v > s.Level {
+			out = append(out, detection{name: d.name, confidence: v})
+		}
+	}
+
+	// forget names whose average has decayed away
+	for name, v := range s.ema {
+		if v <= s.Level*1e-3 {
+			delete(s.ema, name)
+		}
+	}
+
+	return out
+}
+
+func (s *Smoothing) window(dets []detection) []detection {
+	window := s.Window
+	if window < s.Hits {
+		window = s.Hits
+	}
+	if window < 1 {
+		window = 1
+	}
+
+	if len(s.frames) != window {
+		s.frames, s.next = make([][]string, window), 0
+		s.counts = make(map[string]int)
+	}
+
+	for _, name := range s.frames[s.next] {
+		if s.counts[name]--; s.counts[name] == 0 {
+			delete(s.counts, name)
+		}
+	}
+
+	names := s.frames[s.next][:0]
+	for _, d := range dets {
+		if !contains(names, d.name) {
+			names = append(names, d.name)
+			s.counts[d.name]++
+		}
+	}
+	s.frames[s.next] = names
+	s.next = (s.next + 1) % window
+
+	out := dets[:0]
+	for _, d := range dets {
+		if s.counts[d.name] >= s.Hits {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func seenBefore(dets []detection, name string) bool {
+	for _, d := range dets {
+		if d.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// best returns the highest confidence of name in dets.
+func best(dets []detection, name string) float32 {
+	var c float32
+	for _, d := range dets {
+		if d.name == name {
+			c = max32(c, d.confidence)
+		}
+	}
+	return c
+}
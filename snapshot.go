@@ -0,0 +1,234 @@
+package mvnc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultSnapshotTemplate names the snapshots of a Snapshotter by default,
+// by the time, frame and name of the detection, and its index among the
+// boxes of the frame.
+const DefaultSnapshotTemplate = `{{.Time.Format "20060102-150405.000"}}-{{.FrameID}}-{{.Name}}-{{.Index}}.jpg`
+
+// Snapshot is a frame, or a box cropped from it, taken by a Snapshotter
+// for a detection.  Box is the box it was cropped to, and Path the file it
+// was saved to, if any.
+type Snapshot struct {
+	FrameID    uint64
+	Time       time.Time
+	Name       string
+	Confidence float32
+	Index      int
+	Box        *BoundingBox
+	Image      image.Image
+	Path       string
+}
+
+// Snapshotter takes a snapshot of the full resolution frame whenever
+// something is detected in a frame read by Process, a Pool or a
+// Multiplexer, after Smoothing.  Set Graph.Snapshots to use one.  The
+// snapshots are taken from the goroutine completing each inference, like
+// the OnFrame handlers, so Interval should keep them to a rate the disk
+// can keep up with.  It is safe for concurrent use.
+type Snapshotter struct {
+	// Dir, if set, is the directory the snapshots are saved in as JPEGs,
+	// named by Template, a text/template executed with the Snapshot, or
+	// DefaultSnapshotTemplate.  Directories in the names are created.
+	Dir      string
+	Template string
+	Quality  int
+
+	// Images, if non-nil, receives every snapshot.
+	Images chan<- Snapshot
+
+	// Crop takes a snapshot of each box found by an SSD or YOLO graph,
+	// cropped from the frame, rather than one of the whole frame.
+	Crop bool
+
+	// Names, if non-empty, limits the snapshots to detections of the names
+	// it lists.
+	Names []string
+
+	// Interval is the shortest time between snapshots of detections of the
+	// same name.  Detections sooner than that are not snapshotted.
+	Interval time.Duration
+
+	mu   sync.Mutex
+	tmpl *template.Template
+	last map[string]time.Time
+	err  error
+}
+
+// NewSnapshotter returns a Snapshotter saving to dir, with its file names
+// parsed from tmpl, or DefaultSnapshotTemplate if tmpl is empty.
+func NewSnapshotter(dir, tmpl string) (*Snapshotter, error) {
+	s := &Snapshotter{Dir: dir, Template: tmpl}
+	if _, err := s.template(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// template returns the parsed Template.
+func (s *Snapshotter) template() (*template.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tmpl == nil && s.err == nil {
+		text := s.Template
+		if text == "" {
+			text = DefaultSnapshotTemplate
+		}
+		if s.tmpl, s.err = template.New("snapshot").Parse(text); s.err != nil {
+			s.err = fmt.Errorf("error parsing snapshot template: %w", s.err)
+		}
+	}
+	return s.tmpl, s.err
+}
+
+// due reports whether a snapshot of name may be taken at now, and records
+// that it has been if so.
+func (s *Snapshotter) due(name string, now time.Time) bool {
+	if len(s.Names) > 0 && !contains(s.Names, name) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.last[name]; ok && s.Interval > 0 && now.Sub(last) < s.Interval {
+		return false
+	}
+	if s.last == nil {
+		s.last = make(map[string]time.Time)
+	}
+	s.last[name] = now
+	return true
+}
+
+// take takes the snapshots of the detections of a frame.  img may be
+// reused once it returns, so the snapshots are copies.
+func (s *Snapshotter) take(f *Graph, r *request, boxes []BoundingBox, dets []detection) {
+	now := time.Now()
+
+	if s.Crop && len(boxes) > 0 {
+		for i := range boxes {
+			b := boxes[i]
+			if b.Name == "" || !s.due(b.Name, now) {
+				continue
+			}
+			rect := b.Rect(r.img.Bounds()).Intersect(r.img.Bounds())
+			if rect.Empty() {
+				continue
+			}
+			s.save(f, Snapshot{FrameID: r.id, Time: r.readAt, Name: b.Name, Confidence: b.Confidence, Index: i, Box: &b, Image: crop(r.img, rect)})
+		}
+		return
+	}
+
+	// a snapshot of the whole frame, named by its best detection due
+	best := -1
+	for i, d := range dets {
+		if s.due(d.name, now) && (best < 0 || d.confidence > dets[best].confidence) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		s.save(f, Snapshot{FrameID: r.id, Time: r.readAt, Name: dets[best].name, Confidence: dets[best].confidence, Image: crop(r.img, r.img.Bounds())})
+	}
+}
+
+// save saves snap to Dir and sends it to Images, logging any error.
+func (s *Snapshotter) save(f *Graph, snap Snapshot) {
+	if s.Dir != "" {
+		if err := s.write(&snap); err != nil {
+			f.logf("error saving snapshot of frame %d: %v", snap.FrameID, err)
+		}
+	}
+
+	// held while sending, so that detach waits for the send
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Images != nil {
+		s.Images <- snap
+	}
+}
+
+// detach stops sending snapshots to ch, if it is Images, once any being
+// sent has been, so that ch can be closed.
+func (s *Snapshotter) detach(ch chan<- Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Images == ch {
+		s.Images = nil
+	}
+}
+
+// write saves snap as a JPEG, setting its Path.
+func (s *Snapshotter) write(snap *Snapshot) error {
+	tmpl, err := s.template()
+	if err != nil {
+		return err
+	}
+
+	named := *snap
+	named.Name = safeName(named.Name)
+	path, err := templatePath(s.Dir, tmpl, named)
+	if err != nil {
+		return err
+	}
+
+	quality := s.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, snap.Image, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	snap.Path = path
+	return nil
+}
+
+// templatePath returns the path in dir named by executing tmpl with data,
+// creating the directories it names.
+func templatePath(dir string, tmpl *template.Template, data interface{}) (string, error) {
+	var name bytes.Buffer
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name.String())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// safeName returns the name of a class, for a file name, with the path
+// separators it may hold replaced so that it cannot leave its directory.
+func safeName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+}
+
+// crop returns a copy of the pixels of img within r.
+func crop(img image.Image, r image.Rectangle) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(out, out.Bounds(), img, r.Min, draw.Src)
+	return out
+}
@@ -0,0 +1,120 @@
+package mvnc
+
+import (
+	"math"
+)
+
+// OutputFormat describes how a graph's output tensor is interpreted by
+// Process.
+type OutputFormat int
+
+// Output formats: Classification treats each output as the score of the
+// class with that index in Names, SSD decodes the detection records
+// produced by SSD graphs such as MobileNet-SSD, YOLO decodes the grid
+// output of YOLO graphs as described by Graph.YOLO, and Embedding treats the
+// output as a vector to match against Graph.Gallery, as produced by
+// FaceNet-style graphs.  Segmentation and Heatmap reshape the output into
+// a map of the frame, as described by Graph.Map: Segmentation takes the
+// class scoring highest at each pixel, and Heatmap colors a single value
+// per pixel, such as the depth.  Keypoints decodes the landmarks of faces
+// or the joints of poses as described by Graph.Keypoints, and Attributes
+// the heads of attribute networks, such as age and gender, as described by
+// Graph.Attributes.
+const (
+	Classification OutputFormat = iota
+	SSD
+	YOLO
+	Embedding
+	Segmentation
+	Heatmap
+	Keypoints
+	Attributes
+)
+
+func (o OutputFormat) String() string {
+	switch o {
+	case Classification:
+		return "Classification"
+	case SSD:
+		return "SSD"
+	case YOLO:
+		return "YOLO"
+	case Embedding:
+		return "Embedding"
+	case Segmentation:
+		return "Segmentation"
+	case Heatmap:
+		return "Heatmap"
+	case Keypoints:
+		return "Keypoints"
+	case Attributes:
+		return "Attributes"
+	default:
+		return "unknown"
+	}
+}
+
+// ssdRecord is the number of values in each SSD detection record: image id,
+// class, confidence and the xmin, ymin, xmax, ymax corners.
+const ssdRecord = 7
+
+// DecodeSSD decodes the output of an SSD graph into the boxes with a
+// confidence above threshold, named from names.  The first value of the
+// output is the number of detections, and the records follow from the
+// seventh value on.  Records with non-finite values, as produced for unused
+// detections, are skipped, and a negative image id ends the list.
+func DecodeSSD(output []float32, threshold float32, names map[int]string) []BoundingBox {
+	if len(output) < ssdRecord {
+		return nil
+	}
+
+	n := len(output)/ssdRecord - 1
+	if count := output[0]; finite(count) && int(count) < n {
+		n = int(count)
+	}
+
+	var boxes []BoundingBox
+	for i := 0; i < n; i++ {
+		rec := output[(i+1)*ssdRecord : (i+2)*ssdRecord]
+
+		if !finite(rec...) {
+			continue
+		} else if rec[0] < 0 {
+			break
+		} else if rec[2] <= threshold {
+			continue
+		}
+
+		class := int(rec[1])
+		boxes = append(boxes, BoundingBox{
+			Class:      class,
+			Name:       names[class],
+			Confidence: rec[2],
+			XMin:       unit(rec[3]),
+			YMin:       unit(rec[4]),
+			XMax:       unit(rec[5]),
+			YMax:       unit(rec[6]),
+		})
+	}
+
+	return boxes
+}
+
+func finite(v ...float32) bool {
+	for _, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// unit clamps v to [0, 1].
+func unit(v float32) float32 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,213 @@
+package mvnc
+
+import (
+	"sync"
+	"time"
+)
+
+// Timing is how long a single inference took.
+type Timing struct {
+	// Latency is the time from writing the input to the fifo to reading the
+	// output, including any time spent queued behind other inferences.
+	Latency time.Duration
+
+	// Layers is the time the stick spent in each layer of the graph, as
+	// reported by NC_RO_GRAPH_TIME_TAKEN, and Device is their total.  They
+	// are only set if the graph's Profile field is set.
+	Layers []time.Duration
+	Device time.Duration
+}
+
+// Stats aggregates the timings of a graph's inferences.
+type Stats struct {
+	Inferences int
+
+	MinLatency, AvgLatency, MaxLatency time.Duration
+
+	// AvgDevice is the average time spent on the stick, if the graph's
+	// Profile field is set.
+	AvgDevice time.Duration
+
+	// FPS is the rate of inferences between the first and the last.
+	FPS float64
+
+	// Frames is the number of frames read by Process, a Pool or a
+	// Multiplexer, and Dropped the number of those not run, because they
+	// were throttled or the pipeline was full.  FrameRate is the effective
+	// rate of the frames run, from the first frame read to the last.
+	Frames, Dropped int
+	FrameRate       float64
+
+	// Latencies is a histogram of the inferences' latencies: Latencies[i]
+	// counts those above LatencyBuckets[i-1] and at most LatencyBuckets[i],
+	// and its last element those above every bucket.  TotalLatency is the
+	// sum of every latency.
+	Latencies    []int
+	TotalLatency time.Duration
+
+	// Errors counts the calls to the NCAPI for the graph and its fifos that
+	// failed, by status.
+	Errors map[Status]int
+
+	// The state of the pipeline when Stats was called, zero while the graph
+	// is not open: Depth is the number of inferences that may be in flight
+	// at once, the depth of the input fifo, InFlight the number written to
+	// the stick and not yet read, and Waiting the number waiting for one of
+	// them to finish.  InputFill and OutputFill are the fill levels of the
+	// fifos, as from FillLevels.  A pipeline whose inferences are all in
+	// flight, with more waiting, is bound by the stick, and one with
+	// inferences to spare by the host.
+	Depth, InFlight, Waiting int
+	InputFill, OutputFill    int
+}
+
+// LatencyBuckets are the upper bounds of the buckets of Stats.Latencies.
+// They must not be changed once a graph has run an inference.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+type stats struct {
+	mu          sync.Mutex
+	count       int
+	min, max    time.Duration
+	sum, device time.Duration
+	first, last time.Time
+
+	frames, dropped       int
+	firstFrame, lastFrame time.Time
+
+	buckets []int
+	errors  map[Status]int
+}
+
+func (s *stats) record(t Timing) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.count == 0 {
+		s.first = now
+		s.min, s.max = t.Latency, t.Latency
+	}
+	if t.Latency < s.min {
+		s.min = t.Latency
+	}
+	if t.Latency > s.max {
+		s.max = t.Latency
+	}
+
+	if s.buckets == nil {
+		s.buckets = make([]int, len(LatencyBuckets)+1)
+	}
+	i := 0
+	for i < len(LatencyBuckets) && t.Latency > LatencyBuckets[i] {
+		i++
+	}
+	s.buckets[i]++
+
+	s.count++
+	s.sum += t.Latency
+	s.device += t.Device
+	s.last = now
+}
+
+// read records a frame read from the source, returning its sequence
+// number.
+func (s *stats) read() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.frames == 0 {
+		s.firstFrame = now
+	}
+	s.frames++
+	s.lastFrame = now
+	return uint64(s.frames)
+}
+
+// drop records that the last frame read was not run.
+func (s *stats) drop() {
+	s.mu.Lock()
+	s.dropped++
+	s.mu.Unlock()
+}
+
+// fail records a failed call to the NCAPI.
+func (s *stats) fail(status Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.errors == nil {
+		s.errors = make(map[Status]int)
+	}
+	s.errors[status]++
+}
+
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := Stats{Inferences: s.count, MinLatency: s.min, MaxLatency: s.max, Frames: s.frames, Dropped: s.dropped, TotalLatency: s.sum}
+	st.Latencies = make([]int, len(LatencyBuckets)+1)
+	copy(st.Latencies, s.buckets)
+	if len(s.errors) > 0 {
+		st.Errors = make(map[Status]int, len(s.errors))
+		for status, n := range s.errors {
+			st.Errors[status] = n
+		}
+	}
+	if elapsed := s.lastFrame.Sub(s.firstFrame); elapsed > 0 && s.frames-s.dropped > 1 {
+		st.FrameRate = float64(s.frames-s.dropped-1) / elapsed.Seconds()
+	}
+	if s.count == 0 {
+		return st
+	}
+
+	st.AvgLatency = s.sum / time.Duration(s.count)
+	st.AvgDevice = s.device / time.Duration(s.count)
+	if elapsed := s.last.Sub(s.first); elapsed > 0 {
+		st.FPS = float64(s.count-1) / elapsed.Seconds()
+	}
+
+	return st
+}
+
+// Stats returns the aggregate timings of every inference the graph has run,
+// including through Infer, InferImage and a Pool, since it was created, and
+// the state of its pipeline.
+func (f *Graph) Stats() Stats {
+	st := f.stats.snapshot()
+	f.pipelineStats(&st)
+	return st
+}
+
+// pipelineStats sets the pipeline state of st, unless the graph is not open.
+// It does not wait for a graph being opened or closed, leaving it zero.
+func (f *Graph) pipelineStats(st *Stats) {
+	f.init()
+	select {
+	case f.sem <- struct{}{}:
+	default:
+		return
+	}
+	defer f.release()
+
+	if f.alloc == nil {
+		return
+	}
+	st.Depth = f.alloc.slots.size
+	st.InFlight, st.Waiting = f.alloc.slots.depth()
+	st.InputFill, _ = f.alloc.inputFillLevel()
+	st.OutputFill, _ = f.alloc.outputFillLevel()
+}
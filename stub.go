@@ -0,0 +1,64 @@
+//go:build mvnc_stub
+// +build mvnc_stub
+
+package mvnc
+
+import "unsafe"
+
+// api is the NCAPI used by the package.  Built with the mvnc_stub tag, the
+// package needs neither libmvnc nor cgo, so projects using it can be built
+// and tested on machines without the NCSDK installed: every call returns
+// ErrNoBackend, and UseFake provides simulated sticks.
+var api deviceAPI = stubAPI{}
+
+// stubAPI is the deviceAPI of a build without libmvnc.
+type stubAPI struct{}
+
+func (stubAPI) apiVersion() (Version, Status)           { return Version{}, ErrNoBackend }
+func (stubAPI) logLevel() (LogLevel, Status)            { return 0, ErrNoBackend }
+func (stubAPI) setLogLevel(level LogLevel) Status       { return ErrNoBackend }
+func (stubAPI) deviceCreate(int) (deviceHandle, Status) { return nil, ErrNoBackend }
+func (stubAPI) deviceOpen(deviceHandle) Status          { return ErrNoBackend }
+func (stubAPI) deviceClose(deviceHandle) Status         { return ErrNoBackend }
+func (stubAPI) deviceDestroy(deviceHandle) Status       { return ErrNoBackend }
+
+func (stubAPI) deviceName(deviceHandle) (string, Status)             { return "", ErrNoBackend }
+func (stubAPI) deviceFirmwareVersion(deviceHandle) (Version, Status) { return Version{}, ErrNoBackend }
+func (stubAPI) deviceThermalStats(deviceHandle) ([]float32, Status)  { return nil, ErrNoBackend }
+func (stubAPI) deviceIntOption(deviceHandle, deviceOption) (int, Status) {
+	return 0, ErrNoBackend
+}
+func (stubAPI) deviceDebugInfo(deviceHandle) (string, Status) { return "", ErrNoBackend }
+
+func (stubAPI) graphCreate(string) (graphHandle, Status)               { return nil, ErrNoBackend }
+func (stubAPI) graphAllocate(deviceHandle, graphHandle, []byte) Status { return ErrNoBackend }
+func (stubAPI) graphAllocateWithFifos(deviceHandle, graphHandle, []byte, FifoConfig, FifoConfig) (fifoHandle, fifoHandle, Status) {
+	return nil, nil, ErrNoBackend
+}
+func (stubAPI) graphDestroy(graphHandle) Status { return ErrNoBackend }
+func (stubAPI) graphSetIntOption(graphHandle, graphOption, int) Status {
+	return ErrNoBackend
+}
+func (stubAPI) graphTensorDescriptors(graphHandle, bool) ([]TensorDescriptor, Status) {
+	return nil, ErrNoBackend
+}
+func (stubAPI) graphTimeTaken(graphHandle) ([]float32, Status) { return nil, ErrNoBackend }
+func (stubAPI) graphDebugInfo(graphHandle) (string, Status)    { return "", ErrNoBackend }
+func (stubAPI) queueInference(graphHandle, []fifoHandle, []fifoHandle) Status {
+	return ErrNoBackend
+}
+
+func (stubAPI) fifoCreate(string, bool) (fifoHandle, Status) { return nil, ErrNoBackend }
+func (stubAPI) fifoAllocate(fifoHandle, deviceHandle, TensorDescriptor, FifoConfig) Status {
+	return ErrNoBackend
+}
+func (stubAPI) fifoElementSize(fifoHandle) (int, Status)    { return 0, ErrNoBackend }
+func (stubAPI) fifoWriteFillLevel(fifoHandle) (int, Status) { return 0, ErrNoBackend }
+func (stubAPI) fifoReadFillLevel(fifoHandle) (int, Status)  { return 0, ErrNoBackend }
+func (stubAPI) fifoWrite(fifoHandle, unsafe.Pointer, int, uint64) Status {
+	return ErrNoBackend
+}
+func (stubAPI) fifoRead(fifoHandle, unsafe.Pointer, int) (uint64, Status) {
+	return 0, ErrNoBackend
+}
+func (stubAPI) fifoDestroy(fifoHandle) Status { return ErrNoBackend }
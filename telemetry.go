@@ -0,0 +1,121 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ThrottlingLevel is the thermal throttling state of a stick.
+type ThrottlingLevel int
+
+// Throttling levels: the stick throttles briefly once it reaches its lower
+// guard temperature, and for longer once it reaches its upper guard
+// temperature.
+const (
+	NotThrottling ThrottlingLevel = iota
+	LowerGuardThrottling
+	UpperGuardThrottling
+)
+
+func (l ThrottlingLevel) String() string {
+	switch l {
+	case NotThrottling:
+		return "not throttling"
+	case LowerGuardThrottling:
+		return "lower guard temperature reached"
+	case UpperGuardThrottling:
+		return "upper guard temperature reached"
+	default:
+		return fmt.Sprintf("unknown throttling level %d", int(l))
+	}
+}
+
+// Telemetry is a snapshot of the health of a stick.
+type Telemetry struct {
+	Time time.Time
+
+	// Temperatures are the recent temperatures of the stick in degrees
+	// Celsius, as reported by NC_RO_DEVICE_THERMAL_STATS.
+	Temperatures []float32
+	Throttling   ThrottlingLevel
+
+	// MemoryUsed and MemorySize are the stick's memory in use and in total,
+	// in bytes.
+	MemoryUsed, MemorySize int
+}
+
+// Telemetry reads the stick's thermal and memory statistics.
+func (d *Device) Telemetry() (Telemetry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t := Telemetry{Time: time.Now()}
+	if d.handle == nil {
+		return t, fmt.Errorf("device %d is closed", d.Index)
+	}
+
+	var ret Status
+	if t.Temperatures, ret = api.deviceThermalStats(d.handle); ret != OK {
+		return t, fmt.Errorf("could not get thermal stats of device %d: %w", d.Index, errorFor(ret))
+	}
+
+	level, err := d.intOption(optThrottlingLevel)
+	if err != nil {
+		return t, fmt.Errorf("could not get throttling level of device %d: %w", d.Index, err)
+	}
+	t.Throttling = ThrottlingLevel(level)
+
+	if t.MemoryUsed, err = d.intOption(optMemoryUsed); err != nil {
+		return t, fmt.Errorf("could not get memory used by device %d: %w", d.Index, err)
+	}
+	if t.MemorySize, err = d.intOption(optMemorySize); err != nil {
+		return t, fmt.Errorf("could not get memory size of device %d: %w", d.Index, err)
+	}
+
+	return t, nil
+}
+
+// intOption reads an integer device option; the caller must hold d.mu.
+func (d *Device) intOption(option deviceOption) (int, error) {
+	v, ret := api.deviceIntOption(d.handle, option)
+	if ret != OK {
+		return 0, errorFor(ret)
+	}
+
+	return v, nil
+}
+
+// Monitor reads the stick's telemetry every interval and sends it on the
+// returned channel, which is closed once ctx is done or reading fails.  A
+// slow reader misses snapshots rather than delaying them.
+func (d *Device) Monitor(ctx context.Context, interval time.Duration) <-chan Telemetry {
+	r := make(chan Telemetry, 1)
+
+	go func() {
+		defer close(r)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			t, err := d.Telemetry()
+			if err != nil {
+				return
+			}
+
+			select {
+			case r <- t:
+			default:
+			}
+		}
+	}()
+
+	return r
+}
@@ -0,0 +1,78 @@
+package mvnc
+
+import "fmt"
+
+// DataType is the element type of a tensor.
+type DataType int
+
+// Data types supported by the NCSDK fifos.
+const (
+	FP32 DataType = iota
+	FP16
+)
+
+func (t DataType) String() string {
+	switch t {
+	case FP32:
+		return "FP32"
+	case FP16:
+		return "FP16"
+	default:
+		return fmt.Sprintf("DataType(%d)", int(t))
+	}
+}
+
+// size returns the size in bytes of one element of type t.
+func (t DataType) size() int {
+	if t == FP16 {
+		return 2
+	}
+	return 4
+}
+
+// FifoConfig configures one of the fifos used to move tensors to and from a
+// graph, as passed to ncGraphAllocateWithFifosEx, or to CreateFifo.
+type FifoConfig struct {
+	// Depth is the number of tensors the fifo can hold, and so the number
+	// of inferences that can be in flight at once.  It defaults to 2.
+	Depth int
+
+	// DataType is the element type of the tensors in the fifo.  FP16 halves
+	// the amount of data sent over USB and avoids converting on the stick;
+	// the conversion to and from float32 is done on the host.
+	DataType DataType
+}
+
+// DefaultFifoDepth is the fifo depth used when FifoConfig.Depth is zero; it
+// is the depth ncGraphAllocateWithFifos uses.
+const DefaultFifoDepth = 2
+
+func (c FifoConfig) depth() int {
+	if c.Depth <= 0 {
+		return DefaultFifoDepth
+	}
+	return c.Depth
+}
+
+// TensorDescriptor describes the shape and memory layout of one of a graph's
+// input or output tensors.
+type TensorDescriptor struct {
+	N, C, W, H int // batch size, channels, width and height
+
+	// TotalSize is the size of the whole tensor in bytes, and the strides
+	// are the distance in bytes between consecutive channels, columns and
+	// rows.
+	TotalSize                 int
+	CStride, WStride, HStride int
+
+	DataType DataType
+}
+
+func (d TensorDescriptor) String() string {
+	return fmt.Sprintf("%dx%dx%dx%d %v", d.N, d.C, d.H, d.W, d.DataType)
+}
+
+// Elements returns the number of values in the tensor.
+func (d TensorDescriptor) Elements() int {
+	return d.N * d.C * d.W * d.H
+}
@@ -0,0 +1,49 @@
+package mvnc
+
+import "time"
+
+// limiter paces frames to at most one per interval.  It is a token bucket
+// holding a single token: a frame is due once the interval has passed since
+// the last one taken, and a stall does not let frames be saved up.
+type limiter struct {
+	interval time.Duration
+	next     time.Time
+	timer    *time.Timer
+}
+
+// due reports whether a frame at now may be run.
+func (l *limiter) due(now time.Time) bool {
+	return l.interval <= 0 || !now.Before(l.next)
+}
+
+// take records that a frame was run at now.
+func (l *limiter) take(now time.Time) {
+	l.next = now.Add(l.interval)
+}
+
+// wait sleeps until the next frame is due, returning false if stop or
+// shutdown is closed first.  Either may be nil.
+func (l *limiter) wait(stop, shutdown <-chan struct{}) bool {
+	d := time.Until(l.next)
+	if l.interval <= 0 || d <= 0 {
+		return true
+	}
+
+	// the timer is reused so that pacing makes no allocations
+	if l.timer == nil {
+		l.timer = time.NewTimer(d)
+	} else {
+		l.timer.Reset(d)
+	}
+
+	select {
+	case <-l.timer.C:
+		return true
+	case <-stop:
+	case <-shutdown:
+	}
+	if !l.timer.Stop() {
+		<-l.timer.C
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package mvnc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// Tiling splits a frame into a grid of overlapping tiles, each run through a
+// detection graph at the full resolution of its input tensor, so that
+// objects too small to survive downscaling the whole frame are still found.
+// Each tile is fitted to the input tensor as described by the graph's Fit,
+// so Columns and Rows are best chosen to give tiles of its shape: 3 by 2 for
+// a 1920x1080 frame and a square input, for instance.
+type Tiling struct {
+	Columns, Rows int
+
+	// Overlap is the fraction of each tile's width and height shared with
+	// its neighbours, so that an object on a boundary is whole in at least
+	// one tile.
+	Overlap float32
+
+	// Whole also runs the whole frame, for objects larger than a tile.
+	Whole bool
+
+	// NMS merges the boxes found by neighbouring tiles.  Its IoUThreshold
+	// defaults to 0.5.
+	NMS NMSOptions
+}
+
+// Tiles returns the rectangles of the tiles of a frame with the given
+// bounds, in rows from the top left.
+func (t Tiling) Tiles(bounds image.Rectangle) []image.Rectangle {
+	cols, rows := t.Columns, t.Rows
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+
+	// n tiles overlapping by o cover n - (n-1)*o tiles' worth of the frame
+	tw := float32(bounds.Dx()) / (float32(cols) - float32(cols-1)*t.Overlap)
+	th := float32(bounds.Dy()) / (float32(rows) - float32(rows-1)*t.Overlap)
+
+	tiles := make([]image.Rectangle, 0, cols*rows)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			x0 := int(float32(x)*tw*(1-t.Overlap) + 0.5)
+			y0 := int(float32(y)*th*(1-t.Overlap) + 0.5)
+			r := image.Rect(x0, y0, x0+int(tw+0.5), y0+int(th+0.5)).Add(bounds.Min)
+			tiles = append(tiles, r.Intersect(bounds))
+		}
+	}
+	return tiles
+}
+
+// InferTiled runs each tile of img through the graph, whose OutputFormat
+// must be SSD or YOLO unless it has a Postprocessor, and returns the boxes
+// found, mapped to the whole of img and merged with t.NMS, along with their
+// names and confidences.  The tiles are preprocessed like the images of
+// InferImage and pipelined through the graph's fifos.
+func (f *Graph) InferTiled(ctx context.Context, img image.Image, t Tiling) (Result, error) {
+	if !f.decodeBoxes() {
+		return Result{}, fmt.Errorf("tiled inference needs an SSD or YOLO graph, not %v", f.OutputFormat)
+	}
+
+	desc, err := f.InputDescriptor()
+	if err != nil {
+		return Result{}, err
+	}
+	outputs, err := f.OutputDescriptors()
+	if err != nil {
+		return Result{}, err
+	}
+	meta := FrameMeta{Output: outputs[0], Outputs: outputs}
+
+	bounds := img.Bounds()
+	tiles := t.Tiles(bounds)
+	if t.Whole {
+		tiles = append(tiles, bounds)
+	}
+
+	var wg sync.WaitGroup
+	found := make([][]BoundingBox, len(tiles))
+	errs := make([]error, len(tiles))
+	for i := range tiles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			output, err := f.inferRect(ctx, img, tiles[i])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			res, err := f.decodeRect(output, meta, f.seen(tiles[i], desc), bounds)
+			found[i], errs[i] = res.Boxes, err
+		}(i)
+	}
+	wg.Wait()
+
+	var boxes []BoundingBox
+	for i := range tiles {
+		if errs[i] != nil {
+			return Result{}, fmt.Errorf("error running tile %v: %w", tiles[i], errs[i])
+		}
+		boxes = append(boxes, found[i]...)
+	}
+
+	opts := t.NMS
+	if opts.IoUThreshold == 0 {
+		opts.IoUThreshold = 0.5
+	}
+	res := Result{Boxes: NMS(boxes, opts)}
+	for _, b := range res.Boxes {
+		if len(f.Zones) > 0 {
+			zones, _ := f.zonesOf(b)
+			res.Zones = append(res.Zones, zones)
+		}
+		if b.Name != "" {
+			res.Names = append(res.Names, b.Name)
+			res.Confidences = append(res.Confidences, b.Confidence)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package mvnc
+
+import (
+	"context"
+	"time"
+)
+
+// Stage is a step of a frame's trip through the pipeline.
+type Stage int
+
+// The stages of a frame, in order.  StageFifoRead overlaps StageInference,
+// since reading the output fifo blocks until the inference completes.
+const (
+	// StagePreprocess converts the frame to the input tensor, resizing it if
+	// necessary.
+	StagePreprocess Stage = iota
+
+	// StageFifoWrite writes the input tensor to the input fifo and queues the
+	// inference, including any retries.
+	StageFifoWrite
+
+	// StageInference is the stick running the inference, from queuing it
+	// until its output was read from the output fifo.
+	StageInference
+
+	// StageFifoRead waits for and reads the output from the output fifo.
+	StageFifoRead
+
+	// StagePostprocess decodes the output and sends the results and
+	// detections.
+	StagePostprocess
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StagePreprocess:
+		return "preprocess"
+	case StageFifoWrite:
+		return "fifo_write"
+	case StageInference:
+		return "inference"
+	case StageFifoRead:
+		return "fifo_read"
+	case StagePostprocess:
+		return "postprocess"
+	default:
+		return "unknown"
+	}
+}
+
+// Span is the time a frame spent in a single stage.
+type Span struct {
+	Stage      Stage
+	Start, End time.Time
+}
+
+// Trace is the stages of a single frame read by Process, a Pool or a
+// Multiplexer, or of a single call to Infer or InferImage.
+type Trace struct {
+	// FrameID and User are those of the frame's Result.  They are zero for
+	// Infer and InferImage.
+	FrameID uint64
+	User    interface{}
+
+	// Context is the context passed to Infer or InferImage, so that the
+	// trace can be attached to the caller's, or nil for frames read from a
+	// stream.
+	Context context.Context
+
+	// Spans are the stages the frame went through, in order.  Stages which
+	// were not run, such as StagePreprocess for Infer or StagePostprocess
+	// for a failed inference, are left out.
+	Spans []Span
+
+	// Err is the error the inference failed with, if any.
+	Err error
+}
+
+// Tracer is the interface used to record where each frame's latency goes,
+// for example as OpenTelemetry spans.  Trace is called once the frame's last
+// stage completes, from the goroutine completing it, so it should not
+// block.
+type Tracer interface {
+	Trace(t Trace)
+}
+
+// stageTimes are the times a request entered and left each stage, for the
+// Tracer.
+type stageTimes struct {
+	started, preprocessed time.Time
+	queued                time.Time
+	readStart, inferred   time.Time
+	readDone              time.Time
+}
+
+// trace sends the trace of r to the graph's Tracer, with a StagePostprocess
+// span from post until now if post is non-zero.
+func (f *Graph) trace(ctx context.Context, r *request, post time.Time) {
+	if f.Tracer == nil {
+		return
+	}
+
+	t := Trace{FrameID: r.id, User: r.user, Context: ctx, Err: r.err}
+	add := func(stage Stage, start, end time.Time) {
+		if !start.IsZero() && !end.IsZero() {
+			t.Spans = append(t.Spans, Span{Stage: stage, Start: start, End: end})
+		}
+	}
+
+	st := &r.times
+	add(StagePreprocess, st.started, st.preprocessed)
+	add(StageFifoWrite, r.written, st.queued)
+	add(StageInference, st.queued, st.inferred)
+	add(StageFifoRead, st.readStart, st.readDone)
+	if !post.IsZero() {
+		add(StagePostprocess, post, time.Now())
+	}
+
+	f.Tracer.Trace(t)
+}
+
+// traceDone traces a request run by do, which returned err, unless ctx was
+// done first and the request may still be in flight.
+func (f *Graph) traceDone(ctx context.Context, r *request, err error) {
+	if f.Tracer == nil || (err != nil && err == ctx.Err()) {
+		return
+	}
+
+	r.err = err
+	f.trace(ctx, r, time.Time{})
+}
@@ -0,0 +1,11 @@
+module github.com/donniet/mvnc/tracing
+
+go 1.27.1
+
+require (
+	github.com/donniet/mvnc v0.0.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+replace github.com/donniet/mvnc => ../
@@ -0,0 +1,75 @@
+// Package tracing records the pipeline of a graph as OpenTelemetry spans, so
+// the latency of each frame can be broken down alongside the traces of the
+// services using it.
+//
+//	graph.Tracer = tracing.New(otel.GetTracerProvider())
+//
+// Each frame becomes an mvnc.frame span, with a child span for each stage
+// it went through, and the ID of frames read from a stream as the
+// mvnc.frame_id attribute.  The spans of Infer and InferImage are children
+// of the span in their context.
+//
+// It is a separate module so that the mvnc package itself does not depend
+// on OpenTelemetry.
+package tracing
+
+import (
+	"context"
+
+	"github.com/donniet/mvnc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is an mvnc.Tracer creating OpenTelemetry spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns a Tracer creating its spans with a tracer from provider.
+func New(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer("github.com/donniet/mvnc")}
+}
+
+// Trace implements mvnc.Tracer.
+func (t *Tracer) Trace(tr mvnc.Trace) {
+	if len(tr.Spans) == 0 {
+		return
+	}
+
+	parent := tr.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	// frames from Infer have no ID
+	var attrs []attribute.KeyValue
+	if tr.FrameID != 0 {
+		attrs = append(attrs, attribute.Int64("mvnc.frame_id", int64(tr.FrameID)))
+	}
+
+	// stages may overlap, so the frame spans from the earliest start to the
+	// latest end
+	start, end := tr.Spans[0].Start, tr.Spans[0].End
+	for _, s := range tr.Spans[1:] {
+		if s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+
+	ctx, frame := t.tracer.Start(parent, "mvnc.frame", trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	for _, s := range tr.Spans {
+		_, span := t.tracer.Start(ctx, "mvnc."+s.Stage.String(), trace.WithTimestamp(s.Start), trace.WithAttributes(attrs...))
+		span.End(trace.WithTimestamp(s.End))
+	}
+
+	if tr.Err != nil {
+		frame.RecordError(tr.Err)
+		frame.SetStatus(codes.Error, tr.Err.Error())
+	}
+	frame.End(trace.WithTimestamp(end))
+}
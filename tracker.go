@@ -0,0 +1,281 @@
+package mvnc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Track is an object followed across frames by a Tracker.  ID is stable
+// for as long as the object is tracked, so that counting IDs counts
+// objects rather than detections.  Box is the object's box as estimated
+// by the tracker's filter, with the Class, Name and Confidence of the box
+// it was last matched with.
+type Track struct {
+	ID  uint64
+	Box BoundingBox
+
+	// Hits is the number of frames the track has been matched in, and Age
+	// the number of frames since it started.
+	Hits int
+	Age  int
+}
+
+// Tracker assigns stable IDs to the boxes of SSD and YOLO graphs across
+// frames, in the manner of SORT: each object's box is predicted for the
+// next frame by a constant velocity Kalman filter, and the boxes found are
+// matched to the predictions by their overlap, best first, and only to
+// objects of the same name.  Set Graph.Tracker to send the tracks of every
+// frame in its Result; the sources of a Multiplexer are tracked
+// separately.
+type Tracker struct {
+	// IoU is the overlap a box must have with an object's predicted box
+	// to be matched with it, by default 0.3.
+	IoU float32 `json:"iou,omitempty"`
+
+	// MinHits is the number of frames an object must be matched in before
+	// its track is reported, by default 3, and MaxMissed the number of
+	// frames in a row it may go unmatched before its track is dropped, by
+	// default 3.
+	MinHits   int `json:"min_hits,omitempty"`
+	MaxMissed int `json:"max_missed,omitempty"`
+
+	// Lines are counting lines, whose crossings by the objects tracked are
+	// sent in the Result of the frame they cross in and counted.
+	Lines []CountingLine `json:"lines,omitempty"`
+
+	mu        sync.Mutex
+	tracks    []*track
+	lastID    uint64
+	frame     uint64
+	counts    map[string]int
+	crossings map[string][2]int
+}
+
+// track is the state of a tracked object.  Its filter tracks the center,
+// width and height of the box.
+type track struct {
+	Track
+	filter    [4]kalman
+	missed    int
+	confirmed bool
+	positions [][2]float32 // where it was last reported, for each line
+}
+
+// Update matches the boxes found in a frame, read at the given time, with
+// the objects tracked and returns the tracks of those matched which have
+// been reported, in the order of boxes, and their crossings of the Lines.
+// Frames older than the last one passed are ignored.
+func (t *Tracker) Update(frameID uint64, at time.Time, boxes []BoundingBox) ([]Track, []CrossingEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracks := t.update(frameID, boxes)
+	out := make([]Track, len(tracks))
+	var crossings []CrossingEvent
+	for i, tr := range tracks {
+		out[i] = tr.Track
+		crossings = append(crossings, t.cross(tr, frameID, at)...)
+	}
+	return out, crossings
+}
+
+// update is Update, returning the tracks themselves.
+func (t *Tracker) update(frameID uint64, boxes []BoundingBox) []*track {
+	if frameID != 0 && frameID <= t.frame {
+		return nil
+	}
+	t.frame = frameID
+
+	iou, minHits, maxMissed := t.IoU, t.MinHits, t.MaxMissed
+	if iou == 0 {
+		iou = 0.3
+	}
+	if minHits == 0 {
+		minHits = 3
+	}
+	if maxMissed == 0 {
+		maxMissed = 3
+	}
+
+	predicted := make([]BoundingBox, len(t.tracks))
+	for i, tr := range t.tracks {
+		tr.predict()
+		predicted[i] = tr.Box
+	}
+
+	// every pair of a prediction and a box of its name overlapping
+	// enough, best first
+	type pair struct {
+		track, box int
+		iou        float32
+	}
+	var pairs []pair
+	for i, p := range predicted {
+		for j, b := range boxes {
+			if b.Name != p.Name || b.Class != p.Class {
+				continue
+			}
+			if v := IoU(p, b); v >= iou {
+				pairs = append(pairs, pair{i, j, v})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	matched := make([]*track, len(boxes))
+	seen := make([]bool, len(t.tracks))
+	for _, p := range pairs {
+		if seen[p.track] || matched[p.box] != nil {
+			continue
+		}
+		seen[p.track] = true
+		matched[p.box] = t.tracks[p.track]
+		matched[p.box].correct(boxes[p.box])
+	}
+
+	kept := t.tracks[:0]
+	for i, tr := range t.tracks {
+		if !seen[i] {
+			if tr.missed++; tr.missed > maxMissed {
+				continue
+			}
+		}
+		kept = append(kept, tr)
+	}
+	for i := len(kept); i < len(t.tracks); i++ {
+		t.tracks[i] = nil
+	}
+	t.tracks = kept
+
+	var reported []*track
+	for j, b := range boxes {
+		tr := matched[j]
+		if tr == nil {
+			t.lastID++
+			tr = newTrack(t.lastID, b)
+			t.tracks = append(t.tracks, tr)
+		}
+
+		if !tr.confirmed && tr.Hits >= minHits {
+			tr.confirmed = true
+			if t.counts == nil {
+				t.counts = make(map[string]int)
+			}
+			t.counts[tr.Box.Name]++
+		}
+		if tr.confirmed {
+			reported = append(reported, tr)
+		}
+	}
+	return reported
+}
+
+// Counts returns the number of objects of each name the tracker has
+// reported tracks of.
+func (t *Tracker) Counts() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := make(map[string]int, len(t.counts))
+	for name, n := range t.counts {
+		counts[name] = n
+	}
+	return counts
+}
+
+// Reset forgets the objects tracked, counted and seen crossing the Lines.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tracks, t.counts, t.crossings, t.frame = nil, nil, nil, 0
+}
+
+func newTrack(id uint64, b BoundingBox) *track {
+	tr := &track{Track: Track{ID: id, Box: b, Hits: 1, Age: 1}}
+	for i, v := range boxState(b) {
+		tr.filter[i] = newKalman(v)
+	}
+	return tr
+}
+
+// predict advances the track's filter to the next frame.
+func (tr *track) predict() {
+	var s [4]float32
+	for i := range tr.filter {
+		s[i] = tr.filter[i].predict()
+	}
+	tr.Age++
+	tr.setBox(s)
+}
+
+// correct updates the track's filter with the box it was matched with.
+func (tr *track) correct(b BoundingBox) {
+	var s [4]float32
+	for i, v := range boxState(b) {
+		s[i] = tr.filter[i].correct(v)
+	}
+	tr.Box.Class, tr.Box.Name, tr.Box.Confidence = b.Class, b.Name, b.Confidence
+	tr.Hits++
+	tr.missed = 0
+	tr.setBox(s)
+}
+
+// setBox sets the track's box from the state of its filter.
+func (tr *track) setBox(s [4]float32) {
+	w, h := max32(s[2], 0), max32(s[3], 0)
+	tr.Box.XMin, tr.Box.XMax = s[0]-w/2, s[0]+w/2
+	tr.Box.YMin, tr.Box.YMax = s[1]-h/2, s[1]+h/2
+}
+
+// boxState returns the center, width and height of b.
+func boxState(b BoundingBox) [4]float32 {
+	return [4]float32{(b.XMin + b.XMax) / 2, (b.YMin + b.YMax) / 2, b.XMax - b.XMin, b.YMax - b.YMin}
+}
+
+// The noise of the tracker's filters, in the normalized coordinates of the
+// frame: the variance of a box's measured coordinates, of the change in an
+// object's velocity from one frame to the next, and of the velocity of a
+// new object.
+const (
+	kalmanMeasurement = 0.02 * 0.02
+	kalmanProcess     = 0.005 * 0.005
+	kalmanVelocity    = 0.05 * 0.05
+)
+
+// kalman is a constant velocity Kalman filter of a single coordinate, as
+// SORT's filter of a box separates into, its covariances being diagonal.
+type kalman struct {
+	x, v          float32 // position and velocity
+	pxx, pxv, pvv float32 // their covariance
+}
+
+func newKalman(x float32) kalman {
+	return kalman{x: x, pxx: kalmanMeasurement, pvv: kalmanVelocity}
+}
+
+// predict advances the filter a frame and returns the position predicted.
+func (k *kalman) predict() float32 {
+	k.x += k.v
+	k.pxx += 2*k.pxv + k.pvv + kalmanProcess/4
+	k.pxv += k.pvv + kalmanProcess/2
+	k.pvv += kalmanProcess
+	return k.x
+}
+
+// correct updates the filter with the measured position z and returns the
+// position estimated.
+func (k *kalman) correct(z float32) float32 {
+	s := k.pxx + kalmanMeasurement
+	gx, gv := k.pxx/s, k.pxv/s
+
+	y := z - k.x
+	k.x += gx * y
+	k.v += gv * y
+
+	k.pvv -= gv * k.pxv
+	k.pxv -= gx * k.pxv
+	k.pxx -= gx * k.pxx
+	return k.x
+}
@@ -0,0 +1,377 @@
+package mvnc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sample is a frame of a labeled validation set, for Tune: the graph's
+// output for the frame, or the image file to infer it from, and the names
+// of a Classification graph or the boxes of an SSD or YOLO graph the frame
+// contains.  Boxes are matched to detections by Name, or by Class if they
+// have none.
+type Sample struct {
+	Image  string        `json:"image,omitempty"`
+	Output []float32     `json:"output,omitempty"`
+	Names  []string      `json:"names,omitempty"`
+	Boxes  []BoundingBox `json:"boxes,omitempty"`
+}
+
+// LoadSamples reads a validation set from a JSONL file of Samples, one per
+// line, such as
+//
+//	{"image": "frames/0001.jpg", "boxes": [{"name": "person", "xmin": 0.1, "ymin": 0.2, "xmax": 0.4, "ymax": 0.9}]}
+//
+// Relative image paths are resolved against the file's directory.
+func LoadSamples(path string) ([]Sample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var s Sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("error parsing sample on line %d of %s: %w", line, path, err)
+		}
+		if s.Image != "" && !filepath.IsAbs(s.Image) {
+			s.Image = filepath.Join(filepath.Dir(path), s.Image)
+		}
+		samples = append(samples, s)
+	}
+	return samples, scanner.Err()
+}
+
+// InferSamples sets the Output of the samples without one by running their
+// Image through the graph.
+func (f *Graph) InferSamples(ctx context.Context, samples []Sample) error {
+	for i := range samples {
+		s := &samples[i]
+		if s.Output != nil {
+			continue
+		} else if s.Image == "" {
+			return fmt.Errorf("sample %d has neither an output nor an image", i)
+		}
+
+		img, err := NewFileSource(s.Image).Next()
+		if err != nil {
+			return err
+		}
+		if s.Output, err = f.InferImage(ctx, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TuneTarget is the operating point Tune looks for.  With Precision set, it
+// is the threshold with the highest recall of those reaching that
+// precision; otherwise with Recall set, the highest threshold reaching that
+// recall; and otherwise the threshold with the best F1 score.
+type TuneTarget struct {
+	Precision float32
+	Recall    float32
+
+	// IoU is the overlap a box must have with a labeled box to find it, by
+	// default 0.5.
+	IoU float32
+}
+
+// OperatingPoint is the threshold Tune chose for a class, and the precision
+// and recall the graph achieves with it on the validation set.  Reached is
+// false if no threshold reaches the target, in which case Threshold is the
+// one coming closest.
+type OperatingPoint struct {
+	Threshold float32
+	Precision float32
+	Recall    float32
+
+	TruePositives  int
+	FalsePositives int
+	FalseNegatives int
+
+	Reached bool
+}
+
+// Tune sweeps the threshold of each class of a Classification, SSD or YOLO
+// graph over the confidences of its detections in samples, which must all
+// have an Output, and returns the operating point closest to target for
+// each class labeled in them, by name.  The detections are those the graph
+// would make at any threshold, after its Calibration, so that the points
+// can be used as its NamedThresholds, or the Thresholds of its Config with
+// SetThresholds.
+func (f *Graph) Tune(samples []Sample, target TuneTarget) (map[string]OperatingPoint, error) {
+	if f.Postprocessor != nil || (f.OutputFormat != Classification && f.OutputFormat != SSD && f.OutputFormat != YOLO) {
+		return nil, fmt.Errorf("thresholds can only be tuned for classification, ssd and yolo graphs")
+	} else if err := f.checkFormat(); err != nil {
+		return nil, err
+	}
+	if target.IoU == 0 {
+		target.IoU = 0.5
+	}
+
+	// the labels and candidate detections of each class
+	positives := make(map[string]int)
+	candidates := make(map[string][]candidate)
+	for i, s := range samples {
+		if s.Output == nil {
+			return nil, fmt.Errorf("sample %d has no output", i)
+		}
+
+		for _, name := range s.Names {
+			positives[name]++
+		}
+		for _, b := range s.Boxes {
+			positives[f.labelName(b)]++
+		}
+		for _, c := range f.candidates(i, s.Output) {
+			candidates[c.name] = append(candidates[c.name], c)
+		}
+	}
+
+	points := make(map[string]OperatingPoint, len(positives))
+	for name, n := range positives {
+		if curve := f.curve(samples, candidates[name], n, target.IoU); len(curve) > 0 {
+			points[name] = choose(curve, target)
+		} else {
+			// nothing was detected, at any threshold
+			points[name] = OperatingPoint{FalseNegatives: n}
+		}
+	}
+	return points, nil
+}
+
+// candidate is a detection made by a graph at any threshold.
+type candidate struct {
+	sample     int
+	name       string
+	confidence float32
+	box        *BoundingBox
+}
+
+// candidates returns the named detections of output, the output of sample,
+// with their calibrated confidences.
+func (f *Graph) candidates(sample int, output []float32) []candidate {
+	var cands []candidate
+	if f.OutputFormat == Classification {
+		scores := append([]float32(nil), output...)
+		if f.Calibration != nil {
+			f.Calibration.scores(scores, f.Softmax)
+		} else if f.Softmax {
+			softmax(scores)
+		}
+
+		for i, s := range scores {
+			if name, ok := f.Names[i]; ok {
+				cands = append(cands, candidate{sample: sample, name: name, confidence: s})
+			}
+		}
+		return cands
+	}
+
+	var boxes []BoundingBox
+	if f.OutputFormat == SSD {
+		boxes = DecodeSSD(output, 0, f.Names)
+	} else {
+		boxes = f.YOLO.Decode(output, 0, f.Names)
+	}
+	for i := range boxes {
+		b := &boxes[i]
+		if b.Name == "" {
+			continue
+		}
+		if f.Calibration != nil {
+			b.Confidence = f.Calibration.Calibrate(b.Class, b.Confidence)
+		}
+		cands = append(cands, candidate{sample: sample, name: b.Name, confidence: b.Confidence, box: b})
+	}
+	return cands
+}
+
+// labelName returns the name of a labeled box.
+func (f *Graph) labelName(b BoundingBox) string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return f.Names[b.Class]
+}
+
+// curve returns the operating points of every threshold distinguishing the
+// candidates of a class with the given number of positives in samples,
+// from the highest threshold to the lowest.  Candidates are matched to the
+// labels best first, so that each label is found at most once.
+func (f *Graph) curve(samples []Sample, cands []candidate, positives int, iou float32) []OperatingPoint {
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].confidence > cands[j].confidence })
+
+	found := make(map[[2]int]bool)
+	var points []OperatingPoint
+	tp, fp := 0, 0
+	for k, c := range cands {
+		if label, ok := f.finds(samples[c.sample], c, iou, found); ok {
+			found[[2]int{c.sample, label}] = true
+			tp++
+		} else {
+			fp++
+		}
+
+		if k+1 < len(cands) && cands[k+1].confidence == c.confidence {
+			// a threshold cannot separate equal confidences
+			continue
+		}
+
+		// detections must exceed the threshold
+		p := OperatingPoint{TruePositives: tp, FalsePositives: fp, FalseNegatives: positives - tp}
+		if k+1 < len(cands) {
+			p.Threshold = cands[k+1].confidence
+		} else {
+			p.Threshold = math.Nextafter32(c.confidence, 0)
+		}
+		p.Precision = float32(tp) / float32(tp+fp)
+		p.Recall = float32(tp) / float32(positives)
+		points = append(points, p)
+	}
+	return points
+}
+
+// finds returns the index of the label of s, not yet found, that c finds:
+// a name for a classification, and the box overlapping it most for a box.
+func (f *Graph) finds(s Sample, c candidate, iou float32, found map[[2]int]bool) (int, bool) {
+	if c.box == nil {
+		for i, name := range s.Names {
+			if name == c.name && !found[[2]int{c.sample, i}] {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	best, bestIoU := 0, float32(0)
+	for i, b := range s.Boxes {
+		if f.labelName(b) != c.name || found[[2]int{c.sample, i}] {
+			continue
+		}
+		if o := IoU(*c.box, b); o >= iou && o > bestIoU {
+			best, bestIoU = i, o
+		}
+	}
+	return best, bestIoU > 0
+}
+
+// choose returns the point of a curve, ordered from the highest threshold
+// to the lowest, closest to target.
+func choose(points []OperatingPoint, target TuneTarget) OperatingPoint {
+	best := -1
+	switch {
+	case target.Precision > 0:
+		for i, p := range points {
+			if p.Precision >= target.Precision && (best < 0 || p.Recall > points[best].Recall) {
+				best = i
+			}
+		}
+		if best < 0 {
+			best = 0
+			for i, p := range points {
+				if p.Precision > points[best].Precision {
+					best = i
+				}
+			}
+			return points[best]
+		}
+	case target.Recall > 0:
+		for i, p := range points {
+			if p.Recall >= target.Recall {
+				best = i
+				break
+			}
+		}
+		if best < 0 {
+			return points[len(points)-1]
+		}
+	default:
+		best = 0
+		for i, p := range points {
+			if f1(p) > f1(points[best]) {
+				best = i
+			}
+		}
+	}
+
+	p := points[best]
+	p.Reached = true
+	return p
+}
+
+func f1(p OperatingPoint) float32 {
+	if p.Precision+p.Recall == 0 {
+		return 0
+	}
+	return 2 * p.Precision * p.Recall / (p.Precision + p.Recall)
+}
+
+// SetThresholds sets the thresholds of c, by class name, to those of
+// points, as returned by Graph.Tune.
+func (c *Config) SetThresholds(points map[string]OperatingPoint) {
+	if c.Thresholds == nil {
+		c.Thresholds = make(map[string]float32, len(points))
+	}
+	for name, p := range points {
+		c.Thresholds[name] = p.Threshold
+	}
+}
+
+// WriteThresholds sets the thresholds of the JSON config file at path, by
+// class name, to those of points, as returned by Graph.Tune, leaving its
+// other settings as they are.  YAML files cannot be written.
+func WriteThresholds(path string, points map[string]OperatingPoint) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return fmt.Errorf("thresholds can only be written to JSON configs, not %s", path)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var c struct {
+		Thresholds map[string]float32 `json:"thresholds"`
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return fmt.Errorf("error reading config from %s: %w", path, err)
+	} else if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("error reading config from %s: %w", path, err)
+	}
+
+	if c.Thresholds == nil {
+		c.Thresholds = make(map[string]float32, len(points))
+	}
+	for name, p := range points {
+		c.Thresholds[name] = p.Threshold
+	}
+	if fields["thresholds"], err = json.Marshal(c.Thresholds); err != nil {
+		return err
+	}
+
+	if b, err = json.MarshalIndent(fields, "", "  "); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
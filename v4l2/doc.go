@@ -0,0 +1,14 @@
+// Package v4l2 captures frames from a webcam through Video4Linux2, so that
+// they can be fed to a graph without an external ffmpeg process.  It is
+// only built on Linux.
+//
+//	cam, err := v4l2.Open("/dev/video0", 640, 480, v4l2.YUYV)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer cam.Close()
+//
+//	for name := range graph.Process(cam.Reader(300, 300)) {
+//		log.Print(name)
+//	}
+package v4l2
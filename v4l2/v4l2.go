@@ -0,0 +1,284 @@
+//go:build linux
+// +build linux
+
+package v4l2
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"syscall"
+	"unsafe"
+
+	"github.com/donniet/mvnc"
+)
+
+// Format is the pixel format frames are captured in.
+type Format uint32
+
+// Formats: YUYV is uncompressed 4:2:2 YCbCr, supported by nearly every
+// webcam, while MJPEG frames are individually JPEG compressed, which allows
+// larger frames over USB at the cost of decoding.
+const (
+	YUYV  Format = 'Y' | 'U'<<8 | 'Y'<<16 | 'V'<<24
+	MJPEG Format = 'M' | 'J'<<8 | 'P'<<16 | 'G'<<24
+)
+
+func (f Format) String() string {
+	return string([]byte{byte(f), byte(f >> 8), byte(f >> 16), byte(f >> 24)})
+}
+
+const (
+	bufTypeVideoCapture = 1
+	memoryMmap          = 1
+	fieldAny            = 0
+	capVideoCapture     = 0x00000001
+	capStreaming        = 0x04000000
+
+	ptrSize = 4 << (^uintptr(0) >> 63)
+)
+
+// the structures below mirror those in linux/videodev2.h
+
+type capability struct {
+	Driver       [16]byte
+	Card         [32]byte
+	BusInfo      [32]byte
+	Version      uint32
+	Capabilities uint32
+	DeviceCaps   uint32
+	Reserved     [3]uint32
+}
+
+type pixFormat struct {
+	Width, Height uint32
+	PixelFormat   uint32
+	Field         uint32
+	BytesPerLine  uint32
+	SizeImage     uint32
+	Colorspace    uint32
+	Priv          uint32
+	Flags         uint32
+	YCbCrEnc      uint32
+	Quantization  uint32
+	XferFunc      uint32
+}
+
+type format struct {
+	Type uint32
+	_    [ptrSize - 4]byte // the union is pointer aligned
+	Pix  pixFormat
+	_    [200 - unsafe.Sizeof(pixFormat{})]byte
+}
+
+type requestBuffers struct {
+	Count        uint32
+	Type         uint32
+	Memory       uint32
+	Capabilities uint32
+	Flags        uint8
+	Reserved     [3]uint8
+}
+
+type buffer struct {
+	Index     uint32
+	Type      uint32
+	BytesUsed uint32
+	Flags     uint32
+	Field     uint32
+	Timestamp syscall.Timeval
+	Timecode  [16]byte
+	Sequence  uint32
+	Memory    uint32
+	Offset    uintptr // union of offset, userptr, planes and fd
+	Length    uint32
+	Reserved2 uint32
+	RequestFD int32
+}
+
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 'V'<<8 | nr
+}
+
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+var (
+	vidiocQuerycap  = ioc(iocRead, 0, unsafe.Sizeof(capability{}))
+	vidiocSFmt      = ioc(iocRead|iocWrite, 5, unsafe.Sizeof(format{}))
+	vidiocReqbufs   = ioc(iocRead|iocWrite, 8, unsafe.Sizeof(requestBuffers{}))
+	vidiocQuerybuf  = ioc(iocRead|iocWrite, 9, unsafe.Sizeof(buffer{}))
+	vidiocQbuf      = ioc(iocRead|iocWrite, 15, unsafe.Sizeof(buffer{}))
+	vidiocDqbuf     = ioc(iocRead|iocWrite, 17, unsafe.Sizeof(buffer{}))
+	vidiocStreamon  = ioc(iocWrite, 18, unsafe.Sizeof(int32(0)))
+	vidiocStreamoff = ioc(iocWrite, 19, unsafe.Sizeof(int32(0)))
+)
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+		if errno == syscall.EINTR {
+			continue
+		} else if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
+
+// numBuffers is the number of frames the driver may capture into while the
+// previous one is being processed.
+const numBuffers = 4
+
+// Camera is an open V4L2 capture device streaming frames.
+type Camera struct {
+	// Width and Height are the size of the captured frames, which the
+	// driver may have adjusted from the size requested.
+	Width, Height int
+	Format        Format
+
+	fd   int
+	bufs [][]byte
+}
+
+// Open opens the capture device at path, such as /dev/video0, and starts
+// streaming frames of the given size and format.
+func Open(path string, width, height int, f Format) (*Camera, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+
+	c := &Camera{fd: fd}
+	if err := c.start(width, height, f); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("could not start capture on %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+func (c *Camera) start(width, height int, f Format) error {
+	var cp capability
+	if err := ioctl(c.fd, vidiocQuerycap, unsafe.Pointer(&cp)); err != nil {
+		return fmt.Errorf("not a V4L2 device: %w", err)
+	} else if cp.Capabilities&capVideoCapture == 0 || cp.Capabilities&capStreaming == 0 {
+		return fmt.Errorf("device does not support streaming capture")
+	}
+
+	fm := format{Type: bufTypeVideoCapture}
+	fm.Pix = pixFormat{Width: uint32(width), Height: uint32(height), PixelFormat: uint32(f), Field: fieldAny}
+	if err := ioctl(c.fd, vidiocSFmt, unsafe.Pointer(&fm)); err != nil {
+		return fmt.Errorf("could not set format %v %dx%d: %w", f, width, height, err)
+	} else if Format(fm.Pix.PixelFormat) != f {
+		return fmt.Errorf("device does not support format %v", f)
+	}
+	c.Width, c.Height, c.Format = int(fm.Pix.Width), int(fm.Pix.Height), f
+
+	req := requestBuffers{Count: numBuffers, Type: bufTypeVideoCapture, Memory: memoryMmap}
+	if err := ioctl(c.fd, vidiocReqbufs, unsafe.Pointer(&req)); err != nil {
+		return fmt.Errorf("could not request buffers: %w", err)
+	}
+
+	for i := uint32(0); i < req.Count; i++ {
+		b := buffer{Index: i, Type: bufTypeVideoCapture, Memory: memoryMmap}
+		if err := ioctl(c.fd, vidiocQuerybuf, unsafe.Pointer(&b)); err != nil {
+			return fmt.Errorf("could not query buffer %d: %w", i, err)
+		}
+
+		mem, err := syscall.Mmap(c.fd, int64(b.Offset), int(b.Length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return fmt.Errorf("could not map buffer %d: %w", i, err)
+		}
+		c.bufs = append(c.bufs, mem)
+
+		if err := ioctl(c.fd, vidiocQbuf, unsafe.Pointer(&b)); err != nil {
+			return fmt.Errorf("could not queue buffer %d: %w", i, err)
+		}
+	}
+
+	typ := int32(bufTypeVideoCapture)
+	if err := ioctl(c.fd, vidiocStreamon, unsafe.Pointer(&typ)); err != nil {
+		return fmt.Errorf("could not start streaming: %w", err)
+	}
+
+	return nil
+}
+
+// Next waits for the next frame and returns it decoded.
+func (c *Camera) Next() (image.Image, error) {
+	b := buffer{Type: bufTypeVideoCapture, Memory: memoryMmap}
+	if err := ioctl(c.fd, vidiocDqbuf, unsafe.Pointer(&b)); err != nil {
+		return nil, fmt.Errorf("could not dequeue frame: %w", err)
+	}
+
+	data := c.bufs[b.Index][:b.BytesUsed]
+
+	var img image.Image
+	var err error
+	switch c.Format {
+	case YUYV:
+		img, err = c.yuyv(data)
+	case MJPEG:
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	}
+
+	// the frame has been copied out of the buffer, so it can be reused
+	if qerr := ioctl(c.fd, vidiocQbuf, unsafe.Pointer(&b)); qerr != nil && err == nil {
+		err = fmt.Errorf("could not requeue frame: %w", qerr)
+	}
+
+	return img, err
+}
+
+// yuyv unpacks a YUYV frame, which stores each pair of pixels as Y0 U Y1 V,
+// into planar 4:2:2 YCbCr.
+func (c *Camera) yuyv(data []byte) (image.Image, error) {
+	if len(data) < c.Width*c.Height*2 {
+		return nil, fmt.Errorf("short YUYV frame of %d bytes", len(data))
+	}
+
+	img := image.NewYCbCr(image.Rect(0, 0, c.Width, c.Height), image.YCbCrSubsampleRatio422)
+
+	for y := 0; y < c.Height; y++ {
+		row := data[y*c.Width*2 : (y+1)*c.Width*2]
+		ys := img.Y[y*img.YStride:]
+		cs := y * img.CStride
+
+		for x := 0; x+1 < c.Width; x += 2 {
+			p := row[x*2 : x*2+4]
+			ys[x], ys[x+1] = p[0], p[2]
+			img.Cb[cs+x/2], img.Cr[cs+x/2] = p[1], p[3]
+		}
+	}
+
+	return img, nil
+}
+
+// Reader returns the captured frames as raw RGB frames of width by height
+// pixels, ready to pass to Process.
+func (c *Camera) Reader(width, height int) io.Reader {
+	return &mvnc.ImageReader{Width: width, Height: height, Next: c.Next}
+}
+
+// Frames returns the captured frames as an mvnc.FrameSource.
+func (c *Camera) Frames() mvnc.FrameSource {
+	return mvnc.Images(c)
+}
+
+// Close stops streaming and closes the device.
+func (c *Camera) Close() error {
+	typ := int32(bufTypeVideoCapture)
+	ioctl(c.fd, vidiocStreamoff, unsafe.Pointer(&typ))
+
+	for _, b := range c.bufs {
+		syscall.Munmap(b)
+	}
+	c.bufs = nil
+
+	return syscall.Close(c.fd)
+}
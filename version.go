@@ -0,0 +1,72 @@
+package mvnc
+
+import "fmt"
+
+// Version is an NCSDK version, such as that of the NCAPI or a stick's
+// firmware.  Versions are comparable with ==, and ordered by Compare.
+type Version struct {
+	Major, Minor, Hotfix, ReleaseCandidate uint32
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Hotfix, v.ReleaseCandidate)
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as, or newer than
+// w.
+func (v Version) Compare(w Version) int {
+	a := [...]uint32{v.Major, v.Minor, v.Hotfix, v.ReleaseCandidate}
+	b := [...]uint32{w.Major, w.Minor, w.Hotfix, w.ReleaseCandidate}
+
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// APIVersion returns the version of the installed NCAPI.
+func APIVersion() (Version, error) {
+	v, ret := api.apiVersion()
+	if ret != OK {
+		return Version{}, fmt.Errorf("could not get API version: %w", errorFor(ret))
+	}
+
+	return v, nil
+}
+
+// RequireAPIVersion returns an error describing the mismatch if the
+// installed NCAPI is older than min, so applications can refuse to start
+// with a clear message.
+func RequireAPIVersion(min Version) error {
+	v, err := APIVersion()
+	if err != nil {
+		return err
+	}
+
+	if v.Compare(min) < 0 {
+		return fmt.Errorf("NCAPI version %v is installed, but %v or newer is required", v, min)
+	}
+
+	return nil
+}
+
+// FirmwareVersion returns the version of the firmware running on the stick.
+func (d *Device) FirmwareVersion() (Version, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handle == nil {
+		return Version{}, fmt.Errorf("device %d is closed", d.Index)
+	}
+
+	v, ret := api.deviceFirmwareVersion(d.handle)
+	if ret != OK {
+		return Version{}, fmt.Errorf("could not get firmware version of device %d: %w", d.Index, errorFor(ret))
+	}
+
+	return v, nil
+}
@@ -0,0 +1,203 @@
+package mvnc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"time"
+)
+
+// WebhookSignatureHeader is the header a Webhook signs its requests in, as
+// "sha256=" and the hex HMAC-SHA256 of the body keyed by its Secret.
+const WebhookSignatureHeader = "X-Mvnc-Signature-256"
+
+// Webhook is a Sink POSTing the detections of each Result to a URL, so that
+// Home Assistant, Node-RED and the like can take them directly.  The body is
+// a JSON object with the frame's FrameID and Time and its detections, as
+// written by a JSONLWriter:
+//
+//	{"frame_id":42,"time":"2019-06-01T12:00:00.5Z","detections":[{"frame_id":42,"time":"2019-06-01T12:00:00.5Z","name":"dog","confidence":0.93}]}
+//
+// Results without detections are not posted.  It also posts the snapshots
+// of a Snapshotter sent to PostSnapshot, as multipart/form-data.  It is safe
+// for concurrent use.
+type Webhook struct {
+	URL string
+
+	// Secret, if set, signs every request in the WebhookSignatureHeader, so
+	// that the receiver can check that it came from the graph.
+	Secret string
+
+	// Client makes the requests, by default one timing out after 10s.
+	Client *http.Client
+
+	// MaxAttempts is the number of times a request is made before its error
+	// is returned, including the first, by default 3.  Requests failing to
+	// connect or with a 5xx or 429 status are retried, waiting Backoff
+	// between the attempts.
+	MaxAttempts int
+	Backoff     Backoff
+}
+
+// NewWebhook returns a Webhook posting to url, signed with secret if it is
+// not empty.
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{URL: url, Secret: secret}
+}
+
+type webhookBody struct {
+	FrameID    uint64           `json:"frame_id"`
+	Time       time.Time        `json:"time"`
+	Detections []jsonlDetection `json:"detections"`
+}
+
+// Write posts the detections of r, if it has any.
+func (w *Webhook) Write(r Result) error {
+	dets := jsonlDetections(r)
+	if len(dets) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(webhookBody{FrameID: r.FrameID, Time: r.Time, Detections: dets})
+	if err != nil {
+		return err
+	}
+	return w.post(body, "application/json")
+}
+
+// PostSnapshot posts snap as multipart/form-data, with a part named
+// "detection" holding its detection as JSON and a part named "snapshot"
+// holding its image as a JPEG.  Send it the Images of a Snapshotter.
+func (w *Webhook) PostSnapshot(snap Snapshot) error {
+	d := jsonlDetection{FrameID: snap.FrameID, Time: snap.Time, Name: snap.Name, Confidence: snap.Confidence}
+	if b := snap.Box; b != nil {
+		d.Box = &jsonlBox{Class: b.Class, XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax}
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="detection"`)
+	h.Set("Content-Type", "application/json")
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(d); err != nil {
+		return err
+	}
+
+	h = make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="snapshot"; filename="snapshot.jpg"`)
+	h.Set("Content-Type", "image/jpeg")
+	if part, err = mw.CreatePart(h); err != nil {
+		return err
+	}
+	if err := jpeg.Encode(part, snap.Image, nil); err != nil {
+		return err
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return w.post(body.Bytes(), mw.FormDataContentType())
+}
+
+// webhookSnapshots is the Sink of a Config's webhook posting snapshots,
+// which posts the Results to the Webhook and the snapshots sent to snaps
+// from a goroutine of its own, until it is closed.
+type webhookSnapshots struct {
+	*Webhook
+	snapshotter *Snapshotter
+	snaps       chan Snapshot
+	done        chan struct{}
+}
+
+// postSnapshots returns a Sink posting the Results of f to w, as well as
+// the snapshots taken by s, which it is made the Images of.
+func postSnapshots(f *Graph, w *Webhook, s *Snapshotter) *webhookSnapshots {
+	ws := &webhookSnapshots{Webhook: w, snapshotter: s, snaps: make(chan Snapshot, 16), done: make(chan struct{})}
+	s.Images = ws.snaps
+
+	go func() {
+		defer close(ws.done)
+		for snap := range ws.snaps {
+			if err := w.PostSnapshot(snap); err != nil {
+				f.logf("error posting snapshot of frame %d: %v", snap.FrameID, err)
+			}
+		}
+	}()
+	return ws
+}
+
+// Close stops the Snapshotter sending snapshots to be posted, and waits for
+// those already sent to be.
+func (ws *webhookSnapshots) Close() error {
+	ws.snapshotter.detach(ws.snaps)
+	close(ws.snaps)
+	<-ws.done
+	return nil
+}
+
+// post posts body, retrying as described by MaxAttempts.
+func (w *Webhook) post(body []byte, contentType string) error {
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	attempts := w.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(w.Backoff.delay(i - 1))
+		}
+
+		var retry bool
+		if retry, err = w.send(client, body, contentType); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// send makes a single request, reporting whether it may be retried if it
+// fails.
+func (w *Webhook) send(client *http.Client, body []byte, contentType string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook: %w", err)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook: %s returned %s", w.URL, resp.Status)
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
@@ -0,0 +1,194 @@
+package mvnc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records the requests made to it, answering the first
+// len(statuses) with those statuses and the rest with 204.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		s.mu.Lock()
+		status := http.StatusNoContent
+		if n := len(s.requests); n < len(s.statuses) {
+			status = s.statuses[n]
+		}
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func TestWebhookWrite(t *testing.T) {
+	s := newWebhookServer(t)
+	w := NewWebhook(s.URL, "secret")
+
+	if err := w.Write(Result{FrameID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(); n != 0 {
+		t.Errorf("posted %d requests for a Result without detections", n)
+	}
+
+	if err := w.Write(Result{FrameID: 7, Names: []string{"dog"}, Confidences: []float32{0.9}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(); n != 1 {
+		t.Fatalf("posted %d requests, want 1", n)
+	}
+
+	var body webhookBody
+	if err := json.Unmarshal(s.bodies[0], &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.FrameID != 7 || len(body.Detections) != 1 || body.Detections[0].Name != "dog" {
+		t.Errorf("posted %s, want the dog of frame 7", s.bodies[0])
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(s.bodies[0])
+	if sig, want := s.requests[0].Header.Get(WebhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); sig != want {
+		t.Errorf("signed the request %q, want %q", sig, want)
+	}
+
+	unsigned := NewWebhook(s.URL, "")
+	if err := unsigned.Write(Result{FrameID: 8, Names: []string{"cat"}, Confidences: []float32{0.7}}); err != nil {
+		t.Fatal(err)
+	}
+	if sig := s.requests[1].Header.Get(WebhookSignatureHeader); sig != "" {
+		t.Errorf("signed a request without a secret %q", sig)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		fails    bool
+	}{
+		{"server error", []int{500, 503}, 3, false},
+		{"too many requests", []int{429}, 2, false},
+		{"out of attempts", []int{500, 500, 500}, 3, true},
+		{"client error", []int{400}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newWebhookServer(t, tt.statuses...)
+			w := NewWebhook(s.URL, "")
+			w.Backoff = Backoff{Initial: time.Millisecond}
+
+			err := w.Write(Result{FrameID: 1, Names: []string{"dog"}, Confidences: []float32{0.9}})
+			if tt.fails && err == nil {
+				t.Error("Write succeeded")
+			} else if !tt.fails && err != nil {
+				t.Errorf("Write failed: %v", err)
+			}
+			if n := s.count(); n != tt.requests {
+				t.Errorf("made %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestWebhookPostSnapshot(t *testing.T) {
+	s := newWebhookServer(t)
+	w := NewWebhook(s.URL, "")
+
+	box := &BoundingBox{Class: 2, XMin: 0.1, YMin: 0.2, XMax: 0.5, YMax: 0.6}
+	snap := Snapshot{FrameID: 3, Name: "dog", Confidence: 0.8, Box: box, Image: image.NewRGBA(image.Rect(0, 0, 8, 6))}
+	if err := w.PostSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(); n != 1 {
+		t.Fatalf("posted %d requests, want 1", n)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(s.bodies[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", s.requests[0].Header.Get("Content-Type"))
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	var d jsonlDetection
+	if err := json.Unmarshal([]byte(r.FormValue("detection")), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.FrameID != 3 || d.Name != "dog" || d.Box == nil || d.Box.Class != 2 {
+		t.Errorf("posted detection %+v, want the dog of frame 3 and its box", d)
+	}
+
+	f, _, err := r.FormFile("snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 6 {
+		t.Errorf("posted a %dx%d snapshot, want 8x6", b.Dx(), b.Dy())
+	}
+}
+
+// TestWebhookSnapshotsClose checks that closing the sink posting the
+// snapshots of a Config stops its goroutine, once the snapshots sent to it
+// have been posted, and detaches it from the Snapshotter.
+func TestWebhookSnapshotsClose(t *testing.T) {
+	s := newWebhookServer(t)
+	snapshotter := &Snapshotter{}
+	ws := postSnapshots(&Graph{Logger: testLogger}, NewWebhook(s.URL, ""), snapshotter)
+
+	snapshotter.save(&Graph{Logger: testLogger}, Snapshot{FrameID: 1, Name: "dog", Image: image.NewRGBA(image.Rect(0, 0, 2, 2))})
+	if err := ws.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(); n != 1 {
+		t.Errorf("posted %d snapshots before closing, want 1", n)
+	}
+	if snapshotter.Images != nil {
+		t.Error("the Snapshotter still sends its snapshots to the closed sink")
+	}
+}
@@ -0,0 +1,392 @@
+package mvnc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document with its indentation and comment
+// removed.
+type yamlLine struct {
+	n      int // line number, counting from 1
+	indent int
+	text   string
+}
+
+// parseYAML parses the subset of YAML used by configuration files: block
+// mappings and sequences, flow sequences and mappings, and plain, single
+// and double quoted scalars.  Anchors, tags and multi-line scalars are not
+// supported.  The result is made of the types encoding/json decodes into an
+// interface{}, so that it can be converted to JSON.
+func parseYAML(b string) (interface{}, error) {
+	var lines []yamlLine
+	for i, l := range strings.Split(b, "\n") {
+		l = strings.TrimRight(stripComment(l), " \t\r")
+		text := strings.TrimLeft(l, " ")
+		if text == "" || text == "---" || text == "..." {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{n: i + 1, indent: len(l) - len(text), text: text})
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	} else if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].n)
+	}
+	return v, nil
+}
+
+// stripComment removes a comment from the end of l, ignoring # within
+// quotes.
+func stripComment(l string) string {
+	var quote byte
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || l[i-1] == ' ' || l[i-1] == '\t'):
+			return l[:i]
+		}
+	}
+	return l
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence whose lines are indented by indent.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if l := p.lines[p.pos]; l.text == "-" || strings.HasPrefix(l.text, "- ") {
+		return p.sequence(indent, false)
+	}
+	return p.mapping(indent)
+}
+
+// sequence parses the sequence whose items are indented by indent.  keyed
+// is set for a sequence indented as far as the key it is the value of,
+// which ends at the next key.
+func (p *yamlParser) sequence(indent int, keyed bool) (interface{}, error) {
+	seq := []interface{}{}
+
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		} else if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.n)
+		} else if l.text != "-" && !strings.HasPrefix(l.text, "- ") {
+			if keyed {
+				break
+			}
+			return nil, fmt.Errorf("line %d: expected a sequence item", l.n)
+		}
+
+		item := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if item == "" {
+			// the item is the block on the following lines
+			p.pos++
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+
+		if _, _, ok := splitKey(item); ok || item == "-" || strings.HasPrefix(item, "- ") {
+			// a block starting on the same line as the dash, such as a
+			// mapping of which this is the first key
+			p.lines[p.pos] = yamlLine{n: l.n, indent: l.indent + len(l.text) - len(item), text: item}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+
+		v, err := scalar(item, l.n)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+		p.pos++
+	}
+
+	return seq, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		} else if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.n)
+		}
+
+		key, value, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a key", l.n)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", l.n, key)
+		}
+		p.pos++
+
+		if value == "" {
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		v, err := scalar(value, l.n)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+
+	return m, nil
+}
+
+// nested parses the block following a key or dash with nothing after it,
+// which is null if the next line is not indented further.  A sequence may
+// be indented as far as its key.
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+
+	next := p.lines[p.pos]
+	if next.indent > indent {
+		return p.block(next.indent)
+	} else if next.indent == indent && (next.text == "-" || strings.HasPrefix(next.text, "- ")) {
+		return p.sequence(indent, true)
+	}
+	return nil, nil
+}
+
+// splitKey splits a line of a mapping into its key and value.
+func splitKey(s string) (key, value string, ok bool) {
+	if s == "" {
+		return "", "", false
+	}
+
+	if s[0] == '"' || s[0] == '\'' {
+		end := closingQuote(s)
+		if end < 0 || end+1 >= len(s) || s[end+1] != ':' {
+			return "", "", false
+		} else if end+2 < len(s) && s[end+2] != ' ' {
+			return "", "", false
+		}
+		k, err := unquote(s[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(s[end+2:]), true
+	}
+
+	if s[0] == '[' || s[0] == '{' {
+		return "", "", false
+	}
+
+	i := strings.Index(s, ": ")
+	if strings.HasSuffix(s, ":") && (i < 0 || i == len(s)-1) {
+		i = len(s) - 1
+	}
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+}
+
+// closingQuote returns the index of the quote closing the string s starts
+// with, or -1.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// scalar parses a value on a single line: a quoted or plain scalar, or a
+// flow sequence or mapping.
+func scalar(s string, line int) (interface{}, error) {
+	fp := &flowParser{s: s, line: line}
+	v, err := fp.value(false)
+	if err != nil {
+		return nil, err
+	}
+	if fp.skipSpace(); fp.pos < len(fp.s) {
+		return nil, fmt.Errorf("line %d: unexpected '%s'", line, fp.s[fp.pos:])
+	}
+	return v, nil
+}
+
+type flowParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (fp *flowParser) skipSpace() {
+	for fp.pos < len(fp.s) && fp.s[fp.pos] == ' ' {
+		fp.pos++
+	}
+}
+
+// value parses the value at fp.pos; inFlow ends plain scalars at flow
+// indicators.
+func (fp *flowParser) value(inFlow bool) (interface{}, error) {
+	fp.skipSpace()
+	if fp.pos == len(fp.s) {
+		return nil, nil
+	}
+
+	switch fp.s[fp.pos] {
+	case '[':
+		fp.pos++
+		seq := []interface{}{}
+		for {
+			fp.skipSpace()
+			if fp.pos < len(fp.s) && fp.s[fp.pos] == ']' {
+				fp.pos++
+				return seq, nil
+			}
+			v, err := fp.value(true)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if err := fp.separator(']'); err != nil {
+				return nil, err
+			} else if fp.s[fp.pos-1] == ']' {
+				return seq, nil
+			}
+		}
+
+	case '{':
+		fp.pos++
+		m := map[string]interface{}{}
+		for {
+			fp.skipSpace()
+			if fp.pos < len(fp.s) && fp.s[fp.pos] == '}' {
+				fp.pos++
+				return m, nil
+			}
+			k, err := fp.value(true)
+			if err != nil {
+				return nil, err
+			}
+			if fp.skipSpace(); fp.pos == len(fp.s) || fp.s[fp.pos] != ':' {
+				return nil, fmt.Errorf("line %d: expected ':' in flow mapping", fp.line)
+			}
+			fp.pos++
+			v, err := fp.value(true)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+			if err := fp.separator('}'); err != nil {
+				return nil, err
+			} else if fp.s[fp.pos-1] == '}' {
+				return m, nil
+			}
+		}
+
+	case '"', '\'':
+		end := closingQuote(fp.s[fp.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated string", fp.line)
+		}
+		v, err := unquote(fp.s[fp.pos : fp.pos+end+1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", fp.line, err)
+		}
+		fp.pos += end + 1
+		return v, nil
+	}
+
+	start := fp.pos
+	for fp.pos < len(fp.s) {
+		c := fp.s[fp.pos]
+		if inFlow && (c == ',' || c == ']' || c == '}' || (c == ':' && (fp.pos+1 == len(fp.s) || fp.s[fp.pos+1] == ' '))) {
+			break
+		}
+		fp.pos++
+	}
+	return plain(strings.TrimSpace(fp.s[start:fp.pos])), nil
+}
+
+// separator consumes the comma between flow items, or the closing bracket.
+func (fp *flowParser) separator(closing byte) error {
+	fp.skipSpace()
+	if fp.pos < len(fp.s) && (fp.s[fp.pos] == ',' || fp.s[fp.pos] == closing) {
+		fp.pos++
+		return nil
+	}
+	return fmt.Errorf("line %d: expected ',' or '%c'", fp.line, closing)
+}
+
+// plain resolves a plain scalar to null, a boolean, a number or a string.
+func plain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	// only decimal numbers, so that names such as "nan" stay strings
+	if strings.Trim(s, "0123456789+-.eE") == "" {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+package mvnc
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type yamlMap = map[string]interface{}
+type yamlSeq = []interface{}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"empty", "# nothing\n---\n", nil},
+		{"scalars", "a: 1\nb: 0.5\nc: true\nd: ~\ne: text\nf: nan\n", yamlMap{"a": int64(1), "b": 0.5, "c": true, "d": nil, "e": "text", "f": "nan"}},
+		{"block map", "device:\n  name: 1.3-ma2480\n  index: 2\nthreshold: 0.5\n", yamlMap{"device": yamlMap{"name": "1.3-ma2480", "index": int64(2)}, "threshold": 0.5}},
+		{"flow map", "device: {name: \"1.3-ma2480\", index: 2}\n", yamlMap{"device": yamlMap{"name": "1.3-ma2480", "index": int64(2)}}},
+		{"nested flow", "preprocess: {mean: [127.5, 1], swap: {a: []}}\n", yamlMap{"preprocess": yamlMap{"mean": yamlSeq{127.5, int64(1)}, "swap": yamlMap{"a": yamlSeq{}}}}},
+		{"block sequence", "labels:\n  - a\n  - b\n", yamlMap{"labels": yamlSeq{"a", "b"}}},
+		{"flow sequence", "labels: [a, 'b, c', \"d\"]\n", yamlMap{"labels": yamlSeq{"a", "b, c", "d"}}},
+		{"top level sequence", "- 1\n- 2\n", yamlSeq{int64(1), int64(2)}},
+		{"sequence at key indent", "labels:\n- a\n- b\n", yamlMap{"labels": yamlSeq{"a", "b"}}},
+		{"key after sequence at key indent", "labels:\n- a\n- b\nthreshold: 0.5\n", yamlMap{"labels": yamlSeq{"a", "b"}, "threshold": 0.5}},
+		{"key after nested sequence at key indent", "sinks:\n  include:\n  - a\n  jsonl: out.jsonl\ntop_k: 2\n", yamlMap{"sinks": yamlMap{"include": yamlSeq{"a"}, "jsonl": "out.jsonl"}, "top_k": int64(2)}},
+		{"key after indented sequence", "labels:\n  - a\nthreshold: 0.5\n", yamlMap{"labels": yamlSeq{"a"}, "threshold": 0.5}},
+		{"sequence of maps", "heads:\n  - name: age\n    scale: 100\n  - name: gender\n", yamlMap{"heads": yamlSeq{yamlMap{"name": "age", "scale": int64(100)}, yamlMap{"name": "gender"}}}},
+		{"sequence of sequences", "- - 1\n  - 2\n- []\n", yamlSeq{yamlSeq{int64(1), int64(2)}, yamlSeq{}}},
+		{"dash alone", "-\n  a: 1\n", yamlSeq{yamlMap{"a": int64(1)}}},
+		{"null value", "a:\nb: 1\n", yamlMap{"a": nil, "b": int64(1)}},
+		{"quoted", "a: 'it''s'\nb: \"tab\\t\"\n\"c d\": '#'\n'e': \"1\"\n", yamlMap{"a": "it's", "b": "tab\t", "c d": "#", "e": "1"}},
+		{"url", "broker: tcp://broker:1883\n", yamlMap{"broker": "tcp://broker:1883"}},
+		{"comments", "# config\na: 1 # one\nb: a#b\nc: 'x # y' # z\n  # indented\n", yamlMap{"a": int64(1), "b": "a#b", "c": "x # y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseYAML(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("parsed %#v, want %#v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{"tab", "a:\n\tb: 1\n", "line 2: tabs cannot be used for indentation"},
+		{"indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"dedent", "a:\n    b: 1\n  c: 2\n", "line 3: unexpected indentation"},
+		{"key in top level sequence", "- 1\nb: 2\n", "line 2: expected a sequence item"},
+		{"key in sequence", "a:\n  - 1\n  b: 2\n", "line 3: expected a sequence item"},
+		{"not a key", "a: 1\n\nb\n", "line 3: expected a key"},
+		{"duplicate", "a: 1\n# again\na: 2\n", "line 3: duplicate key 'a'"},
+		{"unterminated string", "a: 1\nb: 'x\n", "line 2: unterminated string"},
+		{"unclosed flow sequence", "a: [1, 2\n", "line 1: expected ',' or ']'"},
+		{"flow map without colon", "a:\n  b: {c}\n", "line 2: expected ':' in flow mapping"},
+		{"trailing text", "a: [1] 2\n", "line 1: unexpected '2'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseYAML(tt.in)
+			if err == nil {
+				t.Fatalf("parsed %#v, want an error", v)
+			}
+			if err.Error() != tt.err {
+				t.Errorf("returned %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseYAMLConfig(t *testing.T) {
+	c, err := ParseYAMLConfig([]byte(`
+graph: mobilenet-ssd.graph
+output_format: ssd
+include:
+- person
+- car
+threshold: 0.5
+thresholds: {person: 0.7}
+names:
+  0: background
+  15: person
+input_fifo: {depth: 4}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Graph != "mobilenet-ssd.graph" || c.OutputFormat != "ssd" || c.Threshold != 0.5 {
+		t.Errorf("parsed graph %q, output format %q and threshold %v", c.Graph, c.OutputFormat, c.Threshold)
+	}
+	if !reflect.DeepEqual(c.Include, []string{"person", "car"}) {
+		t.Errorf("parsed include %q", c.Include)
+	}
+	if c.Thresholds["person"] != 0.7 {
+		t.Errorf("parsed thresholds %v", c.Thresholds)
+	}
+	if !reflect.DeepEqual(c.Names, map[int]string{0: "background", 15: "person"}) {
+		t.Errorf("parsed names %v", c.Names)
+	}
+	if c.InputFifo.Depth != 4 {
+		t.Errorf("parsed an input fifo depth of %d", c.InputFifo.Depth)
+	}
+
+	if _, err := ParseYAMLConfig([]byte("graph: a.graph\nthreshhold: 0.5\n")); err == nil || !strings.Contains(err.Error(), "threshhold") {
+		t.Errorf("a misspelt setting returned %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, config := range map[string]string{
+		"config.yaml": "graph: ssd.graph\nlabels: labels.txt\nthreshold: 0.5\n",
+		"config.json": `{"graph": "/graphs/ssd.graph", "labels": "labels.txt", "threshold": 0.5}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Graph != filepath.Join(dir, "ssd.graph") || c.Labels != filepath.Join(dir, "labels.txt") || c.Threshold != 0.5 {
+		t.Errorf("loaded graph %q, labels %q and threshold %v from YAML", c.Graph, c.Labels, c.Threshold)
+	}
+
+	c, err = LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Graph != "/graphs/ssd.graph" || c.Labels != filepath.Join(dir, "labels.txt") {
+		t.Errorf("loaded graph %q and labels %q from JSON", c.Graph, c.Labels)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.yml"), []byte("graph: a\n  labels: b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "bad.yml")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("loading a bad YAML file returned %v", err)
+	}
+}
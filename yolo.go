@@ -0,0 +1,197 @@
+package mvnc
+
+import (
+	"fmt"
+	"math"
+)
+
+// YOLOLayer is one detection layer of a YOLO graph's output.  Version 2
+// graphs have a single layer, while version 3 graphs have one per scale,
+// concatenated in the output tensor in order.
+type YOLOLayer struct {
+	GridW int `json:"grid_w"`
+	GridH int `json:"grid_h"`
+
+	// Anchors are the width and height of each of the layer's anchor boxes,
+	// as written in the darknet cfg file: in grid cells for version 2, and in
+	// input pixels for version 3.
+	Anchors [][2]float32 `json:"anchors"`
+}
+
+// YOLOConfig describes the output of a YOLO graph, for use with the YOLO
+// OutputFormat.
+type YOLOConfig struct {
+	// Version is 2 or 3.  Version 2 applies a softmax to the class scores
+	// and version 3 a sigmoid.
+	Version int `json:"version"`
+
+	Classes int         `json:"classes"`
+	Layers  []YOLOLayer `json:"layers"`
+
+	// InputW and InputH are the size of the graph's input, which version 3
+	// anchors are relative to.
+	InputW int `json:"input_w"`
+	InputH int `json:"input_h"`
+
+	// Layout is the layout of each layer: HWC stores the values of every
+	// anchor of a cell together, while CHW, darknet's own, stores each of an
+	// anchor's values as a whole plane of the grid.
+	Layout Layout `json:"layout,omitempty"`
+
+	// NMSThreshold is the overlap, as intersection over union, above which
+	// the less confident of two boxes of the same class is dropped.  It
+	// defaults to 0.45.
+	NMSThreshold float32 `json:"nms_threshold,omitempty"`
+}
+
+// check returns an error if y cannot decode an output.
+func (y *YOLOConfig) check() error {
+	if y.Version != 2 && y.Version != 3 {
+		return fmt.Errorf("a yolo config needs version 2 or 3, not %d", y.Version)
+	} else if y.Classes <= 0 {
+		return fmt.Errorf("a yolo config needs a positive number of classes, not %d", y.Classes)
+	}
+	for i, l := range y.Layers {
+		if l.GridW <= 0 || l.GridH <= 0 {
+			return fmt.Errorf("yolo layer %d has a %dx%d grid", i, l.GridW, l.GridH)
+		} else if len(l.Anchors) == 0 {
+			return fmt.Errorf("yolo layer %d has no anchors", i)
+		}
+	}
+	if y.Version == 3 && (y.InputW <= 0 || y.InputH <= 0) {
+		return fmt.Errorf("a version 3 yolo config needs the input size, input_w and input_h")
+	}
+	return nil
+}
+
+// Decode decodes the output of a YOLO graph into the boxes whose objectness
+// times class probability is above threshold, named from names, with
+// overlapping boxes removed.  Each box is given its most probable class.
+// An invalid config, such as a version 3 config without the input size,
+// decodes no boxes.
+func (y *YOLOConfig) Decode(output []float32, threshold float32, names map[int]string) []BoundingBox {
+	if y.check() != nil {
+		return nil
+	}
+
+	n := 5 + y.Classes
+	probs := make([]float32, y.Classes)
+
+	var boxes []BoundingBox
+
+	offset := 0
+	for _, l := range y.Layers {
+		cells := l.GridW * l.GridH
+		size := cells * len(l.Anchors) * n
+		if offset+size > len(output) {
+			break
+		}
+		layer := output[offset : offset+size]
+		offset += size
+
+		for a, anchor := range l.Anchors {
+			for cy := 0; cy < l.GridH; cy++ {
+				for cx := 0; cx < l.GridW; cx++ {
+					at := func(k int) float32 {
+						if y.Layout == HWC {
+							return layer[((cy*l.GridW+cx)*len(l.Anchors)+a)*n+k]
+						}
+						return layer[(a*n+k)*cells+cy*l.GridW+cx]
+					}
+
+					objectness := sigmoid(at(4))
+					if objectness <= threshold {
+						continue
+					}
+
+					for c := range probs {
+						probs[c] = at(5 + c)
+					}
+					if y.Version == 2 {
+						softmax(probs)
+					} else {
+						for c := range probs {
+							probs[c] = sigmoid(probs[c])
+						}
+					}
+
+					class := argmax(probs)
+					if class < 0 {
+						continue
+					}
+					confidence := objectness * probs[class]
+					if confidence <= threshold {
+						continue
+					}
+
+					x := (float32(cx) + sigmoid(at(0))) / float32(l.GridW)
+					yy := (float32(cy) + sigmoid(at(1))) / float32(l.GridH)
+
+					w := exp32(at(2)) * anchor[0]
+					h := exp32(at(3)) * anchor[1]
+					if y.Version == 2 {
+						w /= float32(l.GridW)
+						h /= float32(l.GridH)
+					} else {
+						w /= float32(y.InputW)
+						h /= float32(y.InputH)
+					}
+
+					boxes = append(boxes, BoundingBox{
+						Class:      class,
+						Name:       names[class],
+						Confidence: confidence,
+						XMin:       unit(x - w/2),
+						YMin:       unit(yy - h/2),
+						XMax:       unit(x + w/2),
+						YMax:       unit(yy + h/2),
+					})
+				}
+			}
+		}
+	}
+
+	threshold = y.NMSThreshold
+	if threshold == 0 {
+		threshold = 0.45
+	}
+
+	return NMS(boxes, NMSOptions{IoUThreshold: threshold})
+}
+
+func sigmoid(x float32) float32 {
+	return 1 / (1 + exp32(-x))
+}
+
+func exp32(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
+
+// softmax replaces v with its softmax.
+func softmax(v []float32) {
+	if len(v) == 0 {
+		return
+	}
+
+	m := v[argmax(v)]
+
+	var sum float32
+	for i, x := range v {
+		v[i] = exp32(x - m)
+		sum += v[i]
+	}
+	for i := range v {
+		v[i] /= sum
+	}
+}
+
+// argmax returns the index of the largest value of v, or -1 if v is empty.
+func argmax(v []float32) int {
+	best := -1
+	for i, x := range v {
+		if best < 0 || x > v[best] {
+			best = i
+		}
+	}
+	return best
+}
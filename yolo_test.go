@@ -0,0 +1,74 @@
+package mvnc
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestYOLOInputSize checks that a version 3 config without the input size,
+// which its anchors are relative to, is rejected instead of decoding every
+// box as the whole frame.
+func TestYOLOInputSize(t *testing.T) {
+	_, err := (&Config{Graph: "yolo.graph", OutputFormat: "yolo", YOLO: &YOLOConfig{Version: 3, Classes: 1}}).NewGraph()
+	if err == nil || !strings.Contains(err.Error(), "input size") {
+		t.Errorf("NewGraph returned %v, want an error for the missing input size", err)
+	}
+
+	y := &YOLOConfig{Version: 3, Classes: 1, Layers: []YOLOLayer{{GridW: 1, GridH: 1, Anchors: [][2]float32{{10, 10}}}}}
+	g := &Graph{GraphFile: "yolo.graph", OutputFormat: YOLO, YOLO: y}
+	output := []float32{0, 0, 0, 0, 10, 10}
+	if _, err := g.Decode(output); err == nil {
+		t.Error("Decode succeeded without the input size")
+	}
+	if boxes := y.Decode(output, 0.5, nil); len(boxes) != 0 {
+		t.Errorf("decoded %v without the input size", boxes)
+	}
+
+	y.InputW, y.InputH = 100, 100
+	boxes := y.Decode(output, 0.5, nil)
+	if len(boxes) != 1 {
+		t.Fatalf("decoded %d boxes, want 1", len(boxes))
+	}
+	if b := boxes[0]; b.XMax-b.XMin > 0.11 || b.YMax-b.YMin > 0.11 {
+		t.Errorf("decoded %+v, want a box a tenth of the frame", b)
+	}
+}
+
+// TestYOLOInvalid checks that configs which would make Decode panic are
+// rejected when the graph is loaded, and decode no boxes.
+func TestYOLOInvalid(t *testing.T) {
+	layer := func(w, h, anchors int) YOLOLayer {
+		return YOLOLayer{GridW: w, GridH: h, Anchors: make([][2]float32, anchors)}
+	}
+
+	tests := []struct {
+		name string
+		y    YOLOConfig
+		want string
+	}{
+		{"no version", YOLOConfig{Classes: 1, Layers: []YOLOLayer{layer(1, 1, 1)}}, "version"},
+		{"version 4", YOLOConfig{Version: 4, Classes: 1, InputW: 416, InputH: 416, Layers: []YOLOLayer{layer(1, 1, 1)}}, "version"},
+		{"no classes", YOLOConfig{Version: 2, Layers: []YOLOLayer{layer(1, 1, 1)}}, "classes"},
+		{"negative classes", YOLOConfig{Version: 2, Classes: -1, Layers: []YOLOLayer{layer(1, 1, 1)}}, "classes"},
+		{"negative grid width", YOLOConfig{Version: 2, Classes: 1, Layers: []YOLOLayer{layer(-1, 1, 1)}}, "grid"},
+		{"zero grid height", YOLOConfig{Version: 2, Classes: 1, Layers: []YOLOLayer{layer(1, 0, 1)}}, "grid"},
+		{"no anchors", YOLOConfig{Version: 2, Classes: 1, Layers: []YOLOLayer{layer(1, 1, 1), layer(1, 1, 0)}}, "anchors"},
+	}
+
+	output := make([]float32, 64)
+	for i := range output {
+		output[i] = 10
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := tt.y
+			_, err := (&Config{Graph: "yolo.graph", OutputFormat: "yolo", YOLO: &y}).NewGraph()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewGraph returned %v, want an error about the %s", err, tt.want)
+			}
+			if boxes := y.Decode(output, 0.5, nil); len(boxes) != 0 {
+				t.Errorf("decoded %v", boxes)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package mvnc
+
+import (
+	"image/color"
+)
+
+// PixelFormat is how the pixels of the raw frames read by Process are
+// stored.
+type PixelFormat int
+
+// Pixel formats: RGB24 interleaves 8-bit red, green and blue values.  I420
+// (also called YUV420) and NV12 are the 4:2:0 YCbCr formats produced by the
+// Raspberry Pi camera and most hardware video decoders: both store a full
+// resolution Y plane followed by quarter resolution chroma, as separate Cb
+// and Cr planes in I420 and as one plane of interleaved Cb, Cr pairs in
+// NV12.  YCbCr frames are converted to RGB with the JFIF (full range BT.601)
+// coefficients, as by image/color.
+const (
+	RGB24 PixelFormat = iota
+	I420
+	NV12
+)
+
+func (p PixelFormat) String() string {
+	switch p {
+	case RGB24:
+		return "RGB24"
+	case I420:
+		return "I420"
+	case NV12:
+		return "NV12"
+	default:
+		return "unknown"
+	}
+}
+
+// frameBytes returns the size of a frame of width by height pixels.
+func (p PixelFormat) frameBytes(width, height int) int {
+	if p == RGB24 {
+		return width * height * 3
+	}
+
+	cw, ch := (width+1)/2, (height+1)/2
+	return width*height + 2*cw*ch
+}
+
+// toRGB converts a YCbCr frame src of width by height pixels to interleaved
+// RGB in dst.
+func (p PixelFormat) toRGB(dst, src []byte, width, height int) {
+	cw := (width + 1) / 2
+	planeSize := cw * ((height + 1) / 2)
+	ys := src[:width*height]
+	chroma := src[width*height:]
+
+	for y := 0; y < height; y++ {
+		row := ys[y*width : (y+1)*width]
+		out := dst[y*width*3 : (y+1)*width*3]
+		c := (y / 2) * cw
+
+		for x, yy := range row {
+			var cb, cr byte
+			if p == NV12 {
+				i := (c + x/2) * 2
+				cb, cr = chroma[i], chroma[i+1]
+			} else {
+				cb, cr = chroma[c+x/2], chroma[planeSize+c+x/2]
+			}
+
+			out[x*3], out[x*3+1], out[x*3+2] = color.YCbCrToRGB(yy, cb, cr)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mvnc
+
+// Zone is a region of the frame with a rule for the boxes found in it, such
+// as a driveway in which people are reported, or a street in which they are
+// not.  Its Polygon is in the normalized coordinates of boxes, across the
+// whole frame, and a box is in the zone if the middle of its bottom edge,
+// where the object stands, is inside the polygon, or its center if Center
+// is set, as suits cameras looking straight down.
+type Zone struct {
+	Name    string       `json:"name"`
+	Polygon [][2]float32 `json:"polygon"`
+	Center  bool         `json:"center,omitempty"`
+
+	// Classes, if non-empty, limits the rule to the boxes of the classes
+	// it names, after Remap.
+	Classes []string `json:"classes,omitempty"`
+
+	// Suppress drops the boxes in the zone, rather than annotating them
+	// with the zone's name.
+	Suppress bool `json:"suppress,omitempty"`
+}
+
+// inside reports whether the point (x, y) is inside the zone's polygon,
+// by the even-odd rule.
+func (z *Zone) inside(x, y float32) bool {
+	in := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a[1] > y) != (b[1] > y) && x < a[0]+(y-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+			in = !in
+		}
+	}
+	return in
+}
+
+// applies reports whether the zone's rule applies to b.
+func (z *Zone) applies(b BoundingBox) bool {
+	return len(z.Classes) == 0 || contains(z.Classes, b.Name)
+}
+
+// zonesOf returns the names of the zones annotating b, and whether b is
+// kept, not being in a zone suppressing it, and in a zone if OnlyInZones
+// is set.
+func (f *Graph) zonesOf(b BoundingBox) ([]string, bool) {
+	var zones []string
+	for i := range f.Zones {
+		z := &f.Zones[i]
+		if !z.applies(b) {
+			continue
+		}
+
+		y := b.YMax
+		if z.Center {
+			y = (b.YMin + b.YMax) / 2
+		}
+		if !z.inside((b.XMin+b.XMax)/2, y) {
+			continue
+		} else if z.Suppress {
+			return nil, false
+		}
+		zones = append(zones, z.Name)
+	}
+	return zones, len(zones) > 0 || !f.OnlyInZones
+}
+
+// zone drops the boxes of d its Zones leave out, recording the zones of
+// the rest.
+func (f *Graph) zone(d *decoding) {
+	if len(f.Zones) == 0 {
+		return
+	}
+
+	kept := d.boxes[:0]
+	for _, b := range d.boxes {
+		if zones, ok := f.zonesOf(b); ok {
+			kept = append(kept, b)
+			d.zones = append(d.zones, zones)
+		}
+	}
+	d.boxes = kept
+}